@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"disruptive/console/fakeproviders"
+)
+
+var fakeProvidersCmd = &cobra.Command{
+	Use:   "fake-providers",
+	Short: "serve fake AI providers",
+	Long:  "Serve deterministic canned responses for chat, embeddings, moderation, TTS, TTI and vector queries.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("addr")
+
+		if err := fakeproviders.Serve(cmd.Root().Context(), addr); err != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fakeProvidersCmd)
+	fakeProvidersCmd.Flags().StringP("addr", "a", "localhost:8090", "listen address")
+}
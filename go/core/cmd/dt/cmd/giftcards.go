@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"disruptive/console/giftcards"
+	"disruptive/pkg/vox/bank"
+)
+
+var giftCardsCmd = &cobra.Command{
+	Use:   "giftcards",
+	Short: "gift cards commands",
+	Long:  "Gift cards commands.",
+}
+
+var mintGiftCardsCmd = &cobra.Command{
+	Use:   "mint [campaign] [count] [value]",
+	Short: "mint gift cards",
+	Long:  "Mint a batch of gift cards with random codes and write them as CSV.",
+	Args:  cobra.ExactArgs(3),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		for _, name := range []string{"start", "expiration"} {
+			if _, err := parseGiftCardTime(cmd, name); err != nil {
+				return errors.New("invalid " + name)
+			}
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		count, err := strconv.Atoi(args[1])
+		if err != nil {
+			os.Exit(400)
+		}
+
+		value, err := strconv.Atoi(args[2])
+		if err != nil {
+			os.Exit(400)
+		}
+
+		start, _ := parseGiftCardTime(cmd, "start")
+		expiration, _ := parseGiftCardTime(cmd, "expiration")
+		description, _ := cmd.Flags().GetString("description")
+		maxRedemptions, _ := cmd.Flags().GetInt("max-redemptions")
+		perAccountLimit, _ := cmd.Flags().GetInt("per-account-limit")
+		prefix, _ := cmd.Flags().GetString("prefix")
+		length, _ := cmd.Flags().GetInt("length")
+		output, _ := cmd.Flags().GetString("output")
+
+		batch := bank.GiftCardBatch{
+			Campaign:        args[0],
+			CodeLength:      length,
+			Count:           count,
+			Description:     description,
+			Expiration:      expiration,
+			MaxRedemptions:  maxRedemptions,
+			PerAccountLimit: perAccountLimit,
+			Prefix:          prefix,
+			Start:           start,
+			Value:           int64(value),
+		}
+
+		if err := giftcards.Mint(cmd.Root().Context(), batch, output); err != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+var exportGiftCardsCmd = &cobra.Command{
+	Use:   "export [campaign]",
+	Short: "export gift cards",
+	Long:  "Export the gift cards of a campaign as CSV.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		if err := giftcards.Export(cmd.Root().Context(), args[0], output); err != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+var reportGiftCardsCmd = &cobra.Command{
+	Use:   "report [campaign]",
+	Short: "campaign report",
+	Long:  "Report the gift cards and redemptions of a campaign.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := giftcards.Report(cmd.Root().Context(), args[0]); err != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+// parseGiftCardTime parses an RFC 3339 time or a date flag, zero when the flag is empty.
+func parseGiftCardTime(cmd *cobra.Command, name string) (time.Time, error) {
+	s, _ := cmd.Flags().GetString(name)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, s)
+}
+
+func init() {
+	rootCmd.AddCommand(giftCardsCmd)
+
+	giftCardsCmd.AddCommand(mintGiftCardsCmd)
+	mintGiftCardsCmd.Flags().String("description", "", "description")
+	mintGiftCardsCmd.Flags().String("start", "", "start, RFC 3339 or YYYY-MM-DD")
+	mintGiftCardsCmd.Flags().String("expiration", "", "expiration, RFC 3339 or YYYY-MM-DD")
+	mintGiftCardsCmd.Flags().Int("max-redemptions", 1, "redemptions per card, unlimited when zero")
+	mintGiftCardsCmd.Flags().Int("per-account-limit", 1, "redemptions per card and account, unlimited when zero")
+	mintGiftCardsCmd.Flags().String("prefix", "", "code prefix")
+	mintGiftCardsCmd.Flags().Int("length", bank.DefaultGiftCardCodeLength, "code length without the prefix")
+	mintGiftCardsCmd.Flags().StringP("output", "o", "", "CSV file, stdout when empty")
+
+	giftCardsCmd.AddCommand(exportGiftCardsCmd)
+	exportGiftCardsCmd.Flags().StringP("output", "o", "", "CSV file, stdout when empty")
+
+	giftCardsCmd.AddCommand(reportGiftCardsCmd)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"disruptive/console/accounts"
+)
+
+var subscriptionsCmd = &cobra.Command{
+	Use:   "subscriptions",
+	Short: "subscriptions commands",
+	Long:  "Subscriptions commands.",
+}
+
+var renewSubscriptionsCmd = &cobra.Command{
+	Use:   "renew",
+	Short: "renew subscriptions",
+	Long:  "Renew the subscriptions whose period has ended. With --interval, keep renewing until interrupted.",
+	Args:  cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if interval, _ := cmd.Flags().GetDuration("interval"); interval < 0 {
+			return errors.New("invalid interval")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		accountID, _ := cmd.Flags().GetString("account")
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		if err := accounts.RenewSubscriptions(cmd.Root().Context(), accountID, interval); err != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+var historySubscriptionsCmd = &cobra.Command{
+	Use:   "history [account_id]",
+	Short: "subscription history",
+	Long:  "Get the subscription renewal history of an account.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		accountID := args[0]
+
+		if err := accounts.GetRenewals(cmd.Root().Context(), accountID); err != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(subscriptionsCmd)
+
+	subscriptionsCmd.AddCommand(renewSubscriptionsCmd)
+	renewSubscriptionsCmd.Flags().String("account", "", "account id, all accounts when empty")
+	renewSubscriptionsCmd.Flags().Duration("interval", 0, "renewal interval, run once when zero")
+
+	subscriptionsCmd.AddCommand(historySubscriptionsCmd)
+}
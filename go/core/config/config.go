@@ -23,6 +23,10 @@ var (
 		BuildDateTime                    string  `mapstructure:"DIS_BUILD_DATETIME"`
 		BuildTag                         string  `mapstructure:"DIS_BUILD_TAG"`
 		DisableCaches                    bool    `mapstructure:"DIS_DISABLE_CACHES"`
+		DocStore                         string  `mapstructure:"DIS_DOCSTORE"`
+		DocStorePath                     string  `mapstructure:"DIS_DOCSTORE_PATH"`
+		ObjectStore                      string  `mapstructure:"DIS_OBJECTSTORE"`
+		ObjectStorePath                  string  `mapstructure:"DIS_OBJECTSTORE_PATH"`
 		GPT35TurboPromptCost             float64 `mapstructure:"DIS_GPT_35_TURBO_PROMPT_COST"`
 		GPT35TurboResponseCost           float64 `mapstructure:"DIS_GPT_35_TURBO_RESPONSE_COST"`
 		GPT4TurboPromptCost              float64 `mapstructure:"DIS_GPT_4_TURBO_PROMPT_COST"`
@@ -30,28 +34,55 @@ var (
 		LoggingLevel                     string  `mapstructure:"DIS_LOGGING_LEVEL"`
 		LoggingOutput                    string  `mapstructure:"DIS_LOGGING_OUTPUT"`
 		AnthropicKey                     string  `mapstructure:"DIS_ANTHROPIC_KEY"`
+		AudioMaxDuration                 int     `mapstructure:"DIS_AUDIO_MAX_DURATION"`
+		AudioMaxSize                     int     `mapstructure:"DIS_AUDIO_MAX_SIZE"`
+		AudioSilenceThreshold            float64 `mapstructure:"DIS_AUDIO_SILENCE_THRESHOLD"`
+		AnthropicURL                     string  `mapstructure:"DIS_ANTHROPIC_URL"`
 		AnyMailFinderKey                 string  `mapstructure:"DIS_ANYMAILFINDER_KEY"`
+		AnyMailFinderURL                 string  `mapstructure:"DIS_ANYMAILFINDER_URL"`
+		AppleBundleID                    string  `mapstructure:"DIS_APPLE_BUNDLE_ID"`
+		AppleEnvironments                string  `mapstructure:"DIS_APPLE_ENVIRONMENTS"`
+		AppleRootCerts                   string  `mapstructure:"DIS_APPLE_ROOT_CERTS"`
 		FirebaseProject                  string  `mapstructure:"DIS_FIREBASE_PROJECT"`
+		GooglePlayClient                 string  `mapstructure:"DIS_GOOGLE_PLAY_CLIENT"`
+		GooglePlayPackageName            string  `mapstructure:"DIS_GOOGLE_PLAY_PACKAGE_NAME"`
+		GooglePlayPushAudience           string  `mapstructure:"DIS_GOOGLE_PLAY_PUSH_AUDIENCE"`
+		GooglePlayPushServiceAccount     string  `mapstructure:"DIS_GOOGLE_PLAY_PUSH_SERVICE_ACCOUNT"`
 		CoquiKey                         string  `mapstructure:"DIS_COQUI_KEY"`
+		CoquiURL                         string  `mapstructure:"DIS_COQUI_URL"`
 		ElevenLabsKey                    string  `mapstructure:"DIS_ELEVENLABS_KEY"`
+		ElevenLabsURL                    string  `mapstructure:"DIS_ELEVENLABS_URL"`
 		JWTSessionSecret                 string  `mapstructure:"DIS_JWT_SESSION_SECRET"`
 		DeepgramHost                     string  `mapstructure:"DIS_DEEPGRAM_HOST"`
 		DeepgramKey                      string  `mapstructure:"DIS_DEEPGRAM_KEY"`
+		DeepgramLive                     bool    `mapstructure:"DIS_DEEPGRAM_LIVE"`
 		DeepgramOnPremKey                string  `mapstructure:"DIS_DEEPGRAM_ONPREM_KEY"`
 		ERCDataRoot                      string  `mapstructure:"DIS_ERC_DATA_ROOT"`
 		ERCSpannerProject                string  `mapstructure:"DIS_ERC_SPANNER_PROJECT"`
 		ERCSpannerInstance               string  `mapstructure:"DIS_ERC_SPANNER_INSTANCE"`
 		ERCSpannerDatabase               string  `mapstructure:"DIS_ERC_SPANNER_DATABASE"`
+		LLMCompatibleKey                 string  `mapstructure:"DIS_LLM_COMPATIBLE_KEY"`
+		LLMCompatibleURL                 string  `mapstructure:"DIS_LLM_COMPATIBLE_URL"`
 		MailgunDomain                    string  `mapstructure:"DIS_MAILGUN_DOMAIN"`
 		MailgunNotificationsFrom         string  `mapstructure:"DIS_MAILGUN_NOTIFICATIONS_FROM"`
 		MailgunLowBalanceNotificationsTo string  `mapstructure:"DIS_MAILGUN_LOWBALANCE_NOTIFICATION_TO"`
 		MailgunKey                       string  `mapstructure:"DIS_MAILGUN_KEY"`
 		MondayKey                        string  `mapstructure:"DIS_MONDAY_KEY"`
 		OpenAIKey                        string  `mapstructure:"DIS_OPENAI_KEY"`
+		OpenAIURL                        string  `mapstructure:"DIS_OPENAI_URL"`
 		PineconeKey                      string  `mapstructure:"DIS_PINECONE_KEY"`
+		PineconeURL                      string  `mapstructure:"DIS_PINECONE_URL"`
 		RocketReachKey                   string  `mapstructure:"DIS_ROCKETREACH_KEY"`
+		RocketReachURL                   string  `mapstructure:"DIS_ROCKETREACH_URL"`
 		StabilityAIKey                   string  `mapstructure:"DIS_STABILITYAI_KEY"`
+		StabilityAIURL                   string  `mapstructure:"DIS_STABILITYAI_URL"`
 		STTRegion                        string  `mapstructure:"DIS_STT_REGION"`
+		STTWhisperLocalKey               string  `mapstructure:"DIS_STT_WHISPER_LOCAL_KEY"`
+		STTWhisperLocalModel             string  `mapstructure:"DIS_STT_WHISPER_LOCAL_MODEL"`
+		STTWhisperLocalURL               string  `mapstructure:"DIS_STT_WHISPER_LOCAL_URL"`
+		TTSLocalKey                      string  `mapstructure:"DIS_TTS_LOCAL_KEY"`
+		TTSLocalModel                    string  `mapstructure:"DIS_TTS_LOCAL_MODEL"`
+		TTSLocalURL                      string  `mapstructure:"DIS_TTS_LOCAL_URL"`
 		UserAgent                        string  `mapstructure:"DIS_USER_AGENT"`
 	}
 )
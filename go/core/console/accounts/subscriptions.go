@@ -0,0 +1,73 @@
+package accounts
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"disruptive/lib/common"
+	"disruptive/pkg/vox/bank"
+)
+
+// RenewSubscriptions renews the subscriptions whose period has ended, for one account or all of them.
+// A positive interval keeps renewing until the context is canceled.
+func RenewSubscriptions(ctx context.Context, accountID string, interval time.Duration) error {
+	logCtx := slog.With("account_id", accountID)
+
+	for {
+		if err := renewSubscriptions(ctx, logCtx, accountID); err != nil && interval <= 0 {
+			return err
+		}
+
+		if interval <= 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func renewSubscriptions(ctx context.Context, logCtx *slog.Logger, accountID string) error {
+	if accountID != "" {
+		r, due, err := bank.RenewSubscription(ctx, logCtx, accountID, time.Now())
+		if err != nil {
+			logCtx.Error("unable to renew subscription", "error", err)
+			return err
+		}
+
+		if !due {
+			logCtx.Info("subscription not due")
+			return nil
+		}
+
+		common.P(r)
+		return nil
+	}
+
+	summary, err := bank.RenewSubscriptions(ctx, logCtx, time.Now())
+	if err != nil {
+		logCtx.Error("unable to renew subscriptions", "error", err)
+		return err
+	}
+
+	common.P(summary)
+	return nil
+}
+
+// GetRenewals prints the subscription renewal history of an account.
+func GetRenewals(ctx context.Context, accountID string) error {
+	logCtx := slog.With("account_id", accountID)
+
+	renewals, err := bank.GetRenewals(ctx, logCtx, accountID)
+	if err != nil {
+		logCtx.Error("unable to get renewals", "error", err)
+		return err
+	}
+
+	common.P(renewals)
+	return nil
+}
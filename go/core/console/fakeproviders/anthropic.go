@@ -0,0 +1,98 @@
+package fakeproviders
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+func postAnthropicComplete(c echo.Context) error {
+	req := struct {
+		Prompt string `json:"prompt"`
+	}{}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": map[string]any{"message": "invalid request"}})
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"completion":  " " + chatText([]chatMessage{{Role: "user", Content: req.Prompt}}),
+		"stop_reason": "stop_sequence",
+	})
+}
+
+func postAnthropicMessages(c echo.Context) error {
+	req := struct {
+		Model    string        `json:"model"`
+		System   string        `json:"system"`
+		Messages []chatMessage `json:"messages"`
+		Stream   bool          `json:"stream"`
+	}{}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"type": "error", "error": map[string]any{"type": "invalid_request_error", "message": "invalid request"}})
+	}
+
+	text := chatText(req.Messages)
+
+	inputTokens := countTokens(req.System)
+	for _, m := range req.Messages {
+		inputTokens += countTokens(m.Content)
+	}
+
+	if req.Stream {
+		return streamAnthropicMessages(c, req.Model, text, inputTokens)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"id":            "msg_fake",
+		"type":          "message",
+		"role":          "assistant",
+		"model":         req.Model,
+		"content":       []map[string]any{{"type": "text", "text": text}},
+		"stop_reason":   "end_turn",
+		"stop_sequence": nil,
+		"usage":         map[string]any{"input_tokens": inputTokens, "output_tokens": countTokens(text)},
+	})
+}
+
+func streamAnthropicMessages(c echo.Context, model, text string, inputTokens int) error {
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+
+	events := []map[string]any{
+		{"type": "message_start", "message": map[string]any{
+			"id":      "msg_fake",
+			"type":    "message",
+			"role":    "assistant",
+			"model":   model,
+			"content": []any{},
+			"usage":   map[string]any{"input_tokens": inputTokens, "output_tokens": 1},
+		}},
+		{"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "text", "text": ""}},
+	}
+
+	for _, word := range strings.SplitAfter(text, " ") {
+		events = append(events, map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "text_delta", "text": word}})
+	}
+
+	events = append(events,
+		map[string]any{"type": "content_block_stop", "index": 0},
+		map[string]any{"type": "message_delta", "delta": map[string]any{"stop_reason": "end_turn", "stop_sequence": nil}, "usage": map[string]any{"output_tokens": countTokens(text)}},
+		map[string]any{"type": "message_stop"},
+	)
+
+	for _, event := range events {
+		b, _ := json.Marshal(event)
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event["type"], b); err != nil {
+			return err
+		}
+		w.Flush()
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package fakeproviders
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// mp3FrameSize is the size of a 128kbps 44.1kHz MPEG-1 layer III frame without padding.
+	mp3FrameSize     = 417
+	mp3FrameDuration = 26 * time.Millisecond
+	speechPerChar    = 60 * time.Millisecond
+	maxSpeech        = 30 * time.Second
+)
+
+// speechDuration approximates how long the text takes to say.
+func speechDuration(text string) time.Duration {
+	d := time.Duration(len(text)) * speechPerChar
+	if d < time.Second {
+		d = time.Second
+	}
+	return min(d, maxSpeech)
+}
+
+// silentMP3 returns mono silent MP3 frames. A zeroed side info and main data decodes as silence.
+func silentMP3(d time.Duration) []byte {
+	frame := make([]byte, mp3FrameSize)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0xC4})
+
+	n := int(d / mp3FrameDuration)
+	return bytes.Repeat(frame, n)
+}
+
+// silentPCM returns signed 16-bit little-endian mono silence.
+func silentPCM(d time.Duration, sampleRate int) []byte {
+	return make([]byte, int(d.Seconds()*float64(sampleRate))*2)
+}
+
+func postElevenlabsTTS(c echo.Context) error {
+	req := struct {
+		Text string `json:"text"`
+	}{}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"detail": "invalid request"})
+	}
+
+	d := speechDuration(req.Text)
+
+	format := c.QueryParam("output_format")
+	if rate, ok := strings.CutPrefix(format, "pcm_"); ok {
+		sampleRate, err := strconv.Atoi(rate)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]any{"detail": "invalid output_format"})
+		}
+		return c.Blob(http.StatusOK, "audio/pcm", silentPCM(d, sampleRate))
+	}
+
+	return c.Blob(http.StatusOK, "audio/mpeg", silentMP3(d))
+}
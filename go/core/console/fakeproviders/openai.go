@@ -0,0 +1,294 @@
+package fakeproviders
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const embeddingDimensions = 1536
+
+var moderationCategories = []string{
+	"harassment",
+	"harassment/threatening",
+	"hate",
+	"hate/threatening",
+	"self-harm",
+	"self-harm/instructions",
+	"self-harm/intent",
+	"sexual",
+	"sexual/minors",
+	"violence",
+	"violence/graphic",
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+}
+
+type analysis struct {
+	Assessment            string   `json:"assessment"`
+	AssessmentTranslation string   `json:"assessment_translation"`
+	AssessmentAge         int      `json:"assessment_age"`
+	Topic                 string   `json:"topic"`
+	Classifications       []string `json:"classifications"`
+	MovieRating           string   `json:"movie_rating"`
+	TVRating              string   `json:"tv_rating"`
+	ESRBRating            string   `json:"esrb_rating"`
+	PEGIRating            int      `json:"pegi_rating"`
+	Sentiment             string   `json:"sentiment"`
+	Emotion               string   `json:"emotion"`
+	Intent                string   `json:"intent"`
+	Toxic                 bool     `json:"toxic"`
+	NamedEntities         []string `json:"named_entities"`
+	SubjectCategory       string   `json:"subject_category"`
+	Language              string   `json:"language"`
+}
+
+// chatText returns the canned assistant text. Moderation analysis prompts ask for JSON and get a JSON analysis.
+func chatText(messages []chatMessage) string {
+	last := ""
+	if len(messages) > 0 {
+		last = messages[len(messages)-1].Content
+	}
+
+	if !strings.Contains(last, "RFC8259 compliant JSON") {
+		return chatResponse
+	}
+
+	a := analysis{
+		Assessment:            "General conversation suitable for children.",
+		AssessmentTranslation: "General conversation suitable for children.",
+		Topic:                 "general",
+		Classifications:       []string{"positive"},
+		MovieRating:           "G",
+		TVRating:              "TV-Y",
+		ESRBRating:            "E",
+		PEGIRating:            3,
+		Sentiment:             "positive",
+		Emotion:               "neutral",
+		Intent:                "statement",
+		NamedEntities:         []string{},
+		SubjectCategory:       "general",
+		Language:              "en",
+	}
+
+	if flagged(last) {
+		a.Assessment = "Not suitable for children."
+		a.AssessmentTranslation = a.Assessment
+		a.AssessmentAge = 18
+		a.Classifications = []string{"toxic"}
+		a.MovieRating = "R"
+		a.TVRating = "TV-MA"
+		a.ESRBRating = "M"
+		a.PEGIRating = 18
+		a.Sentiment = "negative"
+		a.Emotion = "anger"
+		a.Toxic = true
+	}
+
+	b, _ := json.Marshal(a)
+	return string(b)
+}
+
+func countTokens(s string) int {
+	return len(strings.Fields(s))
+}
+
+func postOpenAIChat(c echo.Context) error {
+	req := chatRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": map[string]any{"message": "invalid request"}})
+	}
+
+	text := chatText(req.Messages)
+
+	promptTokens := 0
+	for _, m := range req.Messages {
+		promptTokens += countTokens(m.Content)
+	}
+
+	if !req.Stream {
+		return c.JSON(http.StatusOK, map[string]any{
+			"id":      "chatcmpl-fake",
+			"object":  "chat.completion",
+			"created": 0,
+			"model":   req.Model,
+			"choices": []map[string]any{{
+				"index":         0,
+				"message":       map[string]any{"role": "assistant", "content": text},
+				"finish_reason": "stop",
+			}},
+			"usage": map[string]any{
+				"prompt_tokens":     promptTokens,
+				"completion_tokens": countTokens(text),
+				"total_tokens":      promptTokens + countTokens(text),
+			},
+		})
+	}
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+
+	words := strings.SplitAfter(text, " ")
+	for i, word := range words {
+		finishReason := ""
+		if i == len(words)-1 {
+			finishReason = "stop"
+		}
+
+		chunk, _ := json.Marshal(map[string]any{
+			"id":      "chatcmpl-fake",
+			"object":  "chat.completion.chunk",
+			"created": 0,
+			"model":   req.Model,
+			"choices": []map[string]any{{
+				"index":         0,
+				"delta":         map[string]any{"content": word},
+				"finish_reason": finishReason,
+			}},
+		})
+
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", chunk); err != nil {
+			return err
+		}
+		w.Flush()
+	}
+
+	_, err := fmt.Fprint(w, "data: [DONE]\n\n")
+	return err
+}
+
+func postOpenAITranscriptions(c echo.Context) error {
+	if _, err := c.FormFile("file"); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": map[string]any{"message": "invalid file"}})
+	}
+
+	language := "english"
+	if l := c.FormValue("language"); l != "" {
+		language = l
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"task":     "transcribe",
+		"language": language,
+		"duration": 2.5,
+		"text":     transcriptionResponse,
+	})
+}
+
+func postOpenAISpeech(c echo.Context) error {
+	req := struct {
+		Input  string `json:"input"`
+		Format string `json:"response_format"`
+	}{}
+
+	if err := c.Bind(&req); err != nil || req.Input == "" {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": map[string]any{"message": "invalid request"}})
+	}
+
+	d := speechDuration(req.Input)
+
+	if req.Format == "pcm" {
+		return c.Blob(http.StatusOK, "audio/pcm", silentPCM(d, 24000))
+	}
+
+	return c.Blob(http.StatusOK, "audio/mpeg", silentMP3(d))
+}
+
+// embedding returns a deterministic unit vector so identical inputs have a cosine similarity of 1.
+func embedding(s string) []float64 {
+	v := make([]float64, embeddingDimensions)
+	x := seed(s)
+	norm := 0.0
+
+	for i := range v {
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+		v[i] = float64(x%2000)/1000.0 - 1.0
+		norm += v[i] * v[i]
+	}
+
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+
+	return v
+}
+
+func postOpenAIEmbeddings(c echo.Context) error {
+	req := struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}{}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": map[string]any{"message": "invalid request"}})
+	}
+
+	tokens := 0
+	data := make([]map[string]any, 0, len(req.Input))
+	for i, input := range req.Input {
+		tokens += countTokens(input)
+		data = append(data, map[string]any{
+			"object":    "embedding",
+			"embedding": embedding(input),
+			"index":     i,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"object": "list",
+		"data":   data,
+		"model":  req.Model,
+		"usage":  map[string]any{"prompt_tokens": tokens, "total_tokens": tokens},
+	})
+}
+
+func postOpenAIModerations(c echo.Context) error {
+	req := struct {
+		Input string `json:"input"`
+	}{}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": map[string]any{"message": "invalid request"}})
+	}
+
+	flag := flagged(req.Input)
+
+	categories := make(map[string]bool, len(moderationCategories))
+	scores := make(map[string]float64, len(moderationCategories))
+	for _, category := range moderationCategories {
+		categories[category] = false
+		scores[category] = 0.0001
+	}
+
+	if flag {
+		categories["harassment"] = true
+		scores["harassment"] = 0.9
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"id":    "modr-fake",
+		"model": "text-moderation-fake",
+		"results": []map[string]any{{
+			"flagged":         flag,
+			"categories":      categories,
+			"category_scores": scores,
+		}},
+	})
+}
@@ -0,0 +1,108 @@
+// Package fakeproviders serves deterministic canned responses for the external AI providers
+// so the vox play flow can run without network access.
+package fakeproviders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// moderationTrigger flags text in moderation and analysis responses.
+	moderationTrigger = "[flag]"
+
+	chatResponse = "That is a great question! Let's explore it together."
+
+	transcriptionResponse = "Can you tell me a story about dinosaurs?"
+)
+
+// Serve runs the fake provider server until ctx is canceled.
+func Serve(ctx context.Context, addr string) error {
+	logCtx := slog.With("fid", "console.fakeproviders.Serve")
+
+	e := echo.New()
+	e.Logger.SetOutput(io.Discard)
+	e.HideBanner = true
+	e.HidePort = true
+
+	Routes(e)
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		e.Shutdown(shutdownCtx)
+	}()
+
+	base := "http://" + addr
+	if strings.HasPrefix(addr, ":") {
+		base = "http://localhost" + addr
+	}
+
+	logCtx.Info("fake providers started", "addr", addr)
+	fmt.Printf("DIS_ANTHROPIC_URL=%s/anthropic/v1\n", base)
+	fmt.Printf("DIS_ELEVENLABS_URL=%s/elevenlabs/v1\n", base)
+	fmt.Printf("DIS_OPENAI_URL=%s/openai/v1\n", base)
+	fmt.Printf("DIS_PINECONE_URL=%s/pinecone\n", base)
+	fmt.Printf("DIS_STABILITYAI_URL=%s/stabilityai/v1/\n", base)
+	fmt.Printf("DIS_STT_WHISPER_LOCAL_URL=%s/openai/v1\n", base)
+	fmt.Printf("DIS_TTS_LOCAL_URL=%s/openai/v1\n", base)
+
+	if err := e.Start(addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logCtx.Error("unable to serve fake providers", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Routes adds the fake provider routes.
+func Routes(e *echo.Echo) {
+	openai := e.Group("/openai/v1")
+	openai.POST("/audio/speech", postOpenAISpeech)
+	openai.POST("/audio/transcriptions", postOpenAITranscriptions)
+	openai.POST("/chat/completions", postOpenAIChat)
+	openai.POST("/embeddings", postOpenAIEmbeddings)
+	openai.POST("/moderations", postOpenAIModerations)
+
+	anthropic := e.Group("/anthropic/v1")
+	anthropic.POST("/complete", postAnthropicComplete)
+	anthropic.POST("/messages", postAnthropicMessages)
+
+	elevenlabs := e.Group("/elevenlabs/v1")
+	elevenlabs.POST("/text-to-speech/:voice", postElevenlabsTTS)
+	elevenlabs.POST("/text-to-speech/:voice/stream", postElevenlabsTTS)
+
+	stabilityai := e.Group("/stabilityai/v1")
+	stabilityai.POST("/generation/:engine_id/text-to-image", postStabilityAITTI)
+
+	pinecone := e.Group("/pinecone")
+	pinecone.POST("/query", postPineconeQuery)
+	pinecone.POST("/describe_index_stats", postPineconeStats)
+	pinecone.GET("/vectors/fetch", getPineconeFetch)
+	pinecone.POST("/vectors/upsert", postPineconeUpsert)
+	pinecone.POST("/vectors/update", pineconeOK)
+	pinecone.DELETE("/vectors/delete", pineconeOK)
+}
+
+// seed returns a stable hash of the input so every response is deterministic.
+func seed(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func flagged(s string) bool {
+	return strings.Contains(strings.ToLower(s), moderationTrigger)
+}
@@ -0,0 +1,90 @@
+package fakeproviders
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+const maxTopK = 100
+
+func postPineconeQuery(c echo.Context) error {
+	req := struct {
+		ID              string            `json:"id"`
+		Namespace       string            `json:"namespace"`
+		TopK            int               `json:"topK"`
+		Vector          []float64         `json:"vector"`
+		IncludeValues   bool              `json:"includeValues"`
+		IncludeMetadata bool              `json:"includeMetadata"`
+		Filter          map[string]string `json:"filter"`
+	}{}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"code": 3, "message": "invalid request"})
+	}
+
+	topK := min(max(req.TopK, 0), maxTopK)
+
+	matches := make([]map[string]any, 0, topK)
+	for i := 0; i < topK; i++ {
+		id := fmt.Sprintf("fake-%d", i+1)
+
+		match := map[string]any{
+			"id":    id,
+			"score": 1.0 - float64(i)*0.05,
+		}
+
+		if req.IncludeValues {
+			match["values"] = embedding(id)
+		}
+
+		if req.IncludeMetadata {
+			match["metadata"] = map[string]string{"text": fmt.Sprintf("Canned match %d.", i+1)}
+		}
+
+		matches = append(matches, match)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"namespace": req.Namespace,
+		"matches":   matches,
+	})
+}
+
+func postPineconeStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{
+		"namespaces":       map[string]any{"": map[string]any{"vectorCount": maxTopK}},
+		"dimension":        embeddingDimensions,
+		"indexFullness":    0.0,
+		"totalVectorCount": maxTopK,
+	})
+}
+
+func getPineconeFetch(c echo.Context) error {
+	vectors := map[string]any{}
+	for _, id := range c.QueryParams()["ids"] {
+		vectors[id] = map[string]any{"id": id, "values": embedding(id)}
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"namespace": c.QueryParam("namespace"),
+		"vectors":   vectors,
+	})
+}
+
+func postPineconeUpsert(c echo.Context) error {
+	req := struct {
+		Vectors []map[string]any `json:"vectors"`
+	}{}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"code": 3, "message": "invalid request"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"upsertedCount": len(req.Vectors)})
+}
+
+func pineconeOK(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{})
+}
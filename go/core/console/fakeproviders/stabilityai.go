@@ -0,0 +1,62 @@
+package fakeproviders
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultImageSize = 512
+	maxImageSize     = 1024
+)
+
+func postStabilityAITTI(c echo.Context) error {
+	req := struct {
+		TextPrompts []struct {
+			Text string `json:"text"`
+		} `json:"text_prompts"`
+		Height int `json:"height"`
+		Width  int `json:"width"`
+	}{}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"message": "invalid request"})
+	}
+
+	prompts := make([]string, 0, len(req.TextPrompts))
+	for _, p := range req.TextPrompts {
+		prompts = append(prompts, p.Text)
+	}
+
+	width, height := req.Width, req.Height
+	if width <= 0 || width > maxImageSize {
+		width = defaultImageSize
+	}
+	if height <= 0 || height > maxImageSize {
+		height = defaultImageSize
+	}
+
+	// The color is derived from the prompt so the same prompt always renders the same image.
+	s := seed(strings.Join(prompts, "\n"))
+	fill := color.RGBA{R: uint8(s), G: uint8(s >> 8), B: uint8(s >> 16), A: 0xFF}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, fill)
+		}
+	}
+
+	b := bytes.Buffer{}
+	if err := png.Encode(&b, img); err != nil {
+		return err
+	}
+
+	return c.Blob(http.StatusOK, "image/png", b.Bytes())
+}
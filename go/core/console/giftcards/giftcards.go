@@ -0,0 +1,81 @@
+// Package giftcards contains console commands for gift cards.
+package giftcards
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+
+	"disruptive/lib/common"
+	"disruptive/pkg/vox/bank"
+)
+
+// Mint mints a batch of gift cards and writes them as CSV to output, or stdout when output is empty.
+func Mint(ctx context.Context, batch bank.GiftCardBatch, output string) error {
+	logCtx := slog.With("campaign", batch.Campaign, "count", batch.Count)
+
+	cards, err := bank.MintGiftCards(ctx, logCtx, batch)
+	if len(cards) > 0 {
+		// Cards minted before a failure exist, so they are written either way.
+		if err := writeCSV(output, cards); err != nil {
+			logCtx.Error("unable to write gift cards", "error", err)
+			return err
+		}
+	}
+
+	if err != nil {
+		logCtx.Error("unable to mint gift cards", "minted", len(cards), "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Export writes the gift cards of a campaign as CSV to output, or stdout when output is empty.
+func Export(ctx context.Context, campaign, output string) error {
+	logCtx := slog.With("campaign", campaign)
+
+	cards, err := bank.GetCampaignGiftCards(ctx, logCtx, campaign)
+	if err != nil {
+		logCtx.Error("unable to get campaign gift cards", "error", err)
+		return err
+	}
+
+	if err := writeCSV(output, cards); err != nil {
+		logCtx.Error("unable to write gift cards", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Report prints the redemption report of a campaign.
+func Report(ctx context.Context, campaign string) error {
+	logCtx := slog.With("campaign", campaign)
+
+	report, err := bank.GetCampaignReport(ctx, logCtx, campaign)
+	if err != nil {
+		logCtx.Error("unable to get campaign report", "error", err)
+		return err
+	}
+
+	common.P(report)
+	return nil
+}
+
+func writeCSV(output string, cards []bank.GiftCard) error {
+	var w io.Writer = os.Stdout
+
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	return bank.WriteGiftCardsCSV(w, cards)
+}
@@ -0,0 +1,96 @@
+package completion
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"disruptive/lib/common"
+	"disruptive/lib/llm"
+)
+
+func chat(ctx context.Context, logCtx *slog.Logger, config *Config, sessionMemory *SessionMemory, contextPrompt, query string, verbose bool) error {
+	req := llm.Request{
+		Messages:   []llm.Message{{Role: "system", Content: contextPrompt}},
+		Creativity: config.Creativity,
+		MaxTokens:  250,
+	}
+
+	start := len(sessionMemory.Entries) - config.SessionMemory
+	if start < 0 {
+		start = 0
+	}
+
+	for _, e := range sessionMemory.Entries[start:] {
+		req.Messages = append(req.Messages, llm.Message{Role: "user", Content: e.User})
+		req.Messages = append(req.Messages, llm.Message{Role: "assistant", Content: e.Assistant})
+	}
+
+	req.Messages = append(req.Messages, llm.Message{
+		Role:    "user",
+		Content: strings.TrimSpace(fmt.Sprintf("%s. %s", query, config.QueryAttributes)),
+	})
+
+	model, provider, _, err := llm.Resolve(ctx, logCtx, config.Model, req.Messages)
+	if err != nil {
+		logCtx.Error("unable to resolve model", "error", err)
+		return err
+	}
+
+	if verbose {
+		fmt.Println(common.MarshalIndent(req))
+	}
+
+	fmt.Printf("[%s: %s] %s\n\n", config.Set, model.Name, query)
+
+	if config.Repeat > 0 {
+		repeatCSV.Write([]string{fmt.Sprintf("[%s: %s] %s", config.Set, model.Name, query)})
+
+		for i := 0; i < config.Repeat; i++ {
+			t := time.Now()
+			res, err := provider.Chat(ctx, logCtx, model, req)
+			if err != nil {
+				logCtx.Error("unable to post chat", "error", err)
+				return err
+			}
+			d := time.Since(t).Seconds()
+
+			res.Text = strings.TrimSpace(res.Text)
+
+			if verbose {
+				fmt.Println(common.MarshalIndent(res))
+			} else {
+				fmt.Println("   ", res.Text)
+				fmt.Println()
+			}
+
+			repeatCSV.Write([]string{"", res.Text, config.Set, model.Name, time.Now().Format("2006-01-02 15:04:05 MST"), fmt.Sprintf("%f", d), res.FinishReason, strconv.Itoa(res.TokensPrompt + res.TokensResponse), strconv.Itoa(res.TokensPrompt), strconv.Itoa(res.TokensResponse)})
+		}
+
+	} else {
+		res, err := provider.Chat(ctx, logCtx, model, req)
+		if err != nil {
+			logCtx.Error("unable to post chat", "error", err)
+			return err
+		}
+
+		res.Text = strings.TrimSpace(res.Text)
+
+		if verbose {
+			fmt.Println(common.MarshalIndent(res))
+		} else {
+			fmt.Println(res.Text)
+			fmt.Println()
+		}
+
+		sessionMemory.Entries = append(sessionMemory.Entries, SessionMemoryEntry{
+			User:      query,
+			Assistant: res.Text,
+		})
+	}
+
+	return nil
+}
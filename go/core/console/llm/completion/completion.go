@@ -1,4 +1,4 @@
-// Package completion tests the completion API for OpenAI and Anthropic.
+// Package completion tests chat completions for any model in the LLM model registry.
 package completion
 
 import (
@@ -32,7 +32,7 @@ var (
 	repeatCSV *csv.Writer
 )
 
-// Main integrates Pinecone context, Session memory and feeds it to OpenAI/Anthropic Chat.
+// Main integrates Pinecone context, Session memory and feeds it to the model provider chat.
 func Main(ctx context.Context, configFile, set, query string, repeat int, repeatFile string, verbose bool) error {
 	logCtx := slog.With("config_file", configFile, "set", set, "query", query)
 
@@ -183,13 +183,9 @@ func processQuery(ctx context.Context, logCtx *slog.Logger, config *Config, sess
 		}
 	}
 
-	switch config.Model {
-	case "gpt-3.5-turbo", "gpt-4":
-		chatOpenAI(ctx, logCtx, config, sessionMemory, contextPrompt.String(), query, config.Verbose)
-	case "claude-v1", "claude-instant-v1":
-		chatAnthropic(ctx, logCtx, config, sessionMemory, contextPrompt.String(), query, config.Verbose)
-	default:
-		logCtx.Error("unknown model")
+	if err := chat(ctx, logCtx, config, sessionMemory, contextPrompt.String(), query, config.Verbose); err != nil {
+		logCtx.Error("unable to chat", "error", err)
+		return err
 	}
 
 	return nil
@@ -10,7 +10,7 @@ import (
 	"time"
 
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 )
 
 // ImportProductDevice generates and imports product device mac addresses to firestore.
@@ -20,7 +20,7 @@ func ImportProductDevice(ctx context.Context, productName string, macAddress uin
 	start := time.Now()
 
 	path := fmt.Sprintf("products/%s/ids", productName)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("product collection not found")
 		return common.ErrNotFound{}
@@ -38,7 +38,7 @@ func ImportProductDevice(ctx context.Context, productName string, macAddress uin
 		}
 
 		if force {
-			if _, err := collection.Doc(mac.String()).Set(ctx, doc); err != nil {
+			if err := collection.Doc(mac.String()).Set(ctx, doc); err != nil {
 				err = common.ConvertGRPCError(err)
 				logCtx.Error("unable to set device document", "error", err)
 
@@ -48,7 +48,7 @@ func ImportProductDevice(ctx context.Context, productName string, macAddress uin
 
 			valid++
 		} else {
-			if _, err := collection.Doc(mac.String()).Create(ctx, doc); err != nil {
+			if err := collection.Doc(mac.String()).Create(ctx, doc); err != nil {
 				err = common.ConvertGRPCError(err)
 				if errors.Is(err, common.ErrAlreadyExists{}) {
 					fmt.Println(mac.String() + " already exists. Skipping.")
@@ -0,0 +1,231 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"disruptive/lib/common"
+)
+
+// Message contains a single user or assistant message.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// MessagesRequest is the Messages API request structure.
+type MessagesRequest struct {
+	Model         string    `json:"model"`
+	System        string    `json:"system,omitempty"`
+	Messages      []Message `json:"messages"`
+	MaxTokens     int       `json:"max_tokens"`
+	Temperature   float64   `json:"temperature"`
+	StopSequences []string  `json:"stop_sequences,omitempty"`
+	Stream        bool      `json:"stream,omitempty"`
+}
+
+type messagesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type messagesResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason   string        `json:"stop_reason"`
+	StopSequence string        `json:"stop_sequence"`
+	Usage        messagesUsage `json:"usage"`
+}
+
+type messagesEvent struct {
+	Type    string           `json:"type"`
+	Message messagesResponse `json:"message"`
+	Delta   struct {
+		Type         string `json:"type"`
+		Text         string `json:"text"`
+		StopReason   string `json:"stop_reason"`
+		StopSequence string `json:"stop_sequence"`
+	} `json:"delta"`
+	Usage messagesUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type messagesError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// MessagesResponse is the Messages API response structure.
+type MessagesResponse struct {
+	Text          string `json:"text"`
+	StopReason    string `json:"stop_reason"`
+	StopSequence  string `json:"stop_sequence,omitempty"`
+	UsagePrompt   int    `json:"usage_prompt"`
+	UsageResponse int    `json:"usage_response"`
+}
+
+// MessagesStream is filled with the response text as it streams.
+// The stop reason and usage are set once the stream has been read to EOF.
+type MessagesStream struct {
+	*io.PipeReader
+	Response MessagesResponse
+}
+
+// PostMessages sends role structured messages to Anthropic.
+func PostMessages(ctx context.Context, logCtx *slog.Logger, req MessagesRequest) (MessagesResponse, error) {
+	fid := slog.String("fid", "anthropic.PostMessages")
+
+	req.Stream = false
+
+	res, err := Resty.R().
+		SetContext(ctx).
+		SetBody(req).
+		SetResult(&messagesResponse{}).
+		Post(messagesEndpoint)
+
+	if err != nil {
+		logCtx.Error("anthropic messages endpoint failed", fid, "error", err)
+		return MessagesResponse{}, err
+	}
+
+	if err := checkStatus(logCtx, fid, res.StatusCode(), res.Status(), res.Body()); err != nil {
+		return MessagesResponse{}, err
+	}
+
+	_res := res.Result().(*messagesResponse)
+
+	text := strings.Builder{}
+	for _, c := range _res.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+
+	return MessagesResponse{
+		Text:          text.String(),
+		StopReason:    _res.StopReason,
+		StopSequence:  _res.StopSequence,
+		UsagePrompt:   _res.Usage.InputTokens,
+		UsageResponse: _res.Usage.OutputTokens,
+	}, nil
+}
+
+// PostMessagesStream sends role structured messages to Anthropic and streams the response text.
+func PostMessagesStream(ctx context.Context, logCtx *slog.Logger, req MessagesRequest) (*MessagesStream, error) {
+	fid := slog.String("fid", "anthropic.PostMessagesStream")
+
+	req.Stream = true
+
+	res, err := Resty.R().
+		SetContext(ctx).
+		SetBody(req).
+		SetDoNotParseResponse(true).
+		Post(messagesEndpoint)
+
+	if err != nil {
+		logCtx.Error("anthropic messages endpoint failed", fid, "error", err)
+		return nil, err
+	}
+
+	if res.StatusCode() != http.StatusOK {
+		defer res.RawBody().Close()
+		body, _ := io.ReadAll(res.RawBody())
+		return nil, checkStatus(logCtx, fid, res.StatusCode(), res.Status(), body)
+	}
+
+	r, w := io.Pipe()
+	stream := &MessagesStream{PipeReader: r}
+
+	go func() {
+		defer res.RawBody().Close()
+
+		scanner := bufio.NewScanner(res.RawBody())
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if !bytes.HasPrefix(line, []byte("data:")) {
+				continue
+			}
+
+			event := messagesEvent{}
+			if err := json.Unmarshal(bytes.TrimSpace(line[5:]), &event); err != nil {
+				logCtx.Error("unable to read event data", fid, "error", err)
+				w.CloseWithError(err)
+				return
+			}
+
+			switch event.Type {
+			case "message_start":
+				stream.Response.UsagePrompt = event.Message.Usage.InputTokens
+				stream.Response.UsageResponse = event.Message.Usage.OutputTokens
+			case "content_block_delta":
+				if event.Delta.Type != "text_delta" {
+					continue
+				}
+
+				if _, err := w.Write([]byte(event.Delta.Text)); err != nil {
+					logCtx.Warn("unable to write stream text", fid, "error", err)
+					return
+				}
+
+				stream.Response.Text += event.Delta.Text
+			case "message_delta":
+				stream.Response.StopReason = event.Delta.StopReason
+				stream.Response.StopSequence = event.Delta.StopSequence
+				stream.Response.UsageResponse = event.Usage.OutputTokens
+			case "message_stop":
+				w.Close()
+				return
+			case "error":
+				logCtx.Error("anthropic stream error", fid, "type", event.Error.Type, "message", event.Error.Message)
+				w.CloseWithError(errors.New("anthropic: " + event.Error.Message))
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			logCtx.Error("unable to read stream", fid, "error", err)
+			w.CloseWithError(err)
+			return
+		}
+
+		w.Close()
+	}()
+
+	return stream, nil
+}
+
+func checkStatus(logCtx *slog.Logger, fid slog.Attr, statusCode int, status string, body []byte) error {
+	if statusCode == http.StatusOK {
+		return nil
+	}
+
+	if statusCode == http.StatusUnauthorized {
+		logCtx.Error("anthropic unauthorized", fid, "status", status)
+		return common.ErrUnauthorized
+	}
+
+	e := messagesError{}
+	json.Unmarshal(body, &e)
+
+	if statusCode == http.StatusBadRequest {
+		logCtx.Error("anthropic messages bad request", fid, "status", status, "type", e.Error.Type, "message", e.Error.Message)
+		return common.ErrBadRequest{Msg: "anthropic: " + e.Error.Message}
+	}
+
+	logCtx.Error("anthropic messages endpoint failed", fid, "status", status, "type", e.Error.Type, "message", e.Error.Message)
+	return errors.New("anthropic: " + status)
+}
@@ -2,17 +2,20 @@
 package anthropic
 
 import (
-	"disruptive/config"
-	"disruptive/lib/common"
 	"net/http"
 	"time"
 
 	"github.com/go-resty/resty/v2"
+
+	"disruptive/config"
+	"disruptive/lib/common"
 )
 
 const (
-	baseURL          = "https://api.anthropic.com/v1"
 	completeEndpoint = "/complete"
+	messagesEndpoint = "/messages"
+
+	apiVersion = "2023-06-01"
 )
 
 // Resty is the shared Resty client for the anthropic package.
@@ -20,12 +23,13 @@ var Resty *resty.Client
 
 func init() {
 	Resty = resty.New().
-		SetBaseURL(baseURL).
+		SetBaseURL(config.VARS.AnthropicURL).
 		SetLogger(common.LogDiscard).
 		AddRetryCondition(func(r *resty.Response, err error) bool {
 			return r.StatusCode() == http.StatusTooManyRequests
 		}).
 		SetHeader("x-api-key", config.VARS.AnthropicKey).
+		SetHeader("anthropic-version", apiVersion).
 		SetHeader("User-Agent", config.VARS.UserAgent).
 		SetHeader("Content-Type", "application/json").
 		SetTimeout(time.Minute)
@@ -0,0 +1,64 @@
+// Package appstore verifies App Store signed transactions and server notifications.
+package appstore
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"log/slog"
+	"os"
+	"strings"
+
+	"disruptive/config"
+)
+
+// Environments
+const (
+	EnvironmentProduction = "Production"
+	EnvironmentSandbox    = "Sandbox"
+)
+
+// Notification types handled by the bank.
+const (
+	NotificationRefund = "REFUND"
+	NotificationRevoke = "REVOKE"
+	NotificationTest   = "TEST"
+)
+
+var (
+	// Apple marks the certificates of the App Store signing chain with these extensions.
+	oidLeaf         = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+	oidIntermediate = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+
+	// Default verifies with the configured Apple roots, bundle id and environments. It is nil when the roots are not configured.
+	Default *Verifier
+)
+
+func init() {
+	logCtx := slog.With("fid", "appstore.init")
+
+	if config.VARS.AppleRootCerts == "" {
+		logCtx.Warn("apple root certificates not configured")
+		return
+	}
+
+	b, err := os.ReadFile(config.VARS.AppleRootCerts)
+	if err != nil {
+		logCtx.Warn("unable to read apple root certificates", "error", err)
+		return
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(b) {
+		logCtx.Warn("invalid apple root certificates")
+		return
+	}
+
+	environments := []string{}
+	for _, e := range strings.Split(config.VARS.AppleEnvironments, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			environments = append(environments, e)
+		}
+	}
+
+	Default = NewVerifier(roots, config.VARS.AppleBundleID, environments...)
+}
@@ -0,0 +1,154 @@
+package appstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"time"
+)
+
+// LocalSigner signs App Store data with a locally generated root, intermediate and leaf chain
+// so the verifier can be exercised offline. It is never trusted unless its roots are configured.
+type LocalSigner struct {
+	key   *ecdsa.PrivateKey
+	chain [][]byte
+	root  *x509.Certificate
+}
+
+// NewLocalSigner generates a certificate chain valid for a year.
+func NewLocalSigner() (*LocalSigner, error) {
+	return newLocalSigner(
+		[]pkix.Extension{{Id: oidLeaf, Value: []byte{0x05, 0x00}}},
+		[]pkix.Extension{{Id: oidIntermediate, Value: []byte{0x05, 0x00}}},
+	)
+}
+
+// newLocalSigner generates a chain with the given leaf and intermediate extensions.
+func newLocalSigner(leafExtensions, intermediateExtensions []pkix.Extension) (*LocalSigner, error) {
+	now := time.Now()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Local Root CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	rootDER, err := x509.CreateCertificate(rand.Reader, root, root, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if root, err = x509.ParseCertificate(rootDER); err != nil {
+		return nil, err
+	}
+
+	intermediateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	intermediate := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Local Intermediate CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		ExtraExtensions:       intermediateExtensions,
+	}
+
+	intermediateDER, err := x509.CreateCertificate(rand.Reader, intermediate, root, &intermediateKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if intermediate, err = x509.ParseCertificate(intermediateDER); err != nil {
+		return nil, err
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf := &x509.Certificate{
+		SerialNumber:    big.NewInt(3),
+		Subject:         pkix.Name{CommonName: "Local App Store Signing"},
+		NotBefore:       now.Add(-time.Hour),
+		NotAfter:        now.AddDate(1, 0, 0),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: leafExtensions,
+	}
+
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, intermediate, &leafKey.PublicKey, intermediateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalSigner{
+		key:   leafKey,
+		chain: [][]byte{leafDER, intermediateDER, rootDER},
+		root:  root,
+	}, nil
+}
+
+// Roots returns a pool with the local root.
+func (s *LocalSigner) Roots() *x509.CertPool {
+	roots := x509.NewCertPool()
+	roots.AddCert(s.root)
+	return roots
+}
+
+// RootPEM returns the local root certificate in PEM, as read from DIS_APPLE_ROOT_CERTS.
+func (s *LocalSigner) RootPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.root.Raw})
+}
+
+// Sign returns payload as an ES256 JWS with the x5c chain.
+func (s *LocalSigner) Sign(payload any) (string, error) {
+	x5c := make([]string, 0, len(s.chain))
+	for _, der := range s.chain {
+		x5c = append(x5c, base64.StdEncoding.EncodeToString(der))
+	}
+
+	header, err := json.Marshal(map[string]any{"alg": "ES256", "x5c": x5c})
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	hash := sha256.Sum256([]byte(input))
+
+	r, ss, err := ecdsa.Sign(rand.Reader, s.key, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	ss.FillBytes(sig[32:])
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,255 @@
+package appstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"disruptive/lib/common"
+)
+
+// Transaction is the decoded payload of a signed transaction.
+// Dates are milliseconds since the epoch.
+type Transaction struct {
+	AppAccountToken       string `json:"appAccountToken,omitempty"`
+	BundleID              string `json:"bundleId"`
+	Environment           string `json:"environment"`
+	ExpiresDate           int64  `json:"expiresDate,omitempty"`
+	OriginalTransactionID string `json:"originalTransactionId"`
+	ProductID             string `json:"productId"`
+	PurchaseDate          int64  `json:"purchaseDate"`
+	Quantity              int    `json:"quantity"`
+	RevocationDate        int64  `json:"revocationDate,omitempty"`
+	RevocationReason      *int   `json:"revocationReason,omitempty"`
+	SignedDate            int64  `json:"signedDate"`
+	TransactionID         string `json:"transactionId"`
+	Type                  string `json:"type"`
+}
+
+// Notification is the decoded payload of an App Store Server Notification v2.
+// Transaction is set when the notification data has a signed transaction.
+type Notification struct {
+	NotificationType string `json:"notificationType"`
+	Subtype          string `json:"subtype,omitempty"`
+	NotificationUUID string `json:"notificationUUID"`
+	Data             struct {
+		BundleID              string `json:"bundleId"`
+		Environment           string `json:"environment"`
+		SignedTransactionInfo string `json:"signedTransactionInfo,omitempty"`
+	} `json:"data"`
+	Version    string `json:"version"`
+	SignedDate int64  `json:"signedDate"`
+
+	Transaction *Transaction `json:"-"`
+}
+
+// Verifier verifies the JWS signed data of the App Store.
+type Verifier struct {
+	BundleID     string
+	Environments []string
+	Now          func() time.Time
+	Roots        *x509.CertPool
+}
+
+// NewVerifier returns a verifier that trusts roots and accepts bundleID in the environments.
+// Only production is accepted when no environment is given.
+func NewVerifier(roots *x509.CertPool, bundleID string, environments ...string) *Verifier {
+	if len(environments) == 0 {
+		environments = []string{EnvironmentProduction}
+	}
+
+	return &Verifier{
+		BundleID:     bundleID,
+		Environments: environments,
+		Now:          time.Now,
+		Roots:        roots,
+	}
+}
+
+// VerifyTransaction verifies a signedTransactionInfo and its bundle id and environment.
+func (v *Verifier) VerifyTransaction(signed string) (Transaction, error) {
+	t := Transaction{}
+
+	if err := v.verify(signed, &t); err != nil {
+		return Transaction{}, err
+	}
+
+	if err := v.checkApp(t.BundleID, t.Environment); err != nil {
+		return Transaction{}, err
+	}
+
+	if t.TransactionID == "" || t.ProductID == "" {
+		return Transaction{}, fmt.Errorf("%w: missing transaction id or product id", common.ErrIAPUnauthorized)
+	}
+
+	return t, nil
+}
+
+// VerifyNotification verifies a notification signedPayload and the signed transaction it contains.
+func (v *Verifier) VerifyNotification(signed string) (Notification, error) {
+	n := Notification{}
+
+	if err := v.verify(signed, &n); err != nil {
+		return Notification{}, err
+	}
+
+	if err := v.checkApp(n.Data.BundleID, n.Data.Environment); err != nil {
+		return Notification{}, err
+	}
+
+	if n.Data.SignedTransactionInfo != "" {
+		t, err := v.VerifyTransaction(n.Data.SignedTransactionInfo)
+		if err != nil {
+			return Notification{}, err
+		}
+
+		n.Transaction = &t
+	}
+
+	return n, nil
+}
+
+func (v *Verifier) checkApp(bundleID, environment string) error {
+	if v.BundleID == "" || bundleID != v.BundleID {
+		return fmt.Errorf("%w: invalid bundle id %q", common.ErrIAPUnauthorized, bundleID)
+	}
+
+	if !slices.Contains(v.Environments, environment) {
+		return fmt.Errorf("%w: invalid environment %q", common.ErrIAPUnauthorized, environment)
+	}
+
+	return nil
+}
+
+// verify checks the x5c certificate chain up to the roots and the ES256 signature, then decodes the payload into p.
+func (v *Verifier) verify(signed string, p any) error {
+	parts := strings.Split(signed, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: malformed jws", common.ErrIAPUnauthorized)
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("%w: invalid jws header: %v", common.ErrIAPUnauthorized, err)
+	}
+
+	header := struct {
+		Alg string   `json:"alg"`
+		X5C []string `json:"x5c"`
+	}{}
+
+	if err := json.Unmarshal(b, &header); err != nil {
+		return fmt.Errorf("%w: invalid jws header: %v", common.ErrIAPUnauthorized, err)
+	}
+
+	if header.Alg != "ES256" {
+		return fmt.Errorf("%w: unsupported algorithm %q", common.ErrIAPUnauthorized, header.Alg)
+	}
+
+	leaf, err := v.verifyChain(header.X5C)
+	if err != nil {
+		return err
+	}
+
+	key, ok := leaf.PublicKey.(*ecdsa.PublicKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return fmt.Errorf("%w: invalid signing key", common.ErrIAPUnauthorized)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return fmt.Errorf("%w: invalid signature", common.ErrIAPUnauthorized)
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+
+	if !ecdsa.Verify(key, hash[:], r, s) {
+		return fmt.Errorf("%w: invalid signature", common.ErrIAPUnauthorized)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("%w: invalid jws payload: %v", common.ErrIAPUnauthorized, err)
+	}
+
+	if err := json.Unmarshal(payload, p); err != nil {
+		return fmt.Errorf("%w: invalid jws payload: %v", common.ErrIAPUnauthorized, err)
+	}
+
+	return nil
+}
+
+// verifyChain verifies the leaf, intermediate and root certificates of the x5c header and returns the leaf.
+func (v *Verifier) verifyChain(x5c []string) (*x509.Certificate, error) {
+	if v.Roots == nil {
+		return nil, fmt.Errorf("%w: apple root certificates not configured", common.ErrIAPUnauthorized)
+	}
+
+	if len(x5c) < 2 {
+		return nil, fmt.Errorf("%w: incomplete certificate chain", common.ErrIAPUnauthorized)
+	}
+
+	certs := make([]*x509.Certificate, 0, len(x5c))
+
+	for _, s := range x5c {
+		der, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid certificate: %v", common.ErrIAPUnauthorized, err)
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid certificate: %v", common.ErrIAPUnauthorized, err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if !hasExtension(certs[0], oidLeaf) || !hasExtension(certs[1], oidIntermediate) {
+		return nil, fmt.Errorf("%w: not an app store certificate", common.ErrIAPUnauthorized)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+
+	opts := x509.VerifyOptions{
+		CurrentTime:   now(),
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		Roots:         v.Roots,
+	}
+
+	if _, err := certs[0].Verify(opts); err != nil {
+		return nil, fmt.Errorf("%w: %v", common.ErrIAPUnauthorized, err)
+	}
+
+	return certs[0], nil
+}
+
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+
+	return false
+}
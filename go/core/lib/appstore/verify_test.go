@@ -0,0 +1,140 @@
+package appstore
+
+import (
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"disruptive/lib/common"
+)
+
+const testBundleID = "com.example.vox"
+
+func testTransaction() Transaction {
+	return Transaction{
+		BundleID:              testBundleID,
+		Environment:           EnvironmentProduction,
+		OriginalTransactionID: "1000",
+		ProductID:             "vexels_100",
+		PurchaseDate:          time.Now().UnixMilli(),
+		Quantity:              1,
+		SignedDate:            time.Now().UnixMilli(),
+		TransactionID:         "1001",
+		Type:                  "Consumable",
+	}
+}
+
+func newTestSigner(t *testing.T) *LocalSigner {
+	t.Helper()
+
+	s, err := NewLocalSigner()
+	if err != nil {
+		t.Fatalf("NewLocalSigner: %v", err)
+	}
+
+	return s
+}
+
+func sign(t *testing.T, s *LocalSigner, payload any) string {
+	t.Helper()
+
+	signed, err := s.Sign(payload)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	return signed
+}
+
+func TestVerifyTransaction(t *testing.T) {
+	signer := newTestSigner(t)
+	verifier := NewVerifier(signer.Roots(), testBundleID)
+
+	got, err := verifier.VerifyTransaction(sign(t, signer, testTransaction()))
+	if err != nil {
+		t.Fatalf("VerifyTransaction: %v", err)
+	}
+
+	want := testTransaction()
+	if got.TransactionID != want.TransactionID || got.ProductID != want.ProductID || got.BundleID != want.BundleID {
+		t.Errorf("VerifyTransaction = %+v, want %+v", got, want)
+	}
+}
+
+func TestVerifyTransactionRejects(t *testing.T) {
+	signer := newTestSigner(t)
+	other := newTestSigner(t)
+
+	noLeafOID, err := newLocalSigner(nil, []pkix.Extension{{Id: oidIntermediate, Value: []byte{0x05, 0x00}}})
+	if err != nil {
+		t.Fatalf("newLocalSigner: %v", err)
+	}
+
+	noIntermediateOID, err := newLocalSigner([]pkix.Extension{{Id: oidLeaf, Value: []byte{0x05, 0x00}}}, nil)
+	if err != nil {
+		t.Fatalf("newLocalSigner: %v", err)
+	}
+
+	valid := sign(t, signer, testTransaction())
+	parts := strings.Split(valid, ".")
+
+	tampered := testTransaction()
+	tampered.ProductID = "vexels_10000"
+	body, _ := json.Marshal(tampered)
+	tamperedPayload := parts[0] + "." + base64.RawURLEncoding.EncodeToString(body) + "." + parts[2]
+
+	otherParts := strings.Split(sign(t, other, testTransaction()), ".")
+	wrongSignature := parts[0] + "." + parts[1] + "." + otherParts[2]
+
+	wrongBundle := testTransaction()
+	wrongBundle.BundleID = "com.example.other"
+
+	sandbox := testTransaction()
+	sandbox.Environment = EnvironmentSandbox
+
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		signed   string
+		want     string
+	}{
+		{name: "tampered payload", verifier: NewVerifier(signer.Roots(), testBundleID), signed: tamperedPayload, want: "invalid signature"},
+		{name: "wrong signature", verifier: NewVerifier(signer.Roots(), testBundleID), signed: wrongSignature, want: "invalid signature"},
+		{name: "foreign root", verifier: NewVerifier(other.Roots(), testBundleID), signed: valid, want: "unknown authority"},
+		{name: "missing leaf oid", verifier: NewVerifier(noLeafOID.Roots(), testBundleID), signed: sign(t, noLeafOID, testTransaction()), want: "not an app store certificate"},
+		{name: "missing intermediate oid", verifier: NewVerifier(noIntermediateOID.Roots(), testBundleID), signed: sign(t, noIntermediateOID, testTransaction()), want: "not an app store certificate"},
+		{name: "bundle id mismatch", verifier: NewVerifier(signer.Roots(), testBundleID), signed: sign(t, signer, wrongBundle), want: "invalid bundle id"},
+		{name: "environment mismatch", verifier: NewVerifier(signer.Roots(), testBundleID), signed: sign(t, signer, sandbox), want: "invalid environment"},
+		{
+			name:     "expired chain",
+			verifier: &Verifier{BundleID: testBundleID, Environments: []string{EnvironmentProduction}, Roots: signer.Roots(), Now: func() time.Time { return time.Now().AddDate(2, 0, 0) }},
+			signed:   valid,
+			want:     "expired",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.verifier.VerifyTransaction(tt.signed)
+			if !errors.Is(err, common.ErrIAPUnauthorized) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("VerifyTransaction error = %v, want %v: %s", err, common.ErrIAPUnauthorized, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySandboxEnvironment(t *testing.T) {
+	signer := newTestSigner(t)
+	verifier := NewVerifier(signer.Roots(), testBundleID, EnvironmentProduction, EnvironmentSandbox)
+
+	tx := testTransaction()
+	tx.Environment = EnvironmentSandbox
+
+	if _, err := verifier.VerifyTransaction(sign(t, signer, tx)); err != nil {
+		t.Errorf("VerifyTransaction: %v", err)
+	}
+}
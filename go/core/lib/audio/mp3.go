@@ -0,0 +1,122 @@
+package audio
+
+import "time"
+
+var (
+	// mp3Bitrates are the bitrates in kbps by MPEG-1 or MPEG-2/2.5, layer I, II, III and bitrate index.
+	mp3Bitrates = [2][3][15]int{
+		{
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		},
+		{
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		},
+	}
+
+	// mp3SampleRates are the sample rates by MPEG-2.5, reserved, MPEG-2, MPEG-1 and sample rate index.
+	mp3SampleRates = [4][3]int{
+		{11025, 12000, 8000},
+		{},
+		{22050, 24000, 16000},
+		{44100, 48000, 32000},
+	}
+)
+
+type mp3Header struct {
+	size       int
+	samples    int
+	sampleRate int
+	channels   int
+}
+
+// parseMP3Header parses an MPEG audio frame header. Free format bitrates are not supported.
+func parseMP3Header(b []byte) (mp3Header, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mp3Header{}, false
+	}
+
+	version := int(b[1]>>3) & 0x03
+	layer := 4 - int(b[1]>>1)&0x03
+	bitrateIndex := int(b[2] >> 4)
+	sampleRateIndex := int(b[2]>>2) & 0x03
+	padding := int(b[2]>>1) & 0x01
+
+	if version == 1 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return mp3Header{}, false
+	}
+
+	mpeg := 0
+	if version != 3 {
+		mpeg = 1
+	}
+
+	bitrate := mp3Bitrates[mpeg][layer-1][bitrateIndex] * 1000
+	sampleRate := mp3SampleRates[version][sampleRateIndex]
+
+	h := mp3Header{sampleRate: sampleRate, channels: 2}
+	if b[3]>>6 == 3 {
+		h.channels = 1
+	}
+
+	switch {
+	case layer == 1:
+		h.samples = 384
+		h.size = (12*bitrate/sampleRate + padding) * 4
+	case layer == 3 && mpeg == 1:
+		h.samples = 576
+		h.size = 72*bitrate/sampleRate + padding
+	default:
+		h.samples = 1152
+		h.size = 144*bitrate/sampleRate + padding
+	}
+
+	return h, true
+}
+
+func isMP3Frame(b []byte) bool {
+	_, ok := parseMP3Header(b)
+	return ok
+}
+
+// probeMP3 skips the ID3v2 tag and adds up the frame durations until the frames end.
+func probeMP3(b []byte, info Info) (Info, error) {
+	info.Codec = CodecMP3
+	offset := 0
+
+	if len(b) >= 10 && string(b[:3]) == "ID3" {
+		// The tag size is a syncsafe integer of 7 bits per byte.
+		offset = 10 + (int(b[6]&0x7F)<<21 | int(b[7]&0x7F)<<14 | int(b[8]&0x7F)<<7 | int(b[9]&0x7F))
+		if b[5]&0x10 != 0 {
+			offset += 10
+		}
+	}
+
+	samples := 0
+
+	for offset < len(b) {
+		h, ok := parseMP3Header(b[offset:])
+		if !ok || offset+h.size > len(b) {
+			break
+		}
+
+		if info.SampleRate == 0 {
+			info.SampleRate = h.sampleRate
+			info.Channels = h.channels
+		}
+
+		samples += h.samples
+		offset += h.size
+	}
+
+	info.HasDuration = true
+
+	if info.SampleRate > 0 {
+		info.Duration = time.Duration(samples) * time.Second / time.Duration(info.SampleRate)
+	}
+
+	return info, nil
+}
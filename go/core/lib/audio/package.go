@@ -0,0 +1,42 @@
+// Package audio inspects uploaded audio: it detects the container from its content,
+// measures the duration and finds speech in PCM audio.
+package audio
+
+import "time"
+
+// Containers detected by Probe. They match the file extensions used for user audio.
+const (
+	ContainerFLAC = "flac"
+	ContainerM4A  = "m4a"
+	ContainerMP3  = "mp3"
+	ContainerMP4  = "mp4"
+	ContainerOgg  = "ogg"
+	ContainerWAV  = "wav"
+	ContainerWebM = "webm"
+)
+
+// Codecs detected by Probe.
+const (
+	CodecFLAC   = "flac"
+	CodecMP3    = "mp3"
+	CodecOpus   = "opus"
+	CodecPCM    = "pcm"
+	CodecVorbis = "vorbis"
+)
+
+// Info describes an audio file. Duration is only measured when HasDuration is set, the
+// duration of WebM and MP4 is not known without demuxing.
+type Info struct {
+	Container     string        `json:"container"`
+	Codec         string        `json:"codec,omitempty"`
+	SampleRate    int           `json:"sample_rate,omitempty"`
+	Channels      int           `json:"channels,omitempty"`
+	BitsPerSample int           `json:"bits_per_sample,omitempty"`
+	Duration      time.Duration `json:"duration,omitempty"`
+	HasDuration   bool          `json:"-"`
+	Size          int           `json:"size"`
+
+	// dataOffset and dataSize locate the samples of a WAV file.
+	dataOffset int
+	dataSize   int
+}
@@ -0,0 +1,187 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// ErrUnknownContainer is returned when the container can not be detected from the content.
+var ErrUnknownContainer = errors.New("unknown audio container")
+
+// Probe detects the container and codec of b and measures its duration.
+func Probe(b []byte) (Info, error) {
+	info := Info{Size: len(b)}
+
+	switch {
+	case len(b) >= 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WAVE":
+		info.Container = ContainerWAV
+		return probeWAV(b, info)
+
+	case len(b) >= 4 && string(b[:4]) == "OggS":
+		info.Container = ContainerOgg
+		return probeOgg(b, info)
+
+	case len(b) >= 4 && string(b[:4]) == "fLaC":
+		info.Container = ContainerFLAC
+		return probeFLAC(b, info)
+
+	case len(b) >= 4 && bytes.Equal(b[:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		info.Container = ContainerWebM
+		return info, nil
+
+	case len(b) >= 12 && string(b[4:8]) == "ftyp":
+		info.Container = ContainerMP4
+		if string(b[8:12]) == "M4A " {
+			info.Container = ContainerM4A
+		}
+		return info, nil
+
+	case len(b) >= 3 && string(b[:3]) == "ID3", isMP3Frame(b):
+		info.Container = ContainerMP3
+		return probeMP3(b, info)
+	}
+
+	return info, ErrUnknownContainer
+}
+
+// probeWAV reads the fmt chunk and locates the data chunk. A streamed data size is the rest of the file.
+func probeWAV(b []byte, info Info) (Info, error) {
+	var byteRate int
+
+	for offset := 12; offset+8 <= len(b); {
+		id := string(b[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(b[offset+4:]))
+		offset += 8
+
+		switch id {
+		case "fmt ":
+			if size < 16 || offset+16 > len(b) {
+				return info, errors.New("invalid wav fmt chunk")
+			}
+
+			if format := binary.LittleEndian.Uint16(b[offset:]); format == 1 || format == 0xFFFE {
+				info.Codec = CodecPCM
+			}
+
+			info.Channels = int(binary.LittleEndian.Uint16(b[offset+2:]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(b[offset+4:]))
+			byteRate = int(binary.LittleEndian.Uint32(b[offset+8:]))
+			info.BitsPerSample = int(binary.LittleEndian.Uint16(b[offset+14:]))
+
+		case "data":
+			if byteRate == 0 {
+				return info, errors.New("missing wav fmt chunk")
+			}
+
+			if offset+size > len(b) {
+				size = len(b) - offset
+			}
+
+			info.dataOffset = offset
+			info.dataSize = size
+			info.Duration = time.Duration(size) * time.Second / time.Duration(byteRate)
+			info.HasDuration = true
+
+			return info, nil
+		}
+
+		// Chunks are padded to an even size.
+		offset += size + size%2
+	}
+
+	// A header without a data chunk has no audio.
+	info.HasDuration = byteRate > 0
+	return info, nil
+}
+
+// probeOgg reads the codec from the first packet and the duration from the last granule position.
+func probeOgg(b []byte, info Info) (Info, error) {
+	var (
+		granule int64
+		preSkip int64
+		rate    int64
+	)
+
+	for offset := 0; offset+27 <= len(b) && string(b[offset:offset+4]) == "OggS"; {
+		segments := int(b[offset+26])
+		if offset+27+segments > len(b) {
+			break
+		}
+
+		size := 0
+		for _, s := range b[offset+27 : offset+27+segments] {
+			size += int(s)
+		}
+
+		data := offset + 27 + segments
+		if data+size > len(b) {
+			break
+		}
+
+		packet := b[data : data+size]
+
+		switch {
+		case len(packet) >= 19 && string(packet[:8]) == "OpusHead":
+			info.Codec = CodecOpus
+			info.Channels = int(packet[9])
+			info.SampleRate = int(binary.LittleEndian.Uint32(packet[12:]))
+			preSkip = int64(binary.LittleEndian.Uint16(packet[10:]))
+			rate = 48000
+
+		case len(packet) >= 16 && string(packet[:7]) == "\x01vorbis":
+			info.Codec = CodecVorbis
+			info.Channels = int(packet[11])
+			info.SampleRate = int(binary.LittleEndian.Uint32(packet[12:]))
+			rate = int64(info.SampleRate)
+		}
+
+		// A granule position of -1 marks a page without a completed packet.
+		if g := int64(binary.LittleEndian.Uint64(b[offset+6:])); g >= 0 {
+			granule = g
+		}
+
+		offset = data + size
+	}
+
+	if rate > 0 {
+		samples := max(granule-preSkip, 0)
+		info.Duration = time.Duration(samples) * time.Second / time.Duration(rate)
+		info.HasDuration = true
+	}
+
+	return info, nil
+}
+
+// probeFLAC reads the STREAMINFO block. A total of 0 samples means the encoder did not know the length.
+func probeFLAC(b []byte, info Info) (Info, error) {
+	info.Codec = CodecFLAC
+
+	if len(b) < 8+34 || b[4]&0x7F != 0 {
+		return info, errors.New("missing flac streaminfo")
+	}
+
+	s := b[8:]
+
+	info.SampleRate = int(s[10])<<12 | int(s[11])<<4 | int(s[12])>>4
+	info.Channels = int(s[12]>>1&0x07) + 1
+	info.BitsPerSample = int(s[12]&0x01)<<4 | int(s[13]>>4) + 1
+
+	total := int64(s[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(s[14:]))
+
+	if info.SampleRate > 0 && total > 0 {
+		info.Duration = time.Duration(total) * time.Second / time.Duration(info.SampleRate)
+		info.HasDuration = true
+	}
+
+	return info, nil
+}
+
+// WAVData returns the samples of a WAV file probed by Probe.
+func WAVData(b []byte, info Info) []byte {
+	if info.Container != ContainerWAV || info.dataOffset+info.dataSize > len(b) {
+		return nil
+	}
+	return b[info.dataOffset : info.dataOffset+info.dataSize]
+}
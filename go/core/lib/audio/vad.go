@@ -0,0 +1,63 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+const (
+	// vadFrame is the duration of the frames whose energy is compared to the threshold.
+	vadFrame = 20 * time.Millisecond
+
+	// vadMinSpeech is the speech needed for audio not to be silent, so clicks and pops are ignored.
+	vadMinSpeech = 100 * time.Millisecond
+
+	// vadPadding is the silence kept around speech so word onsets and endings are not clipped.
+	vadPadding = 200 * time.Millisecond
+)
+
+// TrimSilence returns the part of signed 16-bit little-endian PCM from the first to the last frame
+// louder than thresholdDB dBFS, padded with some silence. It returns false when there is no speech.
+func TrimSilence(pcm []byte, sampleRate, channels int, thresholdDB float64) ([]byte, bool) {
+	frameSize := int(int64(sampleRate)*int64(vadFrame)/int64(time.Second)) * channels * 2
+	if frameSize == 0 {
+		return nil, false
+	}
+
+	threshold := math.Pow(10, thresholdDB/20) * math.MaxInt16
+
+	first, last, speech := -1, -1, 0
+
+	for i := 0; i+frameSize <= len(pcm); i += frameSize {
+		if rms(pcm[i:i+frameSize]) < threshold {
+			continue
+		}
+
+		if first < 0 {
+			first = i
+		}
+		last = i + frameSize
+		speech++
+	}
+
+	if time.Duration(speech)*vadFrame < vadMinSpeech {
+		return nil, false
+	}
+
+	padding := int(vadPadding/vadFrame) * frameSize
+
+	return pcm[max(first-padding, 0):min(last+padding, len(pcm)-len(pcm)%(channels*2))], true
+}
+
+func rms(pcm []byte) float64 {
+	sum := 0.0
+	n := len(pcm) / 2
+
+	for i := 0; i < n; i++ {
+		s := float64(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+		sum += s * s
+	}
+
+	return math.Sqrt(sum / float64(n))
+}
@@ -486,3 +486,32 @@ func (e ErrModeration) Is(err error) bool {
 func (e ErrModeration) Unwrap() error {
 	return e.Err
 }
+
+// ErrNoSpeech error
+type ErrNoSpeech struct {
+	Err error
+	Msg string
+	Src string
+}
+
+func (e ErrNoSpeech) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+
+	if e.Src == "" {
+		return e.Msg
+	}
+
+	return strings.Join([]string{e.Src, e.Msg}, ": ")
+}
+
+// Is interface method
+func (e ErrNoSpeech) Is(err error) bool {
+	as := ErrNoSpeech{}
+	return errors.As(err, &as)
+}
+
+func (e ErrNoSpeech) Unwrap() error {
+	return e.Err
+}
@@ -5,17 +5,22 @@ import "fmt"
 var (
 	// AudioFormatExtensions is a map containing valid formats and their file extention.
 	AudioFormatExtensions = map[string]string{
-		"mp3":           "mp3",
-		"mp3_44100":     "mp3",
-		"mp3_44100_64":  "mp3",
-		"mp3_44100_96":  "mp3",
-		"mp3_44100_128": "mp3",
-		"mp3_44100_192": "mp3",
-		"opus":          "opus",
-		"pcm_16000":     "l16",
-		"pcm_22050":     "l22",
-		"pcm_24000":     "l24",
-		"pcm_44100":     "l44",
+		"mp3":            "mp3",
+		"mp3_44100":      "mp3",
+		"mp3_44100_64":   "mp3",
+		"mp3_44100_96":   "mp3",
+		"mp3_44100_128":  "mp3",
+		"mp3_44100_192":  "mp3",
+		"ogg_opus_16000": "ogg",
+		"opus":           "opus",
+		"pcm_16000":      "l16",
+		"pcm_22050":      "l22",
+		"pcm_24000":      "l24",
+		"pcm_44100":      "l44",
+		"wav_16000":      "wav",
+		"wav_22050":      "wav",
+		"wav_24000":      "wav",
+		"wav_44100":      "wav",
 	}
 
 	// AudioExtensionContentTypes is a map containing valid formats and their content types.
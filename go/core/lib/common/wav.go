@@ -0,0 +1,43 @@
+package common
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// wavStreamSize is the RIFF and data size of a stream whose length is not known when the header is written.
+const wavStreamSize = 0xFFFFFFFF
+
+// WAVHeader returns a RIFF/WAVE header for signed 16-bit little-endian PCM with dataSize bytes of samples.
+func WAVHeader(sampleRate, channels int, dataSize uint32) []byte {
+	h := make([]byte, 44)
+
+	riffSize := uint32(wavStreamSize)
+	if dataSize != wavStreamSize {
+		riffSize = 36 + dataSize
+	}
+
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], riffSize)
+	copy(h[8:], "WAVE")
+
+	copy(h[12:], "fmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], 1)
+	binary.LittleEndian.PutUint16(h[22:], uint16(channels))
+	binary.LittleEndian.PutUint32(h[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(h[28:], uint32(sampleRate*channels*2))
+	binary.LittleEndian.PutUint16(h[32:], uint16(channels*2))
+	binary.LittleEndian.PutUint16(h[34:], 16)
+
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], dataSize)
+
+	return h
+}
+
+// WriteWAVHeader writes a RIFF/WAVE header for streamed signed 16-bit little-endian mono PCM.
+func WriteWAVHeader(w io.Writer, sampleRate int) error {
+	_, err := w.Write(WAVHeader(sampleRate, 1, wavStreamSize))
+	return err
+}
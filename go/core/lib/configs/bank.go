@@ -2,12 +2,11 @@ package configs
 
 import (
 	"context"
-	"disruptive/lib/common"
-	"disruptive/lib/firestore"
 	"errors"
 	"log/slog"
 
-	fs "cloud.google.com/go/firestore"
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
 )
 
 // Rates are the character rates and tiers
@@ -29,14 +28,14 @@ type SKUs map[string]SKU
 func GetSKUs(ctx context.Context, logCtx *slog.Logger) (SKUs, error) {
 	fid := slog.String("fid", "console.configs.GetSKUs")
 
-	collection := firestore.Client.Collection("configs")
+	collection := docstore.Client.Collection("configs")
 	if collection == nil {
 		logCtx.Warn("configs collection not found", fid)
 		return SKUs{}, common.ErrNotFound{}
 	}
 
 	var (
-		doc *fs.DocumentSnapshot
+		doc *docstore.DocumentSnapshot
 		err error
 	)
 
@@ -64,14 +63,14 @@ func GetSKUs(ctx context.Context, logCtx *slog.Logger) (SKUs, error) {
 func GetRates(ctx context.Context, logCtx *slog.Logger) (Rates, error) {
 	fid := slog.String("fid", "console.configs.GetRates")
 
-	collection := firestore.Client.Collection("configs")
+	collection := docstore.Client.Collection("configs")
 	if collection == nil {
 		logCtx.Warn("configs collection not found", fid)
 		return Rates{}, common.ErrNotFound{}
 	}
 
 	var (
-		doc *fs.DocumentSnapshot
+		doc *docstore.DocumentSnapshot
 		err error
 	)
 
@@ -94,3 +93,51 @@ func GetRates(ctx context.Context, logCtx *slog.Logger) (Rates, error) {
 
 	return r, nil
 }
+
+// LowBalanceAlerts are the low balance alert thresholds by product, then tier.
+// The default product applies to accounts without a configured product.
+type LowBalanceAlerts map[string]map[string][]int
+
+// DefaultLowBalanceProduct is the product used when an account has no configured product.
+const DefaultLowBalanceProduct = "default"
+
+// GetLowBalanceAlerts returns the low balance alert thresholds.
+func GetLowBalanceAlerts(ctx context.Context, logCtx *slog.Logger) (LowBalanceAlerts, error) {
+	fid := slog.String("fid", "console.configs.GetLowBalanceAlerts")
+
+	collection := docstore.Client.Collection("configs")
+	if collection == nil {
+		logCtx.Warn("configs collection not found", fid)
+		return LowBalanceAlerts{}, common.ErrNotFound{}
+	}
+
+	doc, err := collection.Doc("low_balance_alerts").Get(ctx)
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		if !errors.Is(err, common.ErrNotFound{}) {
+			logCtx.Error("unable to get low balance alerts config", fid, "error", err)
+		}
+		return LowBalanceAlerts{}, err
+	}
+
+	a := LowBalanceAlerts{}
+
+	if err := doc.DataTo(&a); err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to read configs low balance alerts data", fid, "error", err)
+		return LowBalanceAlerts{}, err
+	}
+
+	return a, nil
+}
+
+// Thresholds returns the thresholds of the first product configured for tier, or of the default product.
+func (a LowBalanceAlerts) Thresholds(products []string, tier string) []int {
+	for _, p := range products {
+		if t, ok := a[p][tier]; ok {
+			return t
+		}
+	}
+
+	return a[DefaultLowBalanceProduct][tier]
+}
@@ -6,7 +6,7 @@ import (
 
 	"disruptive/config"
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 )
 
 // Document is the config data.
@@ -16,7 +16,7 @@ type Document map[string]any
 func Get(ctx context.Context, logCtx *slog.Logger, document string) (Document, error) {
 	logCtx = logCtx.With("fid", "configs.Get")
 
-	doc, err := firestore.Client.Collection("configs").Doc(document).Get(ctx)
+	doc, err := docstore.Client.Collection("configs").Doc(document).Get(ctx)
 	if err != nil {
 		err = common.ConvertGRPCError(err)
 		if config.VARS.Env == "local" {
@@ -39,7 +39,7 @@ func Get(ctx context.Context, logCtx *slog.Logger, document string) (Document, e
 func Put(ctx context.Context, logCtx *slog.Logger, name string, document Document) error {
 	logCtx = logCtx.With("fid", "configs.Put")
 
-	if _, err := firestore.Client.Collection("configs").Doc(name).Set(ctx, document); err != nil {
+	if err := docstore.Client.Collection("configs").Doc(name).Set(ctx, document); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Warn("unable to set config", "error", err)
 		return err
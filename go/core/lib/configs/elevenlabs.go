@@ -6,15 +6,15 @@ import (
 
 	"disruptive/config"
 	"disruptive/lib/common"
+	"disruptive/lib/docstore"
 	"disruptive/lib/elevenlabs"
-	"disruptive/lib/firestore"
 )
 
 // SetElevenlabsVoices returns elevenlabs voices.
 func SetElevenlabsVoices(ctx context.Context, logCtx *slog.Logger) error {
 	logCtx = logCtx.With("fid", "configs.GetElevenlabsVoices")
 
-	doc, err := firestore.Client.Collection("configs").Doc("elevenlabs_voices_v2").Get(ctx)
+	doc, err := docstore.Client.Collection("configs").Doc("elevenlabs_voices_v2").Get(ctx)
 	if err != nil {
 		err = common.ConvertGRPCError(err)
 		if config.VARS.Env == "local" {
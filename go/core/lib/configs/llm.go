@@ -0,0 +1,71 @@
+package configs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"disruptive/config"
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
+)
+
+// LLMModel maps a model name to the provider serving it.
+type LLMModel struct {
+	Provider        string  `firestore:"provider" json:"provider"`
+	ID              string  `firestore:"id" json:"id"`
+	MaxPromptTokens int     `firestore:"max_prompt_tokens" json:"max_prompt_tokens"`
+	Fallback        string  `firestore:"fallback" json:"fallback"`
+	PromptCost      float64 `firestore:"prompt_cost" json:"prompt_cost"`
+	ResponseCost    float64 `firestore:"response_cost" json:"response_cost"`
+}
+
+// LLMModels is the model registry keyed by the model name used by characters.
+type LLMModels map[string]LLMModel
+
+var (
+	llmModelsMutex sync.Mutex
+	llmModels      LLMModels
+)
+
+// GetLLMModels returns the LLM model registry.
+func GetLLMModels(ctx context.Context, logCtx *slog.Logger) (LLMModels, error) {
+	fid := slog.String("fid", "console.configs.GetLLMModels")
+
+	llmModelsMutex.Lock()
+	defer llmModelsMutex.Unlock()
+
+	if llmModels != nil && !config.VARS.DisableCaches {
+		return llmModels, nil
+	}
+
+	collection := docstore.Client.Collection("configs")
+	if collection == nil {
+		logCtx.Warn("configs collection not found", fid)
+		return LLMModels{}, common.ErrNotFound{}
+	}
+
+	doc, err := collection.Doc("llm_models").Get(ctx)
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		if !errors.Is(err, common.ErrNotFound{}) {
+			logCtx.Error("unable to get llm models config", fid, "error", err)
+			return LLMModels{}, err
+		}
+	}
+
+	m := LLMModels{}
+
+	if doc.Exists() {
+		if err := doc.DataTo(&m); err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Error("unable to read configs llm models data", fid, "error", err)
+			return LLMModels{}, err
+		}
+	}
+
+	llmModels = m
+
+	return m, nil
+}
@@ -7,11 +7,9 @@ import (
 	"strings"
 	"sync"
 
-	fs "cloud.google.com/go/firestore"
-
 	"disruptive/config"
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 )
 
 // Localize contains the localization config.
@@ -38,14 +36,14 @@ func GetLocalization(ctx context.Context, logCtx *slog.Logger, version, language
 		return a.(Localize), nil
 	}
 
-	collection := firestore.Client.Collection("configs")
+	collection := docstore.Client.Collection("configs")
 	if collection == nil {
 		logCtx.Warn("configs collection not found", fid)
 		return Localize{}, common.ErrNotFound{}
 	}
 
 	var (
-		doc *fs.DocumentSnapshot
+		doc *docstore.DocumentSnapshot
 		err error
 	)
 
@@ -84,14 +82,14 @@ func GetLocalization(ctx context.Context, logCtx *slog.Logger, version, language
 func SetLocalization(ctx context.Context, logCtx *slog.Logger, localizations map[string]Localize) error {
 	fid := slog.String("fid", "vox.configs.SetLocalization")
 
-	collection := firestore.Client.Collection("configs")
+	collection := docstore.Client.Collection("configs")
 	if collection == nil {
 		logCtx.Error("configs collection not found", fid)
 		return common.ErrNotFound{}
 	}
 
 	for name, localize := range localizations {
-		if _, err := collection.Doc(name).Set(ctx, localize); err != nil {
+		if err := collection.Doc(name).Set(ctx, localize); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to set config localize document", fid, "name", name, "error", err)
 			return err
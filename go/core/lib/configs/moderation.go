@@ -0,0 +1,117 @@
+package configs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
+)
+
+// Moderation policy actions, from least to most severe.
+const (
+	ModerationAllow    = "allow"
+	ModerationSoften   = "soften"
+	ModerationNotify   = "notify"
+	ModerationBlock    = "block"
+	ModerationEscalate = "escalate"
+)
+
+var moderationActions = []string{ModerationAllow, ModerationSoften, ModerationNotify, ModerationBlock, ModerationEscalate}
+
+// ModerationRule matches a moderation when all of its set conditions match, and applies its action.
+// List conditions match when any of their values match.
+type ModerationRule struct {
+	Name              string   `firestore:"name" json:"name"`
+	Action            string   `firestore:"action" json:"action"`
+	AboveResponseAge  bool     `firestore:"above_response_age,omitempty" json:"above_response_age,omitempty"`
+	Categories        []string `firestore:"categories,omitempty" json:"categories,omitempty"`
+	Entities          []string `firestore:"entities,omitempty" json:"entities,omitempty"`
+	Intents           []string `firestore:"intents,omitempty" json:"intents,omitempty"`
+	MinAssessmentAge  int      `firestore:"min_assessment_age,omitempty" json:"min_assessment_age,omitempty"`
+	Sentiments        []string `firestore:"sentiments,omitempty" json:"sentiments,omitempty"`
+	SubjectCategories []string `firestore:"subject_categories,omitempty" json:"subject_categories,omitempty"`
+	Toxic             bool     `firestore:"toxic,omitempty" json:"toxic,omitempty"`
+}
+
+// ModerationPreset is a named list of rules for profiles whose response age is in [MinAge, MaxAge].
+type ModerationPreset struct {
+	Name   string           `firestore:"name" json:"name"`
+	MinAge int              `firestore:"min_age" json:"min_age"`
+	MaxAge int              `firestore:"max_age" json:"max_age"`
+	Rules  []ModerationRule `firestore:"rules" json:"rules"`
+}
+
+// ModerationPolicies are the moderation presets by age band.
+type ModerationPolicies struct {
+	Presets []ModerationPreset `firestore:"presets" json:"presets"`
+}
+
+// GetModerationPolicies returns the moderation policy presets.
+func GetModerationPolicies(ctx context.Context, logCtx *slog.Logger) (ModerationPolicies, error) {
+	fid := slog.String("fid", "console.configs.GetModerationPolicies")
+
+	collection := docstore.Client.Collection("configs")
+	if collection == nil {
+		logCtx.Warn("configs collection not found", fid)
+		return ModerationPolicies{}, common.ErrNotFound{}
+	}
+
+	doc, err := collection.Doc("moderation_policies").Get(ctx)
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		if !errors.Is(err, common.ErrNotFound{}) {
+			logCtx.Error("unable to get moderation policies config", fid, "error", err)
+		}
+		return ModerationPolicies{}, err
+	}
+
+	p := ModerationPolicies{}
+
+	if err := doc.DataTo(&p); err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to read configs moderation policies data", fid, "error", err)
+		return ModerationPolicies{}, err
+	}
+
+	return p, nil
+}
+
+// Preset returns the named preset, or the preset of the age band when name is empty.
+func (p ModerationPolicies) Preset(name string, age int) (ModerationPreset, bool) {
+	for _, preset := range p.Presets {
+		if name != "" && preset.Name == name {
+			return preset, true
+		}
+
+		if name == "" && age >= preset.MinAge && age <= preset.MaxAge {
+			return preset, true
+		}
+	}
+
+	return ModerationPreset{}, false
+}
+
+// ModerationSeverity returns the severity of an action, -1 when it is unknown.
+func ModerationSeverity(action string) int {
+	return slices.Index(moderationActions, action)
+}
+
+// ValidateModerationRules checks that every rule has a known action and at least one condition.
+func ValidateModerationRules(rules []ModerationRule) error {
+	for i, r := range rules {
+		if !slices.Contains(moderationActions, r.Action) {
+			return common.ErrBadRequest{Src: "moderation_policy", Msg: fmt.Sprintf("rule %d: invalid action %q", i, r.Action)}
+		}
+
+		if !r.AboveResponseAge && !r.Toxic && r.MinAssessmentAge == 0 && len(r.Categories) == 0 && len(r.Entities) == 0 &&
+			len(r.Intents) == 0 && len(r.Sentiments) == 0 && len(r.SubjectCategories) == 0 {
+			return common.ErrBadRequest{Src: "moderation_policy", Msg: fmt.Sprintf("rule %d: no conditions", i)}
+		}
+	}
+
+	return nil
+}
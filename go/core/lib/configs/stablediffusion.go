@@ -6,7 +6,7 @@ import (
 	"log/slog"
 
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 )
 
 // StableDiffusion contains the Stable Diffusion configuration.
@@ -25,7 +25,7 @@ func GetStableDiffusion(ctx context.Context, logCtx *slog.Logger) (*StableDiffus
 		return stableDiffusion, nil
 	}
 
-	collection := firestore.Client.Collection("configs")
+	collection := docstore.Client.Collection("configs")
 	if collection == nil {
 		logCtx.Warn("configs collection not found", fid)
 		return nil, common.ErrNotFound{}
@@ -0,0 +1,67 @@
+package configs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"disruptive/config"
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
+)
+
+// STTRoute lists the STT engines tried in order for a language. Timeout is in seconds per engine.
+type STTRoute struct {
+	Engines []string `firestore:"engines" json:"engines"`
+	Timeout int      `firestore:"timeout" json:"timeout"`
+}
+
+// STTRoutes maps a language, its base language or "default" to a route.
+type STTRoutes map[string]STTRoute
+
+var (
+	sttRoutesMutex sync.Mutex
+	sttRoutes      STTRoutes
+)
+
+// GetSTTRoutes returns the STT routes.
+func GetSTTRoutes(ctx context.Context, logCtx *slog.Logger) (STTRoutes, error) {
+	fid := slog.String("fid", "console.configs.GetSTTRoutes")
+
+	sttRoutesMutex.Lock()
+	defer sttRoutesMutex.Unlock()
+
+	if sttRoutes != nil && !config.VARS.DisableCaches {
+		return sttRoutes, nil
+	}
+
+	collection := docstore.Client.Collection("configs")
+	if collection == nil {
+		logCtx.Warn("configs collection not found", fid)
+		return STTRoutes{}, common.ErrNotFound{}
+	}
+
+	doc, err := collection.Doc("stt_routes").Get(ctx)
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		if !errors.Is(err, common.ErrNotFound{}) {
+			logCtx.Error("unable to get stt routes config", fid, "error", err)
+			return STTRoutes{}, err
+		}
+	}
+
+	r := STTRoutes{}
+
+	if doc.Exists() {
+		if err := doc.DataTo(&r); err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Error("unable to read configs stt routes data", fid, "error", err)
+			return STTRoutes{}, err
+		}
+	}
+
+	sttRoutes = r
+
+	return r, nil
+}
@@ -12,7 +12,6 @@ import (
 )
 
 const (
-	baseURL                  = "https://app.coqui.ai/api/v2"
 	sampleFileEndpoint       = "/samples"
 	sampleFileXTTSEndpoint   = "/samples/xtts/render/"
 	samplePromptXTTSEndpoint = "/samples/xtts/render-from-prompt/"
@@ -100,7 +99,7 @@ var (
 
 func init() {
 	Resty = resty.New().
-		SetBaseURL(baseURL).
+		SetBaseURL(config.VARS.CoquiURL).
 		SetLogger(common.LogDiscard).
 		AddRetryCondition(func(r *resty.Response, err error) bool {
 			return r.StatusCode() == http.StatusTooManyRequests
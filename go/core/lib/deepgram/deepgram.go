@@ -2,7 +2,6 @@ package deepgram
 
 import (
 	"context"
-	"disruptive/config"
 	"io"
 	"log/slog"
 	"slices"
@@ -11,6 +10,8 @@ import (
 	prerecorded "github.com/deepgram/deepgram-go-sdk/pkg/api/prerecorded/v1"
 	interfaces "github.com/deepgram/deepgram-go-sdk/pkg/client/interfaces"
 	client "github.com/deepgram/deepgram-go-sdk/pkg/client/prerecorded"
+
+	"disruptive/config"
 )
 
 var (
@@ -60,14 +61,8 @@ func init() {
 func PostTranscriptionsText(ctx context.Context, logCtx *slog.Logger, r io.Reader, language, version string) (string, string, error) {
 	fid := slog.String("fid", "deepgram.PostTranscriptionText")
 
-	if l := LanguageCountry[language]; l != "" {
-		language = l
-	}
-
-	forceWhisper := false
-	if slices.Contains(LanguagesWhisper, language) {
-		forceWhisper = true
-	}
+	language = normalizeLanguage(language)
+	forceWhisper := slices.Contains(LanguagesWhisper, language)
 
 	var options interfaces.PreRecordedTranscriptionOptions
 	d := time.Second * 10
@@ -83,13 +78,8 @@ func PostTranscriptionsText(ctx context.Context, logCtx *slog.Logger, r io.Reade
 			Punctuate:      true,
 		}
 	} else {
-		model := languageToModelV2[language]
-		if model == "nova-2" && version == "v1" {
-			model = languageToModelV1[language]
-		}
-
 		options = interfaces.PreRecordedTranscriptionOptions{
-			Model:     model,
+			Model:     languageModel(language, version),
 			Language:  language,
 			Punctuate: true,
 		}
@@ -123,3 +113,21 @@ func PostTranscriptionsText(ctx context.Context, logCtx *slog.Logger, r io.Reade
 
 	return text, detectedLanguage, nil
 }
+
+func normalizeLanguage(language string) string {
+	if l := LanguageCountry[language]; l != "" {
+		return l
+	}
+
+	return language
+}
+
+// languageModel returns the best model for the language. Version v1 keeps the models used before nova-2.
+func languageModel(language, version string) string {
+	model := languageToModelV2[language]
+	if model == "nova-2" && version == "v1" {
+		model = languageToModelV1[language]
+	}
+
+	return model
+}
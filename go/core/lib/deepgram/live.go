@@ -0,0 +1,364 @@
+package deepgram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"disruptive/config"
+	"disruptive/lib/common"
+)
+
+// Live result types.
+const (
+	LiveInterim      = "interim"
+	LiveFinal        = "final"
+	LiveUtteranceEnd = "utterance_end"
+)
+
+const (
+	liveHost = "api.deepgram.com"
+	livePath = "/v1/listen"
+
+	// liveEndpointing is the silence in milliseconds that ends speech.
+	liveEndpointing = 300
+
+	// liveUtteranceEnd is the gap in milliseconds between words that ends an utterance when endpointing misses it in noise.
+	liveUtteranceEnd = 1000
+
+	// liveCloseTimeout bounds the wait for the final results once the audio has ended.
+	liveCloseTimeout = time.Second * 5
+)
+
+// LiveResult is a transcript or endpointing event of a live transcription.
+type LiveResult struct {
+	Type        string  `json:"type"`
+	Text        string  `json:"text,omitempty"`
+	SpeechFinal bool    `json:"speech_final,omitempty"`
+	Start       float64 `json:"start"`
+	Duration    float64 `json:"duration,omitempty"`
+}
+
+// LiveOptions configures a live transcription.
+// Encoding and SampleRate are only needed for raw audio, containerized audio is detected.
+// OnResult is called from the connection reader for every result and must not block.
+type LiveOptions struct {
+	Language     string
+	Version      string
+	Encoding     string
+	SampleRate   int
+	Endpointing  int
+	UtteranceEnd int
+	OnResult     func(LiveResult)
+}
+
+// LiveTranscription streams audio to Deepgram as it arrives. Write sends audio and Close returns the final transcript.
+type LiveTranscription struct {
+	ctx      context.Context
+	conn     *websocket.Conn
+	logCtx   *slog.Logger
+	onResult func(LiveResult)
+	stop     func() bool
+
+	writeMutex sync.Mutex
+
+	mu     sync.Mutex
+	finals []string
+	err    error
+	done   chan struct{}
+}
+
+type liveMessage struct {
+	Type    string `json:"type"`
+	Channel struct {
+		Alternatives []struct {
+			Transcript string `json:"transcript"`
+		} `json:"alternatives"`
+	} `json:"channel"`
+	IsFinal     bool    `json:"is_final"`
+	SpeechFinal bool    `json:"speech_final"`
+	Start       float64 `json:"start"`
+	Duration    float64 `json:"duration"`
+	LastWordEnd float64 `json:"last_word_end"`
+	Description string  `json:"description"`
+	Message     string  `json:"message"`
+}
+
+// NewLiveTranscription opens a live transcription. Languages that need Whisper are not supported live.
+func NewLiveTranscription(ctx context.Context, logCtx *slog.Logger, options LiveOptions) (*LiveTranscription, error) {
+	fid := slog.String("fid", "deepgram.NewLiveTranscription")
+
+	language := normalizeLanguage(options.Language)
+	if language == "" || slices.Contains(LanguagesWhisper, language) {
+		logCtx.Error("language not supported by live transcription", fid, "language", options.Language)
+		return nil, common.ErrBadRequest{Msg: "language not supported by live transcription"}
+	}
+
+	u, err := liveURL(language, options)
+	if err != nil {
+		logCtx.Error("invalid deepgram host", fid, "error", err)
+		return nil, err
+	}
+
+	key := config.VARS.DeepgramKey
+	if config.VARS.DeepgramHost != "" {
+		key = config.VARS.DeepgramOnPremKey
+	}
+
+	header := http.Header{}
+	if key != "" {
+		header.Set("Authorization", "token "+key)
+	}
+
+	dialer := websocket.Dialer{HandshakeTimeout: time.Second * 10}
+
+	conn, resp, err := dialer.DialContext(ctx, u, header)
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+
+			if b, _ := io.ReadAll(resp.Body); len(b) > 0 {
+				err = convertDeepgramError(errors.New(string(b)))
+			}
+
+			if resp.StatusCode == http.StatusUnauthorized {
+				err = common.ErrUnauthorized
+			}
+		}
+
+		logCtx.Error("unable to open live transcription", fid, "error", err)
+		return nil, err
+	}
+
+	l := &LiveTranscription{
+		ctx:      ctx,
+		conn:     conn,
+		logCtx:   logCtx,
+		onResult: options.OnResult,
+		done:     make(chan struct{}),
+	}
+
+	l.stop = context.AfterFunc(ctx, func() { conn.Close() })
+
+	go l.read()
+
+	return l, nil
+}
+
+// Write sends audio to Deepgram.
+func (l *LiveTranscription) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	l.writeMutex.Lock()
+	defer l.writeMutex.Unlock()
+
+	if err := l.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close ends the audio, waits for the remaining results and returns the final transcript.
+// A canceled transcription returns the context error instead of a partial transcript.
+func (l *LiveTranscription) Close() (string, error) {
+	fid := slog.String("fid", "deepgram.LiveTranscription.Close")
+
+	defer l.stop()
+
+	l.writeMutex.Lock()
+	err := l.conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"CloseStream"}`))
+	l.writeMutex.Unlock()
+
+	if err == nil {
+		select {
+		case <-l.done:
+		case <-time.After(liveCloseTimeout):
+			l.logCtx.Warn("live transcription close timeout", fid)
+		}
+	}
+
+	l.conn.Close()
+	<-l.done
+
+	if err := l.ctx.Err(); err != nil {
+		l.logCtx.Warn("live transcription canceled", fid, "error", err)
+		return "", err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return "", l.err
+	}
+
+	return strings.Join(l.finals, " "), nil
+}
+
+func (l *LiveTranscription) read() {
+	fid := slog.String("fid", "deepgram.LiveTranscription.read")
+
+	defer close(l.done)
+
+	for {
+		_, b, err := l.conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) && !errors.Is(err, net.ErrClosed) {
+				l.setErr(err)
+			}
+			return
+		}
+
+		m := liveMessage{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			l.logCtx.Warn("unable to read live transcription message", fid, "error", err)
+			continue
+		}
+
+		switch m.Type {
+		case "Results":
+			text := ""
+			if len(m.Channel.Alternatives) > 0 {
+				text = m.Channel.Alternatives[0].Transcript
+			}
+
+			res := LiveResult{Type: LiveInterim, Text: text, SpeechFinal: m.SpeechFinal, Start: m.Start, Duration: m.Duration}
+
+			if m.IsFinal {
+				res.Type = LiveFinal
+
+				if text != "" {
+					l.mu.Lock()
+					l.finals = append(l.finals, text)
+					l.mu.Unlock()
+				}
+			}
+
+			if text != "" || m.SpeechFinal {
+				l.result(res)
+			}
+
+		case "UtteranceEnd":
+			l.result(LiveResult{Type: LiveUtteranceEnd, Start: m.LastWordEnd})
+
+		case "Error":
+			l.logCtx.Error("live transcription error", fid, "message", m.Message, "description", m.Description)
+			l.setErr(common.ErrBadGateway{Msg: m.Description})
+		}
+	}
+}
+
+func (l *LiveTranscription) result(res LiveResult) {
+	if l.onResult != nil {
+		l.onResult(res)
+	}
+}
+
+func (l *LiveTranscription) setErr(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err == nil {
+		l.err = err
+	}
+}
+
+// PostTranscriptionsTextLive streams r to a live transcription and returns the text.
+// Languages that need Whisper or detection use the prerecorded API.
+func PostTranscriptionsTextLive(ctx context.Context, logCtx *slog.Logger, r io.Reader, language, version string) (string, string, error) {
+	fid := slog.String("fid", "deepgram.PostTranscriptionsTextLive")
+
+	if l := normalizeLanguage(language); l == "" || slices.Contains(LanguagesWhisper, l) {
+		return PostTranscriptionsText(ctx, logCtx, r, language, version)
+	}
+
+	l, err := NewLiveTranscription(ctx, logCtx, LiveOptions{Language: language, Version: version})
+	if err != nil {
+		logCtx.Warn("unable to open live transcription, using prerecorded", fid, "error", err)
+		return PostTranscriptionsText(ctx, logCtx, r, language, version)
+	}
+
+	if _, err := io.Copy(l, r); err != nil {
+		l.Close()
+		logCtx.Error("unable to stream audio", fid, "error", err)
+		return "", "", err
+	}
+
+	text, err := l.Close()
+	if err != nil {
+		logCtx.Error("unable to transcribe", fid, "error", err)
+		return "", "", err
+	}
+
+	return text, "", nil
+}
+
+// liveURL honors the on-prem host, an http(s) host is served over ws(s).
+func liveURL(language string, options LiveOptions) (string, error) {
+	u := &url.URL{Scheme: "wss", Host: liveHost}
+
+	if config.VARS.DeepgramHost != "" {
+		host := config.VARS.DeepgramHost
+		if !strings.Contains(host, "://") {
+			host = "https://" + host
+		}
+
+		h, err := url.Parse(host)
+		if err != nil {
+			return "", err
+		}
+
+		u.Host = h.Host
+
+		switch h.Scheme {
+		case "http", "ws":
+			u.Scheme = "ws"
+		}
+	}
+
+	endpointing := options.Endpointing
+	if endpointing == 0 {
+		endpointing = liveEndpointing
+	}
+
+	utteranceEnd := options.UtteranceEnd
+	if utteranceEnd == 0 {
+		utteranceEnd = liveUtteranceEnd
+	}
+
+	q := url.Values{}
+	q.Set("model", languageModel(language, options.Version))
+	q.Set("language", language)
+	q.Set("punctuate", "true")
+	q.Set("interim_results", "true")
+	q.Set("endpointing", strconv.Itoa(endpointing))
+	q.Set("utterance_end_ms", strconv.Itoa(utteranceEnd))
+
+	if options.Encoding != "" {
+		q.Set("encoding", options.Encoding)
+	}
+
+	if options.SampleRate > 0 {
+		q.Set("sample_rate", strconv.Itoa(options.SampleRate))
+	}
+
+	u.Path = livePath
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
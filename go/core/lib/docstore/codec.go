@@ -0,0 +1,428 @@
+package docstore
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// encode converts a Go value into the generic form stored by the memory backend.
+// Structs are encoded with the same firestore tags used by the firestore client.
+func encode(v any) (any, error) {
+	switch v.(type) {
+	case nil:
+		return nil, nil
+	case deleteField, increment, arrayUnion:
+		return v, nil
+	}
+
+	return encodeValue(reflect.ValueOf(v))
+}
+
+func encodeValue(v reflect.Value) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	if v.Type() == timeType {
+		return v.Interface().(time.Time), nil
+	}
+
+	if v.Type() == bytesType {
+		if v.IsNil() {
+			return nil, nil
+		}
+		return append([]byte{}, v.Bytes()...), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return encode(v.Elem().Interface())
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		a := make([]any, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, err := encodeValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			a[i] = e
+		}
+		return a, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			e, err := encodeValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			m[iter.Key().String()] = e
+		}
+		return m, nil
+	case reflect.Struct:
+		m := map[string]any{}
+		if err := encodeStruct(v, m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", v.Type())
+	}
+}
+
+func encodeStruct(v reflect.Value, m map[string]any) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, omitEmpty, skip := parseTag(field)
+		if skip {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				ft, fv = ft.Elem(), fv.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				if err := encodeStruct(fv, m); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		e, err := encodeValue(fv)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+		m[name] = e
+	}
+
+	return nil
+}
+
+func parseTag(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag, ok := field.Tag.Lookup("firestore")
+	if !ok {
+		return "", false, false
+	}
+
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	for _, o := range parts[1:] {
+		if o == "omitempty" {
+			omitEmpty = true
+		}
+	}
+
+	return parts[0], omitEmpty, false
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
+}
+
+// decode copies a generic stored value into the Go value pointed to by p.
+func decode(src any, p any) error {
+	v := reflect.ValueOf(p)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return errInvalidArgument("DataTo requires a non-nil pointer")
+	}
+	return decodeValue(src, v.Elem())
+}
+
+func decodeValue(src any, dst reflect.Value) error {
+	if src == nil {
+		switch dst.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice:
+			dst.Set(reflect.Zero(dst.Type()))
+		}
+		return nil
+	}
+
+	if dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return decodeValue(src, dst.Elem())
+	}
+
+	if dst.Kind() == reflect.Interface && dst.NumMethod() == 0 {
+		dst.Set(reflect.ValueOf(copyValue(src)))
+		return nil
+	}
+
+	if dst.Type() == timeType {
+		switch t := src.(type) {
+		case time.Time:
+			dst.Set(reflect.ValueOf(t))
+		case string:
+			tm, err := time.Parse(time.RFC3339Nano, t)
+			if err != nil {
+				return err
+			}
+			dst.Set(reflect.ValueOf(tm))
+		default:
+			return fmt.Errorf("cannot decode %T into time.Time", src)
+		}
+		return nil
+	}
+
+	if dst.Type() == bytesType {
+		switch t := src.(type) {
+		case []byte:
+			dst.SetBytes(append([]byte{}, t...))
+		case string:
+			b, err := base64.StdEncoding.DecodeString(t)
+			if err != nil {
+				return err
+			}
+			dst.SetBytes(b)
+		default:
+			return fmt.Errorf("cannot decode %T into []byte", src)
+		}
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return fmt.Errorf("cannot decode %T into bool", src)
+		}
+		dst.SetBool(b)
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			return fmt.Errorf("cannot decode %T into string", src)
+		}
+		dst.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toFloat(src)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("cannot decode %v into %s", src, dst.Type())
+		}
+		dst.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := toFloat(src)
+		if !ok || n < 0 || n != math.Trunc(n) {
+			return fmt.Errorf("cannot decode %v into %s", src, dst.Type())
+		}
+		dst.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		n, ok := toFloat(src)
+		if !ok {
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		dst.SetFloat(n)
+	case reflect.Slice:
+		a, ok := src.([]any)
+		if !ok {
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		s := reflect.MakeSlice(dst.Type(), len(a), len(a))
+		for i, e := range a {
+			if err := decodeValue(e, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+	case reflect.Array:
+		a, ok := src.([]any)
+		if !ok {
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		for i := 0; i < dst.Len() && i < len(a); i++ {
+			if err := decodeValue(a[i], dst.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m, ok := src.(map[string]any)
+		if !ok {
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		if dst.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", dst.Type().Key())
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m)))
+		}
+		for k, e := range m {
+			ev := reflect.New(dst.Type().Elem()).Elem()
+			if err := decodeValue(e, ev); err != nil {
+				return err
+			}
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), ev)
+		}
+	case reflect.Struct:
+		m, ok := src.(map[string]any)
+		if !ok {
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		return decodeStruct(m, dst)
+	default:
+		return fmt.Errorf("unsupported type %s", dst.Type())
+	}
+
+	return nil
+}
+
+func decodeStruct(m map[string]any, dst reflect.Value) error {
+	t := dst.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, skip := parseTag(field)
+		if skip {
+			continue
+		}
+
+		fv := dst.Field(i)
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				if field.Type.Kind() == reflect.Pointer {
+					if !field.IsExported() {
+						continue
+					}
+					if fv.IsNil() {
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+				if err := decodeStruct(m, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		e, ok := m[name]
+		if !ok {
+			continue
+		}
+
+		if err := decodeValue(e, fv); err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// copyValue deep copies a generic stored value.
+func copyValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(t))
+		for k, e := range t {
+			m[k] = copyValue(e)
+		}
+		return m
+	case []any:
+		a := make([]any, len(t))
+		for i, e := range t {
+			a[i] = copyValue(e)
+		}
+		return a
+	case []byte:
+		return append([]byte{}, t...)
+	default:
+		return v
+	}
+}
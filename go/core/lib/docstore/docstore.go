@@ -0,0 +1,445 @@
+package docstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Backend is implemented by every document store.
+type Backend interface {
+	Get(ctx context.Context, collection, id string) (*DocumentSnapshot, error)
+	Create(ctx context.Context, collection, id string, data any) error
+	Set(ctx context.Context, collection, id string, data any, merge bool) error
+	Update(ctx context.Context, collection, id string, updates []Update) error
+	Delete(ctx context.Context, collection, id string) error
+	Query(ctx context.Context, collection string, spec QuerySpec) ([]*DocumentSnapshot, error)
+	RunTransaction(ctx context.Context, f func(context.Context, TransactionBackend) error) error
+	Close() error
+}
+
+// TransactionBackend is the document access of a backend transaction.
+type TransactionBackend interface {
+	Get(collection, id string) (*DocumentSnapshot, error)
+	Create(collection, id string, data any) error
+	Set(collection, id string, data any, merge bool) error
+	Update(collection, id string, updates []Update) error
+	Delete(collection, id string) error
+}
+
+// Store is the shared document store used by the vox packages.
+type Store struct {
+	backend Backend
+}
+
+// New wraps a backend.
+func New(backend Backend) *Store {
+	return &Store{backend: backend}
+}
+
+// Close releases the backend.
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.backend.Close()
+}
+
+// RunTransaction runs f in a transaction. The writes are applied atomically when f returns nil and
+// discarded otherwise. f may be retried on contention, so it must not have side effects outside tx.
+// All reads must happen before the writes.
+func (s *Store) RunTransaction(ctx context.Context, f func(context.Context, *Transaction) error) error {
+	if s == nil {
+		return errUnavailable()
+	}
+
+	return s.backend.RunTransaction(ctx, func(ctx context.Context, tx TransactionBackend) error {
+		return f(ctx, &Transaction{tx: tx})
+	})
+}
+
+// Transaction reads and writes documents in a transaction.
+type Transaction struct {
+	tx TransactionBackend
+}
+
+// Get reads the document. A missing document returns a snapshot that does not exist and a NotFound error.
+func (t *Transaction) Get(d *DocumentRef) (*DocumentSnapshot, error) {
+	if d == nil {
+		return &DocumentSnapshot{}, errUnavailable()
+	}
+
+	doc, err := t.tx.Get(d.Parent.Path, d.ID)
+	if doc == nil {
+		doc = &DocumentSnapshot{}
+	}
+	doc.Ref = d
+	return doc, err
+}
+
+// Create creates the document and fails if it already exists.
+func (t *Transaction) Create(d *DocumentRef, data any) error {
+	if d == nil {
+		return errUnavailable()
+	}
+
+	return t.tx.Create(d.Parent.Path, d.ID, data)
+}
+
+// Set replaces the document, or merges into it when MergeAll is passed.
+func (t *Transaction) Set(d *DocumentRef, data any, opts ...SetOption) error {
+	if d == nil {
+		return errUnavailable()
+	}
+
+	merge := false
+	for _, o := range opts {
+		if o == MergeAll {
+			merge = true
+		}
+	}
+	return t.tx.Set(d.Parent.Path, d.ID, data, merge)
+}
+
+// Update applies field updates to an existing document.
+func (t *Transaction) Update(d *DocumentRef, updates []Update) error {
+	if d == nil {
+		return errUnavailable()
+	}
+
+	return t.tx.Update(d.Parent.Path, d.ID, updates)
+}
+
+// Delete removes the document.
+func (t *Transaction) Delete(d *DocumentRef) error {
+	if d == nil {
+		return errUnavailable()
+	}
+
+	return t.tx.Delete(d.Parent.Path, d.ID)
+}
+
+// Collection returns a collection reference or nil if the path is not a collection path.
+func (s *Store) Collection(path string) *CollectionRef {
+	if s == nil || path == "" {
+		return nil
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts)%2 == 0 {
+		return nil
+	}
+
+	for _, p := range parts {
+		if p == "" {
+			return nil
+		}
+	}
+
+	return &CollectionRef{ID: parts[len(parts)-1], Path: path, store: s}
+}
+
+// CollectionRef is a reference to a collection.
+type CollectionRef struct {
+	ID    string
+	Path  string
+	store *Store
+}
+
+// Doc returns a document reference.
+func (c *CollectionRef) Doc(id string) *DocumentRef {
+	if c == nil || id == "" || strings.Contains(id, "/") {
+		return nil
+	}
+	return &DocumentRef{ID: id, Path: c.Path + "/" + id, Parent: c}
+}
+
+// Where returns a filtered query.
+func (c *CollectionRef) Where(path, op string, value any) *Query {
+	return (&Query{collection: c}).Where(path, op, value)
+}
+
+// OrderBy returns a sorted query.
+func (c *CollectionRef) OrderBy(path string, dir Direction) *Query {
+	return (&Query{collection: c}).OrderBy(path, dir)
+}
+
+// Documents returns all documents in the collection.
+func (c *CollectionRef) Documents(ctx context.Context) *DocumentIterator {
+	return (&Query{collection: c}).Documents(ctx)
+}
+
+// DocumentRef is a reference to a document.
+type DocumentRef struct {
+	ID     string
+	Path   string
+	Parent *CollectionRef
+}
+
+// Get reads the document. A missing document returns a snapshot that does not exist and a NotFound error.
+func (d *DocumentRef) Get(ctx context.Context) (*DocumentSnapshot, error) {
+	if d == nil {
+		return &DocumentSnapshot{}, errUnavailable()
+	}
+
+	doc, err := d.Parent.store.backend.Get(ctx, d.Parent.Path, d.ID)
+	if doc == nil {
+		doc = &DocumentSnapshot{}
+	}
+	doc.Ref = d
+	return doc, err
+}
+
+// Create creates the document and fails if it already exists.
+func (d *DocumentRef) Create(ctx context.Context, data any) error {
+	if d == nil {
+		return errUnavailable()
+	}
+
+	return d.Parent.store.backend.Create(ctx, d.Parent.Path, d.ID, data)
+}
+
+// Set replaces the document, or merges into it when MergeAll is passed.
+func (d *DocumentRef) Set(ctx context.Context, data any, opts ...SetOption) error {
+	if d == nil {
+		return errUnavailable()
+	}
+
+	merge := false
+	for _, o := range opts {
+		if o == MergeAll {
+			merge = true
+		}
+	}
+	return d.Parent.store.backend.Set(ctx, d.Parent.Path, d.ID, data, merge)
+}
+
+// Update applies field updates to an existing document.
+func (d *DocumentRef) Update(ctx context.Context, updates []Update) error {
+	if d == nil {
+		return errUnavailable()
+	}
+
+	return d.Parent.store.backend.Update(ctx, d.Parent.Path, d.ID, updates)
+}
+
+// Delete removes the document. Deleting a missing document is not an error.
+func (d *DocumentRef) Delete(ctx context.Context) error {
+	if d == nil {
+		return errUnavailable()
+	}
+
+	return d.Parent.store.backend.Delete(ctx, d.Parent.Path, d.ID)
+}
+
+// DocumentSnapshot is the result of a document read.
+type DocumentSnapshot struct {
+	Ref    *DocumentRef
+	exists bool
+	dataTo func(p any) error
+}
+
+// NewDocumentSnapshot is used by backends to build a snapshot.
+func NewDocumentSnapshot(id string, exists bool, dataTo func(p any) error) *DocumentSnapshot {
+	return &DocumentSnapshot{Ref: &DocumentRef{ID: id}, exists: exists, dataTo: dataTo}
+}
+
+// Exists reports whether the document exists.
+func (d *DocumentSnapshot) Exists() bool {
+	return d != nil && d.exists
+}
+
+// DataTo decodes the document into p.
+func (d *DocumentSnapshot) DataTo(p any) error {
+	if !d.Exists() || d.dataTo == nil {
+		return errNotFound("document does not exist")
+	}
+	return d.dataTo(p)
+}
+
+func errNotFound(format string, a ...any) error {
+	return status.Error(codes.NotFound, fmt.Sprintf(format, a...))
+}
+
+// errUnavailable is returned when the store or the reference is not set.
+func errUnavailable() error {
+	return status.Error(codes.Unavailable, "document store unavailable")
+}
+
+func errAlreadyExists(format string, a ...any) error {
+	return status.Error(codes.AlreadyExists, fmt.Sprintf(format, a...))
+}
+
+func errInvalidArgument(format string, a ...any) error {
+	return status.Error(codes.InvalidArgument, fmt.Sprintf(format, a...))
+}
+
+// Filter is a single query condition.
+type Filter struct {
+	Path  string
+	Op    string
+	Value any
+}
+
+// Direction is the sort direction of a query order.
+type Direction int
+
+// Query sort directions.
+const (
+	Asc Direction = iota
+	Desc
+)
+
+// DocumentID orders a query by the document ID.
+const DocumentID = "__name__"
+
+// Order is a single query sort order.
+type Order struct {
+	Path      string
+	Direction Direction
+}
+
+// QuerySpec contains the filters, sort orders, start cursor and limit of a query.
+// StartAfter has one value per order. A limit of 0 returns every document.
+type QuerySpec struct {
+	Filters    []Filter
+	Orders     []Order
+	StartAfter []any
+	Limit      int
+}
+
+// Query is a filtered collection read.
+type Query struct {
+	collection *CollectionRef
+	spec       QuerySpec
+}
+
+// clone copies the query so the builder methods do not share slices.
+func (q *Query) clone() *Query {
+	spec := q.spec
+	spec.Filters = append([]Filter{}, q.spec.Filters...)
+	spec.Orders = append([]Order{}, q.spec.Orders...)
+	spec.StartAfter = append([]any{}, q.spec.StartAfter...)
+	return &Query{collection: q.collection, spec: spec}
+}
+
+// Where adds a filter to the query.
+func (q *Query) Where(path, op string, value any) *Query {
+	c := q.clone()
+	c.spec.Filters = append(c.spec.Filters, Filter{Path: path, Op: op, Value: value})
+	return c
+}
+
+// OrderBy adds a sort order to the query. Documents without the field are not returned.
+func (q *Query) OrderBy(path string, dir Direction) *Query {
+	c := q.clone()
+	c.spec.Orders = append(c.spec.Orders, Order{Path: path, Direction: dir})
+	return c
+}
+
+// StartAfter starts the results after the document with the given order values.
+func (q *Query) StartAfter(values ...any) *Query {
+	c := q.clone()
+	c.spec.StartAfter = values
+	return c
+}
+
+// Limit returns at most n documents.
+func (q *Query) Limit(n int) *Query {
+	c := q.clone()
+	c.spec.Limit = n
+	return c
+}
+
+// Documents runs the query.
+func (q *Query) Documents(ctx context.Context) *DocumentIterator {
+	if q.collection == nil {
+		return &DocumentIterator{err: errUnavailable()}
+	}
+
+	if len(q.spec.StartAfter) > len(q.spec.Orders) {
+		return &DocumentIterator{err: errInvalidArgument("too many cursor values")}
+	}
+
+	docs, err := q.collection.store.backend.Query(ctx, q.collection.Path, q.spec)
+	for _, doc := range docs {
+		doc.Ref = q.collection.Doc(doc.Ref.ID)
+	}
+	return &DocumentIterator{docs: docs, err: err}
+}
+
+// DocumentIterator iterates over query results.
+type DocumentIterator struct {
+	docs []*DocumentSnapshot
+	err  error
+}
+
+// Next returns the next document or iterator.Done.
+func (it *DocumentIterator) Next() (*DocumentSnapshot, error) {
+	if it.err != nil {
+		return nil, it.err
+	}
+
+	if len(it.docs) == 0 {
+		return nil, iterator.Done
+	}
+
+	doc := it.docs[0]
+	it.docs = it.docs[1:]
+	return doc, nil
+}
+
+// GetAll returns all remaining documents.
+func (it *DocumentIterator) GetAll() ([]*DocumentSnapshot, error) {
+	if it.err != nil {
+		return nil, it.err
+	}
+
+	docs := it.docs
+	it.docs = nil
+	return docs, nil
+}
+
+// Stop releases the iterator.
+func (it *DocumentIterator) Stop() {
+	it.docs = nil
+}
+
+// Update is a single field update. Nested fields are separated with dots.
+type Update struct {
+	Path  string
+	Value any
+}
+
+// SetOption changes the behavior of Set.
+type SetOption int
+
+// MergeAll merges the data into the existing document instead of replacing it.
+const MergeAll SetOption = 1
+
+type deleteField struct{}
+
+type increment struct {
+	n any
+}
+
+type arrayUnion struct {
+	elems []any
+}
+
+// Delete removes a field when used as an update value.
+var Delete = deleteField{}
+
+// Increment adds n to a numeric field when used as an update value.
+func Increment(n any) any {
+	return increment{n: n}
+}
+
+// ArrayUnion adds elements not already present to an array field when used as an update value.
+func ArrayUnion(elems ...any) any {
+	return arrayUnion{elems: elems}
+}
@@ -0,0 +1,177 @@
+package docstore
+
+import (
+	"context"
+
+	fs "cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type firestoreBackend struct {
+	client *fs.Client
+}
+
+// NewFirestore returns a backend that uses a firestore client.
+func NewFirestore(client *fs.Client) Backend {
+	return &firestoreBackend{client: client}
+}
+
+func (b *firestoreBackend) Close() error {
+	return b.client.Close()
+}
+
+func (b *firestoreBackend) Get(ctx context.Context, collection, id string) (*DocumentSnapshot, error) {
+	doc, err := b.client.Collection(collection).Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return NewDocumentSnapshot(id, false, nil), err
+		}
+		return nil, err
+	}
+
+	return NewDocumentSnapshot(id, doc.Exists(), doc.DataTo), nil
+}
+
+func (b *firestoreBackend) Create(ctx context.Context, collection, id string, data any) error {
+	_, err := b.client.Collection(collection).Doc(id).Create(ctx, toFirestoreValue(data))
+	return err
+}
+
+func (b *firestoreBackend) Set(ctx context.Context, collection, id string, data any, merge bool) error {
+	var err error
+
+	if merge {
+		_, err = b.client.Collection(collection).Doc(id).Set(ctx, toFirestoreValue(data), fs.MergeAll)
+	} else {
+		_, err = b.client.Collection(collection).Doc(id).Set(ctx, toFirestoreValue(data))
+	}
+
+	return err
+}
+
+func (b *firestoreBackend) Update(ctx context.Context, collection, id string, updates []Update) error {
+	u := make([]fs.Update, 0, len(updates))
+	for _, update := range updates {
+		u = append(u, fs.Update{Path: update.Path, Value: toFirestoreValue(update.Value)})
+	}
+
+	_, err := b.client.Collection(collection).Doc(id).Update(ctx, u)
+	return err
+}
+
+func (b *firestoreBackend) Delete(ctx context.Context, collection, id string) error {
+	_, err := b.client.Collection(collection).Doc(id).Delete(ctx)
+	return err
+}
+
+func (b *firestoreBackend) Query(ctx context.Context, collection string, spec QuerySpec) ([]*DocumentSnapshot, error) {
+	query := b.client.Collection(collection).Query
+	for _, f := range spec.Filters {
+		query = query.Where(f.Path, f.Op, toFirestoreValue(f.Value))
+	}
+
+	for _, o := range spec.Orders {
+		dir := fs.Asc
+		if o.Direction == Desc {
+			dir = fs.Desc
+		}
+		query = query.OrderBy(o.Path, dir)
+	}
+
+	if len(spec.StartAfter) > 0 {
+		query = query.StartAfter(spec.StartAfter...)
+	}
+
+	if spec.Limit > 0 {
+		query = query.Limit(spec.Limit)
+	}
+
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	docs := []*DocumentSnapshot{}
+
+	for {
+		doc, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+
+		docs = append(docs, NewDocumentSnapshot(doc.Ref.ID, true, doc.DataTo))
+	}
+
+	return docs, nil
+}
+
+func (b *firestoreBackend) RunTransaction(ctx context.Context, f func(context.Context, TransactionBackend) error) error {
+	return b.client.RunTransaction(ctx, func(ctx context.Context, tx *fs.Transaction) error {
+		return f(ctx, &firestoreTransaction{client: b.client, tx: tx})
+	})
+}
+
+type firestoreTransaction struct {
+	client *fs.Client
+	tx     *fs.Transaction
+}
+
+func (t *firestoreTransaction) Get(collection, id string) (*DocumentSnapshot, error) {
+	doc, err := t.tx.Get(t.client.Collection(collection).Doc(id))
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return NewDocumentSnapshot(id, false, nil), err
+		}
+		return nil, err
+	}
+
+	return NewDocumentSnapshot(id, doc.Exists(), doc.DataTo), nil
+}
+
+func (t *firestoreTransaction) Create(collection, id string, data any) error {
+	return t.tx.Create(t.client.Collection(collection).Doc(id), toFirestoreValue(data))
+}
+
+func (t *firestoreTransaction) Set(collection, id string, data any, merge bool) error {
+	if merge {
+		return t.tx.Set(t.client.Collection(collection).Doc(id), toFirestoreValue(data), fs.MergeAll)
+	}
+
+	return t.tx.Set(t.client.Collection(collection).Doc(id), toFirestoreValue(data))
+}
+
+func (t *firestoreTransaction) Update(collection, id string, updates []Update) error {
+	u := make([]fs.Update, 0, len(updates))
+	for _, update := range updates {
+		u = append(u, fs.Update{Path: update.Path, Value: toFirestoreValue(update.Value)})
+	}
+
+	return t.tx.Update(t.client.Collection(collection).Doc(id), u)
+}
+
+func (t *firestoreTransaction) Delete(collection, id string) error {
+	return t.tx.Delete(t.client.Collection(collection).Doc(id))
+}
+
+// toFirestoreValue converts store sentinels, including those nested in maps, into firestore sentinels.
+func toFirestoreValue(v any) any {
+	switch t := v.(type) {
+	case deleteField:
+		return fs.Delete
+	case increment:
+		return fs.Increment(t.n)
+	case arrayUnion:
+		return fs.ArrayUnion(t.elems...)
+	case map[string]any:
+		m := make(map[string]any, len(t))
+		for k, e := range t {
+			m[k] = toFirestoreValue(e)
+		}
+		return m
+	default:
+		return v
+	}
+}
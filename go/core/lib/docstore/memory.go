@@ -0,0 +1,727 @@
+package docstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryBackend struct {
+	sync.Mutex
+	path        string
+	collections map[string]map[string]map[string]any
+}
+
+// NewMemory returns an in-memory backend. If path is not empty the documents are loaded from
+// and saved to that JSON file so the data survives restarts.
+func NewMemory(path string) (Backend, error) {
+	b := &memoryBackend{
+		path:        path,
+		collections: map[string]map[string]map[string]any{},
+	}
+
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return b, nil
+		}
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return b, nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	raw := map[string]map[string]map[string]any{}
+	if err := d.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	for c, docs := range raw {
+		b.collections[c] = make(map[string]map[string]any, len(docs))
+		for id, doc := range docs {
+			b.collections[c][id] = fromJSON(doc).(map[string]any)
+		}
+	}
+
+	return b, nil
+}
+
+func (b *memoryBackend) Close() error {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.save()
+}
+
+func (b *memoryBackend) Get(_ context.Context, collection, id string) (*DocumentSnapshot, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	return getDoc(b, collection, id)
+}
+
+func (b *memoryBackend) Create(_ context.Context, collection, id string, data any) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if err := createDoc(b, collection, id, data); err != nil {
+		return err
+	}
+
+	return b.save()
+}
+
+func (b *memoryBackend) Set(_ context.Context, collection, id string, data any, merge bool) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if err := setDoc(b, collection, id, data, merge); err != nil {
+		return err
+	}
+
+	return b.save()
+}
+
+func (b *memoryBackend) Update(_ context.Context, collection, id string, updates []Update) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if err := updateDoc(b, collection, id, updates); err != nil {
+		return err
+	}
+
+	return b.save()
+}
+
+func (b *memoryBackend) Delete(_ context.Context, collection, id string) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if _, ok := b.lookup(collection, id); !ok {
+		return nil
+	}
+
+	b.store(collection, id, nil)
+	return b.save()
+}
+
+// RunTransaction holds the store lock for the whole transaction, so transactions never conflict.
+// The writes are staged and only applied when f succeeds. f must not use the store outside tx.
+func (b *memoryBackend) RunTransaction(ctx context.Context, f func(context.Context, TransactionBackend) error) error {
+	b.Lock()
+	defer b.Unlock()
+
+	tx := &memoryTransaction{b: b, writes: map[string]map[string]map[string]any{}}
+
+	if err := f(ctx, tx); err != nil {
+		return err
+	}
+
+	for collection, docs := range tx.writes {
+		for id, doc := range docs {
+			b.store(collection, id, doc)
+		}
+	}
+
+	return b.save()
+}
+
+func (b *memoryBackend) lookup(collection, id string) (map[string]any, bool) {
+	doc, ok := b.collections[collection][id]
+	return doc, ok
+}
+
+// store replaces the document, a nil document deletes it.
+func (b *memoryBackend) store(collection, id string, doc map[string]any) {
+	if doc == nil {
+		delete(b.collections[collection], id)
+		return
+	}
+
+	if b.collections[collection] == nil {
+		b.collections[collection] = map[string]map[string]any{}
+	}
+
+	b.collections[collection][id] = doc
+}
+
+// memoryTransaction stages writes over the committed documents.
+type memoryTransaction struct {
+	b      *memoryBackend
+	writes map[string]map[string]map[string]any
+}
+
+func (t *memoryTransaction) lookup(collection, id string) (map[string]any, bool) {
+	if doc, ok := t.writes[collection][id]; ok {
+		return doc, doc != nil
+	}
+
+	return t.b.lookup(collection, id)
+}
+
+func (t *memoryTransaction) store(collection, id string, doc map[string]any) {
+	if t.writes[collection] == nil {
+		t.writes[collection] = map[string]map[string]any{}
+	}
+
+	t.writes[collection][id] = doc
+}
+
+func (t *memoryTransaction) Get(collection, id string) (*DocumentSnapshot, error) {
+	return getDoc(t, collection, id)
+}
+
+func (t *memoryTransaction) Create(collection, id string, data any) error {
+	return createDoc(t, collection, id, data)
+}
+
+func (t *memoryTransaction) Set(collection, id string, data any, merge bool) error {
+	return setDoc(t, collection, id, data, merge)
+}
+
+func (t *memoryTransaction) Update(collection, id string, updates []Update) error {
+	return updateDoc(t, collection, id, updates)
+}
+
+func (t *memoryTransaction) Delete(collection, id string) error {
+	t.store(collection, id, nil)
+	return nil
+}
+
+// memoryDocs is the committed store or a transaction view of it.
+type memoryDocs interface {
+	lookup(collection, id string) (map[string]any, bool)
+	store(collection, id string, doc map[string]any)
+}
+
+func getDoc(m memoryDocs, collection, id string) (*DocumentSnapshot, error) {
+	doc, ok := m.lookup(collection, id)
+	if !ok {
+		return NewDocumentSnapshot(id, false, nil), errNotFound("%s/%s not found", collection, id)
+	}
+
+	return snapshot(id, doc), nil
+}
+
+func createDoc(m memoryDocs, collection, id string, data any) error {
+	if _, ok := m.lookup(collection, id); ok {
+		return errAlreadyExists("%s/%s already exists", collection, id)
+	}
+
+	return setDoc(m, collection, id, data, false)
+}
+
+func updateDoc(m memoryDocs, collection, id string, updates []Update) error {
+	doc, ok := m.lookup(collection, id)
+	if !ok {
+		return errNotFound("%s/%s not found", collection, id)
+	}
+
+	doc = copyValue(doc).(map[string]any)
+
+	for _, u := range updates {
+		if u.Path == "" {
+			return errInvalidArgument("empty update path")
+		}
+
+		v, err := encode(u.Value)
+		if err != nil {
+			return errInvalidArgument("%s: %v", u.Path, err)
+		}
+
+		if err := applyField(doc, strings.Split(u.Path, "."), v); err != nil {
+			return err
+		}
+	}
+
+	m.store(collection, id, doc)
+	return nil
+}
+
+func (b *memoryBackend) Query(_ context.Context, collection string, spec QuerySpec) ([]*DocumentSnapshot, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	encoded := make([]Filter, 0, len(spec.Filters))
+	for _, f := range spec.Filters {
+		v, err := encode(f.Value)
+		if err != nil {
+			return nil, errInvalidArgument("%s: %v", f.Path, err)
+		}
+		encoded = append(encoded, Filter{Path: f.Path, Op: f.Op, Value: v})
+	}
+
+	cursor := make([]any, 0, len(spec.StartAfter))
+	for _, c := range spec.StartAfter {
+		v, err := encode(c)
+		if err != nil {
+			return nil, errInvalidArgument("cursor: %v", err)
+		}
+		cursor = append(cursor, v)
+	}
+
+	ids := make([]string, 0, len(b.collections[collection]))
+	for id := range b.collections[collection] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	type match struct {
+		id     string
+		doc    map[string]any
+		values []any
+	}
+
+	matches := []match{}
+
+	for _, id := range ids {
+		doc := b.collections[collection][id]
+
+		ok, err := matchFilters(doc, encoded)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		// As in firestore, documents without an order field are not returned.
+		values, ok := orderValues(id, doc, spec.Orders)
+		if !ok {
+			continue
+		}
+
+		matches = append(matches, match{id: id, doc: doc, values: values})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return compareOrder(matches[i].values, matches[j].values, spec.Orders) < 0
+	})
+
+	docs := []*DocumentSnapshot{}
+
+	for _, m := range matches {
+		if len(cursor) > 0 && compareOrder(m.values[:len(cursor)], cursor, spec.Orders) <= 0 {
+			continue
+		}
+
+		docs = append(docs, snapshot(m.id, m.doc))
+
+		if spec.Limit > 0 && len(docs) == spec.Limit {
+			break
+		}
+	}
+
+	return docs, nil
+}
+
+func matchFilters(doc map[string]any, filters []Filter) (bool, error) {
+	for _, f := range filters {
+		ok, err := matchFilter(doc, f)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// orderValues returns the values of the order fields of a document.
+func orderValues(id string, doc map[string]any, orders []Order) ([]any, bool) {
+	values := make([]any, 0, len(orders))
+
+	for _, o := range orders {
+		if o.Path == DocumentID {
+			values = append(values, id)
+			continue
+		}
+
+		v, ok := getField(doc, o.Path)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, v)
+	}
+
+	return values, true
+}
+
+// compareOrder compares order values field by field in the direction of each order.
+func compareOrder(a, b []any, orders []Order) int {
+	for i := range b {
+		c := compareValues(a[i], b[i])
+		if c == -1 && compareValues(b[i], a[i]) == -1 {
+			// Values of different kinds are ordered by kind.
+			c = strings.Compare(fmt.Sprintf("%T", a[i]), fmt.Sprintf("%T", b[i]))
+		}
+
+		if orders[i].Direction == Desc {
+			c = -c
+		}
+
+		if c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+func snapshot(id string, doc map[string]any) *DocumentSnapshot {
+	data := copyValue(doc)
+	return NewDocumentSnapshot(id, true, func(p any) error {
+		return decode(data, p)
+	})
+}
+
+func setDoc(m memoryDocs, collection, id string, data any, merge bool) error {
+	v, err := encode(data)
+	if err != nil {
+		return errInvalidArgument("%s/%s: %v", collection, id, err)
+	}
+
+	fields, ok := v.(map[string]any)
+	if !ok {
+		return errInvalidArgument("%s/%s: document data must be a map or struct", collection, id)
+	}
+
+	doc := map[string]any{}
+	if existing, ok := m.lookup(collection, id); ok && merge {
+		doc = copyValue(existing).(map[string]any)
+	}
+
+	if err := mergeFields(doc, fields); err != nil {
+		return err
+	}
+
+	m.store(collection, id, doc)
+	return nil
+}
+
+// mergeFields merges src into dst recursively, applying sentinel values.
+func mergeFields(dst, src map[string]any) error {
+	for k, v := range src {
+		if sm, ok := v.(map[string]any); ok {
+			dm, ok := dst[k].(map[string]any)
+			if !ok {
+				dm = map[string]any{}
+			}
+			if err := mergeFields(dm, sm); err != nil {
+				return err
+			}
+			dst[k] = dm
+			continue
+		}
+
+		if err := applyField(dst, []string{k}, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyField sets the value at a nested field path, creating intermediate maps.
+func applyField(doc map[string]any, path []string, v any) error {
+	m := doc
+	for _, p := range path[:len(path)-1] {
+		next, ok := m[p].(map[string]any)
+		if !ok {
+			if _, isDelete := v.(deleteField); isDelete {
+				return nil
+			}
+			next = map[string]any{}
+			m[p] = next
+		}
+		m = next
+	}
+
+	key := path[len(path)-1]
+
+	switch t := v.(type) {
+	case deleteField:
+		delete(m, key)
+	case increment:
+		n, err := encode(t.n)
+		if err != nil {
+			return errInvalidArgument("%s: %v", strings.Join(path, "."), err)
+		}
+		m[key] = addNumbers(m[key], n)
+	case arrayUnion:
+		a, _ := m[key].([]any)
+		a = append([]any{}, a...)
+		for _, e := range t.elems {
+			ev, err := encode(e)
+			if err != nil {
+				return errInvalidArgument("%s: %v", strings.Join(path, "."), err)
+			}
+			found := false
+			for _, existing := range a {
+				if compareValues(existing, ev) == 0 {
+					found = true
+					break
+				}
+			}
+			if !found {
+				a = append(a, ev)
+			}
+		}
+		m[key] = a
+	default:
+		m[key] = v
+	}
+
+	return nil
+}
+
+// addNumbers follows firestore increment rules: a missing or non-numeric field is replaced by n.
+func addNumbers(current, n any) any {
+	ci, cInt := current.(int64)
+	ni, nInt := n.(int64)
+	if cInt && nInt {
+		return ci + ni
+	}
+
+	c, ok := toFloat(current)
+	if !ok {
+		return n
+	}
+
+	f, _ := toFloat(n)
+	return c + f
+}
+
+func getField(doc map[string]any, path string) (any, bool) {
+	var v any = doc
+	for _, p := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[p]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// matchFilter evaluates a filter. As in firestore, documents without the field never match.
+func matchFilter(doc map[string]any, f Filter) (bool, error) {
+	v, ok := getField(doc, f.Path)
+	if !ok {
+		return false, nil
+	}
+
+	switch f.Op {
+	case "==":
+		return compareValues(v, f.Value) == 0, nil
+	case "!=":
+		return compareValues(v, f.Value) != 0, nil
+	case "<":
+		return sameKind(v, f.Value) && compareValues(v, f.Value) < 0, nil
+	case "<=":
+		return sameKind(v, f.Value) && compareValues(v, f.Value) <= 0, nil
+	case ">":
+		return sameKind(v, f.Value) && compareValues(v, f.Value) > 0, nil
+	case ">=":
+		return sameKind(v, f.Value) && compareValues(v, f.Value) >= 0, nil
+	case "in", "not-in":
+		values, _ := f.Value.([]any)
+		found := false
+		for _, e := range values {
+			if compareValues(v, e) == 0 {
+				found = true
+				break
+			}
+		}
+		return found == (f.Op == "in"), nil
+	case "array-contains":
+		a, _ := v.([]any)
+		for _, e := range a {
+			if compareValues(e, f.Value) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, errInvalidArgument("unsupported operator %s", f.Op)
+	}
+}
+
+func sameKind(a, b any) bool {
+	_, an := toFloat(a)
+	_, bn := toFloat(b)
+	if an || bn {
+		return an && bn
+	}
+	return fmt.Sprintf("%T", a) == fmt.Sprintf("%T", b)
+}
+
+// compareValues orders two stored values. Values of different kinds are never equal.
+func compareValues(a, b any) int {
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		if !ok {
+			return -1
+		}
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+
+	switch at := a.(type) {
+	case nil:
+		if b == nil {
+			return 0
+		}
+	case bool:
+		if bt, ok := b.(bool); ok {
+			switch {
+			case at == bt:
+				return 0
+			case !at:
+				return -1
+			}
+			return 1
+		}
+	case string:
+		if bt, ok := b.(string); ok {
+			return strings.Compare(at, bt)
+		}
+	case time.Time:
+		if bt, ok := b.(time.Time); ok {
+			return at.Compare(bt)
+		}
+	case []byte:
+		if bt, ok := b.([]byte); ok {
+			return bytes.Compare(at, bt)
+		}
+	default:
+		aj, _ := json.Marshal(toJSON(a))
+		bj, _ := json.Marshal(toJSON(b))
+		if bytes.Equal(aj, bj) {
+			return 0
+		}
+	}
+
+	return -1
+}
+
+func (b *memoryBackend) save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	raw := make(map[string]map[string]any, len(b.collections))
+	for c, docs := range b.collections {
+		raw[c] = make(map[string]any, len(docs))
+		for id, doc := range docs {
+			raw[c][id] = toJSON(doc)
+		}
+	}
+
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(b.path), filepath.Base(b.path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), b.path)
+}
+
+// toJSON tags values that JSON cannot represent so they can be restored on load.
+func toJSON(v any) any {
+	switch t := v.(type) {
+	case time.Time:
+		return map[string]any{"$time": t.Format(time.RFC3339Nano)}
+	case []byte:
+		return map[string]any{"$bytes": base64.StdEncoding.EncodeToString(t)}
+	case map[string]any:
+		m := make(map[string]any, len(t))
+		for k, e := range t {
+			m[k] = toJSON(e)
+		}
+		return m
+	case []any:
+		a := make([]any, len(t))
+		for i, e := range t {
+			a[i] = toJSON(e)
+		}
+		return a
+	default:
+		return v
+	}
+}
+
+func fromJSON(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]any:
+		if len(t) == 1 {
+			if s, ok := t["$time"].(string); ok {
+				if tm, err := time.Parse(time.RFC3339Nano, s); err == nil {
+					return tm
+				}
+			}
+			if s, ok := t["$bytes"].(string); ok {
+				if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+					return b
+				}
+			}
+		}
+		m := make(map[string]any, len(t))
+		for k, e := range t {
+			m[k] = fromJSON(e)
+		}
+		return m
+	case []any:
+		a := make([]any, len(t))
+		for i, e := range t {
+			a[i] = fromJSON(e)
+		}
+		return a
+	default:
+		return v
+	}
+}
@@ -0,0 +1,36 @@
+// Package docstore provides the document store used by the vox packages.
+// Firestore is used in deployed environments; the memory store, optionally
+// persisted to a JSON file, lets services run offline.
+package docstore
+
+import (
+	"log/slog"
+
+	"disruptive/config"
+	"disruptive/lib/firestore"
+)
+
+var (
+	// Client is the shared document store.
+	Client *Store
+)
+
+func init() {
+	switch config.VARS.DocStore {
+	case "memory":
+		backend, err := NewMemory(config.VARS.DocStorePath)
+		if err != nil {
+			slog.Warn("unable to create memory document store", "path", config.VARS.DocStorePath, "error", err)
+			return
+		}
+		Client = New(backend)
+	case "", "firestore":
+		if firestore.Client == nil {
+			slog.Warn("unable to create firestore document store")
+			return
+		}
+		Client = New(NewFirestore(firestore.Client))
+	default:
+		slog.Warn("unknown document store", "docstore", config.VARS.DocStore)
+	}
+}
@@ -13,7 +13,6 @@ import (
 )
 
 const (
-	baseURL                       = "https://api.elevenlabs.io/v1"
 	voiceElevenLabsEndpoint       = "/text-to-speech/{voice}"
 	voiceElevenLabsStreamEndpoint = "/text-to-speech/{voice}/stream"
 )
@@ -57,32 +56,42 @@ type (
 var (
 	// AudioFormatExtensions is a map containing valid audio formats and their file extention.
 	AudioFormatExtensions = map[string]string{
-		"mp3_22050_32":  "mp3",
-		"mp3_44100_32":  "mp3",
-		"mp3_44100_64":  "mp3",
-		"mp3_44100_96":  "mp3",
-		"mp3_44100_128": "mp3",
-		"mp3_44100_192": "mp3",
-		"opus_16000":    "opus",
-		"pcm_16000":     "l16",
-		"pcm_22050":     "l22",
-		"pcm_24000":     "l24",
-		"pcm_44100":     "l44",
+		"mp3_22050_32":   "mp3",
+		"mp3_44100_32":   "mp3",
+		"mp3_44100_64":   "mp3",
+		"mp3_44100_96":   "mp3",
+		"mp3_44100_128":  "mp3",
+		"mp3_44100_192":  "mp3",
+		"ogg_opus_16000": "ogg",
+		"opus_16000":     "opus",
+		"pcm_16000":      "l16",
+		"pcm_22050":      "l22",
+		"pcm_24000":      "l24",
+		"pcm_44100":      "l44",
+		"wav_16000":      "wav",
+		"wav_22050":      "wav",
+		"wav_24000":      "wav",
+		"wav_44100":      "wav",
 	}
 
 	// AudioFormatContentTypes is a map containing valid audio formats and their contype types.
 	AudioFormatContentTypes = map[string]string{
-		"mp3_22050_32":  "audio/mpeg",
-		"mp3_44100_32":  "audio/mpeg",
-		"mp3_44100_64":  "audio/mpeg",
-		"mp3_44100_96":  "audio/mpeg",
-		"mp3_44100_128": "audio/mpeg",
-		"mp3_44100_192": "audio/mpeg",
-		"opus_16000":    "audio/opus",
-		"pcm_16000":     "audio/pcm",
-		"pcm_22050":     "audio/pcm",
-		"pcm_24000":     "audio/pcm",
-		"pcm_44100":     "audio/pcm",
+		"mp3_22050_32":   "audio/mpeg",
+		"mp3_44100_32":   "audio/mpeg",
+		"mp3_44100_64":   "audio/mpeg",
+		"mp3_44100_96":   "audio/mpeg",
+		"mp3_44100_128":  "audio/mpeg",
+		"mp3_44100_192":  "audio/mpeg",
+		"ogg_opus_16000": "audio/ogg",
+		"opus_16000":     "audio/opus",
+		"pcm_16000":      "audio/pcm",
+		"pcm_22050":      "audio/pcm",
+		"pcm_24000":      "audio/pcm",
+		"pcm_44100":      "audio/pcm",
+		"wav_16000":      "audio/wav",
+		"wav_22050":      "audio/wav",
+		"wav_24000":      "audio/wav",
+		"wav_44100":      "audio/wav",
 	}
 
 	// OptimizingStreamLatency contains possible option values.
@@ -117,7 +126,7 @@ func renderFromRequest(req Request) request {
 
 func init() {
 	Resty = resty.New().
-		SetBaseURL(baseURL).
+		SetBaseURL(config.VARS.ElevenLabsURL).
 		SetLogger(common.LogDiscard).
 		AddRetryCondition(func(r *resty.Response, err error) bool {
 			return r.StatusCode() == http.StatusTooManyRequests
@@ -3,14 +3,20 @@ package elevenlabs
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"disruptive/lib/common"
 	"disruptive/lib/opus"
 )
 
 // TTSStream calls the ElevenLabs voice API to create an mp3.
+// Opus, Ogg Opus and WAV formats are encoded from the PCM stream.
 func TTSStream(ctx context.Context, logCtx *slog.Logger, req Request) (io.Reader, error) {
 	fid := slog.String("fid", "elevenlabs.TTSStream")
 
@@ -18,11 +24,28 @@ func TTSStream(ctx context.Context, logCtx *slog.Logger, req Request) (io.Reader
 		req.OptimizeStreamingLatency = "0"
 	}
 
-	var isOpus bool
+	var (
+		isOpus     bool
+		isOgg      bool
+		sampleRate int
+	)
 
-	if req.Format == "opus_16000" {
+	// Opus and WAV are encoded from PCM.
+	switch {
+	case req.Format == "opus_16000":
 		isOpus = true
 		req.Format = "pcm_16000"
+	case req.Format == "ogg_opus_16000":
+		isOpus, isOgg = true, true
+		req.Format = "pcm_16000"
+	case strings.HasPrefix(req.Format, "wav_"):
+		rate, err := strconv.Atoi(strings.TrimPrefix(req.Format, "wav_"))
+		if err != nil {
+			logCtx.Error("invalid audio format", fid, "format", req.Format)
+			return nil, err
+		}
+		sampleRate = rate
+		req.Format = fmt.Sprintf("pcm_%d", rate)
 	}
 
 	res, err := Resty.R().
@@ -37,7 +60,7 @@ func TTSStream(ctx context.Context, logCtx *slog.Logger, req Request) (io.Reader
 
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
-			logCtx.Error("timeout", "error", fid, err)
+			logCtx.Error("timeout", fid, "error", err)
 			return nil, err
 		}
 		logCtx.Error("elevenlabs voice stream endpoint failed", fid, "error", err)
@@ -69,6 +92,21 @@ func TTSStream(ctx context.Context, logCtx *slog.Logger, req Request) (io.Reader
 				return
 			}
 
+			var (
+				out io.Writer = w
+				ogg *opus.OggWriter
+			)
+
+			if isOgg {
+				ogg, err = opus.NewOggWriter(w, rand.Uint32())
+				if err != nil {
+					logCtx.Warn("unable to write ogg headers", fid, "error", err)
+					return
+				}
+
+				out = ogg
+			}
+
 			buf := make([]byte, 16384)
 			acc := make([]byte, 0)
 
@@ -93,7 +131,7 @@ func TTSStream(ctx context.Context, logCtx *slog.Logger, req Request) (io.Reader
 						goData := acc[:opus.FrameSize]
 						acc = acc[opus.FrameSize:]
 
-						if err := opus.WriteOpusFrame(opusEncoder, w, goData); err != nil {
+						if err := opus.WriteOpusFrame(opusEncoder, out, goData); err != nil {
 							logCtx.Error("unable to encode opus frame", fid, "error", err)
 							return
 						}
@@ -114,11 +152,17 @@ func TTSStream(ctx context.Context, logCtx *slog.Logger, req Request) (io.Reader
 			if len(acc) > 0 {
 				zeroBytes := make([]byte, opus.FrameSize-len(acc))
 				acc = append(acc, zeroBytes...)
-				if err := opus.WriteOpusFrame(opusEncoder, w, acc); err != nil {
+				if err := opus.WriteOpusFrame(opusEncoder, out, acc); err != nil {
 					logCtx.Error("unable to encode opus frame", fid, "error", err)
 					return
 				}
 			}
+
+			if ogg != nil {
+				if err := ogg.Close(); err != nil {
+					logCtx.Warn("unable to write last ogg page", fid, "error", err)
+				}
+			}
 		}()
 	} else {
 		go func() {
@@ -126,6 +170,13 @@ func TTSStream(ctx context.Context, logCtx *slog.Logger, req Request) (io.Reader
 			defer b.Close()
 			defer w.Close()
 
+			if sampleRate > 0 {
+				if err := common.WriteWAVHeader(w, sampleRate); err != nil {
+					logCtx.Warn("unable to write wav header", fid, "error", err)
+					return
+				}
+			}
+
 			buf := make([]byte, 16384)
 
 			for {
@@ -1,2 +0,0 @@
-// Package gcp interfaces with Google GCP services
-package gcp
@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"disruptive/lib/anthropic"
+)
+
+// defaultAnthropicMaxTokens is used when the request does not limit the response, which the Messages API requires.
+const defaultAnthropicMaxTokens = 1024
+
+type anthropicProvider struct{}
+
+func (p *anthropicProvider) Chat(ctx context.Context, logCtx *slog.Logger, model Model, req Request) (Response, error) {
+	res, err := anthropic.PostMessages(ctx, logCtx, toAnthropicReq(model, req))
+	if err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		Text:           res.Text,
+		FinishReason:   res.StopReason,
+		TokensPrompt:   res.UsagePrompt,
+		TokensResponse: res.UsageResponse,
+	}, nil
+}
+
+func (p *anthropicProvider) ChatStream(ctx context.Context, logCtx *slog.Logger, model Model, req Request) (io.Reader, error) {
+	stream, err := anthropic.PostMessagesStream(ctx, logCtx, toAnthropicReq(model, req))
+	if err != nil {
+		return nil, err
+	}
+
+	return anthropicStream{stream}, nil
+}
+
+// anthropicStream reports the usage of the message_start and message_delta events.
+type anthropicStream struct {
+	*anthropic.MessagesStream
+}
+
+func (s anthropicStream) Usage() (int, int) {
+	return s.Response.UsagePrompt, s.Response.UsageResponse
+}
+
+// CountTokens approximates Claude tokens with the OpenAI encoding.
+func (p *anthropicProvider) CountTokens(messages []Message) int {
+	return countTokens(messages)
+}
+
+func (p *anthropicProvider) Cost(model Model, tokensPrompt, tokensResponse int) (float64, float64) {
+	return cost(model, tokensPrompt, tokensResponse)
+}
+
+// toAnthropicReq moves system messages to the system prompt and merges consecutive
+// messages from the same role since the Messages API requires alternating roles
+// starting with the user. A leading assistant message, such as a conversation
+// summary, becomes part of the system prompt.
+func toAnthropicReq(model Model, req Request) anthropic.MessagesRequest {
+	if req.Creativity < 0 {
+		req.Creativity = 0
+	}
+
+	if req.Creativity > 100 {
+		req.Creativity = 100
+	}
+
+	if req.MaxTokens <= 0 {
+		req.MaxTokens = defaultAnthropicMaxTokens
+	}
+
+	system := []string{}
+	messages := []anthropic.Message{}
+
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+
+		if len(messages) == 0 && m.Role == "assistant" {
+			system = append(system, m.Content)
+			continue
+		}
+
+		if len(messages) > 0 && messages[len(messages)-1].Role == m.Role {
+			messages[len(messages)-1].Content += "\n\n" + m.Content
+			continue
+		}
+
+		messages = append(messages, anthropic.Message{Role: m.Role, Content: m.Content})
+	}
+
+	return anthropic.MessagesRequest{
+		Model:         model.ID,
+		System:        strings.Join(system, "\n\n"),
+		Messages:      messages,
+		MaxTokens:     req.MaxTokens,
+		Temperature:   float64(req.Creativity) / 100.0,
+		StopSequences: req.Stop,
+	}
+}
@@ -0,0 +1,195 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+
+	"disruptive/config"
+	"disruptive/lib/common"
+	"disruptive/lib/configs"
+)
+
+// Message contains a single chat message.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request is a provider independent chat request.
+type Request struct {
+	Messages   []Message `json:"messages"`
+	Creativity int       `json:"creativity"`
+	MaxTokens  int       `json:"max_tokens"`
+	Stop       []string  `json:"stop,omitempty"`
+}
+
+// Response is a provider independent chat response.
+type Response struct {
+	Text           string `json:"text"`
+	FinishReason   string `json:"finish_reason"`
+	TokensPrompt   int    `json:"tokens_prompt"`
+	TokensResponse int    `json:"tokens_response"`
+}
+
+// Model is a resolved model registry entry.
+type Model struct {
+	Name string `json:"name"`
+	configs.LLMModel
+}
+
+// UsageReader is a chat stream that reports its token usage once it has been read to EOF.
+type UsageReader interface {
+	io.Reader
+	Usage() (tokensPrompt, tokensResponse int)
+}
+
+// Provider sends chat requests to an LLM API. ChatStream returns a UsageReader when the API reports the usage of
+// streamed responses.
+type Provider interface {
+	Chat(ctx context.Context, logCtx *slog.Logger, model Model, req Request) (Response, error)
+	ChatStream(ctx context.Context, logCtx *slog.Logger, model Model, req Request) (io.Reader, error)
+	CountTokens(messages []Message) int
+	Cost(model Model, tokensPrompt, tokensResponse int) (promptCost, responseCost float64)
+}
+
+var (
+	encodingOnce sync.Once
+	encoding     *tiktoken.Tiktoken
+)
+
+// defaultModels are used when the llm_models config does not define a model.
+func defaultModels() configs.LLMModels {
+	gpt4Turbo := configs.LLMModel{
+		Provider:        ProviderOpenAI,
+		ID:              "gpt-4-turbo-preview",
+		MaxPromptTokens: 102400,
+		PromptCost:      config.VARS.GPT4TurboPromptCost,
+		ResponseCost:    config.VARS.GPT4TurboResponseCost,
+	}
+
+	return configs.LLMModels{
+		"gpt-3.5-turbo": {
+			Provider:        ProviderOpenAI,
+			ID:              "gpt-3.5-turbo",
+			MaxPromptTokens: 12288,
+			Fallback:        "gpt-4-turbo",
+			PromptCost:      config.VARS.GPT35TurboPromptCost,
+			ResponseCost:    config.VARS.GPT35TurboResponseCost,
+		},
+		"gpt-4-turbo":         gpt4Turbo,
+		"gpt-4-turbo-preview": gpt4Turbo,
+		"fun":                 gpt4Turbo,
+	}
+}
+
+// GetModel returns the registry entry for a model name.
+func GetModel(ctx context.Context, logCtx *slog.Logger, name string) (Model, error) {
+	fid := slog.String("fid", "llm.GetModel")
+
+	models, err := configs.GetLLMModels(ctx, logCtx)
+	if err != nil && !errors.Is(err, common.ErrNotFound{}) {
+		logCtx.Error("unable to get llm models", fid, "error", err)
+		return Model{}, err
+	}
+
+	m, ok := models[name]
+	if !ok {
+		m, ok = defaultModels()[name]
+	}
+
+	if !ok {
+		logCtx.Error("invalid model", fid, "model", name)
+		return Model{}, common.ErrNotFound{Msg: "invalid model"}
+	}
+
+	if m.ID == "" {
+		m.ID = name
+	}
+
+	if m.Provider == "" {
+		m.Provider = ProviderOpenAI
+	}
+
+	return Model{Name: name, LLMModel: m}, nil
+}
+
+// GetProvider returns the provider serving a model.
+func GetProvider(model Model) (Provider, error) {
+	p, ok := providers[model.Provider]
+	if !ok {
+		return nil, common.ErrNotFound{Msg: "invalid provider " + model.Provider}
+	}
+
+	return p, nil
+}
+
+// Resolve returns the model and provider for a model name, following the model
+// fallbacks until the prompt fits within the max prompt tokens.
+func Resolve(ctx context.Context, logCtx *slog.Logger, name string, messages []Message) (Model, Provider, int, error) {
+	fid := slog.String("fid", "llm.Resolve")
+
+	visited := map[string]bool{}
+
+	for {
+		model, err := GetModel(ctx, logCtx, name)
+		if err != nil {
+			return Model{}, nil, 0, err
+		}
+
+		provider, err := GetProvider(model)
+		if err != nil {
+			logCtx.Error("unable to get provider", fid, "model", name, "provider", model.Provider, "error", err)
+			return Model{}, nil, 0, err
+		}
+
+		numTokens := provider.CountTokens(messages)
+		if model.MaxPromptTokens <= 0 || numTokens <= model.MaxPromptTokens {
+			return model, provider, numTokens, nil
+		}
+
+		visited[name] = true
+
+		if model.Fallback == "" || visited[model.Fallback] {
+			logCtx.Error("token length exceeded", fid, "prompt_tokens", numTokens, "model", name)
+			return Model{}, nil, 0, common.ErrBadRequest{Msg: "Request exceeds max token length."}
+		}
+
+		logCtx.Info("token length exceeded. switching model.", fid, "prompt_tokens", numTokens, "model", name, "fallback", model.Fallback)
+		name = model.Fallback
+	}
+}
+
+// countTokens counts message tokens with the cl100k_base encoding and
+// approximates by words when the encoding is unavailable.
+func countTokens(messages []Message) int {
+	encodingOnce.Do(func() {
+		tke, err := tiktoken.GetEncoding(tokenEncodingModel)
+		if err != nil {
+			slog.Warn("unable to get tiktoken encoding", "fid", "llm.countTokens", "error", err)
+			return
+		}
+		encoding = tke
+	})
+
+	numTokens := 0
+	for _, m := range messages {
+		if encoding == nil {
+			numTokens += len(strings.Fields(m.Content)) * 4 / 3
+			continue
+		}
+		numTokens += len(encoding.Encode(m.Content, nil, nil))
+	}
+
+	return numTokens
+}
+
+// cost returns the prompt and response costs from the per 1k token model pricing.
+func cost(model Model, tokensPrompt, tokensResponse int) (float64, float64) {
+	return float64(tokensPrompt) * model.PromptCost / 1000.0, float64(tokensResponse) * model.ResponseCost / 1000.0
+}
@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/go-resty/resty/v2"
+
+	"disruptive/lib/openai"
+)
+
+type openAIProvider struct {
+	client *resty.Client
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, logCtx *slog.Logger, model Model, req Request) (Response, error) {
+	res, err := openai.PostChatWithClient(ctx, logCtx, p.client, toOpenAIReq(model, req))
+	if err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		Text:           res.Text,
+		FinishReason:   res.FinishReason,
+		TokensPrompt:   res.UsagePrompt,
+		TokensResponse: res.UsageResponse,
+	}, nil
+}
+
+func (p *openAIProvider) ChatStream(ctx context.Context, logCtx *slog.Logger, model Model, req Request) (io.Reader, error) {
+	return openai.PostChatStreamWithClient(ctx, logCtx, p.client, toOpenAIReq(model, req))
+}
+
+func (p *openAIProvider) CountTokens(messages []Message) int {
+	return countTokens(messages)
+}
+
+func (p *openAIProvider) Cost(model Model, tokensPrompt, tokensResponse int) (float64, float64) {
+	return cost(model, tokensPrompt, tokensResponse)
+}
+
+func toOpenAIReq(model Model, req Request) openai.ChatRequest {
+	messages := make([]openai.ChatMessage, len(req.Messages))
+	for i, m := range req.Messages {
+		messages[i] = openai.ChatMessage{Role: m.Role, Content: m.Content}
+	}
+
+	return openai.ChatRequest{
+		Model:      model.ID,
+		Messages:   messages,
+		Creativity: req.Creativity,
+		MaxTokens:  req.MaxTokens,
+		Stop:       req.Stop,
+	}
+}
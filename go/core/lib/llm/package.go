@@ -0,0 +1,32 @@
+// Package llm routes chat requests to the provider serving each model.
+// Models are resolved through the llm_models config so new models only
+// need a config change.
+package llm
+
+import (
+	"disruptive/config"
+	"disruptive/lib/openai"
+)
+
+const (
+	// ProviderOpenAI serves models through the OpenAI API.
+	ProviderOpenAI = "openai"
+
+	// ProviderAnthropic serves models through the Anthropic Messages API.
+	ProviderAnthropic = "anthropic"
+
+	// ProviderOpenAICompatible serves models through a self-hosted OpenAI-compatible server.
+	ProviderOpenAICompatible = "openai-compatible"
+
+	tokenEncodingModel = "cl100k_base"
+)
+
+var providers map[string]Provider
+
+func init() {
+	providers = map[string]Provider{
+		ProviderOpenAI:           &openAIProvider{client: openai.Resty},
+		ProviderAnthropic:        &anthropicProvider{},
+		ProviderOpenAICompatible: &openAIProvider{client: openai.NewResty(config.VARS.LLMCompatibleURL, config.VARS.LLMCompatibleKey)},
+	}
+}
@@ -11,7 +11,6 @@ import (
 )
 
 const (
-	baseURL                              = "https://api.anymailfinder.com"
 	anymailfinderAccountEndpoint         = "/v5.0/meta/account.json"
 	anymailfinderSearchEmployeesEndpoint = "/v5.0/search/employees.json"
 	anymailfinderStatusEndpoint          = "/status"
@@ -24,7 +23,7 @@ var (
 
 func init() {
 	Resty = resty.New().
-		SetBaseURL(baseURL).
+		SetBaseURL(config.VARS.AnyMailFinderURL).
 		SetLogger(common.LogDiscard).
 		AddRetryCondition(func(r *resty.Response, err error) bool {
 			return r.StatusCode() == http.StatusTooManyRequests
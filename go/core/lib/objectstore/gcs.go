@@ -0,0 +1,108 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+type gcs struct {
+	client *storage.Client
+}
+
+// NewGCS returns an object store backed by Google Cloud Storage.
+func NewGCS(ctx context.Context) (ObjectStore, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &gcs{client}, nil
+}
+
+func (s *gcs) Close() error {
+	return s.client.Close()
+}
+
+func (s *gcs) Download(ctx context.Context, bucket, name string) (io.ReadCloser, string, error) {
+	o := s.client.Bucket(bucket).Object(name)
+
+	attrs, err := o.Attrs(ctx)
+	if err != nil {
+		return nil, "", convertGCSError(err, name)
+	}
+
+	r, err := o.NewReader(ctx)
+	if err != nil {
+		return nil, "", convertGCSError(err, name)
+	}
+
+	return r, attrs.ContentType, nil
+}
+
+func (s *gcs) Upload(ctx context.Context, r io.Reader, bucket, name, contentType string) error {
+	w := s.client.Bucket(bucket).Object(name).NewWriter(ctx)
+
+	if contentType != "" {
+		w.ContentType = contentType
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+func (s *gcs) Delete(ctx context.Context, bucket, name string) error {
+	return convertGCSError(s.client.Bucket(bucket).Object(name).Delete(ctx), name)
+}
+
+func (s *gcs) List(ctx context.Context, bucket, prefix string) ([]ObjectAttrs, error) {
+	objects := []ObjectAttrs{}
+
+	it := s.client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, ObjectAttrs{
+			Name:        attrs.Name,
+			ContentType: attrs.ContentType,
+			Size:        attrs.Size,
+			Updated:     attrs.Updated,
+		})
+	}
+
+	return objects, nil
+}
+
+func (s *gcs) Stat(ctx context.Context, bucket, name string) (ObjectAttrs, error) {
+	attrs, err := s.client.Bucket(bucket).Object(name).Attrs(ctx)
+	if err != nil {
+		return ObjectAttrs{}, convertGCSError(err, name)
+	}
+
+	return ObjectAttrs{
+		Name:        attrs.Name,
+		ContentType: attrs.ContentType,
+		Size:        attrs.Size,
+		Updated:     attrs.Updated,
+	}, nil
+}
+
+func convertGCSError(err error, name string) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("%w: %s", ErrObjectNotExist, name)
+	}
+	return err
+}
@@ -0,0 +1,233 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// metaDir holds the content type of each object, mirroring the bucket layout.
+const metaDir = ".meta"
+
+type local struct {
+	root string
+}
+
+// NewLocal returns an object store that keeps each bucket as a directory under root.
+func NewLocal(root string) (ObjectStore, error) {
+	if root == "" {
+		return nil, errors.New("local object store root not set")
+	}
+
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &local{root: root}, nil
+}
+
+func (s *local) Close() error {
+	return nil
+}
+
+func (s *local) Download(_ context.Context, bucket, name string) (io.ReadCloser, string, error) {
+	p, err := s.objectPath(bucket, name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, "", convertLocalError(err, name)
+	}
+
+	return f, s.contentType(bucket, name), nil
+}
+
+func (s *local) Upload(_ context.Context, r io.Reader, bucket, name, contentType string) error {
+	p, err := s.objectPath(bucket, name)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(p, r); err != nil {
+		return err
+	}
+
+	m, err := s.metaPath(bucket, name)
+	if err != nil {
+		return err
+	}
+
+	if contentType == "" {
+		os.Remove(m)
+		return nil
+	}
+
+	return writeFile(m, strings.NewReader(contentType))
+}
+
+func (s *local) Delete(_ context.Context, bucket, name string) error {
+	p, err := s.objectPath(bucket, name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(p); err != nil {
+		return convertLocalError(err, name)
+	}
+
+	if m, err := s.metaPath(bucket, name); err == nil {
+		os.Remove(m)
+	}
+
+	return nil
+}
+
+func (s *local) List(_ context.Context, bucket, prefix string) ([]ObjectAttrs, error) {
+	objects := []ObjectAttrs{}
+
+	bucketPath, err := s.objectPath(bucket, "")
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.WalkDir(bucketPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(bucketPath, p)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) || strings.Contains(path.Base(name), ".tmp-") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, ObjectAttrs{
+			Name:        name,
+			ContentType: s.contentType(bucket, name),
+			Size:        info.Size(),
+			Updated:     info.ModTime(),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+func (s *local) Stat(_ context.Context, bucket, name string) (ObjectAttrs, error) {
+	p, err := s.objectPath(bucket, name)
+	if err != nil {
+		return ObjectAttrs{}, err
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return ObjectAttrs{}, convertLocalError(err, name)
+	}
+
+	if info.IsDir() {
+		return ObjectAttrs{}, fmt.Errorf("%w: %s", ErrObjectNotExist, name)
+	}
+
+	return ObjectAttrs{
+		Name:        name,
+		ContentType: s.contentType(bucket, name),
+		Size:        info.Size(),
+		Updated:     info.ModTime(),
+	}, nil
+}
+
+func (s *local) objectPath(bucket, name string) (string, error) {
+	return s.safePath(bucket, bucket, name)
+}
+
+func (s *local) metaPath(bucket, name string) (string, error) {
+	return s.safePath(bucket, metaDir, bucket, name)
+}
+
+// safePath joins the parts under root and rejects names that escape the bucket.
+func (s *local) safePath(bucket string, parts ...string) (string, error) {
+	if bucket == "" || bucket == metaDir || strings.ContainsAny(bucket, `/\`) || bucket == "." || bucket == ".." {
+		return "", fmt.Errorf("invalid bucket %q", bucket)
+	}
+
+	name := parts[len(parts)-1]
+	if name != "" && (strings.HasPrefix(name, "/") || !filepath.IsLocal(filepath.FromSlash(name))) {
+		return "", fmt.Errorf("invalid object name %q", name)
+	}
+
+	elems := append([]string{s.root}, parts[:len(parts)-1]...)
+	elems = append(elems, filepath.FromSlash(name))
+
+	return filepath.Join(elems...), nil
+}
+
+func (s *local) contentType(bucket, name string) string {
+	if m, err := s.metaPath(bucket, name); err == nil {
+		if b, err := os.ReadFile(m); err == nil {
+			return string(b)
+		}
+	}
+
+	return mime.TypeByExtension(path.Ext(name))
+}
+
+// writeFile writes through a temp file so readers never see a partial object.
+func writeFile(p string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+func convertLocalError(err error, name string) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrObjectNotExist, name)
+	}
+	return err
+}
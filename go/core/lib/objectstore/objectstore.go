@@ -0,0 +1,29 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrObjectNotExist is returned when an object is not found.
+var ErrObjectNotExist = errors.New("object does not exist")
+
+// ObjectAttrs describes a stored object.
+type ObjectAttrs struct {
+	Name        string
+	ContentType string
+	Size        int64
+	Updated     time.Time
+}
+
+// ObjectStore stores blobs such as audio files and pictures in buckets.
+type ObjectStore interface {
+	Download(ctx context.Context, bucket, name string) (io.ReadCloser, string, error)
+	Upload(ctx context.Context, r io.Reader, bucket, name, contentType string) error
+	Delete(ctx context.Context, bucket, name string) error
+	List(ctx context.Context, bucket, prefix string) ([]ObjectAttrs, error)
+	Stat(ctx context.Context, bucket, name string) (ObjectAttrs, error)
+	Close() error
+}
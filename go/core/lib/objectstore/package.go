@@ -0,0 +1,34 @@
+// Package objectstore stores blobs in Google Cloud Storage or, for local
+// development and CI, in a directory tree.
+package objectstore
+
+import (
+	"context"
+	"log/slog"
+
+	"disruptive/config"
+)
+
+var (
+	// Client is the shared object store.
+	Client ObjectStore
+)
+
+func init() {
+	var err error
+
+	switch config.VARS.ObjectStore {
+	case "local":
+		Client, err = NewLocal(config.VARS.ObjectStorePath)
+		if err != nil {
+			slog.Warn("unable to create local object store", "path", config.VARS.ObjectStorePath, "error", err)
+		}
+	case "", "gcs":
+		Client, err = NewGCS(context.Background())
+		if err != nil {
+			slog.Warn("unable to create gcs client", "error", err)
+		}
+	default:
+		slog.Warn("unknown object store", "objectstore", config.VARS.ObjectStore)
+	}
+}
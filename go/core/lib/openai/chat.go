@@ -9,6 +9,8 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/go-resty/resty/v2"
+
 	"disruptive/lib/common"
 )
 
@@ -23,6 +25,7 @@ type chatRequest struct {
 	Messages    []ChatMessage `json:"messages"`
 	Temperature float64       `json:"temperature"`
 	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Stop        []string      `json:"stop,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
 }
 
@@ -32,6 +35,7 @@ type ChatRequest struct {
 	Messages   []ChatMessage `json:"messages"`
 	Creativity int           `json:"creativity"`
 	MaxTokens  int           `json:"max_tokens"`
+	Stop       []string      `json:"stop,omitempty"`
 }
 
 type chatResponse struct {
@@ -86,6 +90,7 @@ func renderFromChatReq(req ChatRequest) chatRequest {
 		Messages:    req.Messages,
 		Temperature: float64(req.Creativity) * 2 / 100,
 		MaxTokens:   req.MaxTokens,
+		Stop:        req.Stop,
 	}
 }
 
@@ -107,11 +112,16 @@ func renderToChatRes(_res *chatResponse) ChatResponse {
 
 // PostChat sends chat prompts to OpenAI.
 func PostChat(ctx context.Context, logCtx *slog.Logger, req ChatRequest) (ChatResponse, error) {
+	return PostChatWithClient(ctx, logCtx, Resty, req)
+}
+
+// PostChatWithClient sends chat prompts to OpenAI or an OpenAI-compatible server.
+func PostChatWithClient(ctx context.Context, logCtx *slog.Logger, client *resty.Client, req ChatRequest) (ChatResponse, error) {
 	fid := slog.String("fid", "openai.PostChat")
 
 	_req := renderFromChatReq(req)
 
-	res, err := Resty.R().
+	res, err := client.R().
 		SetContext(ctx).
 		SetBody(_req).
 		SetResult(&chatResponse{}).
@@ -146,12 +156,17 @@ func PostChat(ctx context.Context, logCtx *slog.Logger, req ChatRequest) (ChatRe
 
 // PostChatStream sends chat prompts to OpenAI.
 func PostChatStream(ctx context.Context, logCtx *slog.Logger, req ChatRequest) (io.Reader, error) {
+	return PostChatStreamWithClient(ctx, logCtx, Resty, req)
+}
+
+// PostChatStreamWithClient sends chat prompts to OpenAI or an OpenAI-compatible server.
+func PostChatStreamWithClient(ctx context.Context, logCtx *slog.Logger, client *resty.Client, req ChatRequest) (io.Reader, error) {
 	fid := slog.String("fid", "openai.PostChatStream")
 
 	_req := renderFromChatReq(req)
 	_req.Stream = true
 
-	res, err := Resty.R().
+	res, err := client.R().
 		SetContext(ctx).
 		SetBody(_req).
 		SetDoNotParseResponse(true).
@@ -14,7 +14,6 @@ import (
 )
 
 const (
-	baseURL                = "https://api.openai.com/v1"
 	chatEndpoint           = "/chat/completions"
 	embeddingsEndpoint     = "/embeddings"
 	fileEndpoint           = "/files/{file_id}"
@@ -24,6 +23,7 @@ const (
 	modelsEndpoint         = "/models"
 	modelEndpoint          = "/models/{model_id}"
 	moderationsEndpoint    = "/moderations"
+	speechEndpoint         = "/audio/speech"
 	transcriptionsEndpoint = "/audio/transcriptions"
 	translationsEndpoint   = "/audio/translations"
 )
@@ -60,7 +60,12 @@ type errorResponse struct {
 }
 
 func init() {
-	Resty = resty.New().
+	Resty = NewResty(config.VARS.OpenAIURL, config.VARS.OpenAIKey)
+}
+
+// NewResty returns a resty client for the OpenAI API or any OpenAI-compatible server.
+func NewResty(baseURL, key string) *resty.Client {
+	client := resty.New().
 		SetBaseURL(baseURL).
 		SetLogger(common.LogDiscard).
 		AddRetryCondition(func(r *resty.Response, err error) bool {
@@ -68,14 +73,19 @@ func init() {
 				r.StatusCode() == http.StatusInternalServerError ||
 				r.StatusCode() == http.StatusServiceUnavailable
 		}).
-		SetAuthToken(config.VARS.OpenAIKey).
 		SetHeader("User-Agent", config.VARS.UserAgent).
 		SetHeader("Content-Type", "application/json").
 		SetTimeout(time.Minute)
 
-	Resty.GetClient().Transport = &http.Transport{
+	if key != "" {
+		client.SetAuthToken(key)
+	}
+
+	client.GetClient().Transport = &http.Transport{
 		MaxIdleConnsPerHost: 100,
 	}
+
+	return client
 }
 
 func errorMessage(res []byte) string {
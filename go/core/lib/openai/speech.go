@@ -0,0 +1,67 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+
+	"disruptive/lib/common"
+)
+
+// SpeechRequest contains input parameters for speech.
+// Format is an OpenAI response format: mp3, opus, aac, flac, wav or pcm.
+type SpeechRequest struct {
+	Model  string  `json:"model"`
+	Input  string  `json:"input"`
+	Voice  string  `json:"voice"`
+	Format string  `json:"response_format,omitempty"`
+	Speed  float64 `json:"speed,omitempty"`
+}
+
+// PostSpeech converts text to speech with OpenAI and returns the audio stream.
+func PostSpeech(ctx context.Context, logCtx *slog.Logger, req SpeechRequest) (io.ReadCloser, error) {
+	return PostSpeechWithClient(ctx, logCtx, Resty, req)
+}
+
+// PostSpeechWithClient converts text to speech with the OpenAI API or any OpenAI-compatible server.
+// The caller must close the returned stream.
+func PostSpeechWithClient(ctx context.Context, logCtx *slog.Logger, client *resty.Client, req SpeechRequest) (io.ReadCloser, error) {
+	fid := slog.String("fid", "openai.PostSpeechWithClient")
+
+	if req.Input == "" {
+		logCtx.Error("missing input", fid)
+		return nil, common.ErrBadRequest{Msg: "missing text"}
+	}
+
+	res, err := client.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true).
+		SetBody(req).
+		Post(speechEndpoint)
+
+	if err != nil {
+		logCtx.Error("openai speech endpoint failed", fid, "error", err)
+		return nil, err
+	}
+
+	if res.StatusCode() != http.StatusOK {
+		b := res.RawBody()
+		defer b.Close()
+
+		body, _ := io.ReadAll(b)
+
+		if res.StatusCode() == http.StatusUnauthorized {
+			logCtx.Error("openai unauthorized", fid, "status", res.Status())
+			return nil, common.ErrUnauthorized
+		}
+
+		logCtx.Error("openai speech endpoint failed", fid, "status", res.Status(), "message", errorMessage(body))
+		return nil, errors.New(res.Status())
+	}
+
+	return res.RawBody(), nil
+}
@@ -6,44 +6,74 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	iso "github.com/emvi/iso-639-1"
+	"github.com/go-resty/resty/v2"
 
 	"disruptive/lib/common"
 )
 
 type postTranscriptionsResponse struct {
-	Text string `json:"text"`
+	Text     string `json:"text"`
+	Language string `json:"language"`
+}
+
+// Transcription is a transcription with the language detected by the model.
+type Transcription struct {
+	Text     string `json:"text"`
+	Language string `json:"language"`
 }
 
 // PostTranscriptionsText sends transcriptions to OpenAI and returns the text.
 // language is ISO-639-1 in format: https://en.wikipedia.org/wiki/List_of_ISO_639-1_codes
 func PostTranscriptionsText(ctx context.Context, logCtx *slog.Logger, file io.Reader, extension, language string) (string, error) {
-	fid := slog.String("fid", "openai.PostTranscriptionsText")
-
-	if language != "" && !iso.ValidCode(language) {
-		logCtx.Error("invalid language", "language", language)
-		return "", errors.New("invalid language")
+	t, err := PostTranscriptions(ctx, logCtx, file, extension, language)
+	if err != nil {
+		return "", err
 	}
 
+	return t.Text, nil
+}
+
+// PostTranscriptions sends transcriptions to OpenAI Whisper and returns the text and detected language.
+func PostTranscriptions(ctx context.Context, logCtx *slog.Logger, file io.Reader, extension, language string) (Transcription, error) {
+	fid := slog.String("fid", "openai.PostTranscriptions")
+
 	if err := whisper1Limiter.Wait(ctx); err != nil {
 		logCtx.Error("limiter wait failed", fid, "error", err)
-		return "", err
+		return Transcription{}, err
+	}
+
+	return PostTranscriptionsWithClient(ctx, logCtx, Resty, "whisper-1", file, extension, language)
+}
+
+// PostTranscriptionsWithClient sends transcriptions to the OpenAI API or any OpenAI-compatible server.
+// The detected language is the ISO-639-1 code when the server reports it.
+func PostTranscriptionsWithClient(ctx context.Context, logCtx *slog.Logger, client *resty.Client, model string, file io.Reader, extension, language string) (Transcription, error) {
+	fid := slog.String("fid", "openai.PostTranscriptionsWithClient")
+
+	if language != "" && !iso.ValidCode(language) {
+		logCtx.Error("invalid language", "language", language)
+		return Transcription{}, errors.New("invalid language")
 	}
 
 	// Override the Resty timeout if it exists.
 	if timeout := ctx.Value(common.TimeoutKey); timeout != nil {
-		Resty.SetTimeout(timeout.(time.Duration))
+		client.SetTimeout(timeout.(time.Duration))
 	}
 
-	req := map[string]string{"model": "whisper-1"}
+	req := map[string]string{
+		"model":           model,
+		"response_format": "verbose_json",
+	}
 
 	if language != "" {
 		req["language"] = language
 	}
 
-	res, err := Resty.R().
+	res, err := client.R().
 		SetContext(ctx).
 		SetFileReader("file", "audio."+extension, file).
 		SetFormData(req).
@@ -52,18 +82,35 @@ func PostTranscriptionsText(ctx context.Context, logCtx *slog.Logger, file io.Re
 
 	if err != nil {
 		logCtx.Error("openai transcriptions endpoint failed", fid, "error", err)
-		return "", err
+		return Transcription{}, err
 	}
 
 	if res.StatusCode() == http.StatusUnauthorized {
 		logCtx.Error("openai unauthorized", fid, "status", res.Status())
-		return "", common.ErrUnauthorized
+		return Transcription{}, common.ErrUnauthorized
 	}
 
 	if res.StatusCode() != http.StatusOK {
 		logCtx.Error("openai transcriptions endpoint failed", fid, "status", res.Status())
-		return "", errors.New(res.Status())
+		return Transcription{}, errors.New(res.Status())
+	}
+
+	r := res.Result().(*postTranscriptionsResponse)
+
+	return Transcription{Text: r.Text, Language: languageCode(r.Language)}, nil
+}
+
+// languageCode converts the language name returned by Whisper to its ISO-639-1 code.
+func languageCode(language string) string {
+	if language == "" || iso.ValidCode(language) {
+		return language
+	}
+
+	for code, l := range iso.Languages {
+		if strings.EqualFold(l.Name, language) {
+			return code
+		}
 	}
 
-	return res.Result().(*postTranscriptionsResponse).Text, nil
+	return ""
 }
@@ -0,0 +1,141 @@
+package opus
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	// oggPreSkip is the encoder lookahead in 48kHz samples that players drop from the start.
+	oggPreSkip = 312
+
+	// oggPagePackets is the number of packets per page, about 100ms of audio at 20ms frames.
+	oggPagePackets = 5
+
+	oggVendor = "disruptive"
+
+	oggHeaderBOS = 0x02
+	oggHeaderEOS = 0x04
+
+	// oggFrameSamples is the duration of one frame in the 48kHz samples used by granule positions.
+	oggFrameSamples = 48000 * FrameSize / 2 / 16000
+)
+
+var oggCRCTable [256]uint32
+
+func init() {
+	for i := range oggCRCTable {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		oggCRCTable[i] = r
+	}
+}
+
+// OggWriter encapsulates opus packets in an Ogg stream so browsers and players can play it.
+// Each Write is one opus packet of one frame. Close must be called to end the stream.
+type OggWriter struct {
+	w        io.Writer
+	serial   uint32
+	sequence uint32
+	granule  uint64
+	packets  [][]byte
+}
+
+// NewOggWriter writes the OpusHead and OpusTags pages of a stream from the encoder.
+func NewOggWriter(w io.Writer, serial uint32) (*OggWriter, error) {
+	o := &OggWriter{w: w, serial: serial}
+
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1
+	head[9] = byte(opusChannels)
+	binary.LittleEndian.PutUint16(head[10:], oggPreSkip)
+	binary.LittleEndian.PutUint32(head[12:], uint32(opusSampleRate))
+
+	if err := o.writePage(oggHeaderBOS, 0, [][]byte{head}); err != nil {
+		return nil, err
+	}
+
+	tags := make([]byte, 8+4+len(oggVendor)+4)
+	copy(tags, "OpusTags")
+	binary.LittleEndian.PutUint32(tags[8:], uint32(len(oggVendor)))
+	copy(tags[12:], oggVendor)
+
+	if err := o.writePage(0, 0, [][]byte{tags}); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// Write buffers one opus packet and writes a page once enough packets are buffered.
+func (o *OggWriter) Write(packet []byte) (int, error) {
+	if len(o.packets) == oggPagePackets {
+		if err := o.flush(0); err != nil {
+			return 0, err
+		}
+	}
+
+	o.packets = append(o.packets, append([]byte(nil), packet...))
+	o.granule += oggFrameSamples
+
+	return len(packet), nil
+}
+
+// Close writes the remaining packets on the last page.
+func (o *OggWriter) Close() error {
+	return o.flush(oggHeaderEOS)
+}
+
+func (o *OggWriter) flush(headerType byte) error {
+	err := o.writePage(headerType, o.granule+oggPreSkip, o.packets)
+	o.packets = o.packets[:0]
+	return err
+}
+
+// writePage writes packets on one page. Packets must fit in the 255 lacing values of a page.
+func (o *OggWriter) writePage(headerType byte, granule uint64, packets [][]byte) error {
+	segments := []byte{}
+	size := 0
+
+	for _, p := range packets {
+		for n := len(p); ; n -= 255 {
+			if n < 255 {
+				segments = append(segments, byte(n))
+				break
+			}
+			segments = append(segments, 255)
+		}
+		size += len(p)
+	}
+
+	page := make([]byte, 27+len(segments), 27+len(segments)+size)
+	copy(page, "OggS")
+	page[5] = headerType
+	binary.LittleEndian.PutUint64(page[6:], granule)
+	binary.LittleEndian.PutUint32(page[14:], o.serial)
+	binary.LittleEndian.PutUint32(page[18:], o.sequence)
+	page[26] = byte(len(segments))
+	copy(page[27:], segments)
+
+	for _, p := range packets {
+		page = append(page, p...)
+	}
+
+	crc := uint32(0)
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	binary.LittleEndian.PutUint32(page[22:], crc)
+
+	o.sequence++
+
+	_, err := o.w.Write(page)
+	return err
+}
@@ -124,3 +124,69 @@ func WriteOpusFrame(opusEncoder *C.OpusEncoder, w io.Writer, data []byte) error
 
 	return nil
 }
+
+// OggPCMWriter encodes 16kHz signed 16-bit little-endian mono PCM into one Ogg Opus stream.
+// Close must be called to encode the last partial frame, end the stream and free the encoder.
+type OggPCMWriter struct {
+	encoder *C.OpusEncoder
+	ogg     *OggWriter
+	acc     []byte
+}
+
+// NewOggPCMWriter creates the encoder and writes the Ogg Opus headers to w.
+func NewOggPCMWriter(w io.Writer, serial uint32) (*OggPCMWriter, error) {
+	opusEncoder, err := CreateOpusEncoder()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := InitOpusEncoder(opusEncoder); err != nil {
+		DestroyOpusEncoder(opusEncoder)
+		return nil, err
+	}
+
+	ogg, err := NewOggWriter(w, serial)
+	if err != nil {
+		DestroyOpusEncoder(opusEncoder)
+		return nil, err
+	}
+
+	return &OggPCMWriter{encoder: opusEncoder, ogg: ogg}, nil
+}
+
+// Write encodes every complete frame of the PCM written so far.
+func (o *OggPCMWriter) Write(p []byte) (int, error) {
+	o.acc = append(o.acc, p...)
+
+	n := 0
+	for ; len(o.acc)-n >= FrameSize; n += FrameSize {
+		if err := WriteOpusFrame(o.encoder, o.ogg, o.acc[n:n+FrameSize]); err != nil {
+			return 0, err
+		}
+	}
+
+	o.acc = append(o.acc[:0], o.acc[n:]...)
+
+	return len(p), nil
+}
+
+// Close pads and encodes the last frame, writes the last page and frees the encoder.
+func (o *OggPCMWriter) Close() error {
+	if o.encoder == nil {
+		return nil
+	}
+	defer func() {
+		DestroyOpusEncoder(o.encoder)
+		o.encoder = nil
+	}()
+
+	if len(o.acc) > 0 {
+		o.acc = append(o.acc, make([]byte, FrameSize-len(o.acc))...)
+		if err := WriteOpusFrame(o.encoder, o.ogg, o.acc); err != nil {
+			return err
+		}
+		o.acc = o.acc[:0]
+	}
+
+	return o.ogg.Close()
+}
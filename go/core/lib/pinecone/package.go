@@ -12,8 +12,6 @@ import (
 )
 
 const (
-	baseURL = "https://starter-c85f00b.svc.northamerica-northeast1-gcp.pinecone.io"
-
 	statsStarterEndpoint  = "/describe_index_stats"
 	deleteStarterEndpoint = "/vectors/delete"
 	fetchStarterEndpoint  = "/vectors/fetch"
@@ -51,7 +49,7 @@ type ErrPinecone struct {
 
 func init() {
 	Resty = resty.New().
-		SetBaseURL(baseURL).
+		SetBaseURL(config.VARS.PineconeURL).
 		SetLogger(common.LogDiscard).
 		AddRetryCondition(func(r *resty.Response, err error) bool {
 			return r.StatusCode() == http.StatusTooManyRequests
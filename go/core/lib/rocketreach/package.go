@@ -11,7 +11,6 @@ import (
 )
 
 const (
-	baseURL        = "https://api.rocketreach.co/api/v2"
 	aboutEndpoint  = "/account/"
 	lookupEndpoint = "/profile-company/lookup"
 	searchEndpoint = "/search"
@@ -22,7 +21,7 @@ var Resty *resty.Client
 
 func init() {
 	Resty = resty.New().
-		SetBaseURL(baseURL).
+		SetBaseURL(config.VARS.RocketReachURL).
 		SetLogger(common.LogDiscard).
 		AddRetryCondition(func(r *resty.Response, err error) bool {
 			return r.StatusCode() == http.StatusTooManyRequests
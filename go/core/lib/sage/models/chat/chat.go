@@ -9,7 +9,7 @@ import (
 
 	"disruptive/config"
 	"disruptive/lib/common"
-	"disruptive/lib/openai"
+	"disruptive/lib/llm"
 	"disruptive/lib/sage/sessions"
 )
 
@@ -52,7 +52,12 @@ func Post(ctx context.Context, logCtx *slog.Logger, username, project, session,
 		return Response{}, common.ErrNotFound{Msg: "model not found"}
 	}
 
-	if !(m.Type == "chat" && m.Model == "gpt-3.5-turbo") {
+	if m.Type != "chat" {
+		return Response{}, common.ErrBadRequest{Msg: "invalid chat type and model"}
+	}
+
+	llmModel, provider, err := getProvider(ctx, logCtx, m.Model)
+	if err != nil {
 		return Response{}, common.ErrBadRequest{Msg: "invalid chat type and model"}
 	}
 
@@ -64,25 +69,25 @@ func Post(ctx context.Context, logCtx *slog.Logger, username, project, session,
 		}
 	}
 
-	req, _, prompts, err := createGPTReq(ctx, logCtx, client, username, project, session, model, m.Model, prompt != "")
+	req, _, prompts, err := createGPTReq(ctx, logCtx, client, username, project, session, model, prompt != "")
 	if err != nil {
 		logCtx.Error("unable to create chat request")
 		return Response{}, err
 	}
 
-	chatRes, err := openai.PostChat(ctx, logCtx, req)
+	chatRes, err := provider.Chat(ctx, logCtx, llmModel, req)
 	if err != nil {
-		logCtx.Error("unable to post openai chat.")
+		logCtx.Error("unable to post chat.", "provider", llmModel.Provider)
 		return Response{}, err
 	}
 
 	if prompt != "" {
-		if err := AddGPTAssistantEntry(ctx, logCtx, username, project, session, model, chatRes.Text, chatRes.UsagePrompt, chatRes.UsageResponse, prompts); err != nil {
+		if err := AddGPTAssistantEntry(ctx, logCtx, username, project, session, model, chatRes.Text, chatRes.TokensPrompt, chatRes.TokensResponse, prompts); err != nil {
 			logCtx.Error("unable to add response entry")
 			return Response{}, err
 		}
 	} else {
-		if err := AddGPTAssistantEntryVersion(ctx, logCtx, username, project, session, model, chatRes.Text, chatRes.UsagePrompt, chatRes.UsageResponse, prompts); err != nil {
+		if err := AddGPTAssistantEntryVersion(ctx, logCtx, username, project, session, model, chatRes.Text, chatRes.TokensPrompt, chatRes.TokensResponse, prompts); err != nil {
 			logCtx.Error("unable to add response entry version")
 			return Response{}, err
 		}
@@ -122,26 +127,47 @@ func PostStream(ctx context.Context, logCtx *slog.Logger, username, project, ses
 		return nil, 0, nil, 0, common.ErrNotFound{}
 	}
 
-	if m.Type == "chat" && m.Model == "gpt-3.5-turbo" {
-		entry := -1
+	if m.Type != "chat" {
+		return nil, 0, nil, 0, common.ErrBadRequest{}
+	}
 
-		// regenerate mode if prompt is empty
-		if prompt != "" {
-			entry, err = addGPTPromptEntry(ctx, logCtx, client, username, project, session, model, prompt)
-			if err != nil {
-				return nil, 0, nil, 0, err
-			}
-		}
+	llmModel, provider, err := getProvider(ctx, logCtx, m.Model)
+	if err != nil {
+		return nil, 0, nil, 0, common.ErrBadRequest{}
+	}
+
+	entry := -1
 
-		req, tokens, prompts, err := createGPTReq(ctx, logCtx, client, username, project, session, model, m.Model, prompt != "")
+	// regenerate mode if prompt is empty
+	if prompt != "" {
+		entry, err = addGPTPromptEntry(ctx, logCtx, client, username, project, session, model, prompt)
 		if err != nil {
 			return nil, 0, nil, 0, err
 		}
+	}
+
+	req, tokens, prompts, err := createGPTReq(ctx, logCtx, client, username, project, session, model, prompt != "")
+	if err != nil {
+		return nil, 0, nil, 0, err
+	}
 
-		r, err := openai.PostChatStream(ctx, logCtx, req)
+	r, err := provider.ChatStream(ctx, logCtx, llmModel, req)
 
-		return r, tokens, prompts, entry, err
+	return r, tokens, prompts, entry, err
+}
+
+// getProvider resolves the session model through the LLM model registry.
+func getProvider(ctx context.Context, logCtx *slog.Logger, name string) (llm.Model, llm.Provider, error) {
+	m, err := llm.GetModel(ctx, logCtx, name)
+	if err != nil {
+		return llm.Model{}, nil, err
+	}
+
+	p, err := llm.GetProvider(m)
+	if err != nil {
+		logCtx.Error("unable to get provider", "model", name, "error", err)
+		return llm.Model{}, nil, err
 	}
 
-	return nil, 0, nil, 0, common.ErrBadRequest{}
+	return m, p, nil
 }
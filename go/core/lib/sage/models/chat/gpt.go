@@ -11,6 +11,7 @@ import (
 
 	"disruptive/config"
 	"disruptive/lib/common"
+	"disruptive/lib/llm"
 	"disruptive/lib/openai"
 )
 
@@ -555,17 +556,16 @@ func addGPTPromptEntry(ctx context.Context, logCtx *slog.Logger, client *firesto
 }
 
 // createGPTReq assumes the current message has already been saved.
-func createGPTReq(ctx context.Context, logCtx *slog.Logger, client *firestore.Client, username, project, session, model, modelName string, includeLastEntry bool) (openai.ChatRequest, int, []string, error) {
+func createGPTReq(ctx context.Context, logCtx *slog.Logger, client *firestore.Client, username, project, session, model string, includeLastEntry bool) (llm.Request, int, []string, error) {
 	logCtx = logCtx.With("fid", "sage.models.chat.createGPTReq")
 
 	m, err := GetGPT(ctx, logCtx, client, username, project, session, model)
 	if err != nil {
 		logCtx.Error("unable to get model", "error", err)
-		return openai.ChatRequest{}, 0, nil, err
+		return llm.Request{}, 0, nil, err
 	}
 
-	req := openai.ChatRequest{
-		Model:      modelName,
+	req := llm.Request{
 		Creativity: m.Creativity,
 		MaxTokens:  m.MaxTokens,
 	}
@@ -574,7 +574,7 @@ func createGPTReq(ctx context.Context, logCtx *slog.Logger, client *firestore.Cl
 	chars := 4
 
 	if m.Personality != "" {
-		req.Messages = append(req.Messages, openai.ChatMessage{Role: "system", Content: m.Personality})
+		req.Messages = append(req.Messages, llm.Message{Role: "system", Content: m.Personality})
 		chars += len(m.Personality) + 16
 	}
 
@@ -586,12 +586,12 @@ func createGPTReq(ctx context.Context, logCtx *slog.Logger, client *firestore.Cl
 	if m.LastBlock >= 1 {
 		b, ok := m.Blocks[fmt.Sprintf("%04d", m.LastBlock-1)]
 		if !ok {
-			return openai.ChatRequest{}, 0, nil, common.ErrConsistency
+			return llm.Request{}, 0, nil, common.ErrConsistency
 		}
 
 		startEntry = b.End + 1
 		prompts = append(prompts, fmt.Sprintf("blocks.%04d.summary", m.LastBlock-1))
-		req.Messages = append(req.Messages, openai.ChatMessage{Role: "assistant", Content: b.Summary})
+		req.Messages = append(req.Messages, llm.Message{Role: "assistant", Content: b.Summary})
 	}
 
 	lastEntry := m.LastEntry
@@ -603,17 +603,17 @@ func createGPTReq(ctx context.Context, logCtx *slog.Logger, client *firestore.Cl
 		e, ok := m.Entries[fmt.Sprintf("%04d", i)]
 		if !ok {
 			logCtx.Error("consistency", "entry", i)
-			return openai.ChatRequest{}, 0, nil, common.ErrConsistency
+			return llm.Request{}, 0, nil, common.ErrConsistency
 		}
 
 		v, ok := e.Versions[fmt.Sprintf("%02d", e.CurrentVersion)]
 		if !ok {
 			logCtx.Error("consistency", "entry", i, "version", e.CurrentVersion)
-			return openai.ChatRequest{}, 0, nil, common.ErrConsistency
+			return llm.Request{}, 0, nil, common.ErrConsistency
 		}
 
 		prompts = append(prompts, fmt.Sprintf("entries.%04d.versions.%02d", i, e.CurrentVersion))
-		req.Messages = append(req.Messages, openai.ChatMessage{Role: e.Role, Content: v.Content})
+		req.Messages = append(req.Messages, llm.Message{Role: e.Role, Content: v.Content})
 		chars += len(v.Content) + 16
 	}
 
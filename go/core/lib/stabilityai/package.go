@@ -12,7 +12,6 @@ import (
 )
 
 const (
-	baseURL             = "https://api.stability.ai/v1/"
 	textToImageEndpoint = "generation/{engine_id}/text-to-image"
 )
 
@@ -32,7 +31,7 @@ var (
 
 func init() {
 	Resty = resty.New().
-		SetBaseURL(baseURL).
+		SetBaseURL(config.VARS.StabilityAIURL).
 		SetAuthToken(config.VARS.StabilityAIKey).
 		SetLogger(common.LogDiscard).
 		AddRetryCondition(func(r *resty.Response, err error) bool {
@@ -0,0 +1,32 @@
+package stt
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"disruptive/config"
+	"disruptive/lib/deepgram"
+)
+
+type deepgramEngine struct{}
+
+func (e *deepgramEngine) Transcribe(ctx context.Context, logCtx *slog.Logger, r io.Reader, _, language, version string) (Result, error) {
+	var (
+		text             string
+		detectedLanguage string
+		err              error
+	)
+
+	if config.VARS.DeepgramLive {
+		text, detectedLanguage, err = deepgram.PostTranscriptionsTextLive(ctx, logCtx, r, language, version)
+	} else {
+		text, detectedLanguage, err = deepgram.PostTranscriptionsText(ctx, logCtx, r, language, version)
+	}
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Text: text, DetectedLanguage: detectedLanguage}, nil
+}
@@ -0,0 +1,31 @@
+// Package stt routes speech-to-text requests to the engines configured for each language.
+// Engines are tried in order and the next engine is used when one fails or times out.
+package stt
+
+import (
+	"disruptive/config"
+	"disruptive/lib/openai"
+)
+
+const (
+	// EngineDeepgram transcribes with Deepgram, including its hosted Whisper models.
+	EngineDeepgram = "deepgram"
+
+	// EngineWhisper transcribes with the OpenAI Whisper API.
+	EngineWhisper = "whisper"
+
+	// EngineWhisperLocal transcribes with a self-hosted OpenAI-compatible Whisper server.
+	EngineWhisperLocal = "whisper-local"
+
+	defaultRoute = "default"
+)
+
+var engines map[string]Engine
+
+func init() {
+	engines = map[string]Engine{
+		EngineDeepgram:     &deepgramEngine{},
+		EngineWhisper:      &whisperEngine{transcribe: openai.PostTranscriptions},
+		EngineWhisperLocal: newWhisperClientEngine(openai.NewResty(config.VARS.STTWhisperLocalURL, config.VARS.STTWhisperLocalKey), config.VARS.STTWhisperLocalModel),
+	}
+}
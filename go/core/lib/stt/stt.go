@@ -0,0 +1,143 @@
+package stt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"disruptive/lib/common"
+	"disruptive/lib/configs"
+)
+
+// defaultTimeout is the per engine timeout when the route does not set one.
+const defaultTimeout = 20
+
+// engineReader pauses the engine timeout while a read waits for the audio, so the timeout counts the time the engine
+// takes once the audio is read and not the length of audio streamed as it is spoken.
+type engineReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (e *engineReader) Read(p []byte) (int, error) {
+	e.timer.Stop()
+	n, err := e.r.Read(p)
+	e.timer.Reset(e.timeout)
+	return n, err
+}
+
+// Result is an engine independent transcription.
+type Result struct {
+	Text             string `json:"text"`
+	DetectedLanguage string `json:"detected_language,omitempty"`
+	Engine           string `json:"engine"`
+}
+
+// Engine transcribes audio. language is empty when it should be detected.
+type Engine interface {
+	Transcribe(ctx context.Context, logCtx *slog.Logger, r io.Reader, extension, language, version string) (Result, error)
+}
+
+// defaultRoutes are used when the stt_routes config does not define the language.
+func defaultRoutes() configs.STTRoutes {
+	return configs.STTRoutes{
+		defaultRoute: {Engines: []string{EngineDeepgram, EngineWhisper}, Timeout: defaultTimeout},
+	}
+}
+
+// GetRoute returns the route for a language, falling back to its base language and then the default route.
+func GetRoute(ctx context.Context, logCtx *slog.Logger, language string) (configs.STTRoute, error) {
+	fid := slog.String("fid", "stt.GetRoute")
+
+	routes, err := configs.GetSTTRoutes(ctx, logCtx)
+	if err != nil && !errors.Is(err, common.ErrNotFound{}) {
+		logCtx.Error("unable to get stt routes", fid, "error", err)
+		return configs.STTRoute{}, err
+	}
+
+	keys := []string{language, strings.Split(language, "-")[0], defaultRoute}
+	if language == "" {
+		keys = []string{defaultRoute}
+	}
+
+	for _, k := range keys {
+		if r, ok := routes[k]; ok && len(r.Engines) > 0 {
+			return r, nil
+		}
+	}
+
+	return defaultRoutes()[defaultRoute], nil
+}
+
+// Transcribe transcribes r with the engines routed for the language. The audio is buffered as it is read
+// so the next engine gets the whole audio when an engine fails or times out. The engine timeout does not
+// count the time spent waiting for the audio. When every engine fails
+// the rest of r is drained, so a reader teeing r to the archive still stores the whole audio.
+func Transcribe(ctx context.Context, logCtx *slog.Logger, r io.Reader, extension, language, version string) (Result, error) {
+	fid := slog.String("fid", "stt.Transcribe")
+
+	route, err := GetRoute(ctx, logCtx, language)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if route.Timeout <= 0 {
+		route.Timeout = defaultTimeout
+	}
+
+	timeout := time.Duration(route.Timeout) * time.Second
+
+	var (
+		buf     bytes.Buffer
+		lastErr error
+	)
+
+	src := io.TeeReader(r, &buf)
+
+	for _, name := range route.Engines {
+		engine, ok := engines[name]
+		if !ok {
+			logCtx.Warn("invalid stt engine", fid, "engine", name)
+			continue
+		}
+
+		t := time.Now()
+		engineCtx, cancel := context.WithCancel(ctx)
+		timer := time.AfterFunc(timeout, cancel)
+
+		er := &engineReader{r: io.MultiReader(bytes.NewReader(buf.Bytes()), src), timer: timer, timeout: timeout}
+		res, err := engine.Transcribe(engineCtx, logCtx, er, extension, language, version)
+		timer.Stop()
+		cancel()
+
+		if err == nil {
+			logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "stt_engine", "engine", name)
+			res.Engine = name
+			return res, nil
+		}
+
+		if ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+
+		logCtx.Warn("stt engine failed", fid, "engine", name, "language", language, "error", err)
+		lastErr = err
+	}
+
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		logCtx.Warn("unable to drain audio", fid, "error", err)
+	}
+
+	if lastErr == nil {
+		logCtx.Error("no stt engine", fid, "language", language)
+		return Result{}, common.ErrNotFound{Msg: "no stt engine"}
+	}
+
+	logCtx.Error("unable to transcribe", fid, "language", language, "error", lastErr)
+	return Result{}, lastErr
+}
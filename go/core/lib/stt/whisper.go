@@ -0,0 +1,54 @@
+package stt
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+
+	"disruptive/lib/openai"
+)
+
+// whisperLanguages maps the detected ISO-639-1 code to the default regional language.
+var whisperLanguages = map[string]string{
+	"en": "en-US",
+	"es": "es-ES",
+	"fr": "fr-FR",
+	"pt": "pt-PT",
+}
+
+type whisperEngine struct {
+	transcribe func(ctx context.Context, logCtx *slog.Logger, file io.Reader, extension, language string) (openai.Transcription, error)
+}
+
+// newWhisperClientEngine returns a Whisper engine for an OpenAI-compatible server.
+func newWhisperClientEngine(client *resty.Client, model string) *whisperEngine {
+	return &whisperEngine{
+		transcribe: func(ctx context.Context, logCtx *slog.Logger, file io.Reader, extension, language string) (openai.Transcription, error) {
+			return openai.PostTranscriptionsWithClient(ctx, logCtx, client, model, file, extension, language)
+		},
+	}
+}
+
+func (e *whisperEngine) Transcribe(ctx context.Context, logCtx *slog.Logger, r io.Reader, extension, language, _ string) (Result, error) {
+	// Whisper takes the ISO-639-1 code without the region.
+	code := strings.ToLower(strings.Split(language, "-")[0])
+
+	t, err := e.transcribe(ctx, logCtx, r, extension, code)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{Text: strings.TrimSpace(t.Text)}
+
+	if language == "" {
+		res.DetectedLanguage = t.Language
+		if l, ok := whisperLanguages[t.Language]; ok {
+			res.DetectedLanguage = l
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,132 @@
+package tts
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"disruptive/lib/common"
+	"disruptive/lib/coqui"
+)
+
+// coquiFormats are the sample rates Coqui renders. The WAV header is stripped to stream raw PCM
+// and rewritten for WAV since Coqui sizes it for the whole file.
+var coquiFormats = []string{"pcm_22050", "pcm_24000", "wav_22050", "wav_24000"}
+
+type coquiEngine struct{}
+
+func (e *coquiEngine) Stream(ctx context.Context, logCtx *slog.Logger, req Request) (io.ReadCloser, error) {
+	fid := slog.String("fid", "tts.coquiEngine.Stream")
+
+	r, err := coqui.TTSStream(ctx, logCtx, coqui.Request{Voice: req.Voice, Text: req.Text})
+	if err != nil {
+		return nil, err
+	}
+
+	rc := readCloser(r)
+	br := bufio.NewReader(rc)
+
+	sampleRate, err := readWAVHeader(br)
+	if err != nil {
+		rc.Close()
+		logCtx.Error("invalid coqui wav", fid, "error", err)
+		return nil, err
+	}
+
+	if fmt.Sprintf("pcm_%d", sampleRate) != req.Format && fmt.Sprintf("wav_%d", sampleRate) != req.Format {
+		rc.Close()
+		logCtx.Error("coqui sample rate does not match format", fid, "sample_rate", sampleRate, "format", req.Format)
+		return nil, fmt.Errorf("coqui sample rate %d does not match format %s", sampleRate, req.Format)
+	}
+
+	var audio io.Reader = br
+
+	if strings.HasPrefix(req.Format, "wav_") {
+		var header bytes.Buffer
+		common.WriteWAVHeader(&header, sampleRate)
+		audio = io.MultiReader(&header, br)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{audio, rc}, nil
+}
+
+func (e *coquiEngine) Formats() []string {
+	return coquiFormats
+}
+
+func (e *coquiEngine) Voice(name string) (string, bool) {
+	if v, ok := coqui.Voices[name]; ok {
+		return v, true
+	}
+
+	v, ok := coqui.VoicesXTTS[name]
+	return v, ok
+}
+
+// readWAVHeader reads a RIFF/WAVE header up to the data chunk and returns the sample rate.
+// Only 16-bit mono PCM is accepted since that is what the pcm formats stream.
+func readWAVHeader(r io.Reader) (int, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+
+	if string(header[:4]) != "RIFF" || string(header[8:]) != "WAVE" {
+		return 0, errors.New("not a wav file")
+	}
+
+	sampleRate := 0
+	chunk := make([]byte, 8)
+
+	// Chunks are padded to an even size.
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return 0, err
+		}
+
+		id := string(chunk[:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+
+		switch id {
+		case "fmt ":
+			f := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, f); err != nil {
+				return 0, err
+			}
+
+			if len(f) < 16 {
+				return 0, errors.New("invalid wav fmt chunk")
+			}
+
+			format := binary.LittleEndian.Uint16(f[0:])
+			channels := binary.LittleEndian.Uint16(f[2:])
+			bits := binary.LittleEndian.Uint16(f[14:])
+
+			if format != 1 || channels != 1 || bits != 16 {
+				return 0, fmt.Errorf("unsupported wav format %d, channels %d, bits %d", format, channels, bits)
+			}
+
+			sampleRate = int(binary.LittleEndian.Uint32(f[4:]))
+
+		case "data":
+			if sampleRate == 0 {
+				return 0, errors.New("missing wav fmt chunk")
+			}
+			return sampleRate, nil
+
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package tts
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+
+	"disruptive/lib/elevenlabs"
+)
+
+type elevenlabsEngine struct{}
+
+func (e *elevenlabsEngine) Stream(ctx context.Context, logCtx *slog.Logger, req Request) (io.ReadCloser, error) {
+	v := elevenlabs.Voices[req.Voice]
+
+	r, err := elevenlabs.TTSStream(ctx, logCtx, elevenlabs.Request{
+		Format:                   req.Format,
+		Language:                 req.Language,
+		Model:                    req.Model,
+		OptimizeStreamingLatency: req.OptimizeStreamingLatency,
+		Voice:                    v.ID,
+		Text:                     req.Text,
+		SimilarityBoost:          v.SimilarityBoost,
+		Stability:                v.Stability,
+		StyleExaggeration:        v.StyleExaggeration,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return readCloser(r), nil
+}
+
+func (e *elevenlabsEngine) Formats() []string {
+	formats := make([]string, 0, len(elevenlabs.AudioFormatExtensions))
+	for f := range elevenlabs.AudioFormatExtensions {
+		formats = append(formats, f)
+	}
+	slices.Sort(formats)
+	return formats
+}
+
+func (e *elevenlabsEngine) Voice(name string) (string, bool) {
+	v, ok := elevenlabs.Voices[name]
+	return v.ID, ok
+}
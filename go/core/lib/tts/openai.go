@@ -0,0 +1,77 @@
+package tts
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+
+	"disruptive/lib/openai"
+)
+
+const (
+	openaiModel   = "tts-1"
+	openaiModelHD = "tts-1-hd"
+)
+
+var (
+	// openaiFormats maps formats to OpenAI response formats. OpenAI PCM and WAV are 24kHz.
+	openaiFormats = map[string]string{
+		"mp3_22050_32":  "mp3",
+		"mp3_44100_32":  "mp3",
+		"mp3_44100_64":  "mp3",
+		"mp3_44100_96":  "mp3",
+		"mp3_44100_128": "mp3",
+		"mp3_44100_192": "mp3",
+		"pcm_24000":     "pcm",
+		"wav_24000":     "wav",
+	}
+
+	openaiVoices = []string{"alloy", "ash", "coral", "echo", "fable", "nova", "onyx", "sage", "shimmer"}
+)
+
+// openaiEngine speaks with OpenAI or an OpenAI-compatible server. A nil voices accepts any voice
+// the server knows, a model overrides the requested model.
+type openaiEngine struct {
+	client *resty.Client
+	model  string
+	voices []string
+}
+
+func (e *openaiEngine) Stream(ctx context.Context, logCtx *slog.Logger, req Request) (io.ReadCloser, error) {
+	model := e.model
+	if model == "" {
+		model = openaiModel
+		if strings.HasPrefix(req.Model, "tts-") {
+			model = req.Model
+		} else if req.Model == "hd" {
+			model = openaiModelHD
+		}
+	}
+
+	return openai.PostSpeechWithClient(ctx, logCtx, e.client, openai.SpeechRequest{
+		Model:  model,
+		Input:  req.Text,
+		Voice:  req.Voice,
+		Format: openaiFormats[req.Format],
+	})
+}
+
+func (e *openaiEngine) Formats() []string {
+	formats := make([]string, 0, len(openaiFormats))
+	for f := range openaiFormats {
+		formats = append(formats, f)
+	}
+	slices.Sort(formats)
+	return formats
+}
+
+func (e *openaiEngine) Voice(name string) (string, bool) {
+	if e.voices == nil {
+		return name, name != ""
+	}
+	return name, slices.Contains(e.voices, name)
+}
@@ -0,0 +1,37 @@
+// Package tts converts text to speech with the engine configured for each character.
+// Characters name the engine and map profile voices to engine voices, so a character
+// moves between vendors with a config change.
+package tts
+
+import (
+	"disruptive/config"
+	"disruptive/lib/openai"
+)
+
+const (
+	// EngineElevenLabs speaks with ElevenLabs. It is the default engine.
+	EngineElevenLabs = "elevenlabs"
+
+	// EngineCoqui speaks with Coqui and XTTS voices.
+	EngineCoqui = "coqui"
+
+	// EngineOpenAI speaks with the OpenAI TTS API.
+	EngineOpenAI = "openai"
+
+	// EngineLocal speaks with a self-hosted OpenAI-compatible TTS server.
+	EngineLocal = "local"
+
+	// voiceSeparator separates an engine override from the voice, e.g. "openai:nova".
+	voiceSeparator = ":"
+)
+
+var engines map[string]Engine
+
+func init() {
+	engines = map[string]Engine{
+		EngineElevenLabs: &elevenlabsEngine{},
+		EngineCoqui:      &coquiEngine{},
+		EngineOpenAI:     &openaiEngine{client: openai.Resty, voices: openaiVoices},
+		EngineLocal:      &openaiEngine{client: openai.NewResty(config.VARS.TTSLocalURL, config.VARS.TTSLocalKey), model: config.VARS.TTSLocalModel},
+	}
+}
@@ -0,0 +1,105 @@
+package tts
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"disruptive/lib/common"
+)
+
+// Request contains engine independent input parameters for TTS.
+// Voice is the engine voice name, Format is one of the elevenlabs.AudioFormatExtensions formats.
+type Request struct {
+	Format                   string
+	Language                 string
+	Model                    string
+	OptimizeStreamingLatency string
+	Text                     string
+	Voice                    string
+}
+
+// Engine converts text to speech.
+type Engine interface {
+	// Stream returns the audio of req as it is synthesized.
+	Stream(ctx context.Context, logCtx *slog.Logger, req Request) (io.ReadCloser, error)
+
+	// Formats returns the audio formats the engine can stream.
+	Formats() []string
+
+	// Voice returns the engine voice ID of a voice name.
+	Voice(name string) (string, bool)
+}
+
+// Validate checks that the engine can speak the voice in the format before any text is generated.
+func Validate(engineName, voice, format string) error {
+	engineName, voice = route(engineName, voice)
+	_, err := getEngine(engineName, voice, format)
+	return err
+}
+
+// Stream converts req to speech with the engine. An empty engine is ElevenLabs and
+// a voice configured as "engine:voice" overrides the engine.
+func Stream(ctx context.Context, logCtx *slog.Logger, engineName string, req Request) (io.ReadCloser, error) {
+	fid := slog.String("fid", "tts.Stream")
+	t := time.Now()
+
+	engineName, req.Voice = route(engineName, req.Voice)
+
+	engine, err := getEngine(engineName, req.Voice, req.Format)
+	if err != nil {
+		logCtx.Error("invalid tts request", fid, "engine", engineName, "voice", req.Voice, "format", req.Format, "error", err)
+		return nil, err
+	}
+
+	r, err := engine.Stream(ctx, logCtx, req)
+	if err != nil {
+		logCtx.Error("unable to get tts stream", fid, "engine", engineName, "error", err)
+		return nil, err
+	}
+
+	logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "tts_engine", "engine", engineName)
+
+	return r, nil
+}
+
+// route returns the engine and voice, applying an "engine:voice" override.
+func route(engineName, voice string) (string, string) {
+	if e, v, ok := strings.Cut(voice, voiceSeparator); ok {
+		engineName, voice = e, v
+	}
+
+	if engineName == "" {
+		engineName = EngineElevenLabs
+	}
+
+	return engineName, voice
+}
+
+func getEngine(engineName, voice, format string) (Engine, error) {
+	engine, ok := engines[engineName]
+	if !ok {
+		return nil, common.ErrBadRequest{Msg: "invalid tts engine"}
+	}
+
+	if !slices.Contains(engine.Formats(), format) {
+		return nil, common.ErrBadRequest{Msg: "audio format not supported by tts engine"}
+	}
+
+	if _, ok := engine.Voice(voice); !ok {
+		return nil, common.ErrBadRequest{Msg: "invalid voice"}
+	}
+
+	return engine, nil
+}
+
+// readCloser lets callers stop the producer of a piped stream.
+func readCloser(r io.Reader) io.ReadCloser {
+	if rc, ok := r.(io.ReadCloser); ok {
+		return rc
+	}
+	return io.NopCloser(r)
+}
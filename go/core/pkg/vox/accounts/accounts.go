@@ -10,13 +10,12 @@ import (
 	"strings"
 	"time"
 
-	fs "cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
 
 	"disruptive/lib/common"
 	"disruptive/lib/configs"
+	"disruptive/lib/docstore"
 	"disruptive/lib/firebase"
-	"disruptive/lib/firestore"
 )
 
 // Document contains a firestore account document.
@@ -35,6 +34,7 @@ type Document struct {
 	Products         map[string]Product `firestore:"products" json:"products"`
 	Pin              string             `firestore:"pin" json:"pin,omitempty"`
 	Timezone         string             `firestore:"timezone" json:"timezone"`
+	UsageLimits      *UsageLimits       `firestore:"usage_limits,omitempty" json:"usage_limits,omitempty"`
 }
 
 // PatchDocument contains a firestore account patch document.
@@ -46,6 +46,7 @@ type PatchDocument struct {
 	Inactive         *bool           `firestore:"inactive" json:"inactive"`
 	Pin              *string         `firestore:"pin" json:"pin"`
 	Timezone         *string         `firestore:"timezone" json:"timezone"`
+	UsageLimits      *UsageLimits    `firestore:"usage_limits" json:"usage_limits"`
 }
 
 // Product contains account product attributes.
@@ -67,7 +68,7 @@ func GetAccount(ctx context.Context, logCtx *slog.Logger, uid string) (Document,
 
 	d := Document{}
 
-	collection := firestore.Client.Collection("accounts")
+	collection := docstore.Client.Collection("accounts")
 	if collection == nil {
 		logCtx.Error("unable to get account collection", fid)
 		return d, common.ErrNotFound{}
@@ -93,7 +94,7 @@ func GetAccount(ctx context.Context, logCtx *slog.Logger, uid string) (Document,
 func GetAccountByEmail(ctx context.Context, logCtx *slog.Logger, email string) (Document, error) {
 	fid := slog.String("fid", "vox.accounts.GetAccountByEmail")
 
-	collection := firestore.Client.Collection("accounts")
+	collection := docstore.Client.Collection("accounts")
 	if collection == nil {
 		logCtx.Error("unable to get account collection", fid)
 		return Document{}, common.ErrNotFound{}
@@ -128,7 +129,7 @@ func GetAccountByEmail(ctx context.Context, logCtx *slog.Logger, email string) (
 func CreateAccount(ctx context.Context, logCtx *slog.Logger, document Document) (Document, error) {
 	fid := slog.String("fid", "vox.accounts.CreateAccount")
 
-	collection := firestore.Client.Collection("accounts")
+	collection := docstore.Client.Collection("accounts")
 	if collection == nil {
 		logCtx.Error("unable to get account collection", fid)
 		return Document{}, common.ErrNotFound{}
@@ -136,7 +137,7 @@ func CreateAccount(ctx context.Context, logCtx *slog.Logger, document Document)
 
 	document.CreatedDate = time.Now()
 
-	if _, err := collection.Doc(document.ID).Create(ctx, document); err != nil {
+	if err := collection.Doc(document.ID).Create(ctx, document); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to create accounts document", fid, "error", err)
 		return Document{}, err
@@ -161,48 +162,56 @@ func CreateAccount(ctx context.Context, logCtx *slog.Logger, document Document)
 func PatchAccount(ctx context.Context, logCtx *slog.Logger, document PatchDocument) (Document, error) {
 	fid := slog.String("fid", "vox.accounts.PatchAccount")
 
-	collection := firestore.Client.Collection("accounts")
+	collection := docstore.Client.Collection("accounts")
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return Document{}, common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{}
+	updates := []docstore.Update{}
 
 	if document.DeveloperModeMap != nil {
 		for k, v := range *document.DeveloperModeMap {
 			if v == nil {
-				v = fs.Delete
+				v = docstore.Delete
 			}
 
-			updates = append(updates, fs.Update{Path: "developer_mode_map." + k, Value: v})
+			updates = append(updates, docstore.Update{Path: "developer_mode_map." + k, Value: v})
 		}
 	}
 
 	if document.DisplayName != nil {
-		updates = append(updates, fs.Update{Path: "display_name", Value: *document.DisplayName})
+		updates = append(updates, docstore.Update{Path: "display_name", Value: *document.DisplayName})
 	}
 
 	if document.Email != nil {
-		updates = append(updates, fs.Update{Path: "email", Value: *document.Email})
+		updates = append(updates, docstore.Update{Path: "email", Value: *document.Email})
 	}
 
 	if document.Inactive != nil {
-		updates = append(updates, fs.Update{Path: "inactive", Value: *document.Inactive})
+		updates = append(updates, docstore.Update{Path: "inactive", Value: *document.Inactive})
 	}
 
 	if document.Pin != nil {
-		updates = append(updates, fs.Update{Path: "pin", Value: *document.Pin})
+		updates = append(updates, docstore.Update{Path: "pin", Value: *document.Pin})
 	}
 
 	if document.Timezone != nil {
-		updates = append(updates, fs.Update{Path: "timezone", Value: *document.Timezone})
+		updates = append(updates, docstore.Update{Path: "timezone", Value: *document.Timezone})
+	}
+
+	if document.UsageLimits != nil {
+		if err := ValidateUsageLimits(*document.UsageLimits); err != nil {
+			return Document{}, err
+		}
+
+		updates = append(updates, docstore.Update{Path: "usage_limits", Value: *document.UsageLimits})
 	}
 
 	if len(updates) > 0 {
-		updates = append(updates, fs.Update{Path: "modified_date", Value: time.Now()})
+		updates = append(updates, docstore.Update{Path: "modified_date", Value: time.Now()})
 
-		if _, err := collection.Doc(document.ID).Update(ctx, updates); err != nil {
+		if err := collection.Doc(document.ID).Update(ctx, updates); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Warn("unable to update account document", fid, "error", err)
 			return Document{}, err
@@ -228,17 +237,17 @@ func DeleteAccount(ctx context.Context, _ *slog.Logger, uid string) error {
 func PutProducts(ctx context.Context, logCtx *slog.Logger, uid, product string, value Product) error {
 	fid := slog.String("fid", "vox.accounts.PutProducts")
 
-	collection := firestore.Client.Collection("accounts")
+	collection := docstore.Client.Collection("accounts")
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{
+	updates := []docstore.Update{
 		{Path: fmt.Sprintf("products.%s", product), Value: value},
 	}
 
-	if _, err := collection.Doc(uid).Update(ctx, updates); err != nil {
+	if err := collection.Doc(uid).Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to update account document product", fid, "error", err)
 		return err
@@ -303,7 +312,7 @@ func ConnectProductDevice(ctx context.Context, logCtx *slog.Logger, product, dev
 	// deviceID is a MAC address. verify the MAC address.
 
 	productIDPath := fmt.Sprintf("products/%s/ids", product)
-	collection := firestore.Client.Collection(productIDPath)
+	collection := docstore.Client.Collection(productIDPath)
 	if collection == nil {
 		logCtx.Error("configs character IDs collection not found", fid)
 		return ProductDeviceConnect{}, common.ErrNotFound{}
@@ -322,7 +331,7 @@ func ConnectProductDevice(ctx context.Context, logCtx *slog.Logger, product, dev
 			}
 
 			// whitelist=true, add deviceID to factory ID list.
-			if _, err := collection.Doc(deviceID).Set(ctx, map[string]any{"account_id": account.ID, "timestamp": time.Now(), "white_list": true}); err != nil {
+			if err := collection.Doc(deviceID).Set(ctx, map[string]any{"account_id": account.ID, "timestamp": time.Now(), "white_list": true}); err != nil {
 				err = common.ConvertGRPCError(err)
 				logCtx.Error("unable to add character_id to factory list", fid, "error", err)
 				return ProductDeviceConnect{}, err
@@ -356,17 +365,17 @@ func ConnectProductDevice(ctx context.Context, logCtx *slog.Logger, product, dev
 	var productDoc ProductDeviceConnect
 	if validID {
 		// add valid deviceID to account product ID list
-		collection = firestore.Client.Collection("accounts")
+		collection = docstore.Client.Collection("accounts")
 		if collection == nil {
 			logCtx.Error("unable to get accounts collection", fid)
 			return ProductDeviceConnect{}, common.ErrNotFound{}
 		}
 
-		updates := []fs.Update{
-			{Path: fmt.Sprintf("products.%s.ids", product), Value: fs.ArrayUnion(deviceID)},
+		updates := []docstore.Update{
+			{Path: fmt.Sprintf("products.%s.ids", product), Value: docstore.ArrayUnion(deviceID)},
 		}
 
-		if _, err := collection.Doc(account.ID).Update(ctx, updates); err != nil {
+		if err := collection.Doc(account.ID).Update(ctx, updates); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to update account document character", fid, "error", err)
 			return ProductDeviceConnect{}, err
@@ -374,13 +383,13 @@ func ConnectProductDevice(ctx context.Context, logCtx *slog.Logger, product, dev
 
 		if firstTime {
 			// add timestamp to factory deviceID and the accountID of the account it is assigned to.
-			updates = []fs.Update{
+			updates = []docstore.Update{
 				{Path: "account_id", Value: account.ID},
 				{Path: "timestamp", Value: time.Now()},
 			}
 
-			collection = firestore.Client.Collection(productIDPath)
-			if _, err := collection.Doc(deviceID).Update(ctx, updates); err != nil {
+			collection = docstore.Client.Collection(productIDPath)
+			if err := collection.Doc(deviceID).Update(ctx, updates); err != nil {
 				err = common.ConvertGRPCError(err)
 				logCtx.Error("unable to add deviceID to factory list", fid, "error", err)
 				return ProductDeviceConnect{}, err
@@ -420,17 +429,17 @@ func ConnectProductDevice(ctx context.Context, logCtx *slog.Logger, product, dev
 func DeleteCharacter(ctx context.Context, logCtx *slog.Logger, uid, characterName string) error {
 	fid := slog.String("fid", "vox.accounts.DeleteCharacter")
 
-	collection := firestore.Client.Collection("accounts")
+	collection := docstore.Client.Collection("accounts")
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{
-		{Path: fmt.Sprintf("characters.%s", characterName), Value: fs.Delete},
+	updates := []docstore.Update{
+		{Path: fmt.Sprintf("characters.%s", characterName), Value: docstore.Delete},
 	}
 
-	if _, err := collection.Doc(uid).Update(ctx, updates); err != nil {
+	if err := collection.Doc(uid).Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to delete account document character", fid, "error", err)
 		return err
@@ -485,15 +494,15 @@ func firstTimeIOSFirmware20(ctx context.Context, logCtx *slog.Logger, productCfg
 	account := ctx.Value(common.AccountKey).(Document)
 
 	path := fmt.Sprintf("accounts/%s/bank", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return ProductDeviceConnect{}, common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{{Path: "first_time_ios_firmware_20", Value: true}}
+	updates := []docstore.Update{{Path: "first_time_ios_firmware_20", Value: true}}
 
-	if _, err := collection.Doc("balance").Update(ctx, updates); err != nil {
+	if err := collection.Doc("balance").Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Warn("unable to update accounts bank document", fid, "error", err)
 		return ProductDeviceConnect{}, err
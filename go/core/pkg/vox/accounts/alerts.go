@@ -0,0 +1,255 @@
+package accounts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"disruptive/config"
+	"disruptive/lib/common"
+	"disruptive/lib/configs"
+	"disruptive/lib/docstore"
+)
+
+// Account preferences for low balance alerts. Email and push are on unless set to false,
+// and push needs the device's FCM token.
+const (
+	PreferenceFCMToken        = "fcm_token"
+	PreferenceLowBalanceEmail = "low_balance_email"
+	PreferenceLowBalancePush  = "low_balance_push"
+)
+
+// LowBalanceAlert records the low balance alerts sent to an account in a billing period.
+type LowBalanceAlert struct {
+	Period string               `firestore:"period" json:"period"`
+	Sent   map[string]time.Time `firestore:"sent" json:"sent"`
+}
+
+const lowBalanceHTML = `
+<!DOCTYPE html>
+<html>
+<body>
+<h2>Low Vexels</h2>
+<ul>
+	<li><b>Email:</b> {{ .Email }}</li>
+	<li><b>ID:</b> {{ .ID }}</li>
+	<li><b>Project:</b> {{ .Project }}</li>
+	<li><b>Balance:</b> {{ .Balance }}</li>
+</ul>
+</body>
+</html>
+`
+
+const lowBalanceAccountHTML = `
+<!DOCTYPE html>
+<html>
+<body>
+<h2>Your Vexels are running low</h2>
+<p>You have {{ .Balance }} Vexels left. Add Vexels or subscribe to keep talking with your characters.</p>
+</body>
+</html>
+`
+
+type lowBalanceVars struct {
+	Email   string
+	ID      string
+	Project string
+	Balance int
+}
+
+// AlertLowBalance alerts the account when its total balance, purchased and subscription, has fallen to a threshold
+// configured for its products and tier.
+// Each threshold alerts at most once per billing period, and crossing several thresholds at once sends one alert.
+func AlertLowBalance(ctx context.Context, logCtx *slog.Logger, tier string, balance BalanceInfo) {
+	fid := slog.String("fid", "vox.accounts.AlertLowBalance")
+
+	account := ctx.Value(common.AccountKey).(Document)
+
+	alerts, err := configs.GetLowBalanceAlerts(ctx, logCtx)
+	if err != nil {
+		if !errors.Is(err, common.ErrNotFound{}) {
+			logCtx.Warn("unable to get low balance alerts config", fid, "error", err)
+		}
+		return
+	}
+
+	products := make([]string, 0, len(account.Products))
+	for p := range account.Products {
+		products = append(products, p)
+	}
+	sort.Strings(products)
+
+	thresholds := alerts.Thresholds(products, tier)
+
+	// The lowest threshold reached is the one to alert, the higher ones are implied.
+	crossed := []int{}
+	for _, t := range thresholds {
+		if balance.TotalBalance <= t {
+			crossed = append(crossed, t)
+		}
+	}
+
+	if len(crossed) == 0 {
+		return
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(crossed)))
+	threshold := crossed[len(crossed)-1]
+
+	sent, err := claimLowBalanceAlert(ctx, account.ID, crossed)
+	if err != nil {
+		logCtx.Warn("unable to record low balance alert", fid, "error", err)
+		return
+	}
+
+	if !sent {
+		return
+	}
+
+	logCtx.Info("low balance", fid, "email", account.Email, "id", account.ID, "threshold", threshold, "balance", balance.TotalBalance)
+
+	sendLowBalanceAlert(ctx, logCtx, account, balance.TotalBalance)
+}
+
+// claimLowBalanceAlert marks the crossed thresholds as sent for the current billing period
+// and reports whether the lowest of them had not been sent yet.
+func claimLowBalanceAlert(ctx context.Context, accountID string, crossed []int) (bool, error) {
+	balanceRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", accountID)).Doc("balance")
+	alerts := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank/balance/alerts", accountID))
+
+	var claimed bool
+
+	err := docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		claimed = false
+
+		doc, err := tx.Get(balanceRef)
+		if err != nil && !errors.Is(common.ConvertGRPCError(err), common.ErrNotFound{}) {
+			return err
+		}
+
+		b := BalanceDocument{}
+		if doc.Exists() {
+			if err := doc.DataTo(&b); err != nil {
+				return err
+			}
+		}
+
+		period := billingPeriod(b, time.Now())
+		alertRef := alerts.Doc(period)
+
+		doc, err = tx.Get(alertRef)
+		if err != nil && !errors.Is(common.ConvertGRPCError(err), common.ErrNotFound{}) {
+			return err
+		}
+
+		a := LowBalanceAlert{Period: period, Sent: map[string]time.Time{}}
+		if doc.Exists() {
+			if err := doc.DataTo(&a); err != nil {
+				return err
+			}
+
+			if a.Sent == nil {
+				a.Sent = map[string]time.Time{}
+			}
+		}
+
+		lowest := strconv.Itoa(crossed[len(crossed)-1])
+		if _, ok := a.Sent[lowest]; ok {
+			return nil
+		}
+
+		now := time.Now().UTC()
+		for _, t := range crossed {
+			if _, ok := a.Sent[strconv.Itoa(t)]; !ok {
+				a.Sent[strconv.Itoa(t)] = now
+			}
+		}
+
+		claimed = true
+		return tx.Set(alertRef, a)
+	})
+
+	return claimed, common.ConvertGRPCError(err)
+}
+
+// billingPeriod returns the subscription period start of a subscribed account, otherwise the calendar month.
+func billingPeriod(b BalanceDocument, now time.Time) string {
+	if b.SubscriptionSKU != "" && !b.SubscriptionStartDate.IsZero() {
+		return b.SubscriptionStartDate.UTC().Format("20060102T150405Z")
+	}
+
+	return now.UTC().Format("2006-01")
+}
+
+func sendLowBalanceAlert(ctx context.Context, logCtx *slog.Logger, account Document, balance int) {
+	fid := slog.String("fid", "vox.accounts.sendLowBalanceAlert")
+
+	vars := lowBalanceVars{
+		Email:   account.Email,
+		ID:      account.ID,
+		Project: config.VARS.FirebaseProject,
+		Balance: balance,
+	}
+
+	if config.VARS.MailgunLowBalanceNotificationsTo != "" {
+		emailLowBalance(ctx, logCtx, lowBalanceHTML, strings.Split(config.VARS.MailgunLowBalanceNotificationsTo, ","), vars)
+	}
+
+	if account.Email != "" && preferenceEnabled(account.Preferences, PreferenceLowBalanceEmail) {
+		emailLowBalance(ctx, logCtx, lowBalanceAccountHTML, []string{account.Email}, vars)
+	}
+
+	token, _ := account.Preferences[PreferenceFCMToken].(string)
+	if token != "" && preferenceEnabled(account.Preferences, PreferenceLowBalancePush) {
+		req := PushNotificationRequest{
+			FCMToken: token,
+			Title:    "Low Vexels",
+			Body:     fmt.Sprintf("You have %d Vexels left.", balance),
+		}
+
+		if _, err := PushNotification(ctx, logCtx, req); err != nil {
+			logCtx.Warn("unable to push low balance notification", fid, "error", err)
+		}
+	}
+}
+
+func emailLowBalance(ctx context.Context, logCtx *slog.Logger, html string, to []string, vars lowBalanceVars) {
+	fid := slog.String("fid", "vox.accounts.emailLowBalance")
+
+	t, err := template.New("t").Parse(html)
+	if err != nil {
+		logCtx.Warn("unable to create low balance HTML template", fid, "error", err)
+		return
+	}
+
+	var buf bytes.Buffer
+
+	if err := t.Execute(&buf, vars); err != nil {
+		logCtx.Warn("unable to execute low balance HTML template", fid, "error", err)
+		return
+	}
+
+	req := EmailRequest{
+		From:    config.VARS.MailgunNotificationsFrom,
+		To:      to,
+		Subject: "Low Vexels",
+		HTML:    buf.String(),
+	}
+
+	if err := SendEmail(ctx, logCtx, req); err != nil {
+		logCtx.Warn("unable to send mail", fid, "error", err)
+	}
+}
+
+// preferenceEnabled reports whether a boolean preference is on. Missing preferences are on.
+func preferenceEnabled(preferences map[string]any, key string) bool {
+	v, ok := preferences[key].(bool)
+	return !ok || v
+}
@@ -4,15 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
-	fs "cloud.google.com/go/firestore"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"disruptive/lib/common"
 	"disruptive/lib/configs"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 	"disruptive/pkg/vox/bank"
 )
 
@@ -24,6 +24,7 @@ type BalanceDocument struct {
 	SubscriptionPending    string    `firestore:"subscription_pending" json:"subscription_pending,omitempty"`
 	SubscriptionSKU        string    `firestore:"subscription_sku" json:"subscription_sku"`
 	SubscriptionStartDate  time.Time `firestore:"subscription_start_date" json:"subscription_start_date"`
+	SubscriptionStore      string    `firestore:"subscription_store" json:"subscription_store,omitempty"`
 }
 
 // BalanceInfo contains an accounts balances
@@ -34,6 +35,16 @@ type BalanceInfo struct {
 	TotalBalance        int `json:"total_balance"`
 }
 
+// ChargeDocument records an idempotent charge and the balance it left.
+type ChargeDocument struct {
+	Balance          BalanceDocument `firestore:"balance" json:"balance"`
+	CharacterVersion string          `firestore:"character_version" json:"character_version"`
+	Cost             int             `firestore:"cost" json:"cost"`
+	SessionID        int             `firestore:"session_id,omitempty" json:"session_id,omitempty"`
+	Tier             string          `firestore:"tier" json:"tier"`
+	Timestamp        time.Time       `firestore:"timestamp" json:"timestamp"`
+}
+
 // RedeemedCardInfo contains info on redeeming a gift card
 type RedeemedCardInfo struct {
 	Balance       int    `json:"balance"`
@@ -48,7 +59,7 @@ func GetBalance(ctx context.Context, logCtx *slog.Logger) (BalanceDocument, erro
 	account := ctx.Value(common.AccountKey).(Document)
 
 	path := fmt.Sprintf("accounts/%s/bank", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("unable to get account collection", fid)
 		return BalanceDocument{}, common.ErrNotFound{}
@@ -88,7 +99,7 @@ func GetAvailableBalance(ctx context.Context, logCtx *slog.Logger) (BalanceInfo,
 	account := ctx.Value(common.AccountKey).(Document)
 
 	path := fmt.Sprintf("accounts/%s/bank", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("unable to get account collection", fid)
 		return BalanceInfo{}, common.ErrNotFound{}
@@ -130,27 +141,38 @@ func GetAvailableBalance(ctx context.Context, logCtx *slog.Logger) (BalanceInfo,
 
 // AddBalance adds the amount to the user's balance.
 func AddBalance(ctx context.Context, logCtx *slog.Logger, amount int) (BalanceDocument, error) {
+	return addBalance(ctx, logCtx, amount, bank.ReasonAdd)
+}
+
+func addBalance(ctx context.Context, logCtx *slog.Logger, amount int, reason string) (BalanceDocument, error) {
 	fid := slog.String("fid", "vox.accounts.AddBalance")
 
 	account := ctx.Value(common.AccountKey).(Document)
 
 	path := fmt.Sprintf("accounts/%s/bank", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return BalanceDocument{}, common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{
-		{Path: "balance", Value: fs.Increment(amount)},
+	updates := []docstore.Update{
+		{Path: "balance", Value: docstore.Increment(amount)},
 	}
 
-	if len(updates) > 0 {
-		if _, err := collection.Doc("balance").Update(ctx, updates); err != nil {
-			err = common.ConvertGRPCError(err)
-			logCtx.Warn("unable to update accounts bank document", fid, "error", err)
-			return BalanceDocument{}, err
+	entry := bank.LedgerEntry{Amount: amount, Bucket: bank.BucketPurchased, Reason: reason}
+
+	err := docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		if err := tx.Update(collection.Doc("balance"), updates); err != nil {
+			return err
 		}
+
+		return bank.AddLedgerEntriesTx(tx, account.ID, entry)
+	})
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Warn("unable to update accounts bank document", fid, "error", err)
+		return BalanceDocument{}, err
 	}
 
 	b, err := GetBalance(ctx, logCtx)
@@ -162,14 +184,21 @@ func AddBalance(ctx context.Context, logCtx *slog.Logger, amount int) (BalanceDo
 	return b, nil
 }
 
-// ChargeBank charges an account's bank.
-func ChargeBank(ctx context.Context, logCtx *slog.Logger, characterVersion, tier string) (BalanceDocument, error) {
+// ChargeBank charges an account's bank in a transaction so concurrent charges cannot overdraw it.
+// A charge with an idempotency key, such as the audio id, is applied once and repeating it returns
+// the original balance. sessionID is recorded in the ledger when known.
+func ChargeBank(ctx context.Context, logCtx *slog.Logger, characterVersion, tier string, sessionID int, idempotencyKey string) (BalanceDocument, error) {
 	fid := slog.String("fid", "vox.accounts.ChargeBank")
 
 	account := ctx.Value(common.AccountKey).(Document)
 
+	if strings.Contains(idempotencyKey, "/") {
+		logCtx.Error("invalid idempotency key", fid, "idempotency_key", idempotencyKey)
+		return BalanceDocument{}, common.ErrBadRequest{Src: "ChargeBank", Msg: "invalid idempotency key"}
+	}
+
 	path := fmt.Sprintf("accounts/%s/bank", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return BalanceDocument{}, common.ErrNotFound{}
@@ -187,111 +216,148 @@ func ChargeBank(ctx context.Context, logCtx *slog.Logger, characterVersion, tier
 		return BalanceDocument{}, common.ErrNotFound{Msg: "unable to get character tier and rate"}
 	}
 
-	updates := []fs.Update{}
+	balanceRef := collection.Doc("balance")
 
-	balance, err := GetAvailableBalance(ctx, logCtx)
-	if err != nil {
-		logCtx.Error("unable to get bank balance", fid, "error", err)
-		return BalanceDocument{}, err
+	var chargeRef *docstore.DocumentRef
+	if idempotencyKey != "" {
+		chargeRef = docstore.Client.Collection(path + "/balance/charges").Doc(idempotencyKey)
 	}
 
-	// have enough balance to pay outright
-	if balance.SubscriptionBalance >= cost {
-		updates = append(updates, fs.Update{Path: "subscription_balance", Value: fs.Increment(-cost)})
-	}
+	var (
+		b       BalanceDocument
+		charged bool
+	)
 
-	if len(updates) == 0 {
-		if balance.Balance >= cost {
-			updates = append(updates, fs.Update{Path: "balance", Value: fs.Increment(-cost)})
-		}
-	}
+	err = docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		charged = false
 
-	// need to split cost across both balances
-	if len(updates) == 0 {
-		totalPaid := 0
-		if balance.TotalBalance > 0 {
-			if balance.SubscriptionBalance > 0 {
-				updates = append(updates, fs.Update{Path: "subscription_balance", Value: 0})
-				totalPaid += balance.SubscriptionBalance
+		if chargeRef != nil {
+			doc, err := tx.Get(chargeRef)
+			if err != nil && status.Code(err) != codes.NotFound {
+				return err
 			}
 
-			remainingPayment := cost - totalPaid
-			if balance.Balance > 0 && remainingPayment > 0 {
-				if balance.Balance >= remainingPayment {
-					updates = append(updates, fs.Update{Path: "balance", Value: fs.Increment(-remainingPayment)})
-				} else {
-					updates = append(updates, fs.Update{Path: "balance", Value: 0})
+			if doc.Exists() {
+				charge := ChargeDocument{}
+				if err := doc.DataTo(&charge); err != nil {
+					return err
 				}
+
+				b = charge.Balance
+				charged = true
+				return nil
 			}
 		}
-	}
 
-	if len(updates) == 0 {
-		return BalanceDocument{}, common.ErrPaymentRequired{Src: "ChargeBank", Msg: "user does not have sufficient balance"}
-	}
+		doc, err := tx.Get(balanceRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		balance := BalanceDocument{}
+		if doc.Exists() {
+			if err := doc.DataTo(&balance); err != nil {
+				return err
+			}
+		}
+
+		subscription, purchased, ok := splitCharge(balance, cost)
+		if !ok {
+			return common.ErrPaymentRequired{Src: "ChargeBank", Msg: "user does not have sufficient balance"}
+		}
+
+		balance.SubscriptionBalance -= subscription
+		balance.Balance -= purchased
+
+		data := map[string]any{
+			"subscription_balance": balance.SubscriptionBalance,
+			"balance":              balance.Balance,
+		}
 
-	if _, err := collection.Doc("balance").Update(ctx, updates); err != nil {
+		if err := tx.Set(balanceRef, data, docstore.MergeAll); err != nil {
+			return err
+		}
+
+		entry := bank.LedgerEntry{
+			Bucket:    bank.BucketSubscription,
+			Reason:    bank.ReasonCharge,
+			Character: characterVersion,
+			Tier:      tier,
+			SessionID: sessionID,
+			SourceID:  idempotencyKey,
+		}
+
+		entries := []bank.LedgerEntry{entry, entry}
+		entries[0].Amount = -subscription
+		entries[1].Amount = -purchased
+		entries[1].Bucket = bank.BucketPurchased
+
+		if err := bank.AddLedgerEntriesTx(tx, account.ID, entries...); err != nil {
+			return err
+		}
+
+		b = balance
+
+		if chargeRef == nil {
+			return nil
+		}
+
+		charge := ChargeDocument{
+			Balance:          balance,
+			CharacterVersion: characterVersion,
+			Cost:             subscription + purchased,
+			SessionID:        sessionID,
+			Tier:             tier,
+			Timestamp:        time.Now().UTC(),
+		}
+
+		return tx.Create(chargeRef, charge)
+	})
+	if err != nil {
 		err = common.ConvertGRPCError(err)
-		logCtx.Warn("unable to update account bank", fid, "error", err)
+		logCtx.Warn("unable to charge account bank", fid, "error", err)
 		return BalanceDocument{}, err
 	}
 
-	b, err := GetBalance(ctx, logCtx)
-	if err != nil {
-		logCtx.Error("unable to get account balance document", fid, "error", err)
-		return BalanceDocument{}, err
+	if charged {
+		logCtx.Info("account bank already charged", fid, "idempotency_key", idempotencyKey)
 	}
 
 	return b, nil
 }
 
-// RedeemGiftCard redeems a gift card and adds the balance to the account.
-func RedeemGiftCard(ctx context.Context, logCtx *slog.Logger, giftCard string) (RedeemedCardInfo, error) {
-	fid := slog.String("fid", "vox.accounts.RedeemGiftCard")
-
-	account := ctx.Value(common.AccountKey).(Document)
-
-	gcs, err := bank.GetGiftCards(ctx, logCtx, "")
-	if err != nil {
-		logCtx.Error("unable to get current gift cards", fid, "error", err)
-		return RedeemedCardInfo{}, err
+// splitCharge returns the cost paid from the subscription and purchased balances. A cost that
+// neither balance covers is split across both and the account pays what it has left.
+func splitCharge(b BalanceDocument, cost int) (int, int, bool) {
+	switch {
+	case b.SubscriptionBalance >= cost:
+		return cost, 0, true
+	case b.Balance >= cost:
+		return 0, cost, true
+	case b.SubscriptionBalance+b.Balance <= 0:
+		return 0, 0, false
 	}
 
-	gc, ok := gcs[giftCard]
-	if !ok {
-		logCtx.Error("invalid gift card", fid)
-		return RedeemedCardInfo{}, common.ErrNotFound{Msg: "invalid gift card"}
-	}
+	subscription := max(b.SubscriptionBalance, 0)
+	purchased := 0
 
-	if !gc.Start.IsZero() && gc.Start.After(time.Now()) {
-		logCtx.Warn("gift card not started", fid)
-		return RedeemedCardInfo{}, common.ErrUnprocessable{Msg: "gift card not started"}
+	if remaining := cost - subscription; b.Balance > 0 && remaining > 0 {
+		purchased = min(b.Balance, remaining)
 	}
 
-	path := fmt.Sprintf("accounts/%s/bank", account.ID)
-	collection := firestore.Client.Collection(path)
-	if collection == nil {
-		logCtx.Error("unable to get accounts collection", fid)
-		return RedeemedCardInfo{}, common.ErrNotFound{}
-	}
+	return subscription, purchased, true
+}
 
-	updates := []fs.Update{
-		{Path: "balance", Value: fs.Increment(gc.Value)},
-	}
+// RedeemGiftCard redeems a gift card and adds the balance to the account.
+func RedeemGiftCard(ctx context.Context, logCtx *slog.Logger, giftCard string) (RedeemedCardInfo, error) {
+	fid := slog.String("fid", "vox.accounts.RedeemGiftCard")
 
-	if len(updates) > 0 {
-		if _, err := collection.Doc("balance").Update(ctx, updates); err != nil {
-			err = common.ConvertGRPCError(err)
-			logCtx.Warn("unable to update promo codes", fid, "error", err)
-			return RedeemedCardInfo{}, err
-		}
-	}
+	account := ctx.Value(common.AccountKey).(Document)
 
-	if gc.OneTimeUse {
-		if err := bank.ExpireGiftCard(ctx, logCtx, giftCard); err != nil {
-			logCtx.Error("unable to expire one time use gift card", fid)
-			return RedeemedCardInfo{}, err
-		}
+	gc, err := bank.RedeemGiftCard(ctx, logCtx, account.ID, giftCard)
+	if err != nil {
+		logCtx.Warn("unable to redeem gift card", fid, "error", err)
+		return RedeemedCardInfo{}, err
 	}
 
 	b, err := GetBalance(ctx, logCtx)
@@ -313,13 +379,13 @@ func CreateBalanceDocument(ctx context.Context, logCtx *slog.Logger, accountID s
 	fid := slog.String("fid", "vox.accounts.CreateBalanceDocument")
 
 	path := fmt.Sprintf("accounts/%s/bank", accountID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("unable to get account collection", fid)
 		return common.ErrNotFound{}
 	}
 
-	_, err := collection.Doc("balance").Set(ctx, BalanceDocument{})
+	err := collection.Doc("balance").Set(ctx, BalanceDocument{})
 	if err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to get create accounts balance document", fid, "error", err)
@@ -336,7 +402,7 @@ func BumpTo20kVexels(ctx context.Context, logCtx *slog.Logger, balance int) erro
 	valueTo20k := 20000 - balance
 
 	// bump balance to 20k vexels
-	if _, err := AddBalance(ctx, logCtx, valueTo20k); err != nil {
+	if _, err := addBalance(ctx, logCtx, valueTo20k, bank.ReasonFreeVexels); err != nil {
 		logCtx.Error("unable to add to account balance", fid)
 		return err
 	}
@@ -344,19 +410,19 @@ func BumpTo20kVexels(ctx context.Context, logCtx *slog.Logger, balance int) erro
 	// update account used_codes with a timestamp of the free vexel transaction
 	account := ctx.Value(common.AccountKey).(Document)
 
-	collection := firestore.Client.Collection(fmt.Sprintf("accounts/%s/bank", account.ID))
+	collection := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", account.ID))
 	if collection == nil {
 		logCtx.Error("unable to get account's bank collection", fid)
 		return common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{
+	updates := []docstore.Update{
 		{Path: fmt.Sprintf("free_vexels_%s.vexels", time.Now().Format(time.RFC3339)), Value: valueTo20k},
-		{Path: "free_vexels_added_total.vexels", Value: fs.Increment(valueTo20k)},
-		{Path: "free_vexels_added_total.num_times", Value: fs.Increment(1)},
+		{Path: "free_vexels_added_total.vexels", Value: docstore.Increment(valueTo20k)},
+		{Path: "free_vexels_added_total.num_times", Value: docstore.Increment(1)},
 	}
 
-	if _, err := collection.Doc("used_codes").Update(ctx, updates); err != nil {
+	if err := collection.Doc("used_codes").Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Warn("unable to update account document", fid, "error", err)
 		return err
@@ -364,18 +430,18 @@ func BumpTo20kVexels(ctx context.Context, logCtx *slog.Logger, balance int) erro
 
 	// update bank tally with how many free vexels have been added so far.
 	// storing in bank's gift_card document fields just as an easy place holder to save this data.
-	collection = firestore.Client.Collection("bank")
+	collection = docstore.Client.Collection("bank")
 	if collection == nil {
 		logCtx.Error("unable to get bank collection", fid)
 		return common.ErrNotFound{}
 	}
 
-	updates = []fs.Update{
-		{Path: "total_free_vexels", Value: fs.Increment(valueTo20k)},
-		{Path: "total_num_times", Value: fs.Increment(1)},
+	updates = []docstore.Update{
+		{Path: "total_free_vexels", Value: docstore.Increment(valueTo20k)},
+		{Path: "total_num_times", Value: docstore.Increment(1)},
 	}
 
-	if _, err := collection.Doc("gift_cards").Update(ctx, updates); err != nil {
+	if err := collection.Doc("gift_cards").Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Warn("unable to update account document", fid, "error", err)
 		return err
@@ -383,3 +449,9 @@ func BumpTo20kVexels(ctx context.Context, logCtx *slog.Logger, balance int) erro
 
 	return nil
 }
+
+// GetTransactions returns the account's bank ledger from start up to end, newest first.
+func GetTransactions(ctx context.Context, logCtx *slog.Logger, start, end time.Time, limit int, pageToken string) (bank.LedgerPage, error) {
+	account := ctx.Value(common.AccountKey).(Document)
+	return bank.GetLedger(ctx, logCtx, account.ID, start, end, limit, pageToken)
+}
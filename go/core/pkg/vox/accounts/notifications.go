@@ -9,11 +9,15 @@ import (
 	"disruptive/lib/firebase"
 )
 
+// PushPriorityHigh delivers a notification immediately, waking the device.
+const PushPriorityHigh = "high"
+
 // PushNotificationRequest is a request structure to send a notification.
 type PushNotificationRequest struct {
 	FCMToken string `json:"fcm_token"`
 	Title    string `json:"title"`
 	Body     string `json:"body"`
+	Priority string `json:"priority,omitempty"`
 }
 
 // PushNotification sends a Firebase notification to a device.
@@ -28,6 +32,11 @@ func PushNotification(ctx context.Context, logCtx *slog.Logger, req PushNotifica
 		},
 	}
 
+	if req.Priority == PushPriorityHigh {
+		message.Android = &messaging.AndroidConfig{Priority: "high"}
+		message.APNS = &messaging.APNSConfig{Headers: map[string]string{"apns-priority": "10"}}
+	}
+
 	client, err := firebase.App.Messaging(ctx)
 	if err != nil {
 		logCtx.Error("unable to get firebase messaging client", fid, "error", err)
@@ -4,10 +4,8 @@ import (
 	"context"
 	"log/slog"
 
-	fs "cloud.google.com/go/firestore"
-
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 )
 
 // GetAccountPreferences retrieves an account's preferences.
@@ -16,7 +14,7 @@ func GetAccountPreferences(ctx context.Context, logCtx *slog.Logger) (map[string
 
 	account := ctx.Value(common.AccountKey).(Document)
 
-	collection := firestore.Client.Collection("accounts")
+	collection := docstore.Client.Collection("accounts")
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return nil, common.ErrNotFound{}
@@ -46,17 +44,17 @@ func PutAccountPreferences(ctx context.Context, logCtx *slog.Logger, preferences
 
 	account := ctx.Value(common.AccountKey).(Document)
 
-	collection := firestore.Client.Collection("accounts")
+	collection := docstore.Client.Collection("accounts")
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return common.ErrNotFound{}
 	}
 
-	update := []fs.Update{
+	update := []docstore.Update{
 		{Path: "preferences", Value: preferences},
 	}
 
-	if _, err := collection.Doc(account.ID).Update(ctx, update); err != nil {
+	if err := collection.Doc(account.ID).Update(ctx, update); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to update account preferences", fid, "error", err)
 		return err
@@ -71,19 +69,19 @@ func PatchAccountPreferences(ctx context.Context, logCtx *slog.Logger, preferenc
 
 	account := ctx.Value(common.AccountKey).(Document)
 
-	collection := firestore.Client.Collection("accounts")
+	collection := docstore.Client.Collection("accounts")
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return nil, common.ErrNotFound{}
 	}
 
-	updates := make([]fs.Update, 0, len(preferences))
+	updates := make([]docstore.Update, 0, len(preferences))
 	for k, v := range preferences {
-		updates = append(updates, fs.Update{Path: "preferences." + k, Value: v})
+		updates = append(updates, docstore.Update{Path: "preferences." + k, Value: v})
 	}
 
 	if len(updates) > 0 {
-		if _, err := collection.Doc(account.ID).Update(ctx, updates); err != nil {
+		if err := collection.Doc(account.ID).Update(ctx, updates); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to update account preferences", fid, "error", err)
 			return nil, err
@@ -105,18 +103,18 @@ func DeleteAccountPreferences(ctx context.Context, logCtx *slog.Logger, preferen
 
 	account := ctx.Value(common.AccountKey).(Document)
 
-	collection := firestore.Client.Collection("accounts")
+	collection := docstore.Client.Collection("accounts")
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return nil, common.ErrNotFound{}
 	}
 
-	updates := make([]fs.Update, 0, len(preferences))
+	updates := make([]docstore.Update, 0, len(preferences))
 	for _, v := range preferences {
-		updates = append(updates, fs.Update{Path: "preferences." + v, Value: fs.Delete})
+		updates = append(updates, docstore.Update{Path: "preferences." + v, Value: docstore.Delete})
 	}
 
-	if _, err := collection.Doc(account.ID).Update(ctx, updates); err != nil {
+	if err := collection.Doc(account.ID).Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to delete account preferences", fid, "error", err)
 		return nil, err
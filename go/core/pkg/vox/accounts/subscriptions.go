@@ -6,11 +6,10 @@ import (
 	"log/slog"
 	"time"
 
-	fs "cloud.google.com/go/firestore"
-
 	"disruptive/lib/common"
 	"disruptive/lib/configs"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
+	"disruptive/pkg/vox/bank"
 )
 
 // Subscription contains subscription information
@@ -19,12 +18,21 @@ type Subscription struct {
 	SubscriptionPending   string    `firestore:"subscription_pending" json:"subscription_pending,omitempty"`
 	SubscriptionSKU       string    `firestore:"subscription_sku" json:"subscription_sku"`
 	SubscriptionStartDate time.Time `firestore:"subscription_start_date" json:"subscription_start_date"`
+	SubscriptionStore     string    `firestore:"subscription_store" json:"subscription_store,omitempty"`
 }
 
 // GetSubscription gets a user's subscription information.
 func GetSubscription(ctx context.Context, logCtx *slog.Logger) (Subscription, error) {
 	fid := slog.String("fid", "vox.accounts.GetSubscription")
 
+	account := ctx.Value(common.AccountKey).(Document)
+
+	// Renew here too so a subscription is current even before the renewal job reaches the account.
+	if _, _, err := bank.RenewSubscription(ctx, logCtx, account.ID, time.Now()); err != nil {
+		logCtx.Error("unable to renew subscription", fid, "error", err)
+		return Subscription{}, err
+	}
+
 	b, err := GetBalance(ctx, logCtx)
 	if err != nil {
 		err = common.ConvertGRPCError(err)
@@ -32,19 +40,12 @@ func GetSubscription(ctx context.Context, logCtx *slog.Logger) (Subscription, er
 		return Subscription{}, err
 	}
 
-	if time.Now().After(b.SubscriptionStartDate) && b.SubscriptionPending != "" {
-		b, err = Subscribe(ctx, logCtx, b.SubscriptionPending)
-		if err != nil {
-			logCtx.Error("unable to update subscription", fid)
-			return Subscription{}, err
-		}
-	}
-
 	s := Subscription{
 		SubscriptionBalance:   b.SubscriptionBalance,
 		SubscriptionPending:   b.SubscriptionPending,
 		SubscriptionSKU:       b.SubscriptionSKU,
 		SubscriptionStartDate: b.SubscriptionStartDate,
+		SubscriptionStore:     b.SubscriptionStore,
 	}
 
 	return s, nil
@@ -69,18 +70,18 @@ func PatchPendingSubscription(ctx context.Context, logCtx *slog.Logger, sku stri
 	}
 
 	path := fmt.Sprintf("accounts/%s/bank", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return BalanceDocument{}, common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{
+	updates := []docstore.Update{
 		{Path: "subscription_pending", Value: sku},
 	}
 
 	if len(updates) > 0 {
-		if _, err := collection.Doc("balance").Update(ctx, updates); err != nil {
+		if err := collection.Doc("balance").Update(ctx, updates); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Warn("unable to update accounts bank document", fid, "error", err)
 			return BalanceDocument{}, err
@@ -97,19 +98,12 @@ func PatchPendingSubscription(ctx context.Context, logCtx *slog.Logger, sku stri
 	return b, nil
 }
 
-// Subscribe updates an accounts subscription.
+// Subscribe updates an accounts subscription and starts a new period. Renewals happen in bank.RenewSubscription.
 func Subscribe(ctx context.Context, logCtx *slog.Logger, sku string) (BalanceDocument, error) {
 	fid := slog.String("fid", "vox.accounts.Subscribe")
 
 	account := ctx.Value(common.AccountKey).(Document)
 
-	b, err := GetBalance(ctx, logCtx)
-	if err != nil {
-		err = common.ConvertGRPCError(err)
-		logCtx.Error("unable to get account balance document", fid, "error", err)
-		return BalanceDocument{}, err
-	}
-
 	skus, err := configs.GetSKUs(ctx, logCtx)
 	if err != nil {
 		logCtx.Error("unable to get banking configs", fid)
@@ -123,28 +117,46 @@ func Subscribe(ctx context.Context, logCtx *slog.Logger, sku string) (BalanceDoc
 	}
 
 	path := fmt.Sprintf("accounts/%s/bank", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return BalanceDocument{}, common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{
+	balanceRef := collection.Doc("balance")
+
+	updates := []docstore.Update{
 		{Path: "subscription_balance", Value: newSub.Balance},
 		{Path: "subscription_sku", Value: sku},
-		{Path: "subscription_pending", Value: fs.Delete},
-		{Path: "subscription_start_date", Value: b.SubscriptionStartDate.AddDate(0, 1, 0)},
+		{Path: "subscription_pending", Value: docstore.Delete},
+		{Path: "subscription_start_date", Value: time.Now().UTC()},
 	}
 
-	if len(updates) > 0 {
-		if _, err := collection.Doc("balance").Update(ctx, updates); err != nil {
-			err = common.ConvertGRPCError(err)
-			logCtx.Warn("unable to update accounts bank document", fid, "error", err)
-			return BalanceDocument{}, err
+	err = docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		doc, err := tx.Get(balanceRef)
+		if err != nil {
+			return err
+		}
+
+		balance := BalanceDocument{}
+		if err := doc.DataTo(&balance); err != nil {
+			return err
 		}
+
+		if err := tx.Update(balanceRef, updates); err != nil {
+			return err
+		}
+
+		entry := bank.LedgerEntry{Amount: newSub.Balance - balance.SubscriptionBalance, Bucket: bank.BucketSubscription, Reason: bank.ReasonSubscription, SourceID: sku}
+		return bank.AddLedgerEntriesTx(tx, account.ID, entry)
+	})
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Warn("unable to update accounts bank document", fid, "error", err)
+		return BalanceDocument{}, err
 	}
 
-	b, err = GetBalance(ctx, logCtx)
+	b, err := GetBalance(ctx, logCtx)
 	if err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to get account balance document", fid, "error", err)
@@ -161,17 +173,17 @@ func Unsubscribe(ctx context.Context, logCtx *slog.Logger) (BalanceDocument, err
 	account := ctx.Value(common.AccountKey).(Document)
 
 	path := fmt.Sprintf("accounts/%s/bank", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return BalanceDocument{}, common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{
-		{Path: "subscription_sku", Value: fs.Delete},
+	updates := []docstore.Update{
+		{Path: "subscription_sku", Value: docstore.Delete},
 	}
 
-	if _, err := collection.Doc("balance").Update(ctx, updates); err != nil {
+	if err := collection.Doc("balance").Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Warn("unable to update accounts bank document", fid, "error", err)
 		return BalanceDocument{}, err
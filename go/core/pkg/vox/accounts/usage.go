@@ -0,0 +1,318 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
+)
+
+// DefaultCooldownMessage is returned when a usage limit is reached and no cool-down message is set.
+const DefaultCooldownMessage = "Time for a break! Come back later."
+
+// usageSessionGap is the longest pause between interactions that still counts as play time.
+const usageSessionGap = 5 * time.Minute
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// UsageLimits contains screen-time limits. Zero caps are unlimited and no windows allow any time.
+type UsageLimits struct {
+	CooldownMessage    string       `firestore:"cooldown_message,omitempty" json:"cooldown_message,omitempty"`
+	DailyInteractions  int          `firestore:"daily_interactions,omitempty" json:"daily_interactions,omitempty"`
+	DailyMinutes       int          `firestore:"daily_minutes,omitempty" json:"daily_minutes,omitempty"`
+	WeeklyInteractions int          `firestore:"weekly_interactions,omitempty" json:"weekly_interactions,omitempty"`
+	WeeklyMinutes      int          `firestore:"weekly_minutes,omitempty" json:"weekly_minutes,omitempty"`
+	Windows            []TimeWindow `firestore:"windows,omitempty" json:"windows,omitempty"`
+}
+
+// TimeWindow contains an allowed time of day in the account timezone. Days are "mon" to "sun", every day when empty.
+// An end before the start spans midnight.
+type TimeWindow struct {
+	Days  []string `firestore:"days,omitempty" json:"days,omitempty"`
+	Start string   `firestore:"start" json:"start"`
+	End   string   `firestore:"end" json:"end"`
+}
+
+// Usage contains the interactions and minutes used in the current day and week.
+type Usage struct {
+	Day                string    `firestore:"day" json:"day"`
+	DailyInteractions  int       `firestore:"daily_interactions" json:"daily_interactions"`
+	DailyMinutes       float64   `firestore:"daily_minutes" json:"daily_minutes"`
+	Week               string    `firestore:"week" json:"week"`
+	WeeklyInteractions int       `firestore:"weekly_interactions" json:"weekly_interactions"`
+	WeeklyMinutes      float64   `firestore:"weekly_minutes" json:"weekly_minutes"`
+	LastInteraction    time.Time `firestore:"last_interaction" json:"last_interaction,omitempty"`
+}
+
+// ValidateUsageLimits validates caps and time windows.
+func ValidateUsageLimits(l UsageLimits) error {
+	if l.DailyInteractions < 0 || l.DailyMinutes < 0 || l.WeeklyInteractions < 0 || l.WeeklyMinutes < 0 {
+		return common.ErrBadRequest{Src: "usage_limits", Msg: "limits must not be negative"}
+	}
+
+	for _, w := range l.Windows {
+		if _, err := time.Parse("15:04", w.Start); err != nil {
+			return common.ErrBadRequest{Src: "usage_limits", Msg: fmt.Sprintf("invalid window start %q", w.Start)}
+		}
+
+		if _, err := time.Parse("15:04", w.End); err != nil {
+			return common.ErrBadRequest{Src: "usage_limits", Msg: fmt.Sprintf("invalid window end %q", w.End)}
+		}
+
+		for _, d := range w.Days {
+			if !slices.Contains(weekdays, d) {
+				return common.ErrBadRequest{Src: "usage_limits", Msg: fmt.Sprintf("invalid window day %q", d)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// UseInteraction checks the account and profile usage limits and records an interaction of the given audio length.
+// A reached limit returns ErrTooManyRequests with the cool-down message.
+func UseInteraction(ctx context.Context, logCtx *slog.Logger, profileID string, limits UsageLimits, seconds float64) error {
+	return useInteraction(ctx, logCtx, profileID, limits, seconds, true)
+}
+
+// CheckUsage checks the account and profile usage limits without recording an interaction, for an interaction
+// whose length is only known once it is processed. A reached limit returns ErrTooManyRequests with the cool-down message.
+func CheckUsage(ctx context.Context, logCtx *slog.Logger, profileID string, limits UsageLimits) error {
+	return useInteraction(ctx, logCtx, profileID, limits, 0, false)
+}
+
+func useInteraction(ctx context.Context, logCtx *slog.Logger, profileID string, limits UsageLimits, seconds float64, record bool) error {
+	fid := slog.String("fid", "vox.accounts.UseInteraction")
+
+	account := ctx.Value(common.AccountKey).(Document)
+
+	accountLimits := UsageLimits{}
+	if account.UsageLimits != nil {
+		accountLimits = *account.UsageLimits
+	}
+
+	now := time.Now().In(accountLocation(account))
+
+	accountRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/usage", account.ID)).Doc("current")
+	profileRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/profiles/%s/usage", account.ID, profileID)).Doc("current")
+
+	err := docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		accountUsage, err := getUsage(tx, accountRef, now)
+		if err != nil {
+			return err
+		}
+
+		profileUsage, err := getUsage(tx, profileRef, now)
+		if err != nil {
+			return err
+		}
+
+		if msg, ok := checkUsage(accountLimits, accountUsage, now); !ok {
+			return common.ErrTooManyRequests{Msg: msg}
+		}
+
+		if msg, ok := checkUsage(limits, profileUsage, now); !ok {
+			return common.ErrTooManyRequests{Msg: msg}
+		}
+
+		if !record {
+			return nil
+		}
+
+		if err := tx.Set(accountRef, addUsage(accountUsage, now, seconds)); err != nil {
+			return err
+		}
+
+		return tx.Set(profileRef, addUsage(profileUsage, now, seconds))
+	})
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+
+		if errors.Is(err, common.ErrTooManyRequests{}) {
+			logCtx.Info("usage limit reached", fid, "profile_id", profileID, "error", err)
+		} else {
+			logCtx.Warn("unable to record usage", fid, "error", err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// GetUsage returns the current usage of a profile.
+func GetUsage(ctx context.Context, logCtx *slog.Logger, profileID string) (Usage, error) {
+	fid := slog.String("fid", "vox.accounts.GetUsage")
+
+	account := ctx.Value(common.AccountKey).(Document)
+
+	now := time.Now().In(accountLocation(account))
+	u := Usage{Day: now.Format(time.DateOnly), Week: isoWeek(now)}
+
+	doc, err := docstore.Client.Collection(fmt.Sprintf("accounts/%s/profiles/%s/usage", account.ID, profileID)).Doc("current").Get(ctx)
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		if errors.Is(err, common.ErrNotFound{}) {
+			return u, nil
+		}
+
+		logCtx.Warn("unable to get usage document", fid, "error", err)
+		return u, err
+	}
+
+	if err := doc.DataTo(&u); err != nil {
+		logCtx.Warn("unable to read usage document", fid, "error", err)
+		return u, err
+	}
+
+	return rollUsage(u, now), nil
+}
+
+func getUsage(tx *docstore.Transaction, ref *docstore.DocumentRef, now time.Time) (Usage, error) {
+	u := Usage{}
+
+	doc, err := tx.Get(ref)
+	if err != nil && !errors.Is(common.ConvertGRPCError(err), common.ErrNotFound{}) {
+		return u, err
+	}
+
+	if doc.Exists() {
+		if err := doc.DataTo(&u); err != nil {
+			return u, err
+		}
+	}
+
+	return rollUsage(u, now), nil
+}
+
+// rollUsage resets the daily and weekly counters when the day or week has changed.
+func rollUsage(u Usage, now time.Time) Usage {
+	if day := now.Format(time.DateOnly); u.Day != day {
+		u.Day = day
+		u.DailyInteractions = 0
+		u.DailyMinutes = 0
+	}
+
+	if week := isoWeek(now); u.Week != week {
+		u.Week = week
+		u.WeeklyInteractions = 0
+		u.WeeklyMinutes = 0
+	}
+
+	return u
+}
+
+// addUsage counts an interaction. Time since the previous interaction counts as play time when it is
+// a short pause, otherwise only the interaction's own audio is counted.
+func addUsage(u Usage, now time.Time, seconds float64) Usage {
+	if elapsed := now.Sub(u.LastInteraction); !u.LastInteraction.IsZero() && elapsed > 0 && elapsed < usageSessionGap {
+		seconds = max(seconds, elapsed.Seconds())
+	}
+
+	u.DailyInteractions++
+	u.WeeklyInteractions++
+	u.DailyMinutes += seconds / 60
+	u.WeeklyMinutes += seconds / 60
+	u.LastInteraction = now.UTC()
+
+	return u
+}
+
+// checkUsage returns the cool-down message and false when the limits do not allow another interaction.
+func checkUsage(l UsageLimits, u Usage, now time.Time) (string, bool) {
+	msg := l.CooldownMessage
+	if msg == "" {
+		msg = DefaultCooldownMessage
+	}
+
+	if !inWindows(l.Windows, now) {
+		return msg, false
+	}
+
+	if l.DailyInteractions > 0 && u.DailyInteractions >= l.DailyInteractions {
+		return msg, false
+	}
+
+	if l.WeeklyInteractions > 0 && u.WeeklyInteractions >= l.WeeklyInteractions {
+		return msg, false
+	}
+
+	if l.DailyMinutes > 0 && u.DailyMinutes >= float64(l.DailyMinutes) {
+		return msg, false
+	}
+
+	if l.WeeklyMinutes > 0 && u.WeeklyMinutes >= float64(l.WeeklyMinutes) {
+		return msg, false
+	}
+
+	return "", true
+}
+
+// inWindows reports whether now falls in one of the windows. No windows allow any time.
+func inWindows(windows []TimeWindow, now time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	today := weekdays[now.Weekday()]
+	yesterday := weekdays[(now.Weekday()+6)%7]
+
+	for _, w := range windows {
+		start, err := time.Parse("15:04", w.Start)
+		if err != nil {
+			continue
+		}
+
+		end, err := time.Parse("15:04", w.End)
+		if err != nil {
+			continue
+		}
+
+		s := start.Hour()*60 + start.Minute()
+		e := end.Hour()*60 + end.Minute()
+
+		onDay := func(d string) bool {
+			return len(w.Days) == 0 || slices.Contains(w.Days, d)
+		}
+
+		if s <= e {
+			if onDay(today) && minute >= s && minute < e {
+				return true
+			}
+
+			continue
+		}
+
+		// Overnight windows belong to the day they start on.
+		if onDay(today) && minute >= s {
+			return true
+		}
+
+		if onDay(yesterday) && minute < e {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// accountLocation returns the account's timezone, UTC when it is unset or unknown.
+func accountLocation(account Document) *time.Location {
+	loc, err := time.LoadLocation(account.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
@@ -7,11 +7,10 @@ import (
 	"log/slog"
 	"time"
 
-	fs "cloud.google.com/go/firestore"
-
+	"disruptive/config"
 	"disruptive/lib/common"
 	"disruptive/lib/configs"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 )
 
 // GoogleAndroidIAPTransaction contains an android iap transaction. Inbound only.
@@ -37,65 +36,71 @@ type AndroidIAPTransaction struct {
 	PurchaseTime  time.Time `firestore:"purchase_time,omitempty" json:"purchase_time,omitempty"`
 	PurchaseToken string    `firestore:"purchase_token" json:"purchase_token"`
 	Quantity      int       `firestore:"quantity" json:"quantity"`
+	Voided        bool      `firestore:"voided,omitempty" json:"voided,omitempty"`
 }
 
 // AndroidSubTransaction contains a android subscription transaction.
 type AndroidSubTransaction struct {
 	AccountID      string    `firestore:"account_id"`
 	CountryCode    string    `firestore:"country_code" json:"country_code"`
+	CreditedExpiry string    `firestore:"credited_expiry,omitempty" json:"-"` // expiryTimeMillis of the last period credited
 	LoadBalancer   string    `firestore:"load_balancer" json:"load_balancer"`
 	PurchaseToken  string    `firestore:"purchase_token" json:"purchase_token"` // document_id = purchase_token
 	SKU            string    `firestore:"sku" json:"sku"`
+	State          string    `firestore:"state,omitempty" json:"state,omitempty"`
 	SubscriptionID string    `firestore:"subscription_id" json:"subscription_id"` // user's order number
 	Timestamp      time.Time `firestore:"timestamp"`
 }
 
-func renderAndroidIAPTransaction(txn GoogleAndroidIAPTransaction) AndroidIAPTransaction {
-	_txn := AndroidIAPTransaction{
-		OrderID:       txn.OrderID,
+// androidPurchaseIndex maps a one-time purchase to the account it credited so voided purchases can be clawed back.
+type androidPurchaseIndex struct {
+	AccountID     string  `firestore:"account_id"`
+	Amount        float64 `firestore:"amount"`
+	ProductID     string  `firestore:"product_id"`
+	PurchaseToken string  `firestore:"purchase_token"`
+	Revoked       bool    `firestore:"revoked"`
+}
+
+func renderAndroidIAPTransaction(txn GoogleAndroidIAPTransaction, p PlayProductPurchase) AndroidIAPTransaction {
+	return AndroidIAPTransaction{
+		Acknowledged:  p.AcknowledgementState == 1,
+		OrderID:       p.OrderID,
 		PackageName:   txn.PackageName,
 		ProductID:     txn.ProductID,
-		PurchaseState: txn.PurchaseState,
+		PurchaseState: p.PurchaseState,
+		PurchaseTime:  p.PurchaseTime(),
 		PurchaseToken: txn.PurchaseToken,
-		Quantity:      txn.Quantity,
-		Acknowledged:  txn.Acknowledged,
+		Quantity:      max(p.Quantity, 1),
 	}
-
-	if txn.PurchaseTime != 0 {
-		t := int64(txn.PurchaseTime)
-		s := t / 1000
-		ns := (t & 1000) * 1000000
-
-		_txn.PurchaseTime = time.Unix(s, ns).UTC()
-	}
-
-	return _txn
 }
 
-// PostAndroidIAPTransaction creates a new android transaction.
+// PostAndroidIAPTransaction verifies a purchase token with Google Play, credits the account and acknowledges the purchase.
 func PostAndroidIAPTransaction(ctx context.Context, logCtx *slog.Logger, uid string, txn GoogleAndroidIAPTransaction) (AndroidIAPTransaction, error) {
 	fid := slog.String("fid", "vox.bank.PostAndroidIAPTransaction")
 
-	_txn := renderAndroidIAPTransaction(txn)
+	if Play == nil {
+		logCtx.Error("google play client not configured", fid)
+		return AndroidIAPTransaction{}, fmt.Errorf("%w: google play client not configured", common.ErrIAPUnauthorized)
+	}
 
-	collection := firestore.Client.Collection(fmt.Sprintf("accounts/%s/android_iap_transactions", uid))
-	if collection == nil {
-		logCtx.Error("android IAP transactions collection not found", fid)
-		return AndroidIAPTransaction{}, common.ErrNotFound{}
+	if config.VARS.GooglePlayPackageName != "" && txn.PackageName != config.VARS.GooglePlayPackageName {
+		logCtx.Error("invalid package name", fid, "package_name", txn.PackageName)
+		return AndroidIAPTransaction{}, fmt.Errorf("%w: invalid package name", common.ErrIAPUnauthorized)
 	}
 
-	doc, err := collection.Doc(_txn.PurchaseToken).Get(ctx)
-	if err != nil && !errors.Is(common.ConvertGRPCError(err), common.ErrNotFound{}) {
-		err = common.ConvertGRPCError(err)
-		logCtx.Error("unable to get android IAP transactions document", fid, "error", err)
+	purchase, err := Play.GetProductPurchase(ctx, txn.PackageName, txn.ProductID, txn.PurchaseToken)
+	if err != nil {
+		logCtx.Error("unable to verify android purchase", fid, "error", err)
 		return AndroidIAPTransaction{}, err
 	}
 
-	if doc.Exists() {
-		logCtx.Warn("transaction already exists", fid)
-		return AndroidIAPTransaction{}, common.ErrAlreadyExists{}
+	if purchase.PurchaseState != PlayPurchased {
+		logCtx.Error("android purchase not completed", fid, "purchase_state", purchase.PurchaseState)
+		return AndroidIAPTransaction{}, fmt.Errorf("%w: purchase not completed", common.ErrIAPUnauthorized)
 	}
 
+	_txn := renderAndroidIAPTransaction(txn, purchase)
+
 	skus, err := configs.Get(ctx, logCtx, "skus")
 	if err != nil {
 		logCtx.Error("unable to get sku config", fid, "error", err)
@@ -118,46 +123,161 @@ func PostAndroidIAPTransaction(ctx context.Context, logCtx *slog.Logger, uid str
 		return AndroidIAPTransaction{}, common.ErrNotFound{}
 	}
 
-	if _, err := collection.Doc(_txn.PurchaseToken).Set(ctx, _txn); err != nil {
-		err = common.ConvertGRPCError(err)
-		logCtx.Warn("unable to create android IAP transactions document", fid, "error", err)
-		return AndroidIAPTransaction{}, err
-	}
+	amount := _txn.Amount * float64(_txn.Quantity)
 
-	collection = firestore.Client.Collection(fmt.Sprintf("accounts/%s/bank", uid))
+	collection := docstore.Client.Collection(fmt.Sprintf("accounts/%s/android_iap_transactions", uid))
 	if collection == nil {
+		logCtx.Error("android IAP transactions collection not found", fid)
+		return AndroidIAPTransaction{}, common.ErrNotFound{}
+	}
+
+	index := docstore.Client.Collection("stores/android/purchases")
+	if index == nil {
+		logCtx.Error("android purchases collection not found", fid)
+		return AndroidIAPTransaction{}, common.ErrNotFound{}
+	}
+
+	bankCollection := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", uid))
+	if bankCollection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return AndroidIAPTransaction{}, common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{{Path: "balance", Value: fs.Increment(_txn.Amount * float64(_txn.Quantity))}}
+	err = docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		// Transactions credited before the index existed are only in the account.
+		for _, ref := range []*docstore.DocumentRef{index.Doc(_txn.PurchaseToken), collection.Doc(_txn.PurchaseToken)} {
+			doc, err := tx.Get(ref)
+			if err != nil && !errors.Is(common.ConvertGRPCError(err), common.ErrNotFound{}) {
+				return err
+			}
+
+			if doc.Exists() {
+				return common.ErrAlreadyExists{Msg: "transaction already exists"}
+			}
+		}
+
+		if err := tx.Create(collection.Doc(_txn.PurchaseToken), _txn); err != nil {
+			return err
+		}
 
-	if _, err := collection.Doc("balance").Update(ctx, updates); err != nil {
+		idx := androidPurchaseIndex{
+			AccountID:     uid,
+			Amount:        amount,
+			ProductID:     _txn.ProductID,
+			PurchaseToken: _txn.PurchaseToken,
+		}
+
+		if err := tx.Create(index.Doc(_txn.PurchaseToken), idx); err != nil {
+			return err
+		}
+
+		updates := []docstore.Update{{Path: "balance", Value: docstore.Increment(amount)}}
+
+		if err := tx.Update(bankCollection.Doc("balance"), updates); err != nil {
+			return err
+		}
+
+		entry := LedgerEntry{Amount: int(amount), Bucket: BucketPurchased, Reason: ReasonAndroidIAP, SourceID: _txn.PurchaseToken}
+		return AddLedgerEntriesTx(tx, uid, entry)
+	})
+	if err != nil {
 		err = common.ConvertGRPCError(err)
-		logCtx.Warn("unable to update accounts bank document", fid, "error", err)
+		if errors.Is(err, common.ErrAlreadyExists{}) {
+			logCtx.Warn("transaction already exists", fid)
+			return AndroidIAPTransaction{}, err
+		}
+
+		logCtx.Error("unable to credit android IAP transaction", fid, "error", err)
 		return AndroidIAPTransaction{}, err
 	}
 
+	// Google Play refunds purchases that are not acknowledged within three days, the credit stands either way.
+	if !_txn.Acknowledged {
+		if err := Play.AcknowledgeProductPurchase(ctx, _txn.PackageName, _txn.ProductID, _txn.PurchaseToken); err != nil {
+			logCtx.Warn("unable to acknowledge android purchase", fid, "error", err)
+			return _txn, nil
+		}
+
+		_txn.Acknowledged = true
+
+		updates := []docstore.Update{{Path: "acknowledged", Value: true}}
+		if err := collection.Doc(_txn.PurchaseToken).Update(ctx, updates); err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Warn("unable to update android IAP transactions document", fid, "error", err)
+		}
+	}
+
 	return _txn, nil
 }
 
-// PostAndroidSubTransaction creates a new android transaction.
+// PostAndroidSubTransaction verifies a subscription purchase token with Google Play and binds it to the account.
+// A token already bound to another account is rejected.
 func PostAndroidSubTransaction(ctx context.Context, logCtx *slog.Logger, txn AndroidSubTransaction) (AndroidSubTransaction, error) {
 	fid := slog.String("fid", "vox.bank.PostAndroidSubTransaction")
 
-	collection := firestore.Client.Collection("stores/android/transactions")
+	if Play == nil {
+		logCtx.Error("google play client not configured", fid)
+		return AndroidSubTransaction{}, fmt.Errorf("%w: google play client not configured", common.ErrIAPUnauthorized)
+	}
+
+	purchase, err := Play.GetSubscriptionPurchase(ctx, config.VARS.GooglePlayPackageName, txn.SKU, txn.PurchaseToken)
+	if err != nil {
+		logCtx.Error("unable to verify android subscription", fid, "error", err)
+		return AndroidSubTransaction{}, err
+	}
+
+	if expiry := purchase.ExpiryTime(); !expiry.IsZero() && expiry.Before(time.Now()) {
+		logCtx.Warn("android subscription expired", fid, "expiry", expiry)
+		return AndroidSubTransaction{}, fmt.Errorf("%w: subscription expired", common.ErrIAPUnauthorized)
+	}
+
+	collection := docstore.Client.Collection("stores/android/transactions")
 	if collection == nil {
 		logCtx.Error("memory collection not found", fid)
 		return AndroidSubTransaction{}, common.ErrNotFound{}
 	}
 
-	if _, err := collection.Doc(txn.PurchaseToken).Set(ctx, txn); err != nil {
+	ref := collection.Doc(txn.PurchaseToken)
+
+	// Google Play renews and expires the subscription from here on, not the renewal engine.
+	balanceRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", txn.AccountID)).Doc("balance")
+
+	err = docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		doc, err := tx.Get(ref)
+		switch {
+		case err == nil:
+			existing := AndroidSubTransaction{}
+			if err := doc.DataTo(&existing); err != nil {
+				return err
+			}
+
+			if existing.AccountID != txn.AccountID {
+				return fmt.Errorf("%w: purchase token belongs to another account", common.ErrIAPUnauthorized)
+			}
+
+			txn.CreditedExpiry = existing.CreditedExpiry
+			txn.State = existing.State
+
+			if err := tx.Set(ref, txn); err != nil {
+				return err
+			}
+		case errors.Is(common.ConvertGRPCError(err), common.ErrNotFound{}):
+			if err := tx.Create(ref, txn); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		return tx.Set(balanceRef, map[string]any{"subscription_store": SubscriptionStoreAndroid}, docstore.MergeAll)
+	})
+	if err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Warn("unable to update android transactions document", fid, "error", err)
 		return AndroidSubTransaction{}, err
 	}
 
-	doc, err := collection.Doc(txn.PurchaseToken).Get(ctx)
+	doc, err := ref.Get(ctx)
 	if err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to get android transactions document", fid, "error", err)
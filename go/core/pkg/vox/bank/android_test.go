@@ -0,0 +1,150 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
+)
+
+const (
+	testAccountID   = "account-1"
+	testPackageName = "com.example.vox"
+)
+
+var testLogCtx = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// setupBank replaces the document store with an empty memory store with the sku configs and a zero balance,
+// and Google Play with a local client.
+func setupBank(t *testing.T) *LocalPlayClient {
+	t.Helper()
+
+	backend, err := docstore.NewMemory("")
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+
+	store, play := docstore.Client, Play
+	t.Cleanup(func() { docstore.Client, Play = store, play })
+
+	docstore.Client = docstore.New(backend)
+
+	local := NewLocalPlayClient()
+	Play = local
+
+	ctx := context.Background()
+
+	skus := map[string]any{
+		"vexels_100": map[string]any{"balance": 100},
+		"monthly":    map[string]any{"balance": 500, "period": "monthly"},
+	}
+
+	if err := docstore.Client.Collection("configs").Doc("skus").Set(ctx, skus); err != nil {
+		t.Fatalf("set skus: %v", err)
+	}
+
+	if err := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", testAccountID)).Doc("balance").Set(ctx, map[string]any{"balance": 0, "subscription_balance": 0}); err != nil {
+		t.Fatalf("set balance: %v", err)
+	}
+
+	return local
+}
+
+func getBalance(t *testing.T) subscriptionBalance {
+	t.Helper()
+
+	doc, err := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", testAccountID)).Doc("balance").Get(context.Background())
+	if err != nil {
+		t.Fatalf("get balance: %v", err)
+	}
+
+	b := subscriptionBalance{}
+	if err := doc.DataTo(&b); err != nil {
+		t.Fatalf("read balance: %v", err)
+	}
+
+	return b
+}
+
+// subscriptionBalance is the part of the bank balance document the tests check.
+type subscriptionBalance struct {
+	Balance               int       `firestore:"balance"`
+	SubscriptionBalance   int       `firestore:"subscription_balance"`
+	SubscriptionSKU       string    `firestore:"subscription_sku"`
+	SubscriptionStartDate time.Time `firestore:"subscription_start_date"`
+	SubscriptionStore     string    `firestore:"subscription_store"`
+}
+
+func getLedger(t *testing.T) []LedgerEntry {
+	t.Helper()
+
+	docs, err := docstore.Client.Collection(ledgerPath(testAccountID)).Documents(context.Background()).GetAll()
+	if err != nil {
+		t.Fatalf("get ledger: %v", err)
+	}
+
+	entries := []LedgerEntry{}
+	for _, doc := range docs {
+		e := LedgerEntry{}
+		if err := doc.DataTo(&e); err != nil {
+			t.Fatalf("read ledger entry: %v", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+func TestPostAndroidIAPTransaction(t *testing.T) {
+	play := setupBank(t)
+	ctx := context.Background()
+
+	play.SetProductPurchase("token-1", PlayProductPurchase{OrderID: "GPA.1", ProductID: "vexels_100", PurchaseState: PlayPurchased, Quantity: 1})
+
+	txn := GoogleAndroidIAPTransaction{PackageName: testPackageName, ProductID: "vexels_100", PurchaseToken: "token-1"}
+
+	got, err := PostAndroidIAPTransaction(ctx, testLogCtx, testAccountID, txn)
+	if err != nil {
+		t.Fatalf("PostAndroidIAPTransaction: %v", err)
+	}
+
+	if !got.Acknowledged || !play.Acknowledged("token-1") {
+		t.Errorf("purchase not acknowledged")
+	}
+
+	if b := getBalance(t); b.Balance != 100 {
+		t.Errorf("balance = %d, want 100", b.Balance)
+	}
+
+	if _, err := PostAndroidIAPTransaction(ctx, testLogCtx, testAccountID, txn); !errors.Is(err, common.ErrAlreadyExists{}) {
+		t.Errorf("duplicate token error = %v, want ErrAlreadyExists", err)
+	}
+
+	if b := getBalance(t); b.Balance != 100 {
+		t.Errorf("balance after duplicate = %d, want 100", b.Balance)
+	}
+
+	if entries := getLedger(t); len(entries) != 1 || entries[0].Amount != 100 || entries[0].Reason != ReasonAndroidIAP {
+		t.Errorf("ledger = %+v, want one android_iap credit of 100", entries)
+	}
+}
+
+func TestPostAndroidIAPTransactionUnknownToken(t *testing.T) {
+	setupBank(t)
+
+	txn := GoogleAndroidIAPTransaction{PackageName: testPackageName, ProductID: "vexels_100", PurchaseToken: "unknown"}
+
+	if _, err := PostAndroidIAPTransaction(context.Background(), testLogCtx, testAccountID, txn); !errors.Is(err, common.ErrIAPUnauthorized) {
+		t.Errorf("error = %v, want ErrIAPUnauthorized", err)
+	}
+
+	if b := getBalance(t); b.Balance != 0 {
+		t.Errorf("balance = %d, want 0", b.Balance)
+	}
+}
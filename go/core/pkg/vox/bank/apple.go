@@ -7,72 +7,89 @@ import (
 	"log/slog"
 	"time"
 
-	fs "cloud.google.com/go/firestore"
-
+	"disruptive/lib/appstore"
 	"disruptive/lib/common"
 	"disruptive/lib/configs"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 )
 
 // AppleStoreIAPTransaction contains an apple iap transaction from the store. Inbound only.
+// SignedTransactionInfo is the StoreKit 2 signed transaction, the other fields must match it when set.
 type AppleStoreIAPTransaction struct {
-	ProductID          string `json:"productId"`
-	TransactionID      string `json:"transactionId"`
-	TransactionDate    int    `json:"transactionDate"`
-	TransactionReceipt string `json:"transactionReceipt"`
+	ProductID             string `json:"productId"`
+	SignedTransactionInfo string `json:"signedTransactionInfo"`
+	TransactionID         string `json:"transactionId"`
+	TransactionDate       int    `json:"transactionDate"`
+	TransactionReceipt    string `json:"transactionReceipt"`
 }
 
 // AppleIAPTransaction contains an apple iap transaction. DB and outbound only.
 type AppleIAPTransaction struct {
-	Amount             float64   `firestore:"amount" json:"amount"`
-	ProductID          string    `firestore:"product_id" json:"product_id"`
-	TransactionID      string    `firestore:"transaction_id" json:"transaction_id"`
-	TransactionDate    time.Time `firestore:"transaction_date,omitempty" json:"transaction_date,omitempty"`
-	TransactionReceipt string    `firestore:"transaction_receipt" json:"transaction_receipt"`
+	Amount                float64   `firestore:"amount" json:"amount"`
+	Environment           string    `firestore:"environment" json:"environment"`
+	OriginalTransactionID string    `firestore:"original_transaction_id" json:"original_transaction_id"`
+	ProductID             string    `firestore:"product_id" json:"product_id"`
+	Quantity              int       `firestore:"quantity" json:"quantity"`
+	RevocationDate        time.Time `firestore:"revocation_date,omitempty" json:"revocation_date,omitempty"`
+	Revoked               bool      `firestore:"revoked,omitempty" json:"revoked,omitempty"`
+	TransactionID         string    `firestore:"transaction_id" json:"transaction_id"`
+	TransactionDate       time.Time `firestore:"transaction_date,omitempty" json:"transaction_date,omitempty"`
+	TransactionReceipt    string    `firestore:"transaction_receipt" json:"transaction_receipt"`
+}
+
+// appleTransactionIndex maps a transaction to the account it credited so notifications can reverse it.
+type appleTransactionIndex struct {
+	AccountID     string  `firestore:"account_id"`
+	Amount        float64 `firestore:"amount"`
+	ProductID     string  `firestore:"product_id"`
+	Revoked       bool    `firestore:"revoked"`
+	TransactionID string  `firestore:"transaction_id"`
 }
 
-func renderAppleIAPTransaction(txn AppleStoreIAPTransaction) AppleIAPTransaction {
+func renderAppleIAPTransaction(txn AppleStoreIAPTransaction, signed appstore.Transaction) AppleIAPTransaction {
 	_txn := AppleIAPTransaction{
-		ProductID:          txn.ProductID,
-		TransactionID:      txn.TransactionID,
-		TransactionReceipt: txn.TransactionReceipt,
+		Environment:           signed.Environment,
+		OriginalTransactionID: signed.OriginalTransactionID,
+		ProductID:             signed.ProductID,
+		Quantity:              max(signed.Quantity, 1),
+		TransactionID:         signed.TransactionID,
+		TransactionReceipt:    txn.TransactionReceipt,
 	}
 
-	if txn.TransactionDate != 0 {
-		t := int64(txn.TransactionDate)
-		s := t / 1000
-		ns := (t & 1000) * 1000000
-
-		_txn.TransactionDate = time.Unix(s, ns).UTC()
+	if signed.PurchaseDate != 0 {
+		_txn.TransactionDate = time.UnixMilli(signed.PurchaseDate).UTC()
 	}
 
 	return _txn
 }
 
-// PostAppleIAPTransaction creates a new apple iap transaction.
+// PostAppleIAPTransaction verifies a signed apple iap transaction and credits the account.
 func PostAppleIAPTransaction(ctx context.Context, logCtx *slog.Logger, uid string, txn AppleStoreIAPTransaction) (AppleIAPTransaction, error) {
 	fid := slog.String("fid", "vox.bank.PostAppleIAPTransaction")
 
-	_txn := renderAppleIAPTransaction(txn)
-
-	collection := firestore.Client.Collection(fmt.Sprintf("accounts/%s/apple_iap_transactions", uid))
-	if collection == nil {
-		logCtx.Error("apple IAP transactions collection not found", fid)
-		return AppleIAPTransaction{}, common.ErrNotFound{}
+	if appstore.Default == nil {
+		logCtx.Error("apple verifier not configured", fid)
+		return AppleIAPTransaction{}, fmt.Errorf("%w: apple verifier not configured", common.ErrIAPUnauthorized)
 	}
 
-	doc, err := collection.Doc(_txn.TransactionID).Get(ctx)
-	if err != nil && !errors.Is(common.ConvertGRPCError(err), common.ErrNotFound{}) {
-		err = common.ConvertGRPCError(err)
-		logCtx.Error("unable to get apple IAP transactions document", fid, "error", err)
+	signed, err := appstore.Default.VerifyTransaction(txn.SignedTransactionInfo)
+	if err != nil {
+		logCtx.Error("unable to verify apple transaction", fid, "error", err)
 		return AppleIAPTransaction{}, err
 	}
 
-	if doc.Exists() {
-		logCtx.Warn("transaction already exists", fid)
-		return AppleIAPTransaction{}, common.ErrAlreadyExists{}
+	if (txn.TransactionID != "" && txn.TransactionID != signed.TransactionID) || (txn.ProductID != "" && txn.ProductID != signed.ProductID) {
+		logCtx.Error("apple transaction does not match signed transaction", fid, "transaction_id", txn.TransactionID, "product_id", txn.ProductID)
+		return AppleIAPTransaction{}, fmt.Errorf("%w: transaction does not match signed transaction", common.ErrIAPUnauthorized)
+	}
+
+	if signed.RevocationDate != 0 {
+		logCtx.Error("apple transaction revoked", fid, "transaction_id", signed.TransactionID)
+		return AppleIAPTransaction{}, fmt.Errorf("%w: transaction revoked", common.ErrIAPUnauthorized)
 	}
 
+	_txn := renderAppleIAPTransaction(txn, signed)
+
 	skus, err := configs.Get(ctx, logCtx, "skus")
 	if err != nil {
 		logCtx.Error("unable to get sku config", fid, "error", err)
@@ -87,33 +104,189 @@ func PostAppleIAPTransaction(ctx context.Context, logCtx *slog.Logger, uid strin
 
 	switch b := sku["balance"].(type) {
 	case int64:
-		_txn.Amount = float64(b)
+		_txn.Amount = float64(b) * float64(_txn.Quantity)
 	case float64:
-		_txn.Amount = b
+		_txn.Amount = b * float64(_txn.Quantity)
 	default:
 		logCtx.Error("balance for sku not found", fid, "sku", sku)
 		return AppleIAPTransaction{}, common.ErrNotFound{}
 	}
 
-	if _, err := collection.Doc(_txn.TransactionID).Set(ctx, _txn); err != nil {
-		err = common.ConvertGRPCError(err)
-		logCtx.Warn("unable to create apple IAP transactions document", fid, "error", err)
-		return AppleIAPTransaction{}, err
+	collection := docstore.Client.Collection(fmt.Sprintf("accounts/%s/apple_iap_transactions", uid))
+	if collection == nil {
+		logCtx.Error("apple IAP transactions collection not found", fid)
+		return AppleIAPTransaction{}, common.ErrNotFound{}
 	}
 
-	collection = firestore.Client.Collection(fmt.Sprintf("accounts/%s/bank", uid))
-	if collection == nil {
+	index := docstore.Client.Collection("stores/apple/transactions")
+	if index == nil {
+		logCtx.Error("apple transactions collection not found", fid)
+		return AppleIAPTransaction{}, common.ErrNotFound{}
+	}
+
+	bankCollection := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", uid))
+	if bankCollection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return AppleIAPTransaction{}, common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{{Path: "balance", Value: fs.Increment(_txn.Amount)}}
+	err = docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		// Transactions credited before the index existed are only in the account.
+		for _, ref := range []*docstore.DocumentRef{index.Doc(_txn.TransactionID), collection.Doc(_txn.TransactionID)} {
+			doc, err := tx.Get(ref)
+			if err != nil && !errors.Is(common.ConvertGRPCError(err), common.ErrNotFound{}) {
+				return err
+			}
+
+			if doc.Exists() {
+				return common.ErrAlreadyExists{Msg: "transaction already exists"}
+			}
+		}
+
+		if err := tx.Create(collection.Doc(_txn.TransactionID), _txn); err != nil {
+			return err
+		}
+
+		idx := appleTransactionIndex{
+			AccountID:     uid,
+			Amount:        _txn.Amount,
+			ProductID:     _txn.ProductID,
+			TransactionID: _txn.TransactionID,
+		}
 
-	if _, err := collection.Doc("balance").Update(ctx, updates); err != nil {
+		if err := tx.Create(index.Doc(_txn.TransactionID), idx); err != nil {
+			return err
+		}
+
+		updates := []docstore.Update{{Path: "balance", Value: docstore.Increment(_txn.Amount)}}
+
+		if err := tx.Update(bankCollection.Doc("balance"), updates); err != nil {
+			return err
+		}
+
+		entry := LedgerEntry{Amount: int(_txn.Amount), Bucket: BucketPurchased, Reason: ReasonAppleIAP, SourceID: _txn.TransactionID}
+		return AddLedgerEntriesTx(tx, uid, entry)
+	})
+	if err != nil {
 		err = common.ConvertGRPCError(err)
-		logCtx.Warn("unable to update accounts bank document", fid, "error", err)
+		if errors.Is(err, common.ErrAlreadyExists{}) {
+			logCtx.Warn("transaction already exists", fid, "transaction_id", _txn.TransactionID)
+			return AppleIAPTransaction{}, err
+		}
+
+		logCtx.Error("unable to credit apple IAP transaction", fid, "error", err)
 		return AppleIAPTransaction{}, err
 	}
 
 	return _txn, nil
 }
+
+// RevokeAppleIAPTransaction reverses the vexels credited for a refunded or revoked transaction.
+// A transaction is only reversed once.
+func RevokeAppleIAPTransaction(ctx context.Context, logCtx *slog.Logger, signed appstore.Transaction) error {
+	fid := slog.String("fid", "vox.bank.RevokeAppleIAPTransaction")
+
+	index := docstore.Client.Collection("stores/apple/transactions")
+	if index == nil {
+		logCtx.Error("apple transactions collection not found", fid)
+		return common.ErrNotFound{}
+	}
+
+	revocationDate := time.Now().UTC()
+	if signed.RevocationDate != 0 {
+		revocationDate = time.UnixMilli(signed.RevocationDate).UTC()
+	}
+
+	var (
+		idx     appleTransactionIndex
+		revoked bool
+	)
+
+	err := docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		doc, err := tx.Get(index.Doc(signed.TransactionID))
+		if err != nil {
+			return err
+		}
+
+		if err := doc.DataTo(&idx); err != nil {
+			return err
+		}
+
+		if revoked = idx.Revoked; revoked {
+			return nil
+		}
+
+		txnRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/apple_iap_transactions", idx.AccountID)).Doc(signed.TransactionID)
+		balanceRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", idx.AccountID)).Doc("balance")
+
+		updates := []docstore.Update{
+			{Path: "revoked", Value: true},
+			{Path: "revocation_date", Value: revocationDate},
+		}
+
+		if err := tx.Update(index.Doc(signed.TransactionID), updates[:1]); err != nil {
+			return err
+		}
+
+		if err := tx.Update(txnRef, updates); err != nil {
+			return err
+		}
+
+		if err := tx.Update(balanceRef, []docstore.Update{{Path: "balance", Value: docstore.Increment(-idx.Amount)}}); err != nil {
+			return err
+		}
+
+		entry := LedgerEntry{Amount: -int(idx.Amount), Bucket: BucketPurchased, Reason: ReasonAppleRefund, SourceID: signed.TransactionID}
+		return AddLedgerEntriesTx(tx, idx.AccountID, entry)
+	})
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to revoke apple IAP transaction", fid, "transaction_id", signed.TransactionID, "error", err)
+		return err
+	}
+
+	if revoked {
+		logCtx.Info("apple transaction already revoked", fid, "transaction_id", signed.TransactionID)
+		return nil
+	}
+
+	logCtx.Info("apple transaction revoked", fid, "transaction_id", signed.TransactionID, "account_id", idx.AccountID, "amount", idx.Amount)
+
+	return nil
+}
+
+// PostAppleNotification verifies an App Store Server Notification v2 and reverses refunded or revoked transactions.
+func PostAppleNotification(ctx context.Context, logCtx *slog.Logger, signedPayload string) (appstore.Notification, error) {
+	fid := slog.String("fid", "vox.bank.PostAppleNotification")
+
+	if appstore.Default == nil {
+		logCtx.Error("apple verifier not configured", fid)
+		return appstore.Notification{}, fmt.Errorf("%w: apple verifier not configured", common.ErrIAPUnauthorized)
+	}
+
+	n, err := appstore.Default.VerifyNotification(signedPayload)
+	if err != nil {
+		logCtx.Error("unable to verify apple notification", fid, "error", err)
+		return appstore.Notification{}, err
+	}
+
+	logCtx.Info("apple store", "type", n.NotificationType, "subtype", n.Subtype, "uuid", n.NotificationUUID)
+
+	switch n.NotificationType {
+	case appstore.NotificationRefund, appstore.NotificationRevoke:
+		if n.Transaction == nil {
+			logCtx.Error("apple notification without transaction", fid, "type", n.NotificationType)
+			return n, common.ErrBadRequest{Msg: "notification without transaction"}
+		}
+
+		if err := RevokeAppleIAPTransaction(ctx, logCtx, *n.Transaction); err != nil {
+			if errors.Is(err, common.ErrNotFound{}) {
+				logCtx.Warn("apple transaction not credited", fid, "transaction_id", n.Transaction.TransactionID)
+				return n, nil
+			}
+			return n, err
+		}
+	}
+
+	return n, nil
+}
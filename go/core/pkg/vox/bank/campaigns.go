@@ -0,0 +1,460 @@
+package bank
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
+)
+
+// Gift card statuses
+const (
+	GiftCardCurrent = "current"
+	GiftCardExpired = "expired"
+)
+
+const (
+	// DefaultGiftCardCodeLength is the length of a minted code, without its prefix.
+	DefaultGiftCardCodeLength = 12
+
+	// MaxGiftCardBatch is the largest number of gift cards minted at once.
+	MaxGiftCardBatch = 10000
+
+	// Codes avoid characters that are easily confused when typed, such as 0 and O or 1 and I.
+	giftCardCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	giftCardMintRetries  = 3
+	giftCardMintWorkers  = 32
+)
+
+// GiftCardBatch describes a batch of gift cards to mint.
+type GiftCardBatch struct {
+	Campaign        string    `json:"campaign"`
+	CodeLength      int       `json:"code_length,omitempty"`
+	Count           int       `json:"count"`
+	Description     string    `json:"description"`
+	Expiration      time.Time `json:"expiration,omitempty"`
+	MaxRedemptions  int       `json:"max_redemptions"`
+	PerAccountLimit int       `json:"per_account_limit"`
+	Prefix          string    `json:"prefix,omitempty"`
+	Start           time.Time `json:"start,omitempty"`
+	Value           int64     `json:"value"`
+}
+
+// GiftCard is a gift card with its code and status. Outbound only.
+type GiftCard struct {
+	Code   string `json:"code"`
+	Status string `json:"status"`
+	GiftCardInfo
+}
+
+// CampaignReport summarizes the gift cards and redemptions of a campaign.
+type CampaignReport struct {
+	Accounts      int    `json:"accounts"`
+	Campaign      string `json:"campaign"`
+	Cards         int    `json:"cards"`
+	Current       int    `json:"current"`
+	Expired       int    `json:"expired"`
+	FaceValue     int64  `json:"face_value"`
+	RedeemedValue int64  `json:"redeemed_value"`
+	Redemptions   int    `json:"redemptions"`
+}
+
+// giftCardRedemption counts the redemptions of a gift card by an account.
+type giftCardRedemption struct {
+	AccountID string    `firestore:"account_id"`
+	Campaign  string    `firestore:"campaign,omitempty"`
+	Code      string    `firestore:"code"`
+	Count     int       `firestore:"count"`
+	First     time.Time `firestore:"first"`
+	Last      time.Time `firestore:"last"`
+	Value     int64     `firestore:"value"`
+}
+
+// MintGiftCards creates a batch of gift cards with random codes.
+func MintGiftCards(ctx context.Context, logCtx *slog.Logger, batch GiftCardBatch) ([]GiftCard, error) {
+	fid := slog.String("fid", "vox.bank.MintGiftCards")
+
+	if err := validateGiftCardBatch(&batch); err != nil {
+		logCtx.Warn("invalid gift card batch", fid, "error", err)
+		return nil, err
+	}
+
+	collection := docstore.Client.Collection("bank/gift_cards/current")
+	if collection == nil {
+		logCtx.Error("unable to get current gift cards collection", fid)
+		return nil, common.ErrNotFound{}
+	}
+
+	info := GiftCardInfo{
+		Campaign:        batch.Campaign,
+		Created:         time.Now().UTC(),
+		Description:     batch.Description,
+		Expiration:      batch.Expiration,
+		MaxRedemptions:  batch.MaxRedemptions,
+		OneTimeUse:      batch.MaxRedemptions == 1,
+		PerAccountLimit: batch.PerAccountLimit,
+		Start:           batch.Start,
+		Value:           batch.Value,
+	}
+
+	minted := make([]GiftCard, batch.Count)
+
+	// Cards are created concurrently, a serial batch of MaxGiftCardBatch round trips outlives the request.
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(giftCardMintWorkers)
+
+	for i := range minted {
+		i := i
+		g.Go(func() error {
+			card, err := mintGiftCard(gctx, collection, batch, info)
+			if err != nil {
+				return err
+			}
+
+			minted[i] = card
+			return nil
+		})
+	}
+
+	err := g.Wait()
+
+	cards := make([]GiftCard, 0, batch.Count)
+	for _, card := range minted {
+		if card.Code != "" {
+			cards = append(cards, card)
+		}
+	}
+
+	if err != nil {
+		logCtx.Error("unable to mint gift card", fid, "campaign", batch.Campaign, "minted", len(cards), "error", err)
+		return cards, err
+	}
+
+	logCtx.Info("gift cards minted", fid, "campaign", batch.Campaign, "count", len(cards), "value", batch.Value)
+
+	return cards, nil
+}
+
+// mintGiftCard creates one gift card, retrying with a new code when the code is taken.
+func mintGiftCard(ctx context.Context, collection *docstore.CollectionRef, batch GiftCardBatch, info GiftCardInfo) (GiftCard, error) {
+	var err error
+
+	for i := 0; i < giftCardMintRetries; i++ {
+		var code string
+
+		code, err = newGiftCardCode(batch.Prefix, batch.CodeLength)
+		if err != nil {
+			return GiftCard{}, err
+		}
+
+		if err = collection.Doc(code).Create(ctx, info); err == nil {
+			return GiftCard{Code: code, Status: GiftCardCurrent, GiftCardInfo: info}, nil
+		}
+
+		if err = common.ConvertGRPCError(err); !errors.Is(err, common.ErrAlreadyExists{}) {
+			return GiftCard{}, err
+		}
+	}
+
+	return GiftCard{}, err
+}
+
+func validateGiftCardBatch(batch *GiftCardBatch) error {
+	batch.Campaign = strings.TrimSpace(batch.Campaign)
+
+	if batch.CodeLength == 0 {
+		batch.CodeLength = DefaultGiftCardCodeLength
+	}
+
+	switch {
+	case batch.Campaign == "":
+		return common.ErrBadRequest{Msg: "campaign required"}
+	case strings.Contains(batch.Prefix, "/"):
+		return common.ErrBadRequest{Msg: "invalid prefix"}
+	case batch.Count < 1 || batch.Count > MaxGiftCardBatch:
+		return common.ErrBadRequest{Msg: fmt.Sprintf("count must be between 1 and %d", MaxGiftCardBatch)}
+	case batch.CodeLength < 8 || batch.CodeLength > 32:
+		return common.ErrBadRequest{Msg: "code length must be between 8 and 32"}
+	case batch.Value < 1:
+		return common.ErrBadRequest{Msg: "value must be positive"}
+	case batch.MaxRedemptions < 0 || batch.PerAccountLimit < 0:
+		return common.ErrBadRequest{Msg: "limits must not be negative"}
+	case !batch.Expiration.IsZero() && !batch.Expiration.After(batch.Start):
+		return common.ErrBadRequest{Msg: "expiration must be after start"}
+	}
+
+	return nil
+}
+
+func newGiftCardCode(prefix string, length int) (string, error) {
+	max := big.NewInt(int64(len(giftCardCodeAlphabet)))
+
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		b[i] = giftCardCodeAlphabet[n.Int64()]
+	}
+
+	return prefix + string(b), nil
+}
+
+// RedeemGiftCard credits a current gift card to an account. The card's redemption limits are checked and counted in
+// the same transaction as the credit, and a card that reaches its redemption limit is expired.
+func RedeemGiftCard(ctx context.Context, logCtx *slog.Logger, accountID, code string) (GiftCardInfo, error) {
+	fid := slog.String("fid", "vox.bank.RedeemGiftCard")
+
+	if code == "" || strings.Contains(code, "/") {
+		return GiftCardInfo{}, common.ErrNotFound{Msg: "invalid gift card"}
+	}
+
+	cardRef := docstore.Client.Collection("bank/gift_cards/current").Doc(code)
+	redemptionRef := docstore.Client.Collection("bank/gift_cards/redemptions").Doc(code + ":" + accountID)
+	balanceRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", accountID)).Doc("balance")
+
+	var (
+		gc     GiftCardInfo
+		spent  bool
+		usedUp bool
+	)
+
+	err := docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		gc, spent, usedUp = GiftCardInfo{}, false, false
+
+		doc, err := tx.Get(cardRef)
+		if err != nil {
+			if errors.Is(common.ConvertGRPCError(err), common.ErrNotFound{}) {
+				return common.ErrNotFound{Msg: "invalid gift card"}
+			}
+			return err
+		}
+
+		if err := doc.DataTo(&gc); err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		if !gc.Start.IsZero() && gc.Start.After(now) {
+			return common.ErrUnprocessable{Msg: "gift card not started"}
+		}
+
+		maxRedemptions := gc.MaxRedemptions
+		if gc.OneTimeUse {
+			maxRedemptions = 1
+		}
+
+		if !gc.Expiration.IsZero() && gc.Expiration.Before(now) {
+			spent = true
+			return common.ErrNotFound{Msg: "invalid gift card"}
+		}
+
+		if maxRedemptions > 0 && gc.Redemptions >= maxRedemptions {
+			spent = true
+			return common.ErrNotFound{Msg: "invalid gift card"}
+		}
+
+		r := giftCardRedemption{AccountID: accountID, Campaign: gc.Campaign, Code: code, First: now.UTC()}
+
+		doc, err = tx.Get(redemptionRef)
+		if err != nil && !errors.Is(common.ConvertGRPCError(err), common.ErrNotFound{}) {
+			return err
+		}
+
+		if doc.Exists() {
+			if err := doc.DataTo(&r); err != nil {
+				return err
+			}
+		}
+
+		if gc.PerAccountLimit > 0 && r.Count >= gc.PerAccountLimit {
+			return common.ErrUnprocessable{Msg: "gift card already redeemed"}
+		}
+
+		r.Count++
+		r.Last = now.UTC()
+		r.Value += gc.Value
+		gc.Redemptions++
+
+		usedUp = maxRedemptions > 0 && gc.Redemptions >= maxRedemptions
+
+		if err := tx.Update(cardRef, []docstore.Update{{Path: "redemptions", Value: docstore.Increment(1)}}); err != nil {
+			return err
+		}
+
+		if err := tx.Set(redemptionRef, r); err != nil {
+			return err
+		}
+
+		if err := tx.Update(balanceRef, []docstore.Update{{Path: "balance", Value: docstore.Increment(gc.Value)}}); err != nil {
+			return err
+		}
+
+		entry := LedgerEntry{Amount: int(gc.Value), Bucket: BucketPurchased, Reason: ReasonGiftCard, SourceID: code}
+		return AddLedgerEntriesTx(tx, accountID, entry)
+	})
+
+	// A card that had already expired or reached its limit is moved, and so is one this redemption used up once it
+	// committed. A redemption that failed otherwise never moves the card.
+	if spent || (usedUp && err == nil) {
+		if err := ExpireGiftCard(ctx, logCtx, code); err != nil {
+			logCtx.Warn("unable to expire gift card", fid, "error", err)
+		}
+	}
+
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Warn("unable to redeem gift card", fid, "error", err)
+		return GiftCardInfo{}, err
+	}
+
+	return gc, nil
+}
+
+// GetCampaignGiftCards returns the current and expired gift cards of a campaign, sorted by code.
+func GetCampaignGiftCards(ctx context.Context, logCtx *slog.Logger, campaign string) ([]GiftCard, error) {
+	fid := slog.String("fid", "vox.bank.GetCampaignGiftCards")
+
+	cards := []GiftCard{}
+
+	for _, status := range []string{GiftCardCurrent, GiftCardExpired} {
+		collection := docstore.Client.Collection("bank/gift_cards/" + status)
+		if collection == nil {
+			logCtx.Error("unable to get gift cards collection", fid, "status", status)
+			return nil, common.ErrNotFound{}
+		}
+
+		docs, err := collection.Where("campaign", "==", campaign).Documents(ctx).GetAll()
+		if err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Error("unable to get campaign gift cards", fid, "status", status, "error", err)
+			return nil, err
+		}
+
+		for _, doc := range docs {
+			gc := GiftCard{Code: doc.Ref.ID, Status: status}
+			if err := doc.DataTo(&gc.GiftCardInfo); err != nil {
+				err = common.ConvertGRPCError(err)
+				logCtx.Error("unable to read gift card data", fid, "error", err)
+				return nil, err
+			}
+
+			cards = append(cards, gc)
+		}
+	}
+
+	sort.Slice(cards, func(i, j int) bool {
+		return cards[i].Code < cards[j].Code
+	})
+
+	return cards, nil
+}
+
+// GetCampaignReport summarizes the gift cards of a campaign and their redemptions.
+func GetCampaignReport(ctx context.Context, logCtx *slog.Logger, campaign string) (CampaignReport, error) {
+	fid := slog.String("fid", "vox.bank.GetCampaignReport")
+
+	cards, err := GetCampaignGiftCards(ctx, logCtx, campaign)
+	if err != nil {
+		return CampaignReport{}, err
+	}
+
+	report := CampaignReport{Campaign: campaign, Cards: len(cards)}
+
+	for _, gc := range cards {
+		if gc.Status == GiftCardExpired {
+			report.Expired++
+		} else {
+			report.Current++
+		}
+
+		report.FaceValue += gc.Value
+	}
+
+	collection := docstore.Client.Collection("bank/gift_cards/redemptions")
+	if collection == nil {
+		logCtx.Error("unable to get gift card redemptions collection", fid)
+		return CampaignReport{}, common.ErrNotFound{}
+	}
+
+	docs, err := collection.Where("campaign", "==", campaign).Documents(ctx).GetAll()
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to get campaign redemptions", fid, "error", err)
+		return CampaignReport{}, err
+	}
+
+	accounts := map[string]bool{}
+
+	for _, doc := range docs {
+		r := giftCardRedemption{}
+		if err := doc.DataTo(&r); err != nil {
+			logCtx.Warn("unable to read gift card redemption", fid, "id", doc.Ref.ID, "error", err)
+			continue
+		}
+
+		accounts[r.AccountID] = true
+		report.Redemptions += r.Count
+		report.RedeemedValue += r.Value
+	}
+
+	report.Accounts = len(accounts)
+
+	return report, nil
+}
+
+// WriteGiftCardsCSV writes gift cards as CSV with a header row.
+func WriteGiftCardsCSV(w io.Writer, cards []GiftCard) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"code", "status", "campaign", "value", "start", "expiration", "max_redemptions", "per_account_limit", "redemptions", "description"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, gc := range cards {
+		record := []string{
+			gc.Code,
+			gc.Status,
+			gc.Campaign,
+			strconv.FormatInt(gc.Value, 10),
+			formatCSVTime(gc.Start),
+			formatCSVTime(gc.Expiration),
+			strconv.Itoa(gc.MaxRedemptions),
+			strconv.Itoa(gc.PerAccountLimit),
+			strconv.Itoa(gc.Redemptions),
+			gc.Description,
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatCSVTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,67 @@
+package bank
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMintGiftCards(t *testing.T) {
+	setupBank(t)
+	ctx := context.Background()
+
+	batch := GiftCardBatch{Campaign: "launch", Count: 500, Prefix: "VX-", Value: 50}
+
+	cards, err := MintGiftCards(ctx, testLogCtx, batch)
+	if err != nil {
+		t.Fatalf("MintGiftCards: %v", err)
+	}
+
+	if len(cards) != batch.Count {
+		t.Fatalf("minted %d cards, want %d", len(cards), batch.Count)
+	}
+
+	codes := map[string]bool{}
+	for _, card := range cards {
+		if codes[card.Code] {
+			t.Fatalf("duplicate code %s", card.Code)
+		}
+		codes[card.Code] = true
+	}
+
+	docs, err := GetCampaignGiftCards(ctx, testLogCtx, "launch")
+	if err != nil {
+		t.Fatalf("GetCampaignGiftCards: %v", err)
+	}
+
+	if len(docs) != batch.Count {
+		t.Errorf("stored %d cards, want %d", len(docs), batch.Count)
+	}
+}
+
+func TestRedeemGiftCardFailedKeepsCard(t *testing.T) {
+	setupBank(t)
+	ctx := context.Background()
+
+	cards, err := MintGiftCards(ctx, testLogCtx, GiftCardBatch{Campaign: "launch", Count: 1, MaxRedemptions: 1, Value: 50})
+	if err != nil {
+		t.Fatalf("MintGiftCards: %v", err)
+	}
+
+	// The account has no balance document, so the redemption fails after the card was checked.
+	if _, err := RedeemGiftCard(ctx, testLogCtx, "account-2", cards[0].Code); err == nil {
+		t.Fatal("RedeemGiftCard succeeded without a balance")
+	}
+
+	if _, err := RedeemGiftCard(ctx, testLogCtx, "account-1", cards[0].Code); err != nil {
+		t.Fatalf("RedeemGiftCard: %v", err)
+	}
+
+	docs, err := GetCampaignGiftCards(ctx, testLogCtx, "launch")
+	if err != nil {
+		t.Fatalf("GetCampaignGiftCards: %v", err)
+	}
+
+	if len(docs) != 1 || docs[0].Status != GiftCardExpired || docs[0].Redemptions != 1 {
+		t.Errorf("cards = %+v", docs)
+	}
+}
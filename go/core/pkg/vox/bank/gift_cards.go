@@ -5,32 +5,36 @@ import (
 	"log/slog"
 	"time"
 
-	fs "cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
 
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 )
 
 // GiftCards are a map of gift cards
 type GiftCards map[string]GiftCardInfo
 
-// GiftCardInfo contains a promo codes information
+// GiftCardInfo contains a promo codes information. Zero limits are unlimited.
 type GiftCardInfo struct {
-	Description string    `firestore:"description" json:"description"`
-	Expiration  time.Time `firestore:"expiration" json:"expiration"`
-	OneTimeUse  bool      `firestore:"one_time_use" json:"one_time_use"`
-	Start       time.Time `firestore:"start" json:"start"`
-	Value       int64     `firestore:"value" json:"value"`
+	Campaign        string    `firestore:"campaign,omitempty" json:"campaign,omitempty"`
+	Created         time.Time `firestore:"created,omitempty" json:"created,omitempty"`
+	Description     string    `firestore:"description" json:"description"`
+	Expiration      time.Time `firestore:"expiration" json:"expiration"`
+	MaxRedemptions  int       `firestore:"max_redemptions,omitempty" json:"max_redemptions,omitempty"`
+	OneTimeUse      bool      `firestore:"one_time_use" json:"one_time_use"`
+	PerAccountLimit int       `firestore:"per_account_limit,omitempty" json:"per_account_limit,omitempty"`
+	Redemptions     int       `firestore:"redemptions,omitempty" json:"redemptions,omitempty"`
+	Start           time.Time `firestore:"start" json:"start"`
+	Value           int64     `firestore:"value" json:"value"`
 }
 
 // GetGiftCard retrieves a current gift card.
 func GetGiftCard(ctx context.Context, logCtx *slog.Logger, giftCard string) (GiftCardInfo, error) {
 	fid := slog.String("fid", "vox.bank.GetGiftCards")
 
-	var collection *fs.CollectionRef
+	var collection *docstore.CollectionRef
 
-	collection = firestore.Client.Collection("bank/gift_cards/current")
+	collection = docstore.Client.Collection("bank/gift_cards/current")
 	if collection == nil {
 		logCtx.Error("unable to get current gift cards collection", fid)
 		return GiftCardInfo{}, common.ErrNotFound{}
@@ -52,12 +56,12 @@ func GetGiftCard(ctx context.Context, logCtx *slog.Logger, giftCard string) (Gif
 	}
 
 	if g.Expiration.Before(time.Now()) && !time.Time.IsZero(g.Expiration) {
-		if _, err := doc.Ref.Delete(ctx); err != nil {
+		if err := doc.Ref.Delete(ctx); err != nil {
 			logCtx.Error("unable to delete gift card", fid, "error", err)
 			return GiftCardInfo{}, err
 		}
 
-		if _, err := firestore.Client.Collection("bank/gift_cards/expired").Doc(giftCard).Set(ctx, g); err != nil {
+		if err := docstore.Client.Collection("bank/gift_cards/expired").Doc(giftCard).Set(ctx, g); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to add gift card to expired", fid, "error", err)
 			return GiftCardInfo{}, err
@@ -71,16 +75,16 @@ func GetGiftCard(ctx context.Context, logCtx *slog.Logger, giftCard string) (Gif
 func GetGiftCards(ctx context.Context, logCtx *slog.Logger, expired string) (GiftCards, error) {
 	fid := slog.String("fid", "vox.bank.GetGiftCards")
 
-	var collection *fs.CollectionRef
+	var collection *docstore.CollectionRef
 
 	if expired == "true" {
-		collection = firestore.Client.Collection("bank/gift_cards/expired")
+		collection = docstore.Client.Collection("bank/gift_cards/expired")
 		if collection == nil {
 			logCtx.Error("unable to get expired gift cards collection", fid)
 			return GiftCards{}, common.ErrNotFound{}
 		}
 	} else {
-		collection = firestore.Client.Collection("bank/gift_cards/current")
+		collection = docstore.Client.Collection("bank/gift_cards/current")
 		if collection == nil {
 			logCtx.Error("unable to get current gift cards collection", fid)
 			return GiftCards{}, common.ErrNotFound{}
@@ -110,7 +114,7 @@ func GetGiftCards(ctx context.Context, logCtx *slog.Logger, expired string) (Gif
 
 		if expired != "true" && gc.Expiration.Before(time.Now()) && !time.Time.IsZero(gc.Expiration) {
 			expiredCards[doc.Ref.ID] = gc
-			if _, err := doc.Ref.Delete(ctx); err != nil {
+			if err := doc.Ref.Delete(ctx); err != nil {
 				logCtx.Error("unable to delete gift card", fid, "error", err)
 				return GiftCards{}, err
 			}
@@ -120,7 +124,7 @@ func GetGiftCards(ctx context.Context, logCtx *slog.Logger, expired string) (Gif
 	}
 
 	for name, data := range expiredCards {
-		if _, err := firestore.Client.Collection("bank/gift_cards/expired").Doc(name).Set(ctx, data); err != nil {
+		if err := docstore.Client.Collection("bank/gift_cards/expired").Doc(name).Set(ctx, data); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to add gift card to expired", fid, "error", err)
 			return GiftCards{}, err
@@ -134,7 +138,7 @@ func GetGiftCards(ctx context.Context, logCtx *slog.Logger, expired string) (Gif
 func ExpireGiftCard(ctx context.Context, logCtx *slog.Logger, giftCard string) error {
 	fid := slog.String("fid", "vox.bank.ExpireGiftCard")
 
-	collection := firestore.Client.Collection("bank/gift_cards/current")
+	collection := docstore.Client.Collection("bank/gift_cards/current")
 	if collection == nil {
 		logCtx.Error("unable to get accounts collection", fid)
 		return common.ErrNotFound{}
@@ -154,12 +158,12 @@ func ExpireGiftCard(ctx context.Context, logCtx *slog.Logger, giftCard string) e
 		return err
 	}
 
-	if _, err := doc.Ref.Delete(ctx); err != nil {
+	if err := doc.Ref.Delete(ctx); err != nil {
 		logCtx.Error("unable to delete gift card", fid, "error", err)
 		return err
 	}
 
-	if _, err := firestore.Client.Collection("bank/gift_cards/expired").Doc(giftCard).Set(ctx, gc); err != nil {
+	if err := docstore.Client.Collection("bank/gift_cards/expired").Doc(giftCard).Set(ctx, gc); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to add gift card to expired", fid, "error", err)
 		return err
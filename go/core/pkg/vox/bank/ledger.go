@@ -0,0 +1,196 @@
+package bank
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
+)
+
+// Ledger balance buckets.
+const (
+	BucketPurchased    = "purchased"
+	BucketSubscription = "subscription"
+)
+
+// Ledger reasons.
+const (
+	ReasonAdd                 = "add"
+	ReasonAndroidIAP          = "android_iap"
+	ReasonAndroidRefund       = "android_refund"
+	ReasonAppleIAP            = "apple_iap"
+	ReasonAppleRefund         = "apple_refund"
+	ReasonCharge              = "charge"
+	ReasonFreeVexels          = "free_vexels"
+	ReasonGiftCard            = "gift_card"
+	ReasonSubscription        = "subscription"
+	ReasonSubscriptionExpired = "subscription_expired"
+	ReasonSubscriptionRenewal = "subscription_renewal"
+)
+
+const (
+	// DefaultLedgerLimit is the page size when none is requested.
+	DefaultLedgerLimit = 50
+
+	// MaxLedgerLimit is the largest page size.
+	MaxLedgerLimit = 200
+)
+
+// LedgerEntry is an immutable debit or credit of an account's bank. Debits have a negative amount.
+type LedgerEntry struct {
+	ID        string    `firestore:"id" json:"id"`
+	Amount    int       `firestore:"amount" json:"amount"`
+	Bucket    string    `firestore:"bucket" json:"bucket"`
+	Reason    string    `firestore:"reason" json:"reason"`
+	Character string    `firestore:"character,omitempty" json:"character,omitempty"`
+	Tier      string    `firestore:"tier,omitempty" json:"tier,omitempty"`
+	SessionID int       `firestore:"session_id,omitempty" json:"session_id,omitempty"`
+	SourceID  string    `firestore:"source_id,omitempty" json:"source_id,omitempty"`
+	Timestamp time.Time `firestore:"timestamp" json:"timestamp"`
+}
+
+// LedgerPage is a page of ledger entries, newest first.
+type LedgerPage struct {
+	Transactions  []LedgerEntry `json:"transactions"`
+	NextPageToken string        `json:"next_page_token,omitempty"`
+}
+
+// AddLedgerEntries appends entries to an account's ledger. Entries are never updated or deleted.
+func AddLedgerEntries(ctx context.Context, logCtx *slog.Logger, accountID string, entries ...LedgerEntry) error {
+	fid := slog.String("fid", "vox.bank.AddLedgerEntries")
+
+	collection := docstore.Client.Collection(ledgerPath(accountID))
+	if collection == nil {
+		logCtx.Error("ledger collection not found", fid)
+		return common.ErrNotFound{}
+	}
+
+	for _, entry := range newLedgerEntries(entries) {
+		if err := collection.Doc(entry.ID).Create(ctx, entry); err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Error("unable to create ledger entry", fid, "reason", entry.Reason, "amount", entry.Amount, "error", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddLedgerEntriesTx appends entries to an account's ledger within a transaction.
+func AddLedgerEntriesTx(tx *docstore.Transaction, accountID string, entries ...LedgerEntry) error {
+	collection := docstore.Client.Collection(ledgerPath(accountID))
+	if collection == nil {
+		return common.ErrNotFound{}
+	}
+
+	for _, entry := range newLedgerEntries(entries) {
+		if err := tx.Create(collection.Doc(entry.ID), entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ledgerPath(accountID string) string {
+	return fmt.Sprintf("accounts/%s/bank/balance/transactions", accountID)
+}
+
+// newLedgerEntries sets the IDs and timestamps of new entries and drops empty ones.
+func newLedgerEntries(entries []LedgerEntry) []LedgerEntry {
+	now := time.Now().UTC()
+	res := make([]LedgerEntry, 0, len(entries))
+
+	for _, entry := range entries {
+		if entry.Amount == 0 {
+			continue
+		}
+
+		entry.ID = uuid.New().String()
+
+		if entry.Timestamp.IsZero() {
+			entry.Timestamp = now
+		}
+
+		res = append(res, entry)
+	}
+
+	return res
+}
+
+// GetLedger returns the ledger entries of an account from start up to end, newest first.
+// pageToken is the NextPageToken of the previous page.
+func GetLedger(ctx context.Context, logCtx *slog.Logger, accountID string, start, end time.Time, limit int, pageToken string) (LedgerPage, error) {
+	fid := slog.String("fid", "vox.bank.GetLedger")
+
+	if limit <= 0 {
+		limit = DefaultLedgerLimit
+	}
+
+	if limit > MaxLedgerLimit {
+		limit = MaxLedgerLimit
+	}
+
+	collection := docstore.Client.Collection(ledgerPath(accountID))
+	if collection == nil {
+		logCtx.Error("ledger collection not found", fid)
+		return LedgerPage{}, common.ErrNotFound{}
+	}
+
+	query := collection.Where("timestamp", ">=", start).Where("timestamp", "<", end).
+		OrderBy("timestamp", docstore.Desc).
+		OrderBy("id", docstore.Desc)
+
+	if pageToken != "" {
+		doc, err := collection.Doc(pageToken).Get(ctx)
+		if err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Warn("invalid page token", fid, "page_token", pageToken, "error", err)
+			return LedgerPage{}, common.ErrBadRequest{Msg: "invalid page token"}
+		}
+
+		last := LedgerEntry{}
+		if err := doc.DataTo(&last); err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Error("unable to read ledger entry", fid, "error", err)
+			return LedgerPage{}, err
+		}
+
+		query = query.StartAfter(last.Timestamp, last.ID)
+	}
+
+	// One more entry than the page is read to know whether there is a next page.
+	docs, err := query.Limit(limit + 1).Documents(ctx).GetAll()
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to get ledger entries", fid, "error", err)
+		return LedgerPage{}, err
+	}
+
+	entries := make([]LedgerEntry, 0, len(docs))
+
+	for _, doc := range docs {
+		entry := LedgerEntry{}
+		if err := doc.DataTo(&entry); err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Error("unable to read ledger entry", fid, "error", err)
+			return LedgerPage{}, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	page := LedgerPage{Transactions: entries}
+
+	if len(entries) > limit {
+		page.Transactions = entries[:limit]
+		page.NextPageToken = entries[limit-1].ID
+	}
+
+	return page, nil
+}
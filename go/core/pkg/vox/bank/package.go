@@ -2,13 +2,15 @@
 package bank
 
 import (
-	"disruptive/lib/common"
-	"disruptive/lib/firestore"
 	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/go-resty/resty/v2"
+
+	"disruptive/config"
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
 )
 
 const (
@@ -19,12 +21,26 @@ const (
 var (
 	// Resty is the shared Resty client for the bank package.
 	Resty *resty.Client
+
+	// Play verifies Google Play purchases. DIS_GOOGLE_PLAY_CLIENT selects the Play Developer API or the local stub.
+	Play PlayClient
 )
 
 func init() {
 	logCtx := slog.With("fid", "bank.init")
 
-	if firestore.Client == nil {
+	switch config.VARS.GooglePlayClient {
+	case PlayClientLocal:
+		local := NewLocalPlayClient()
+		local.AcceptAll = true
+		Play = local
+	case "", PlayClientAPI:
+		Play = &playAPIClient{}
+	default:
+		logCtx.Warn("unknown google play client", "client", config.VARS.GooglePlayClient)
+	}
+
+	if docstore.Client == nil {
 		logCtx.Warn("unable use bank")
 		return
 	}
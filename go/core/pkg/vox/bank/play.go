@@ -0,0 +1,284 @@
+package bank
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+
+	"disruptive/lib/common"
+)
+
+// Google Play clients
+const (
+	PlayClientAPI   = "api"
+	PlayClientLocal = "local"
+)
+
+// Google Play purchase states
+const (
+	PlayPurchased = 0
+	PlayCanceled  = 1
+	PlayPending   = 2
+)
+
+const (
+	playScope                  = "https://www.googleapis.com/auth/androidpublisher"
+	productPurchaseEndpoint    = "/applications/{packageName}/purchases/products/{productId}/tokens/{token}"
+	productAcknowledgeEndpoint = "/applications/{packageName}/purchases/products/{productId}/tokens/{token}:acknowledge"
+	playTimeout                = time.Second * 30
+)
+
+// PlayProductPurchase is a one-time product purchase verified with Google Play.
+type PlayProductPurchase struct {
+	AcknowledgementState int    `json:"acknowledgementState"`
+	ConsumptionState     int    `json:"consumptionState"`
+	OrderID              string `json:"orderId"`
+	ProductID            string `json:"productId"`
+	PurchaseState        int    `json:"purchaseState"`
+	PurchaseTimeMillis   string `json:"purchaseTimeMillis"`
+	Quantity             int    `json:"quantity"`
+}
+
+// PlaySubscriptionPurchase is a subscription purchase verified with Google Play.
+type PlaySubscriptionPurchase struct {
+	AcknowledgementState int    `json:"acknowledgementState"`
+	AutoRenewing         bool   `json:"autoRenewing"`
+	CancelReason         *int   `json:"cancelReason,omitempty"`
+	ExpiryTimeMillis     string `json:"expiryTimeMillis"`
+	OrderID              string `json:"orderId"`
+	PaymentState         *int   `json:"paymentState,omitempty"`
+	StartTimeMillis      string `json:"startTimeMillis"`
+}
+
+// PurchaseTime returns the purchase time, zero when unknown.
+func (p PlayProductPurchase) PurchaseTime() time.Time {
+	return parseMillis(p.PurchaseTimeMillis)
+}
+
+// ExpiryTime returns the subscription expiry, zero when unknown.
+func (p PlaySubscriptionPurchase) ExpiryTime() time.Time {
+	return parseMillis(p.ExpiryTimeMillis)
+}
+
+// PlayClient verifies and acknowledges Google Play purchases.
+type PlayClient interface {
+	GetProductPurchase(ctx context.Context, packageName, productID, token string) (PlayProductPurchase, error)
+	AcknowledgeProductPurchase(ctx context.Context, packageName, productID, token string) error
+	GetSubscriptionPurchase(ctx context.Context, packageName, subscriptionID, token string) (PlaySubscriptionPurchase, error)
+}
+
+// playAPIClient uses the Google Play Developer API with the default service account.
+type playAPIClient struct {
+	once   sync.Once
+	client *resty.Client
+	err    error
+}
+
+func (c *playAPIClient) resty(ctx context.Context) (*resty.Client, error) {
+	c.once.Do(func() {
+		httpClient, _, err := htransport.NewClient(context.WithoutCancel(ctx), option.WithScopes(playScope))
+		if err != nil {
+			c.err = err
+			return
+		}
+
+		c.client = common.NewHTTPResty(httpClient).
+			SetBaseURL(baseURL).
+			SetTimeout(playTimeout)
+	})
+
+	return c.client, c.err
+}
+
+func (c *playAPIClient) GetProductPurchase(ctx context.Context, packageName, productID, token string) (PlayProductPurchase, error) {
+	client, err := c.resty(ctx)
+	if err != nil {
+		return PlayProductPurchase{}, err
+	}
+
+	p := PlayProductPurchase{}
+
+	res, err := client.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"packageName": packageName, "productId": productID, "token": token}).
+		SetResult(&p).
+		Get(productPurchaseEndpoint)
+
+	if err := playError(res, err); err != nil {
+		return PlayProductPurchase{}, err
+	}
+
+	return p, nil
+}
+
+func (c *playAPIClient) AcknowledgeProductPurchase(ctx context.Context, packageName, productID, token string) error {
+	client, err := c.resty(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"packageName": packageName, "productId": productID, "token": token}).
+		SetBody(map[string]any{}).
+		Post(productAcknowledgeEndpoint)
+
+	return playError(res, err)
+}
+
+func (c *playAPIClient) GetSubscriptionPurchase(ctx context.Context, packageName, subscriptionID, token string) (PlaySubscriptionPurchase, error) {
+	client, err := c.resty(ctx)
+	if err != nil {
+		return PlaySubscriptionPurchase{}, err
+	}
+
+	p := PlaySubscriptionPurchase{}
+
+	res, err := client.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{"packageName": packageName, "subscriptionId": subscriptionID, "token": token}).
+		SetResult(&p).
+		Get(iapPurchaseEndpoint)
+
+	if err := playError(res, err); err != nil {
+		return PlaySubscriptionPurchase{}, err
+	}
+
+	return p, nil
+}
+
+// playError maps Google Play responses to errors. Unknown tokens are unauthorized purchases.
+func playError(res *resty.Response, err error) error {
+	if err != nil {
+		return err
+	}
+
+	switch res.StatusCode() {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusGone:
+		return fmt.Errorf("%w: %s", common.ErrIAPUnauthorized, res.String())
+	default:
+		return common.ErrBadGateway{Msg: fmt.Sprintf("google play %d: %s", res.StatusCode(), res.String())}
+	}
+}
+
+// LocalPlayClient is an in-memory Google Play for local runs and tests. Unknown tokens are not found,
+// unless AcceptAll is set, in which case any token is a purchased, unacknowledged purchase.
+type LocalPlayClient struct {
+	AcceptAll bool
+
+	mu            sync.Mutex
+	products      map[string]PlayProductPurchase
+	subscriptions map[string]PlaySubscriptionPurchase
+	acknowledged  map[string]bool
+}
+
+// NewLocalPlayClient returns an empty local Google Play.
+func NewLocalPlayClient() *LocalPlayClient {
+	return &LocalPlayClient{
+		products:      map[string]PlayProductPurchase{},
+		subscriptions: map[string]PlaySubscriptionPurchase{},
+		acknowledged:  map[string]bool{},
+	}
+}
+
+// SetProductPurchase adds or replaces a product purchase.
+func (c *LocalPlayClient) SetProductPurchase(token string, p PlayProductPurchase) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.products[token] = p
+}
+
+// SetSubscriptionPurchase adds or replaces a subscription purchase.
+func (c *LocalPlayClient) SetSubscriptionPurchase(token string, p PlaySubscriptionPurchase) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.subscriptions[token] = p
+}
+
+// Acknowledged reports whether a product purchase was acknowledged.
+func (c *LocalPlayClient) Acknowledged(token string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.acknowledged[token]
+}
+
+func (c *LocalPlayClient) GetProductPurchase(_ context.Context, _, productID, token string) (PlayProductPurchase, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.products[token]
+	if !ok {
+		if !c.AcceptAll {
+			return PlayProductPurchase{}, fmt.Errorf("%w: purchase token not found", common.ErrIAPUnauthorized)
+		}
+
+		p = PlayProductPurchase{
+			OrderID:            "GPA.local-" + token,
+			ProductID:          productID,
+			PurchaseState:      PlayPurchased,
+			PurchaseTimeMillis: strconv.FormatInt(time.Now().UnixMilli(), 10),
+			Quantity:           1,
+		}
+	}
+
+	if c.acknowledged[token] {
+		p.AcknowledgementState = 1
+	}
+
+	return p, nil
+}
+
+func (c *LocalPlayClient) AcknowledgeProductPurchase(_ context.Context, _, _, token string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.products[token]; !ok && !c.AcceptAll {
+		return fmt.Errorf("%w: purchase token not found", common.ErrIAPUnauthorized)
+	}
+
+	c.acknowledged[token] = true
+	return nil
+}
+
+func (c *LocalPlayClient) GetSubscriptionPurchase(_ context.Context, _, _, token string) (PlaySubscriptionPurchase, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.subscriptions[token]
+	if !ok {
+		if !c.AcceptAll {
+			return PlaySubscriptionPurchase{}, fmt.Errorf("%w: purchase token not found", common.ErrIAPUnauthorized)
+		}
+
+		now := time.Now()
+		p = PlaySubscriptionPurchase{
+			AutoRenewing:     true,
+			ExpiryTimeMillis: strconv.FormatInt(now.AddDate(0, 1, 0).UnixMilli(), 10),
+			OrderID:          "GPA.local-" + token,
+			StartTimeMillis:  strconv.FormatInt(now.UnixMilli(), 10),
+		}
+	}
+
+	return p, nil
+}
+
+func parseMillis(s string) time.Time {
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || ms == 0 {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(ms).UTC()
+}
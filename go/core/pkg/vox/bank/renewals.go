@@ -0,0 +1,317 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/iterator"
+
+	"disruptive/lib/common"
+	"disruptive/lib/configs"
+	"disruptive/lib/docstore"
+)
+
+// Subscription periods
+const (
+	PeriodDaily   = "daily"
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+	PeriodYearly  = "yearly"
+)
+
+// SubscriptionStoreAndroid marks a subscription that Google Play renews and expires.
+const SubscriptionStoreAndroid = "android"
+
+// subscriptionDocument is the subscription part of an account balance document.
+type subscriptionDocument struct {
+	SubscriptionBalance   int       `firestore:"subscription_balance"`
+	SubscriptionPending   string    `firestore:"subscription_pending"`
+	SubscriptionSKU       string    `firestore:"subscription_sku"`
+	SubscriptionStartDate time.Time `firestore:"subscription_start_date"`
+	SubscriptionStore     string    `firestore:"subscription_store"`
+}
+
+// SubscriptionRenewal records the change of a subscription at a period boundary.
+type SubscriptionRenewal struct {
+	AccountID       string    `firestore:"account_id" json:"account_id"`
+	Balance         int       `firestore:"balance" json:"balance"`
+	Expired         bool      `firestore:"expired,omitempty" json:"expired,omitempty"`
+	Periods         int       `firestore:"periods" json:"periods"`
+	PeriodEnd       time.Time `firestore:"period_end" json:"period_end"`
+	PeriodStart     time.Time `firestore:"period_start" json:"period_start"`
+	PreviousBalance int       `firestore:"previous_balance" json:"previous_balance"`
+	PreviousSKU     string    `firestore:"previous_sku,omitempty" json:"previous_sku,omitempty"`
+	Rollover        int       `firestore:"rollover" json:"rollover"`
+	SKU             string    `firestore:"sku,omitempty" json:"sku,omitempty"`
+	Timestamp       time.Time `firestore:"timestamp" json:"timestamp"`
+}
+
+// RenewalSummary counts the results of a renewal run.
+type RenewalSummary struct {
+	Accounts int                   `json:"accounts"`
+	Errors   int                   `json:"errors"`
+	Renewals []SubscriptionRenewal `json:"renewals"`
+}
+
+// NextPeriod returns the end of the subscription period that starts at start. Unknown periods are monthly.
+func NextPeriod(start time.Time, period string) time.Time {
+	switch strings.ToLower(period) {
+	case PeriodDaily:
+		return start.AddDate(0, 0, 1)
+	case PeriodWeekly:
+		return start.AddDate(0, 0, 7)
+	case PeriodYearly:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+// previousPeriod returns the start of the subscription period that ends at end. Unknown periods are monthly.
+func previousPeriod(end time.Time, period string) time.Time {
+	switch strings.ToLower(period) {
+	case PeriodDaily:
+		return end.AddDate(0, 0, -1)
+	case PeriodWeekly:
+		return end.AddDate(0, 0, -7)
+	case PeriodYearly:
+		return end.AddDate(-1, 0, 0)
+	default:
+		return end.AddDate(0, -1, 0)
+	}
+}
+
+// planRenewal computes the renewal of a subscription at now. It returns false when the current period has not ended.
+// The pending sku, when set, replaces the current sku at the boundary. The unused balance of a rollover sku carries
+// over, capped at one period of the new sku. A subscription without a sku expires and loses its balance.
+// Store subscriptions are never due, their store notifications renew and expire them.
+func planRenewal(s subscriptionDocument, skus configs.SKUs, now time.Time) (SubscriptionRenewal, bool, error) {
+	if s.SubscriptionStartDate.IsZero() || s.SubscriptionStore != "" {
+		return SubscriptionRenewal{}, false, nil
+	}
+
+	current := skus[s.SubscriptionSKU]
+
+	next := s.SubscriptionSKU
+	if s.SubscriptionPending != "" {
+		next = s.SubscriptionPending
+	}
+
+	r := SubscriptionRenewal{
+		PeriodStart:     s.SubscriptionStartDate,
+		PeriodEnd:       NextPeriod(s.SubscriptionStartDate, current.Period),
+		PreviousBalance: s.SubscriptionBalance,
+		PreviousSKU:     s.SubscriptionSKU,
+		SKU:             next,
+		Timestamp:       now.UTC(),
+	}
+
+	if now.Before(r.PeriodEnd) {
+		return SubscriptionRenewal{}, false, nil
+	}
+
+	if next == "" {
+		if s.SubscriptionBalance == 0 {
+			return SubscriptionRenewal{}, false, nil
+		}
+
+		r.Expired = true
+		return r, true, nil
+	}
+
+	sku, ok := skus[next]
+	if !ok {
+		return SubscriptionRenewal{}, false, common.ErrNotFound{Msg: "invalid sku " + next}
+	}
+
+	// The first period of the new sku starts at the boundary. Missed periods are skipped, not paid out.
+	r.PeriodStart = r.PeriodEnd
+	r.PeriodEnd = NextPeriod(r.PeriodStart, sku.Period)
+	r.Periods = 1
+
+	for !now.Before(r.PeriodEnd) {
+		r.PeriodStart = r.PeriodEnd
+		r.PeriodEnd = NextPeriod(r.PeriodStart, sku.Period)
+		r.Periods++
+	}
+
+	if current.Rollover && s.SubscriptionBalance > 0 {
+		r.Rollover = min(s.SubscriptionBalance, sku.Balance)
+	}
+
+	r.Balance = sku.Balance + r.Rollover
+
+	return r, true, nil
+}
+
+// RenewSubscription renews the subscription of an account when its period has ended, writes the change to the
+// ledger and the renewal history, and returns false when nothing was due.
+func RenewSubscription(ctx context.Context, logCtx *slog.Logger, accountID string, now time.Time) (SubscriptionRenewal, bool, error) {
+	fid := slog.String("fid", "vox.bank.RenewSubscription")
+
+	skus, err := configs.GetSKUs(ctx, logCtx)
+	if err != nil {
+		logCtx.Error("unable to get banking configs", fid, "error", err)
+		return SubscriptionRenewal{}, false, err
+	}
+
+	balanceRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", accountID)).Doc("balance")
+	renewals := docstore.Client.Collection(renewalsPath(accountID))
+
+	var (
+		r   SubscriptionRenewal
+		due bool
+	)
+
+	err = docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		doc, err := tx.Get(balanceRef)
+		if err != nil {
+			if errors.Is(common.ConvertGRPCError(err), common.ErrNotFound{}) {
+				return nil
+			}
+			return err
+		}
+
+		s := subscriptionDocument{}
+		if err := doc.DataTo(&s); err != nil {
+			return err
+		}
+
+		r, due, err = planRenewal(s, skus, now)
+		if err != nil || !due {
+			return err
+		}
+
+		r.AccountID = accountID
+
+		updates := []docstore.Update{
+			{Path: "subscription_balance", Value: r.Balance},
+			{Path: "subscription_pending", Value: docstore.Delete},
+		}
+
+		reason := ReasonSubscriptionRenewal
+
+		if r.Expired {
+			reason = ReasonSubscriptionExpired
+		} else {
+			updates = append(updates,
+				docstore.Update{Path: "subscription_sku", Value: r.SKU},
+				docstore.Update{Path: "subscription_start_date", Value: r.PeriodStart},
+			)
+		}
+
+		if err := tx.Update(balanceRef, updates); err != nil {
+			return err
+		}
+
+		// Keyed by the period that ended, so a period is only renewed once.
+		id := s.SubscriptionStartDate.UTC().Format("20060102T150405Z")
+		if err := tx.Create(renewals.Doc(id), r); err != nil {
+			return err
+		}
+
+		entry := LedgerEntry{Amount: r.Balance - r.PreviousBalance, Bucket: BucketSubscription, Reason: reason, SourceID: r.SKU}
+		return AddLedgerEntriesTx(tx, accountID, entry)
+	})
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to renew subscription", fid, "account_id", accountID, "error", err)
+		return SubscriptionRenewal{}, false, err
+	}
+
+	if due {
+		logCtx.Info("subscription renewed", fid, "account_id", accountID, "sku", r.SKU, "previous_sku", r.PreviousSKU, "balance", r.Balance, "rollover", r.Rollover, "expired", r.Expired)
+	}
+
+	return r, due, nil
+}
+
+// RenewSubscriptions renews every account subscription whose period has ended. Accounts that fail are counted and skipped.
+func RenewSubscriptions(ctx context.Context, logCtx *slog.Logger, now time.Time) (RenewalSummary, error) {
+	fid := slog.String("fid", "vox.bank.RenewSubscriptions")
+
+	collection := docstore.Client.Collection("accounts")
+	if collection == nil {
+		logCtx.Error("accounts collection not found", fid)
+		return RenewalSummary{}, common.ErrNotFound{}
+	}
+
+	summary := RenewalSummary{Renewals: []SubscriptionRenewal{}}
+
+	iter := collection.Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+
+		if err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Error("unable to iterate accounts", fid, "error", err)
+			return summary, err
+		}
+
+		summary.Accounts++
+
+		r, due, err := RenewSubscription(ctx, logCtx, doc.Ref.ID, now)
+		if err != nil {
+			if !errors.Is(err, common.ErrNotFound{}) {
+				summary.Errors++
+			}
+			continue
+		}
+
+		if due {
+			summary.Renewals = append(summary.Renewals, r)
+		}
+	}
+
+	return summary, nil
+}
+
+// GetRenewals returns the renewal history of an account, newest first.
+func GetRenewals(ctx context.Context, logCtx *slog.Logger, accountID string) ([]SubscriptionRenewal, error) {
+	fid := slog.String("fid", "vox.bank.GetRenewals")
+
+	collection := docstore.Client.Collection(renewalsPath(accountID))
+	if collection == nil {
+		logCtx.Error("renewals collection not found", fid)
+		return nil, common.ErrNotFound{}
+	}
+
+	docs, err := collection.Documents(ctx).GetAll()
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to get renewals", fid, "error", err)
+		return nil, err
+	}
+
+	renewals := make([]SubscriptionRenewal, 0, len(docs))
+
+	for _, doc := range docs {
+		r := SubscriptionRenewal{}
+		if err := doc.DataTo(&r); err != nil {
+			logCtx.Warn("unable to read renewal", fid, "id", doc.Ref.ID, "error", err)
+			continue
+		}
+
+		renewals = append(renewals, r)
+	}
+
+	sort.Slice(renewals, func(i, j int) bool {
+		return renewals[i].Timestamp.After(renewals[j].Timestamp)
+	})
+
+	return renewals, nil
+}
+
+func renewalsPath(accountID string) string {
+	return fmt.Sprintf("accounts/%s/bank/balance/renewals", accountID)
+}
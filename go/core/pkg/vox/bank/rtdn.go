@@ -0,0 +1,518 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/api/idtoken"
+
+	"disruptive/config"
+	"disruptive/lib/common"
+	"disruptive/lib/configs"
+	"disruptive/lib/docstore"
+)
+
+// Google Play subscription notification types.
+const (
+	SubscriptionRecovered = 1
+	SubscriptionRenewed   = 2
+	SubscriptionCanceled  = 3
+	SubscriptionPurchased = 4
+	SubscriptionOnHold    = 5
+	SubscriptionRestarted = 7
+	SubscriptionRevoked   = 12
+	SubscriptionExpired   = 13
+)
+
+// Google Play voided purchase product types.
+const (
+	VoidedSubscription = 1
+	VoidedOneTime      = 2
+)
+
+// Android subscription states stored on the subscription transaction.
+const (
+	AndroidSubActive   = "active"
+	AndroidSubCanceled = "canceled"
+	AndroidSubExpired  = "expired"
+	AndroidSubRevoked  = "revoked"
+)
+
+// AndroidNotificationData contains a Google Play real-time developer notification.
+type AndroidNotificationData struct {
+	Version                  string `json:"version"`
+	PackageName              string `json:"packageName"`
+	Time                     string `json:"eventTimeMillis"`
+	SubscriptionNotification *struct {
+		Version          string `json:"version"`
+		SubscriptionType int    `json:"notificationType"`
+		PurchaseToken    string `json:"purchaseToken"`
+		SubcriptionID    string `json:"subscriptionId"`
+	} `json:"subscriptionNotification"`
+	OneTimeProductNotification *struct {
+		Version          string `json:"version"`
+		SubscriptionType int    `json:"notificationType"`
+		PurchaseToken    string `json:"purchaseToken"`
+		SKU              string `json:"sku"`
+	} `json:"oneTimeProductNotification"`
+	VoidedPurchaseNotification *struct {
+		PurchaseToken string `json:"purchaseToken"`
+		OrderID       string `json:"orderId"`
+		ProductType   int    `json:"productType"`
+		RefundType    int    `json:"refundType"`
+	} `json:"voidedPurchaseNotification"`
+	TestNotification *struct {
+		Version string `json:"version"`
+	} `json:"testNotification"`
+}
+
+// googleIssuers are the issuers of Google signed OIDC tokens.
+var googleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+// ValidatePushToken validates the signature, expiry and audience of a Pub/Sub push OIDC token.
+var ValidatePushToken = idtoken.Validate
+
+// VerifyAndroidPush verifies the OIDC token Pub/Sub sends in the Authorization header of a push.
+// The token must be signed by Google for the configured audience and push service account.
+// The local Play client accepts pushes without a token when no audience is configured.
+func VerifyAndroidPush(ctx context.Context, logCtx *slog.Logger, authorization string) error {
+	fid := slog.String("fid", "vox.bank.VerifyAndroidPush")
+
+	audience := config.VARS.GooglePlayPushAudience
+	serviceAccount := config.VARS.GooglePlayPushServiceAccount
+
+	if audience == "" && config.VARS.GooglePlayClient == PlayClientLocal {
+		return nil
+	}
+
+	if audience == "" || serviceAccount == "" {
+		logCtx.Error("android push authentication not configured", fid)
+		return common.ErrUnauthorized
+	}
+
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		logCtx.Warn("missing android push token", fid)
+		return common.ErrUnauthorized
+	}
+
+	payload, err := ValidatePushToken(ctx, token, audience)
+	if err != nil {
+		logCtx.Warn("invalid android push token", fid, "error", err)
+		return common.ErrUnauthorized
+	}
+
+	email, _ := payload.Claims["email"].(string)
+	verified, _ := payload.Claims["email_verified"].(bool)
+
+	if !slices.Contains(googleIssuers, payload.Issuer) || !verified || email != serviceAccount {
+		logCtx.Warn("android push token not from the push service account", fid, "issuer", payload.Issuer, "email", email)
+		return common.ErrUnauthorized
+	}
+
+	return nil
+}
+
+// PostAndroidNotification applies a real-time developer notification. Renewed subscriptions are
+// verified with Google Play and refill the subscription balance, canceled subscriptions stop renewing,
+// and expired, on hold and revoked subscriptions and voided purchases claw back the balance they added
+// once Google Play confirms they have ended.
+// Notifications for purchases this service did not record return ErrNotFound.
+func PostAndroidNotification(ctx context.Context, logCtx *slog.Logger, data AndroidNotificationData) error {
+	fid := slog.String("fid", "vox.bank.PostAndroidNotification")
+
+	if config.VARS.GooglePlayPackageName != "" && data.PackageName != config.VARS.GooglePlayPackageName {
+		logCtx.Error("invalid package name", fid, "package_name", data.PackageName)
+		return common.ErrBadRequest{Msg: "invalid package name"}
+	}
+
+	switch {
+	case data.SubscriptionNotification != nil:
+		n := data.SubscriptionNotification
+
+		switch n.SubscriptionType {
+		case SubscriptionRenewed, SubscriptionRecovered, SubscriptionRestarted:
+			return renewAndroidSubscription(ctx, logCtx, data.PackageName, n.SubcriptionID, n.PurchaseToken)
+		case SubscriptionCanceled:
+			return cancelAndroidSubscription(ctx, logCtx, data.PackageName, n.SubcriptionID, n.PurchaseToken, AndroidSubCanceled)
+		case SubscriptionExpired, SubscriptionOnHold:
+			return cancelAndroidSubscription(ctx, logCtx, data.PackageName, n.SubcriptionID, n.PurchaseToken, AndroidSubExpired)
+		case SubscriptionRevoked:
+			return cancelAndroidSubscription(ctx, logCtx, data.PackageName, n.SubcriptionID, n.PurchaseToken, AndroidSubRevoked)
+		}
+
+	case data.VoidedPurchaseNotification != nil:
+		n := data.VoidedPurchaseNotification
+
+		switch n.ProductType {
+		case VoidedSubscription:
+			return cancelAndroidSubscription(ctx, logCtx, data.PackageName, "", n.PurchaseToken, AndroidSubRevoked)
+		case VoidedOneTime:
+			return voidAndroidPurchase(ctx, logCtx, data.PackageName, n.PurchaseToken)
+		}
+	}
+
+	return nil
+}
+
+func getAndroidSubTransaction(tx *docstore.Transaction, token string) (*docstore.DocumentRef, AndroidSubTransaction, error) {
+	collection := docstore.Client.Collection("stores/android/transactions")
+	if collection == nil {
+		return nil, AndroidSubTransaction{}, common.ErrNotFound{}
+	}
+
+	ref := collection.Doc(token)
+
+	doc, err := tx.Get(ref)
+	if err != nil {
+		return nil, AndroidSubTransaction{}, err
+	}
+
+	sub := AndroidSubTransaction{}
+	if err := doc.DataTo(&sub); err != nil {
+		return nil, AndroidSubTransaction{}, err
+	}
+
+	return ref, sub, nil
+}
+
+func renewAndroidSubscription(ctx context.Context, logCtx *slog.Logger, packageName, subscriptionID, token string) error {
+	fid := slog.String("fid", "vox.bank.renewAndroidSubscription")
+
+	if Play == nil {
+		logCtx.Error("google play client not configured", fid)
+		return fmt.Errorf("%w: google play client not configured", common.ErrIAPUnauthorized)
+	}
+
+	purchase, err := Play.GetSubscriptionPurchase(ctx, packageName, subscriptionID, token)
+	if err != nil {
+		logCtx.Error("unable to verify android subscription", fid, "error", err)
+		return err
+	}
+
+	if expiry := purchase.ExpiryTime(); !expiry.IsZero() && expiry.Before(time.Now()) {
+		logCtx.Warn("android subscription expired", fid, "expiry", expiry)
+		return nil
+	}
+
+	skus, err := configs.GetSKUs(ctx, logCtx)
+	if err != nil {
+		logCtx.Error("unable to get banking configs", fid, "error", err)
+		return err
+	}
+
+	var (
+		accountID string
+		credited  bool
+	)
+
+	err = docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		subRef, sub, err := getAndroidSubTransaction(tx, token)
+		if err != nil {
+			return err
+		}
+
+		accountID = sub.AccountID
+
+		// The subscription Google Play verified is credited, the recorded sku is only a fallback.
+		skuID := subscriptionID
+		if skuID == "" {
+			skuID = sub.SKU
+		}
+
+		sku, ok := skus[skuID]
+		if !ok {
+			return common.ErrNotFound{Msg: "invalid sku " + skuID}
+		}
+
+		balanceRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", sub.AccountID)).Doc("balance")
+
+		// A period is credited once. Redelivered notifications and restarts inside a paid period only reactivate it.
+		credited = purchase.ExpiryTimeMillis == "" || purchase.ExpiryTimeMillis != sub.CreditedExpiry
+
+		doc, err := tx.Get(balanceRef)
+		if err != nil {
+			return err
+		}
+
+		b := subscriptionDocument{}
+		if err := doc.DataTo(&b); err != nil {
+			return err
+		}
+
+		// StartTimeMillis is the start of the first period, the current period is the one ending at the expiry.
+		start := time.Now().UTC()
+		if expiry := purchase.ExpiryTime(); !expiry.IsZero() {
+			start = previousPeriod(expiry, sku.Period).UTC()
+		}
+
+		updates := []docstore.Update{
+			{Path: "subscription_sku", Value: skuID},
+			{Path: "subscription_start_date", Value: start},
+			{Path: "subscription_store", Value: SubscriptionStoreAndroid},
+		}
+
+		if credited {
+			updates = append(updates, docstore.Update{Path: "subscription_balance", Value: sku.Balance})
+		}
+
+		if err := tx.Update(balanceRef, updates); err != nil {
+			return err
+		}
+
+		subUpdates := []docstore.Update{
+			{Path: "sku", Value: skuID},
+			{Path: "state", Value: AndroidSubActive},
+		}
+		if credited {
+			subUpdates = append(subUpdates, docstore.Update{Path: "credited_expiry", Value: purchase.ExpiryTimeMillis})
+		}
+
+		if err := tx.Update(subRef, subUpdates); err != nil {
+			return err
+		}
+
+		if !credited {
+			return nil
+		}
+
+		entry := LedgerEntry{Amount: sku.Balance - b.SubscriptionBalance, Bucket: BucketSubscription, Reason: ReasonSubscription, SourceID: token}
+		return AddLedgerEntriesTx(tx, sub.AccountID, entry)
+	})
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to renew android subscription", fid, "error", err)
+		return err
+	}
+
+	logCtx.Info("android subscription renewed", fid, "account_id", accountID, "credited", credited)
+
+	return nil
+}
+
+// cancelAndroidSubscription moves a subscription to state. A canceled subscription stops renewing and keeps its
+// balance until Google Play expires it. Expired and revoked subscriptions lose their remaining balance once Google
+// Play confirms they have ended. subscriptionID defaults to the recorded sku.
+func cancelAndroidSubscription(ctx context.Context, logCtx *slog.Logger, packageName, subscriptionID, token, state string) error {
+	fid := slog.String("fid", "vox.bank.cancelAndroidSubscription")
+
+	ended := state != AndroidSubCanceled
+
+	if ended {
+		if err := confirmAndroidSubscriptionEnded(ctx, logCtx, packageName, subscriptionID, token); err != nil {
+			return err
+		}
+	}
+
+	var accountID string
+
+	err := docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		subRef, sub, err := getAndroidSubTransaction(tx, token)
+		if err != nil {
+			return err
+		}
+
+		accountID = sub.AccountID
+
+		if sub.State == AndroidSubRevoked || (ended && sub.State == state) {
+			return nil
+		}
+
+		balanceRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", sub.AccountID)).Doc("balance")
+
+		doc, err := tx.Get(balanceRef)
+		if err != nil {
+			return err
+		}
+
+		b := subscriptionDocument{}
+		if err := doc.DataTo(&b); err != nil {
+			return err
+		}
+
+		updates := []docstore.Update{{Path: "subscription_sku", Value: docstore.Delete}}
+
+		if ended {
+			updates = append(updates,
+				docstore.Update{Path: "subscription_balance", Value: 0},
+				docstore.Update{Path: "subscription_store", Value: docstore.Delete},
+			)
+		}
+
+		if err := tx.Update(balanceRef, updates); err != nil {
+			return err
+		}
+
+		if err := tx.Update(subRef, []docstore.Update{{Path: "state", Value: state}}); err != nil {
+			return err
+		}
+
+		if !ended {
+			return nil
+		}
+
+		reason := ReasonSubscriptionExpired
+		if state == AndroidSubRevoked {
+			reason = ReasonAndroidRefund
+		}
+
+		entry := LedgerEntry{Amount: -b.SubscriptionBalance, Bucket: BucketSubscription, Reason: reason, SourceID: token}
+		return AddLedgerEntriesTx(tx, sub.AccountID, entry)
+	})
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		if errors.Is(err, common.ErrNotFound{}) {
+			logCtx.Warn("android subscription not found", fid)
+			return err
+		}
+
+		logCtx.Error("unable to cancel android subscription", fid, "error", err)
+		return err
+	}
+
+	logCtx.Info("android subscription canceled", fid, "account_id", accountID, "state", state)
+
+	return nil
+}
+
+// confirmAndroidSubscriptionEnded checks with Google Play that an expired, revoked or voided subscription has ended.
+func confirmAndroidSubscriptionEnded(ctx context.Context, logCtx *slog.Logger, packageName, subscriptionID, token string) error {
+	fid := slog.String("fid", "vox.bank.confirmAndroidSubscriptionEnded")
+
+	if Play == nil {
+		logCtx.Error("google play client not configured", fid)
+		return fmt.Errorf("%w: google play client not configured", common.ErrIAPUnauthorized)
+	}
+
+	if subscriptionID == "" {
+		collection := docstore.Client.Collection("stores/android/transactions")
+		if collection == nil {
+			logCtx.Error("android transactions collection not found", fid)
+			return common.ErrNotFound{}
+		}
+
+		doc, err := collection.Doc(token).Get(ctx)
+		if err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Warn("android subscription not found", fid, "error", err)
+			return err
+		}
+
+		sub := AndroidSubTransaction{}
+		if err := doc.DataTo(&sub); err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Error("unable to read android subscription", fid, "error", err)
+			return err
+		}
+
+		subscriptionID = sub.SKU
+	}
+
+	purchase, err := Play.GetSubscriptionPurchase(ctx, packageName, subscriptionID, token)
+	if err != nil {
+		logCtx.Error("unable to verify android subscription", fid, "error", err)
+		return err
+	}
+
+	// Revoking a subscription ends it immediately, an expired or on hold subscription has an expiry in the past.
+	if expiry := purchase.ExpiryTime(); expiry.IsZero() || expiry.After(time.Now()) {
+		logCtx.Error("android subscription end not confirmed", fid, "expiry", expiry)
+		return fmt.Errorf("%w: subscription not ended", common.ErrIAPUnauthorized)
+	}
+
+	return nil
+}
+
+// voidAndroidPurchase claws back the balance credited for a refunded one-time purchase once Google Play
+// confirms the purchase is canceled. A purchase is only voided once.
+func voidAndroidPurchase(ctx context.Context, logCtx *slog.Logger, packageName, token string) error {
+	fid := slog.String("fid", "vox.bank.voidAndroidPurchase")
+
+	if Play == nil {
+		logCtx.Error("google play client not configured", fid)
+		return fmt.Errorf("%w: google play client not configured", common.ErrIAPUnauthorized)
+	}
+
+	index := docstore.Client.Collection("stores/android/purchases")
+	if index == nil {
+		logCtx.Error("android purchases collection not found", fid)
+		return common.ErrNotFound{}
+	}
+
+	doc, err := index.Doc(token).Get(ctx)
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Warn("android purchase not found", fid, "error", err)
+		return err
+	}
+
+	var idx androidPurchaseIndex
+	if err := doc.DataTo(&idx); err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to read android purchase", fid, "error", err)
+		return err
+	}
+
+	purchase, err := Play.GetProductPurchase(ctx, packageName, idx.ProductID, token)
+	if err != nil {
+		logCtx.Error("unable to verify android purchase", fid, "error", err)
+		return err
+	}
+
+	if purchase.PurchaseState != PlayCanceled {
+		logCtx.Error("android purchase void not confirmed", fid, "purchase_state", purchase.PurchaseState)
+		return fmt.Errorf("%w: purchase not canceled", common.ErrIAPUnauthorized)
+	}
+
+	err = docstore.Client.RunTransaction(ctx, func(ctx context.Context, tx *docstore.Transaction) error {
+		doc, err := tx.Get(index.Doc(token))
+		if err != nil {
+			return err
+		}
+
+		if err := doc.DataTo(&idx); err != nil {
+			return err
+		}
+
+		if idx.Revoked {
+			return nil
+		}
+
+		txnRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/android_iap_transactions", idx.AccountID)).Doc(token)
+		balanceRef := docstore.Client.Collection(fmt.Sprintf("accounts/%s/bank", idx.AccountID)).Doc("balance")
+
+		if err := tx.Update(index.Doc(token), []docstore.Update{{Path: "revoked", Value: true}}); err != nil {
+			return err
+		}
+
+		if err := tx.Update(txnRef, []docstore.Update{{Path: "voided", Value: true}}); err != nil {
+			return err
+		}
+
+		if err := tx.Update(balanceRef, []docstore.Update{{Path: "balance", Value: docstore.Increment(-idx.Amount)}}); err != nil {
+			return err
+		}
+
+		entry := LedgerEntry{Amount: -int(idx.Amount), Bucket: BucketPurchased, Reason: ReasonAndroidRefund, SourceID: token}
+		return AddLedgerEntriesTx(tx, idx.AccountID, entry)
+	})
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		if errors.Is(err, common.ErrNotFound{}) {
+			logCtx.Warn("android purchase not found", fid)
+			return err
+		}
+
+		logCtx.Error("unable to void android purchase", fid, "error", err)
+		return err
+	}
+
+	logCtx.Info("android purchase voided", fid, "account_id", idx.AccountID, "amount", idx.Amount)
+
+	return nil
+}
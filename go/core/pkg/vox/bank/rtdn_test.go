@@ -0,0 +1,418 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/api/idtoken"
+
+	"disruptive/config"
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
+)
+
+func millis(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// subscribeAndroid records an active android subscription of the test account.
+func subscribeAndroid(t *testing.T, token string) {
+	t.Helper()
+
+	sub := AndroidSubTransaction{AccountID: testAccountID, PurchaseToken: token, SKU: "monthly", State: AndroidSubActive, Timestamp: time.Now()}
+	if err := docstore.Client.Collection("stores/android/transactions").Doc(token).Set(context.Background(), sub); err != nil {
+		t.Fatalf("set subscription: %v", err)
+	}
+}
+
+func subscriptionNotification(notificationType int, token string) AndroidNotificationData {
+	data := AndroidNotificationData{PackageName: testPackageName}
+	data.SubscriptionNotification = &struct {
+		Version          string `json:"version"`
+		SubscriptionType int    `json:"notificationType"`
+		PurchaseToken    string `json:"purchaseToken"`
+		SubcriptionID    string `json:"subscriptionId"`
+	}{SubscriptionType: notificationType, PurchaseToken: token, SubcriptionID: "monthly"}
+
+	return data
+}
+
+func voidedNotification(productType int, token string) AndroidNotificationData {
+	data := AndroidNotificationData{PackageName: testPackageName}
+	data.VoidedPurchaseNotification = &struct {
+		PurchaseToken string `json:"purchaseToken"`
+		OrderID       string `json:"orderId"`
+		ProductType   int    `json:"productType"`
+		RefundType    int    `json:"refundType"`
+	}{PurchaseToken: token, ProductType: productType}
+
+	return data
+}
+
+func TestAndroidNotificationRenewed(t *testing.T) {
+	play := setupBank(t)
+	subscribeAndroid(t, "sub-1")
+
+	// The purchase started two periods ago, the current period ends at the expiry.
+	expiry := time.Now().Add(20 * 24 * time.Hour).Truncate(time.Millisecond)
+	play.SetSubscriptionPurchase("sub-1", PlaySubscriptionPurchase{AutoRenewing: true, StartTimeMillis: millis(expiry.AddDate(0, -2, 0)), ExpiryTimeMillis: millis(expiry)})
+
+	if err := PostAndroidNotification(context.Background(), testLogCtx, subscriptionNotification(SubscriptionRenewed, "sub-1")); err != nil {
+		t.Fatalf("PostAndroidNotification: %v", err)
+	}
+
+	b := getBalance(t)
+	if b.SubscriptionBalance != 500 || b.SubscriptionSKU != "monthly" || b.SubscriptionStore != SubscriptionStoreAndroid {
+		t.Errorf("balance = %+v, want an android monthly subscription balance of 500", b)
+	}
+
+	if want := expiry.AddDate(0, -1, 0); !b.SubscriptionStartDate.Equal(want) {
+		t.Errorf("subscription start date = %v, want the current period start %v", b.SubscriptionStartDate, want)
+	}
+
+	// The renewal engine leaves the subscription to Google Play, even once the period has ended.
+	if _, due, err := RenewSubscription(context.Background(), testLogCtx, testAccountID, expiry.Add(time.Hour)); err != nil || due {
+		t.Errorf("RenewSubscription = %v, %v, want the android subscription skipped", due, err)
+	}
+
+	if entries := getLedger(t); len(entries) != 1 {
+		t.Errorf("ledger = %+v, want only the android renewal", entries)
+	}
+}
+
+func TestAndroidNotificationExpired(t *testing.T) {
+	for _, notificationType := range []int{SubscriptionExpired, SubscriptionOnHold} {
+		t.Run(strconv.Itoa(notificationType), func(t *testing.T) {
+			play := setupBank(t)
+			subscribeAndroid(t, "sub-1")
+
+			ctx := context.Background()
+			balanceRef := docstore.Client.Collection("accounts/" + testAccountID + "/bank").Doc("balance")
+			if err := balanceRef.Set(ctx, map[string]any{"subscription_balance": 300, "subscription_sku": "monthly", "subscription_store": SubscriptionStoreAndroid}, docstore.MergeAll); err != nil {
+				t.Fatalf("set balance: %v", err)
+			}
+
+			play.SetSubscriptionPurchase("sub-1", PlaySubscriptionPurchase{AutoRenewing: true, ExpiryTimeMillis: millis(time.Now().AddDate(0, 0, 3))})
+
+			if err := PostAndroidNotification(ctx, testLogCtx, subscriptionNotification(notificationType, "sub-1")); !errors.Is(err, common.ErrIAPUnauthorized) {
+				t.Fatalf("unconfirmed expiry error = %v, want ErrIAPUnauthorized", err)
+			}
+
+			play.SetSubscriptionPurchase("sub-1", PlaySubscriptionPurchase{ExpiryTimeMillis: millis(time.Now().Add(-time.Minute))})
+
+			for i := 0; i < 2; i++ {
+				if err := PostAndroidNotification(ctx, testLogCtx, subscriptionNotification(notificationType, "sub-1")); err != nil {
+					t.Fatalf("PostAndroidNotification: %v", err)
+				}
+			}
+
+			if b := getBalance(t); b.SubscriptionBalance != 0 || b.SubscriptionSKU != "" || b.SubscriptionStore != "" {
+				t.Errorf("balance = %+v, want the subscription ended", b)
+			}
+
+			entries := getLedger(t)
+			if len(entries) != 1 || entries[0].Amount != -300 || entries[0].Reason != ReasonSubscriptionExpired {
+				t.Errorf("ledger = %+v, want one subscription_expired debit of 300", entries)
+			}
+		})
+	}
+}
+
+func TestPostAndroidSubTransaction(t *testing.T) {
+	play := setupBank(t)
+	ctx := context.Background()
+
+	txn := AndroidSubTransaction{AccountID: testAccountID, PurchaseToken: "sub-1", SKU: "monthly", SubscriptionID: "GPA.1"}
+
+	if _, err := PostAndroidSubTransaction(ctx, testLogCtx, txn); !errors.Is(err, common.ErrIAPUnauthorized) {
+		t.Fatalf("unverified token error = %v, want ErrIAPUnauthorized", err)
+	}
+
+	play.SetSubscriptionPurchase("sub-1", PlaySubscriptionPurchase{AutoRenewing: true, ExpiryTimeMillis: millis(time.Now().AddDate(0, 1, 0))})
+
+	if _, err := PostAndroidSubTransaction(ctx, testLogCtx, txn); err != nil {
+		t.Fatalf("PostAndroidSubTransaction: %v", err)
+	}
+
+	if b := getBalance(t); b.SubscriptionStore != SubscriptionStoreAndroid {
+		t.Errorf("subscription store = %q, want %q", b.SubscriptionStore, SubscriptionStoreAndroid)
+	}
+
+	// The same account may post its token again, another account may not take it over.
+	if _, err := PostAndroidSubTransaction(ctx, testLogCtx, txn); err != nil {
+		t.Errorf("repost error = %v", err)
+	}
+
+	other := txn
+	other.AccountID = "account-2"
+	if _, err := PostAndroidSubTransaction(ctx, testLogCtx, other); !errors.Is(err, common.ErrIAPUnauthorized) {
+		t.Errorf("other account error = %v, want ErrIAPUnauthorized", err)
+	}
+
+	doc, err := docstore.Client.Collection("stores/android/transactions").Doc("sub-1").Get(ctx)
+	if err != nil {
+		t.Fatalf("get subscription: %v", err)
+	}
+
+	got := AndroidSubTransaction{}
+	if err := doc.DataTo(&got); err != nil {
+		t.Fatalf("read subscription: %v", err)
+	}
+
+	if got.AccountID != testAccountID {
+		t.Errorf("token account = %q, want %q", got.AccountID, testAccountID)
+	}
+}
+
+func TestAndroidNotificationRenewedCreditsVerifiedSKU(t *testing.T) {
+	play := setupBank(t)
+	ctx := context.Background()
+
+	// The recorded sku came from the client, the notification names the subscription Google Play verified.
+	sub := AndroidSubTransaction{AccountID: testAccountID, PurchaseToken: "sub-1", SKU: "vexels_100", State: AndroidSubActive}
+	if err := docstore.Client.Collection("stores/android/transactions").Doc("sub-1").Set(ctx, sub); err != nil {
+		t.Fatalf("set subscription: %v", err)
+	}
+
+	play.SetSubscriptionPurchase("sub-1", PlaySubscriptionPurchase{AutoRenewing: true, ExpiryTimeMillis: millis(time.Now().AddDate(0, 1, 0))})
+
+	if err := PostAndroidNotification(ctx, testLogCtx, subscriptionNotification(SubscriptionRenewed, "sub-1")); err != nil {
+		t.Fatalf("PostAndroidNotification: %v", err)
+	}
+
+	if b := getBalance(t); b.SubscriptionBalance != 500 || b.SubscriptionSKU != "monthly" {
+		t.Errorf("balance = %+v, want the verified monthly subscription", b)
+	}
+}
+
+func TestAndroidNotificationRenewedOnce(t *testing.T) {
+	play := setupBank(t)
+	subscribeAndroid(t, "sub-1")
+
+	ctx := context.Background()
+	expiry := time.Now().AddDate(0, 0, 20)
+	play.SetSubscriptionPurchase("sub-1", PlaySubscriptionPurchase{AutoRenewing: true, ExpiryTimeMillis: millis(expiry)})
+
+	// Pub/Sub redelivers the renewal, and the user cancels and restarts inside the paid period.
+	notifications := []AndroidNotificationData{
+		subscriptionNotification(SubscriptionRenewed, "sub-1"),
+		subscriptionNotification(SubscriptionRenewed, "sub-1"),
+		subscriptionNotification(SubscriptionCanceled, "sub-1"),
+		subscriptionNotification(SubscriptionRestarted, "sub-1"),
+	}
+
+	for i, data := range notifications {
+		if i == 2 {
+			balanceRef := docstore.Client.Collection("accounts/" + testAccountID + "/bank").Doc("balance")
+			if err := balanceRef.Set(ctx, map[string]any{"subscription_balance": 120}, docstore.MergeAll); err != nil {
+				t.Fatalf("set balance: %v", err)
+			}
+		}
+
+		if err := PostAndroidNotification(ctx, testLogCtx, data); err != nil {
+			t.Fatalf("PostAndroidNotification %d: %v", i, err)
+		}
+	}
+
+	if b := getBalance(t); b.SubscriptionBalance != 120 || b.SubscriptionSKU != "monthly" {
+		t.Errorf("balance = %+v, want the spent balance kept and the subscription restarted", b)
+	}
+
+	if entries := getLedger(t); len(entries) != 1 {
+		t.Errorf("ledger = %+v, want one renewal credit", entries)
+	}
+
+	// The next period is credited.
+	play.SetSubscriptionPurchase("sub-1", PlaySubscriptionPurchase{AutoRenewing: true, ExpiryTimeMillis: millis(expiry.AddDate(0, 1, 0))})
+
+	if err := PostAndroidNotification(ctx, testLogCtx, subscriptionNotification(SubscriptionRenewed, "sub-1")); err != nil {
+		t.Fatalf("PostAndroidNotification: %v", err)
+	}
+
+	if b := getBalance(t); b.SubscriptionBalance != 500 {
+		t.Errorf("subscription balance = %d, want 500 after the next period", b.SubscriptionBalance)
+	}
+}
+
+func TestAndroidNotificationRenewedExpired(t *testing.T) {
+	play := setupBank(t)
+	subscribeAndroid(t, "sub-1")
+
+	play.SetSubscriptionPurchase("sub-1", PlaySubscriptionPurchase{ExpiryTimeMillis: millis(time.Now().Add(-time.Hour))})
+
+	if err := PostAndroidNotification(context.Background(), testLogCtx, subscriptionNotification(SubscriptionRenewed, "sub-1")); err != nil {
+		t.Fatalf("PostAndroidNotification: %v", err)
+	}
+
+	if b := getBalance(t); b.SubscriptionBalance != 0 {
+		t.Errorf("subscription balance = %d, want 0 for an expired subscription", b.SubscriptionBalance)
+	}
+}
+
+func TestAndroidNotificationCanceled(t *testing.T) {
+	setupBank(t)
+	subscribeAndroid(t, "sub-1")
+
+	ctx := context.Background()
+	balanceRef := docstore.Client.Collection("accounts/" + testAccountID + "/bank").Doc("balance")
+	if err := balanceRef.Set(ctx, map[string]any{"subscription_balance": 300, "subscription_sku": "monthly"}, docstore.MergeAll); err != nil {
+		t.Fatalf("set balance: %v", err)
+	}
+
+	if err := PostAndroidNotification(ctx, testLogCtx, subscriptionNotification(SubscriptionCanceled, "sub-1")); err != nil {
+		t.Fatalf("PostAndroidNotification: %v", err)
+	}
+
+	// A canceled subscription keeps its balance until the period ends.
+	if b := getBalance(t); b.SubscriptionBalance != 300 || b.SubscriptionSKU != "" {
+		t.Errorf("balance = %+v, want the balance kept without a subscription sku", b)
+	}
+}
+
+func TestAndroidNotificationRevoked(t *testing.T) {
+	tests := []struct {
+		name string
+		data AndroidNotificationData
+	}{
+		{name: "revoked", data: subscriptionNotification(SubscriptionRevoked, "sub-1")},
+		{name: "voided", data: voidedNotification(VoidedSubscription, "sub-1")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			play := setupBank(t)
+			subscribeAndroid(t, "sub-1")
+
+			ctx := context.Background()
+			balanceRef := docstore.Client.Collection("accounts/" + testAccountID + "/bank").Doc("balance")
+			if err := balanceRef.Set(ctx, map[string]any{"subscription_balance": 300, "subscription_sku": "monthly"}, docstore.MergeAll); err != nil {
+				t.Fatalf("set balance: %v", err)
+			}
+
+			// Google Play still reports an active subscription, so the notification is not trusted.
+			play.SetSubscriptionPurchase("sub-1", PlaySubscriptionPurchase{AutoRenewing: true, ExpiryTimeMillis: millis(time.Now().AddDate(0, 1, 0))})
+
+			if err := PostAndroidNotification(ctx, testLogCtx, tt.data); !errors.Is(err, common.ErrIAPUnauthorized) {
+				t.Fatalf("unconfirmed revocation error = %v, want ErrIAPUnauthorized", err)
+			}
+
+			if b := getBalance(t); b.SubscriptionBalance != 300 {
+				t.Fatalf("subscription balance = %d, want 300 before the revocation is confirmed", b.SubscriptionBalance)
+			}
+
+			play.SetSubscriptionPurchase("sub-1", PlaySubscriptionPurchase{ExpiryTimeMillis: millis(time.Now().Add(-time.Minute))})
+
+			for i := 0; i < 2; i++ {
+				if err := PostAndroidNotification(ctx, testLogCtx, tt.data); err != nil {
+					t.Fatalf("PostAndroidNotification: %v", err)
+				}
+			}
+
+			if b := getBalance(t); b.SubscriptionBalance != 0 || b.SubscriptionSKU != "" {
+				t.Errorf("balance = %+v, want the subscription balance clawed back", b)
+			}
+
+			entries := getLedger(t)
+			if len(entries) != 1 || entries[0].Amount != -300 || entries[0].Reason != ReasonAndroidRefund {
+				t.Errorf("ledger = %+v, want one android_refund debit of 300", entries)
+			}
+		})
+	}
+}
+
+func TestAndroidNotificationVoidedPurchase(t *testing.T) {
+	play := setupBank(t)
+	ctx := context.Background()
+
+	play.SetProductPurchase("token-1", PlayProductPurchase{ProductID: "vexels_100", PurchaseState: PlayPurchased, Quantity: 1})
+
+	txn := GoogleAndroidIAPTransaction{PackageName: testPackageName, ProductID: "vexels_100", PurchaseToken: "token-1"}
+	if _, err := PostAndroidIAPTransaction(ctx, testLogCtx, testAccountID, txn); err != nil {
+		t.Fatalf("PostAndroidIAPTransaction: %v", err)
+	}
+
+	if err := PostAndroidNotification(ctx, testLogCtx, voidedNotification(VoidedOneTime, "token-1")); !errors.Is(err, common.ErrIAPUnauthorized) {
+		t.Fatalf("unconfirmed void error = %v, want ErrIAPUnauthorized", err)
+	}
+
+	play.SetProductPurchase("token-1", PlayProductPurchase{ProductID: "vexels_100", PurchaseState: PlayCanceled, Quantity: 1})
+
+	for i := 0; i < 2; i++ {
+		if err := PostAndroidNotification(ctx, testLogCtx, voidedNotification(VoidedOneTime, "token-1")); err != nil {
+			t.Fatalf("PostAndroidNotification: %v", err)
+		}
+	}
+
+	if b := getBalance(t); b.Balance != 0 {
+		t.Errorf("balance = %d, want 0 after the void", b.Balance)
+	}
+
+	if err := PostAndroidNotification(ctx, testLogCtx, voidedNotification(VoidedOneTime, "unknown")); !errors.Is(err, common.ErrNotFound{}) {
+		t.Errorf("unknown purchase error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestVerifyAndroidPush(t *testing.T) {
+	const (
+		audience       = "https://vox.example.com/api/vox/bank/android/notifications"
+		serviceAccount = "rtdn-push@example.iam.gserviceaccount.com"
+	)
+
+	vars, validate := config.VARS, ValidatePushToken
+	t.Cleanup(func() { config.VARS, ValidatePushToken = vars, validate })
+
+	config.VARS.GooglePlayClient = PlayClientAPI
+	config.VARS.GooglePlayPushAudience = audience
+	config.VARS.GooglePlayPushServiceAccount = serviceAccount
+
+	ValidatePushToken = func(_ context.Context, token, aud string) (*idtoken.Payload, error) {
+		if aud != audience {
+			return nil, errors.New("audience mismatch")
+		}
+
+		switch token {
+		case "valid":
+			return &idtoken.Payload{Issuer: "https://accounts.google.com", Audience: aud, Claims: map[string]any{"email": serviceAccount, "email_verified": true}}, nil
+		case "other-account":
+			return &idtoken.Payload{Issuer: "https://accounts.google.com", Audience: aud, Claims: map[string]any{"email": "attacker@example.com", "email_verified": true}}, nil
+		case "unverified":
+			return &idtoken.Payload{Issuer: "https://accounts.google.com", Audience: aud, Claims: map[string]any{"email": serviceAccount}}, nil
+		}
+
+		return nil, errors.New("invalid token")
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantErr       bool
+	}{
+		{name: "valid", authorization: "Bearer valid"},
+		{name: "missing", authorization: "", wantErr: true},
+		{name: "not bearer", authorization: "Basic valid", wantErr: true},
+		{name: "invalid token", authorization: "Bearer forged", wantErr: true},
+		{name: "other service account", authorization: "Bearer other-account", wantErr: true},
+		{name: "unverified email", authorization: "Bearer unverified", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyAndroidPush(context.Background(), testLogCtx, tt.authorization)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("VerifyAndroidPush error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if err != nil && !errors.Is(err, common.ErrUnauthorized) {
+				t.Errorf("VerifyAndroidPush error = %v, want ErrUnauthorized", err)
+			}
+		})
+	}
+
+	config.VARS.GooglePlayPushAudience = ""
+	if err := VerifyAndroidPush(context.Background(), testLogCtx, "Bearer valid"); !errors.Is(err, common.ErrUnauthorized) {
+		t.Errorf("unconfigured push authentication error = %v, want ErrUnauthorized", err)
+	}
+}
@@ -12,13 +12,11 @@ import (
 	"strings"
 	"time"
 
-	fs "cloud.google.com/go/firestore"
-
 	"github.com/pkoukk/tiktoken-go"
 	"google.golang.org/api/iterator"
 
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 	"disruptive/lib/openai"
 	"disruptive/pkg/vox/accounts"
 	"disruptive/pkg/vox/profiles"
@@ -89,7 +87,7 @@ func GetArchiveByID(ctx context.Context, logCtx *slog.Logger, archiveID, profile
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profileID, characterDoc.Character)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("memory collection not found", fid)
 		return SessionDocument{}, common.ErrNotFound{Msg: "collection not found"}
@@ -135,7 +133,7 @@ func GetArchiveIndex(ctx context.Context, logCtx *slog.Logger, profileID, charac
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profileID, characterDoc.Character)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Warn("memory collection not found", fid)
 		return ArchiveIndex{}, common.ErrNotFound{Msg: "collection not found"}
@@ -207,7 +205,7 @@ func GetArchiveEntriesByDateRange(ctx context.Context, logCtx *slog.Logger, prof
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/archives", account.ID, profileID, characterDoc.Character)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Warn("archives collection not found", fid)
 		return ArchiveEntries{}, common.ErrNotFound{}
@@ -312,7 +310,7 @@ func GetArchiveSummaryByDateRange(ctx context.Context, logCtx *slog.Logger, prof
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/archives", account.ID, profileID, characterDoc.Character)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("archives collection not found", fid)
 		return nil, common.ErrNotFound{}
@@ -461,7 +459,7 @@ func GetArchiveSummaryByDateRange(ctx context.Context, logCtx *slog.Logger, prof
 		summaryID: entries,
 	}
 
-	if _, err := collection.Doc("date_range").Set(ctx, docUpdate, fs.MergeAll); err != nil {
+	if err := collection.Doc("date_range").Set(ctx, docUpdate, docstore.MergeAll); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to update create summaries by date", fid, "error", err)
 		return nil, err
@@ -492,13 +490,13 @@ func DeleteArchiveSummaryDateRange(ctx context.Context, logCtx *slog.Logger, pro
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/archives", account.ID, profileID, characterDoc.Character)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Warn("archives collection not found", fid)
 		return common.ErrNotFound{}
 	}
 
-	if _, err := collection.Doc("date_range").Delete(ctx); err != nil {
+	if err := collection.Doc("date_range").Delete(ctx); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to delete date range summaries", fid, "error", err)
 		return err
@@ -529,40 +527,30 @@ func DeleteSessionMemory(ctx context.Context, logCtx *slog.Logger, profileID, ch
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profileID, characterDoc.Character)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Warn("memory collection not found", fid)
 		return common.ErrNotFound{}
 	}
 
-	bw := firestore.Client.BulkWriter(ctx)
+	iter := collection.Documents(ctx)
+	defer iter.Stop()
 
 	for {
-		iter := collection.Documents(ctx)
-		defer iter.Stop()
-
-		numDeleted := 0
-
-		for {
-			doc, err := iter.Next()
-			if err == iterator.Done {
-				break
-			}
-			if err != nil {
-				logCtx.Error("unable to delete session memory", fid, "error", err)
-				bw.End()
-				return err
-			}
-			bw.Delete(doc.Ref)
-			numDeleted++
-		}
-
-		if numDeleted == 0 {
-			bw.End()
+		doc, err := iter.Next()
+		if err == iterator.Done {
 			break
 		}
+		if err != nil {
+			logCtx.Error("unable to delete session memory", fid, "error", err)
+			return err
+		}
 
-		bw.Flush()
+		if err := doc.Ref.Delete(ctx); err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Error("unable to delete session memory document", fid, "error", err)
+			return err
+		}
 	}
 
 	return nil
@@ -6,7 +6,7 @@ import (
 
 	"disruptive/config"
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 )
 
 // CharacterInfo contains information about a character.
@@ -29,7 +29,7 @@ func GetCharacter(ctx context.Context, logCtx *slog.Logger, characterVersion, la
 		return c, nil
 	}
 
-	collection := firestore.Client.Collection("characters")
+	collection := docstore.Client.Collection("characters")
 	if collection == nil {
 		logCtx.Error("characters collection not found", fid)
 		return Character{}, common.ErrNotFound{}
@@ -64,13 +64,13 @@ func SetCharacter(ctx context.Context, logCtx *slog.Logger, characterVersion, la
 		language = "en-US"
 	}
 
-	collection := firestore.Client.Collection("characters")
+	collection := docstore.Client.Collection("characters")
 	if collection == nil {
 		logCtx.Error("characters collection not found", fid)
 		return common.ErrNotFound{}
 	}
 
-	if _, err := collection.Doc(characterVersion+"_"+language).Set(ctx, character); err != nil {
+	if err := collection.Doc(characterVersion+"_"+language).Set(ctx, character); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to set character document", fid, "error", err)
 		return err
@@ -83,14 +83,14 @@ func SetCharacter(ctx context.Context, logCtx *slog.Logger, characterVersion, la
 func SetCharacters(ctx context.Context, logCtx *slog.Logger, characters map[string]Character) error {
 	fid := slog.String("fid", "vox.characters.SetCharacters")
 
-	collection := firestore.Client.Collection("characters")
+	collection := docstore.Client.Collection("characters")
 	if collection == nil {
 		logCtx.Error("characters collection not found", fid)
 		return common.ErrNotFound{}
 	}
 
 	for name, character := range characters {
-		if _, err := collection.Doc(name).Set(ctx, character); err != nil {
+		if err := collection.Doc(name).Set(ctx, character); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to set character document", fid, "error", err)
 			return err
@@ -41,12 +41,6 @@ const (
 	// MaxPromptTokens35Turbo is the maximum tokens we accept for gpt-3.5-turbo
 	MaxPromptTokens35Turbo = 12288
 
-	// MaxPromptTokensGPT4Turbo is the maximum tokens we accept for gpt-4-turbo 128k model
-	MaxPromptTokensGPT4Turbo = 102400
-
-	// GPT4Turbo is the current gpt-4-turbo model
-	GPT4Turbo = "gpt-4-turbo-preview"
-
 	// TokenEncodingModel is the tiktoken encoding model
 	TokenEncodingModel = "cl100k_base"
 )
@@ -1,27 +1,22 @@
 package play
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"html/template"
 	"io"
 	"log/slog"
 	"path/filepath"
 	"strings"
 	"time"
 
-	fs "cloud.google.com/go/firestore"
-	"cloud.google.com/go/storage"
 	"github.com/google/uuid"
 
-	"disruptive/config"
 	"disruptive/lib/common"
 	"disruptive/lib/deepgram"
+	"disruptive/lib/docstore"
 	"disruptive/lib/firebase"
-	"disruptive/lib/firestore"
-	"disruptive/lib/gcp"
+	"disruptive/lib/objectstore"
 	"disruptive/pkg/vox/accounts"
 	"disruptive/pkg/vox/characters"
 	"disruptive/pkg/vox/profiles"
@@ -54,26 +49,7 @@ func PostUserAudio(ctx context.Context, logCtx *slog.Logger, profile *profiles.D
 			return characters.UserAudio{}, err
 		}
 
-		switch tier {
-		case "tier-conversation-1":
-			if balance.Balance <= 20500 && balance.Balance > 20400 {
-				emailLowBalance(ctx, logCtx, 20500)
-			} else if balance.Balance <= 10500 && balance.Balance > 10400 {
-				emailLowBalance(ctx, logCtx, 10500)
-			}
-		case "tier-fun-1":
-			if balance.Balance <= 20500 && balance.Balance > 20350 {
-				emailLowBalance(ctx, logCtx, 20500)
-			} else if balance.Balance <= 10500 && balance.Balance > 10350 {
-				emailLowBalance(ctx, logCtx, 10500)
-			}
-		case "tier-story-1":
-			if balance.Balance <= 20500 && balance.Balance > 20300 {
-				emailLowBalance(ctx, logCtx, 20500)
-			} else if balance.Balance <= 10500 && balance.Balance > 10300 {
-				emailLowBalance(ctx, logCtx, 10500)
-			}
-		}
+		accounts.AlertLowBalance(ctx, logCtx, tier, balance)
 
 		if balance.TotalBalance < 1 {
 			logCtx.Error("insufficient balance", fid, "error", err)
@@ -94,12 +70,24 @@ func PostUserAudio(ctx context.Context, logCtx *slog.Logger, profile *profiles.D
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profile.ID, characterName)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("memory collection not found", fid)
 		return characters.UserAudio{}, common.ErrNotFound{}
 	}
 
+	in, err := ingestUserAudio(logCtx, r, fileExt)
+	if err != nil {
+		return characters.UserAudio{}, err
+	}
+
+	fileExt = in.ext
+
+	// The interaction is recorded once the audio is transcribed, with the length of the whole audio.
+	if err := checkUsage(ctx, logCtx, profile); err != nil {
+		return characters.UserAudio{}, err
+	}
+
 	now := time.Now()
 	audioID := uuid.New().String()
 
@@ -112,7 +100,12 @@ func PostUserAudio(ctx context.Context, logCtx *slog.Logger, profile *profiles.D
 
 	// STT
 
-	sttResponse, detectedLanguage, err := STT(ctx, logCtx, fileExt, profile.Characters[characterName].Language, version, gcsPath, r)
+	sttResponse, detectedLanguage, err := STT(ctx, logCtx, fileExt, profile.Characters[characterName].Language, version, gcsPath, in.r)
+	if inErr := in.err(); inErr != nil {
+		logCtx.Warn("unable to ingest user audio", fid, "error", inErr)
+		return characters.UserAudio{}, inErr
+	}
+
 	if err != nil {
 		logCtx.Error("unable to get speech-to-text response", fid, "error", err)
 		return characters.UserAudio{}, err
@@ -124,9 +117,15 @@ func PostUserAudio(ctx context.Context, logCtx *slog.Logger, profile *profiles.D
 		return userAudio, nil
 	}
 
+	if err := useInteraction(ctx, logCtx, profile, in.duration().Seconds()); err != nil {
+		return characters.UserAudio{}, err
+	}
+
 	userAudio.AudioID = audioID
 	userAudio.DetectedLanguage = detectedLanguage
+	userAudio.Duration = in.duration().Seconds()
 	userAudio.Path = gcsPath
+	userAudio.STTEngine = sttResponse.Engine
 	userAudio.Text = sttResponse.Text
 	userAudio.Mode = profile.Characters[characterName].Mode
 
@@ -139,17 +138,17 @@ func PostUserAudio(ctx context.Context, logCtx *slog.Logger, profile *profiles.D
 	if s.StartEntry == 0 {
 		s.StartEntry = 1
 
-		if _, err := collection.Doc("latest").Set(ctx, s); err != nil {
+		if err := collection.Doc("latest").Set(ctx, s); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to set latest document", fid, "error", err)
 			return characters.UserAudio{}, err
 		}
 	} else {
-		updates := []fs.Update{
+		updates := []docstore.Update{
 			{Path: "last_user_audio." + audioID, Value: s.LastUserAudio[audioID]},
 		}
 
-		if _, err := collection.Doc("latest").Update(ctx, updates); err != nil {
+		if err := collection.Doc("latest").Update(ctx, updates); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to update lastest document", fid, "error", err)
 			return characters.UserAudio{}, err
@@ -180,9 +179,9 @@ func GetUserAudio(ctx context.Context, logCtx *slog.Logger, profileID, character
 		return nil, "", common.ErrNotFound{Msg: "user audio not found"}
 	}
 
-	rc, cType, err := gcp.Storage.Download(ctx, firebase.GCSBucket, e.UserAudio[fileExt])
+	rc, cType, err := objectstore.Client.Download(ctx, firebase.GCSBucket, e.UserAudio[fileExt])
 	if err != nil {
-		if errors.Is(err, storage.ErrObjectNotExist) {
+		if errors.Is(err, objectstore.ErrObjectNotExist) {
 			logCtx.Error("unable to download user audio file", fid, "error", err)
 			return nil, "", common.ErrGone{Msg: "user audio gone"}
 		}
@@ -206,65 +205,3 @@ func GetAssistantAudio(ctx context.Context, logCtx *slog.Logger, profile *profil
 
 	return rc, cType, nil
 }
-
-const lowBalanceHTML = `
-<!DOCTYPE html>
-<html>
-<body>
-<h2>Low Vexels</h2>
-<ul>
-	<li><b>Email:</b> {{ .Email }}</li>
-	<li><b>ID:</b> {{ .ID }}</li>
-	<li><b>Project:</b> {{ .Project }}</li>
-	<li><b>Balance:</b> {{ .Balance }}</li>
-</ul>
-</body>
-</html>
-`
-
-type lowBalanceVars struct {
-	Email   string
-	ID      string
-	Project string
-	Balance int
-}
-
-func emailLowBalance(ctx context.Context, logCtx *slog.Logger, balance int) {
-	fid := slog.String("fid", "vox.characters.play.emailLowBalance")
-
-	account := ctx.Value(common.AccountKey).(accounts.Document)
-
-	logCtx.Info("low balance", fid, "email", account.Email, "id", account.ID)
-
-	t, err := template.New("t").Parse(lowBalanceHTML)
-	if err != nil {
-		logCtx.Warn("unable to create low balance HTML template", fid, "error", err)
-		return
-	}
-
-	var buf bytes.Buffer
-
-	vars := lowBalanceVars{
-		Email:   account.Email,
-		ID:      account.ID,
-		Project: config.VARS.FirebaseProject,
-		Balance: balance,
-	}
-
-	if err := t.Execute(&buf, vars); err != nil {
-		logCtx.Warn("unable to execute low balance HTML template", fid, "error", err)
-		return
-	}
-
-	req := accounts.EmailRequest{
-		From:    config.VARS.MailgunNotificationsFrom,
-		To:      strings.Split(config.VARS.MailgunLowBalanceNotificationsTo, ","),
-		Subject: "Low Vexels",
-		HTML:    buf.String(),
-	}
-
-	if err := accounts.SendEmail(ctx, logCtx, req); err != nil {
-		logCtx.Warn("unable to send mail", fid, "error", err)
-		return
-	}
-}
@@ -12,20 +12,17 @@ import (
 	"time"
 
 	"disruptive/lib/configs"
-	"disruptive/lib/openai"
+	"disruptive/lib/llm"
 	"disruptive/pkg/vox/characters"
 	"disruptive/pkg/vox/profiles"
-
-	"github.com/pkoukk/tiktoken-go"
 )
 
-func playGPT(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, character *characters.Character,
-	session *characters.SessionDocument, chatReq *openai.ChatRequest, userPrompt, audioID string) (*Result, error) {
+func playGPT(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, p *tttPrompt, userPrompt, audioID string) (*Result, error) {
 	fid := slog.String("fid", "vox.characters.play.playGPT")
 
 	t := time.Now()
 
-	chatRes, err := openai.PostChat(ctx, logCtx, *chatReq)
+	chatRes, err := p.provider.Chat(ctx, logCtx, p.model, *p.req)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			logCtx.Error("timeout", fid, "error", err)
@@ -35,8 +32,78 @@ func playGPT(ctx context.Context, logCtx *slog.Logger, profile *profiles.Documen
 		return nil, err
 	}
 
+	text := filterResponse(logCtx, profile, chatRes.Text)
+	tokensPrompt, tokensResponse := chatRes.TokensPrompt, chatRes.TokensResponse
+
+	m, ok := moderateResponse(ctx, logCtx, profile, p.language, text)
+	verdict := &characters.AssistantModeration{Action: characters.ModerationPassed, Moderation: m}
+
+	if !ok {
+		logCtx.Warn("assistant response failed moderation", fid, "assessment_age", m.Analysis.AssessmentAge, "response_age", profile.ResponseAge)
+
+		verdict.Action = characters.ModerationSubstituted
+		regenerated := ""
+
+		req := *p.req
+		req.Messages = append(slices.Clone(p.req.Messages), llm.Message{Role: "system", Content: fmt.Sprintf(regeneratePrompt, profile.ResponseAge)})
+
+		chatRes, err := p.provider.Chat(ctx, logCtx, p.model, req)
+		if err != nil {
+			logCtx.Warn("unable to regenerate chat response", fid, "error", err)
+		} else {
+			tokensPrompt += chatRes.TokensPrompt
+			tokensResponse += chatRes.TokensResponse
+			regenerated = filterResponse(logCtx, profile, chatRes.Text)
+		}
+
+		if regenerated != "" {
+			if m, ok := moderateResponse(ctx, logCtx, profile, p.language, regenerated); ok {
+				text = regenerated
+				verdict = &characters.AssistantModeration{Action: characters.ModerationRegenerated, Moderation: m}
+			}
+		}
+
+		if verdict.Action == characters.ModerationSubstituted {
+			text = moderationResponseText(profile, &p.localize)
+		}
+	}
+
+	sessionEntry := characters.SessionEntry{
+		Assistant:           text,
+		AssistantModeration: verdict,
+		Mode:                profile.Characters[p.character.Character].Mode,
+		Timestamp:           t,
+		TokensPrompt:        tokensPrompt,
+		TokensResponse:      tokensResponse,
+		User:                userPrompt,
+	}
+
+	sessionID, err := characters.AddSessionEntry(ctx, logCtx, profile.ID, p.character.Character, audioID, p.session, sessionEntry)
+	if err != nil {
+		logCtx.Warn("unable to add session entry", fid, "error", err)
+	}
+
+	if sessionID == 0 {
+		logCtx.Warn("unable to get sessionID", fid)
+	}
+
+	res := &Result{
+		Moderation:     verdict.Moderation,
+		Response:       text,
+		Predefined:     p.session.LastUserAudio[audioID].Predefined,
+		SessionID:      sessionID,
+		TokensPrompt:   tokensPrompt,
+		TokensResponse: tokensResponse,
+	}
+
+	return res, nil
+}
+
+// filterResponse removes the profile dont_say words and applies the profile replace_words.
+func filterResponse(logCtx *slog.Logger, profile *profiles.Document, text string) string {
+	fid := slog.String("fid", "vox.characters.play.filterResponse")
+
 	// remove words (dont_say)
-	text := chatRes.Text
 	for _, v := range profile.DontSay {
 		r := `(?i)\b` + regexp.QuoteMeta(v) + `\b`
 		regex, err := regexp.Compile(r)
@@ -50,11 +117,12 @@ func playGPT(ctx context.Context, logCtx *slog.Logger, profile *profiles.Documen
 
 	// replace words
 	for k, v := range profile.ReplaceWords {
+		words := make([]string, len(v))
 		for i, w := range v {
-			profile.ReplaceWords[k][i] = regexp.QuoteMeta(w)
+			words[i] = regexp.QuoteMeta(w)
 		}
 
-		r := `(?i)\b(` + strings.Join(profile.ReplaceWords[k], "|") + `)\b`
+		r := `(?i)\b(` + strings.Join(words, "|") + `)\b`
 		regex, err := regexp.Compile(r)
 		if err != nil {
 			logCtx.Warn("unable to compile regex", fid, "error", err, "regex", r)
@@ -68,39 +136,11 @@ func playGPT(ctx context.Context, logCtx *slog.Logger, profile *profiles.Documen
 		text = regex.ReplaceAllLiteralString(text, k)
 	}
 
-	text = strings.ReplaceAll(text, "  ", " ")
-
-	sessionEntry := characters.SessionEntry{
-		Assistant:      text,
-		Mode:           profile.Characters[character.Character].Mode,
-		Timestamp:      t,
-		TokensPrompt:   chatRes.UsagePrompt,
-		TokensResponse: chatRes.UsageResponse,
-		User:           userPrompt,
-	}
-
-	sessionID, err := characters.AddSessionEntry(ctx, logCtx, profile.ID, character.Character, audioID, *session, sessionEntry)
-	if err != nil {
-		logCtx.Warn("unable to add session entry", fid, "error", err)
-	}
-
-	if sessionID == 0 {
-		logCtx.Warn("unable to get sessionID", fid)
-	}
-
-	p := &Result{
-		Response:       text,
-		Predefined:     session.LastUserAudio[audioID].Predefined,
-		SessionID:      sessionID,
-		TokensPrompt:   chatRes.UsagePrompt,
-		TokensResponse: chatRes.UsageResponse,
-	}
-
-	return p, nil
+	return strings.ReplaceAll(text, "  ", " ")
 }
 
 func conversationGPTPromptBuilderV1(profile *profiles.Document, character *characters.Character, mode *characters.Mode,
-	session *characters.SessionDocument, userPrompt string, localize *configs.Localize, predefined bool) (*openai.ChatRequest, int) {
+	session *characters.SessionDocument, userPrompt string, localize *configs.Localize, predefined bool) *llm.Request {
 	systemPrompt := strings.Builder{}
 	systemPrompt.WriteString(mode.CharacterPrompt)
 
@@ -118,17 +158,15 @@ func conversationGPTPromptBuilderV1(profile *profiles.Document, character *chara
 
 	systemPrompt.WriteString(localize.Character["guardrail_prompt"])
 
-	systemPromptString := systemPrompt.String()
-	chatReq := openai.ChatRequest{
-		Model:      character.Model,
-		Messages:   []openai.ChatMessage{{Role: "system", Content: systemPromptString}},
+	chatReq := llm.Request{
+		Messages:   []llm.Message{{Role: "system", Content: systemPrompt.String()}},
 		Creativity: mode.Creativity,
 	}
 
 	if !predefined {
 		entryNumber := len(session.Entries) + session.StartEntry
 		numEntries := 0
-		memoryMessages := []openai.ChatMessage{}
+		memoryMessages := []llm.Message{}
 		for numEntries <= mode.SessionEntries && entryNumber >= session.StartEntry {
 			iStr := fmt.Sprintf("%06d", entryNumber)
 
@@ -137,8 +175,8 @@ func conversationGPTPromptBuilderV1(profile *profiles.Document, character *chara
 			}
 
 			if session.Entries[iStr].Moderation != nil && !session.Entries[iStr].Moderation.Triggered {
-				memoryMessages = append(memoryMessages, openai.ChatMessage{Role: "assistant", Content: session.Entries[iStr].Assistant})
-				memoryMessages = append(memoryMessages, openai.ChatMessage{Role: "user", Content: session.Entries[iStr].User})
+				memoryMessages = append(memoryMessages, llm.Message{Role: "assistant", Content: session.Entries[iStr].Assistant})
+				memoryMessages = append(memoryMessages, llm.Message{Role: "user", Content: session.Entries[iStr].User})
 				numEntries++
 			}
 
@@ -208,24 +246,16 @@ func conversationGPTPromptBuilderV1(profile *profiles.Document, character *chara
 
 	content.WriteString(localize.Character["dont_say_ai"])
 
-	contentString := content.String()
-	chatReq.Messages = append(chatReq.Messages, openai.ChatMessage{
+	chatReq.Messages = append(chatReq.Messages, llm.Message{
 		Role:    "user",
-		Content: contentString,
+		Content: content.String(),
 	})
 
-	// tokenize the request.
-	tke, err := tiktoken.GetEncoding(characters.TokenEncodingModel)
-	if err != nil {
-		return nil, 0
-	}
-	numTokens := len(tke.Encode(systemPromptString+contentString, nil, nil))
-
-	return &chatReq, numTokens
+	return &chatReq
 }
 
 func conversationGPTPromptBuilderV2(profile *profiles.Document, character *characters.Character, mode *characters.Mode,
-	session *characters.SessionDocument, userPrompt string, localize *configs.Localize, predefined bool) (*openai.ChatRequest, int) {
+	session *characters.SessionDocument, userPrompt string, localize *configs.Localize, predefined bool) *llm.Request {
 	systemPrompt := strings.Builder{}
 	systemPrompt.WriteString(mode.CharacterPrompt)
 
@@ -243,10 +273,8 @@ func conversationGPTPromptBuilderV2(profile *profiles.Document, character *chara
 
 	systemPrompt.WriteString(localize.Character["guardrail_prompt"])
 
-	systemPromptString := systemPrompt.String()
-	chatReq := openai.ChatRequest{
-		Model:      character.Model,
-		Messages:   []openai.ChatMessage{{Role: "system", Content: systemPromptString}},
+	chatReq := llm.Request{
+		Messages:   []llm.Message{{Role: "system", Content: systemPrompt.String()}},
 		Creativity: mode.Creativity,
 	}
 
@@ -258,7 +286,7 @@ func conversationGPTPromptBuilderV2(profile *profiles.Document, character *chara
 	if !predefined {
 		entryNumber := len(session.Entries) + session.StartEntry
 		numEntries := 0
-		memoryMessages := []openai.ChatMessage{}
+		memoryMessages := []llm.Message{}
 		for numEntries <= mode.SessionEntries && entryNumber >= session.StartEntry {
 			iStr := fmt.Sprintf("%06d", entryNumber)
 
@@ -267,8 +295,8 @@ func conversationGPTPromptBuilderV2(profile *profiles.Document, character *chara
 			}
 
 			if session.Entries[iStr].Moderation != nil && !session.Entries[iStr].Moderation.Triggered {
-				memoryMessages = append(memoryMessages, openai.ChatMessage{Role: "assistant", Content: session.Entries[iStr].Assistant})
-				memoryMessages = append(memoryMessages, openai.ChatMessage{Role: "user", Content: session.Entries[iStr].User})
+				memoryMessages = append(memoryMessages, llm.Message{Role: "assistant", Content: session.Entries[iStr].Assistant})
+				memoryMessages = append(memoryMessages, llm.Message{Role: "user", Content: session.Entries[iStr].User})
 				numEntries++
 			}
 
@@ -336,24 +364,16 @@ func conversationGPTPromptBuilderV2(profile *profiles.Document, character *chara
 		content.WriteString(fmt.Sprintf(localize.Character["topics_encourage"], topic))
 	}
 
-	contentString := content.String()
-	chatReq.Messages = append(chatReq.Messages, openai.ChatMessage{
+	chatReq.Messages = append(chatReq.Messages, llm.Message{
 		Role:    "user",
-		Content: contentString,
+		Content: content.String(),
 	})
 
-	// tokenize the request to determine 4k or 16k model.
-	tke, err := tiktoken.GetEncoding(characters.TokenEncodingModel)
-	if err != nil {
-		return nil, 0
-	}
-	numTokens := len(tke.Encode(systemPromptString+contentString, nil, nil))
-
-	return &chatReq, numTokens
+	return &chatReq
 }
 
 func funGPTPromptBuilderV1(profile *profiles.Document, character *characters.Character, mode *characters.Mode,
-	session *characters.SessionDocument, userPrompt string, localize *configs.Localize) (*openai.ChatRequest, int) {
+	session *characters.SessionDocument, userPrompt string, localize *configs.Localize) *llm.Request {
 	systemPrompt := strings.Builder{}
 	systemPrompt.WriteString(mode.CharacterPrompt)
 
@@ -369,16 +389,14 @@ func funGPTPromptBuilderV1(profile *profiles.Document, character *characters.Cha
 		systemPrompt.WriteString(".")
 	}
 
-	systemPromptString := systemPrompt.String()
-	chatReq := openai.ChatRequest{
-		Model:      character.Model,
-		Messages:   []openai.ChatMessage{{Role: "system", Content: systemPromptString}},
+	chatReq := llm.Request{
+		Messages:   []llm.Message{{Role: "system", Content: systemPrompt.String()}},
 		Creativity: mode.Creativity,
 	}
 
 	entryNumber := len(session.Entries) + session.StartEntry
 	numEntries := 0
-	memoryMessages := []openai.ChatMessage{}
+	memoryMessages := []llm.Message{}
 	for numEntries <= mode.SessionEntries && entryNumber >= session.StartEntry {
 		iStr := fmt.Sprintf("%06d", entryNumber)
 
@@ -387,8 +405,8 @@ func funGPTPromptBuilderV1(profile *profiles.Document, character *characters.Cha
 		}
 
 		if session.Entries[iStr].Moderation != nil && !session.Entries[iStr].Moderation.Triggered {
-			memoryMessages = append(memoryMessages, openai.ChatMessage{Role: "assistant", Content: session.Entries[iStr].Assistant})
-			memoryMessages = append(memoryMessages, openai.ChatMessage{Role: "user", Content: session.Entries[iStr].User})
+			memoryMessages = append(memoryMessages, llm.Message{Role: "assistant", Content: session.Entries[iStr].Assistant})
+			memoryMessages = append(memoryMessages, llm.Message{Role: "user", Content: session.Entries[iStr].User})
 			numEntries++
 		}
 
@@ -430,24 +448,16 @@ func funGPTPromptBuilderV1(profile *profiles.Document, character *characters.Cha
 
 	content.WriteString(localize.Character["dont_say_ai"])
 
-	contentString := content.String()
-	chatReq.Messages = append(chatReq.Messages, openai.ChatMessage{
+	chatReq.Messages = append(chatReq.Messages, llm.Message{
 		Role:    "user",
-		Content: contentString,
+		Content: content.String(),
 	})
 
-	// tokenize the request.
-	tke, err := tiktoken.GetEncoding(characters.TokenEncodingModel)
-	if err != nil {
-		return nil, 0
-	}
-	numTokens := len(tke.Encode(systemPromptString+contentString, nil, nil))
-
-	return &chatReq, numTokens
+	return &chatReq
 }
 
 func funGPTPromptBuilderV2(profile *profiles.Document, character *characters.Character, mode *characters.Mode,
-	session *characters.SessionDocument, userPrompt string, localize *configs.Localize) (*openai.ChatRequest, int) {
+	session *characters.SessionDocument, userPrompt string, localize *configs.Localize) *llm.Request {
 	systemPrompt := strings.Builder{}
 	systemPrompt.WriteString(mode.CharacterPrompt)
 
@@ -465,16 +475,14 @@ func funGPTPromptBuilderV2(profile *profiles.Document, character *characters.Cha
 
 	systemPrompt.WriteString(localize.Character["guardrail_prompt"])
 
-	systemPromptString := systemPrompt.String()
-	chatReq := openai.ChatRequest{
-		Model:      character.Model,
-		Messages:   []openai.ChatMessage{{Role: "system", Content: systemPromptString}},
+	chatReq := llm.Request{
+		Messages:   []llm.Message{{Role: "system", Content: systemPrompt.String()}},
 		Creativity: mode.Creativity,
 	}
 
 	entryNumber := len(session.Entries) + session.StartEntry
 	numEntries := 0
-	memoryMessages := []openai.ChatMessage{}
+	memoryMessages := []llm.Message{}
 	for numEntries <= mode.SessionEntries && entryNumber >= session.StartEntry {
 		iStr := fmt.Sprintf("%06d", entryNumber)
 
@@ -483,8 +491,8 @@ func funGPTPromptBuilderV2(profile *profiles.Document, character *characters.Cha
 		}
 
 		if session.Entries[iStr].Moderation != nil && !session.Entries[iStr].Moderation.Triggered {
-			memoryMessages = append(memoryMessages, openai.ChatMessage{Role: "assistant", Content: session.Entries[iStr].Assistant})
-			memoryMessages = append(memoryMessages, openai.ChatMessage{Role: "user", Content: session.Entries[iStr].User})
+			memoryMessages = append(memoryMessages, llm.Message{Role: "assistant", Content: session.Entries[iStr].Assistant})
+			memoryMessages = append(memoryMessages, llm.Message{Role: "user", Content: session.Entries[iStr].User})
 			numEntries++
 		}
 
@@ -526,18 +534,10 @@ func funGPTPromptBuilderV2(profile *profiles.Document, character *characters.Cha
 
 	content.WriteString(localize.Character["dont_say_ai"])
 
-	contentString := content.String()
-	chatReq.Messages = append(chatReq.Messages, openai.ChatMessage{
+	chatReq.Messages = append(chatReq.Messages, llm.Message{
 		Role:    "user",
-		Content: contentString,
+		Content: content.String(),
 	})
 
-	// tokenize the request.
-	tke, err := tiktoken.GetEncoding(characters.TokenEncodingModel)
-	if err != nil {
-		return nil, 0
-	}
-	numTokens := len(tke.Encode(systemPromptString+contentString, nil, nil))
-
-	return &chatReq, numTokens
+	return &chatReq
 }
@@ -0,0 +1,178 @@
+package play
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"disruptive/config"
+	"disruptive/lib/audio"
+	"disruptive/lib/common"
+)
+
+// ingestPeekSize is the prefix of the user audio read to detect the container before streaming it. It only needs the
+// container headers, a larger prefix would hold the audio back from STT until that much has arrived.
+const ingestPeekSize = 4 << 10
+
+// ingestedAudio is user audio that passed the ingest checks. r streams the audio to STT.
+type ingestedAudio struct {
+	ext  string
+	info audio.Info
+	r    io.Reader
+	size *sizeReader
+
+	// partial is the duration measured from the first partialSize bytes of streamed audio.
+	partial     time.Duration
+	partialSize int
+}
+
+// duration returns the length of the audio once it has been read. The length of streamed audio without a total
+// duration is scaled from the duration of its prefix by the size read, it is 0 when the prefix had none.
+func (in ingestedAudio) duration() time.Duration {
+	if in.info.Duration > 0 || in.partialSize == 0 || in.size == nil {
+		return in.info.Duration
+	}
+
+	return in.partial * time.Duration(in.size.n) / time.Duration(in.partialSize)
+}
+
+// err returns the error that ended the audio stream, like the size limit, once the audio has been read.
+func (in ingestedAudio) err() error {
+	if in.size == nil {
+		return nil
+	}
+	return in.size.err
+}
+
+// sizeReader counts the bytes read and fails once more than max bytes are read. A max of 0 is unlimited.
+type sizeReader struct {
+	r   io.Reader
+	max int
+	n   int
+	err error
+}
+
+func (s *sizeReader) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	n, err := s.r.Read(p)
+	s.n += n
+
+	if s.max > 0 && s.n > s.max {
+		s.err = common.ErrBadRequest{Msg: "audio too large"}
+		return 0, s.err
+	}
+
+	return n, err
+}
+
+// ingestUserAudio detects the real container of user audio from its first bytes instead of trusting
+// the format and enforces the configured size and duration limits. Only 16-bit PCM WAV audio is
+// buffered, to trim the silence around the speech, other audio streams to STT as it is read and the
+// size limit is enforced while it streams. Empty and silent audio returns ErrNoSpeech before any STT
+// vendor is called.
+func ingestUserAudio(logCtx *slog.Logger, r io.Reader, fileExt string) (ingestedAudio, error) {
+	fid := slog.String("fid", "vox.characters.play.ingestUserAudio")
+	t := time.Now()
+
+	size := &sizeReader{r: r, max: config.VARS.AudioMaxSize}
+	br := bufio.NewReaderSize(size, ingestPeekSize)
+
+	prefix, err := br.Peek(ingestPeekSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		if errors.Is(err, common.ErrBadRequest{}) {
+			logCtx.Warn("user audio too large", fid, "max_size", config.VARS.AudioMaxSize)
+		} else {
+			logCtx.Error("unable to read user audio", fid, "error", err)
+		}
+		return ingestedAudio{}, err
+	}
+
+	// The whole audio fits in the prefix when the peek ended early.
+	complete := err == io.EOF
+
+	if len(prefix) == 0 {
+		logCtx.Warn("empty user audio", fid)
+		return ingestedAudio{}, common.ErrNoSpeech{Msg: "empty audio"}
+	}
+
+	in := ingestedAudio{ext: fileExt, r: br, size: size}
+
+	in.info, err = audio.Probe(prefix)
+	if err != nil {
+		if !errors.Is(err, audio.ErrUnknownContainer) {
+			logCtx.Warn("invalid user audio", fid, "extension", fileExt, "error", err)
+			return ingestedAudio{}, common.ErrBadRequest{Msg: "invalid audio: " + err.Error()}
+		}
+
+		logCtx.Warn("unknown user audio container", fid, "extension", fileExt, "size", len(prefix))
+		return in, nil
+	}
+
+	if in.info.Container != fileExt {
+		logCtx.Info("user audio container does not match format", fid, "extension", fileExt, "container", in.info.Container)
+		in.ext = in.info.Container
+	}
+
+	pcmWAV := in.info.Container == audio.ContainerWAV && in.info.Codec == audio.CodecPCM && in.info.BitsPerSample == 16
+
+	if pcmWAV && !complete {
+		b, err := io.ReadAll(br)
+		if err != nil {
+			if errors.Is(err, common.ErrBadRequest{}) {
+				logCtx.Warn("user audio too large", fid, "max_size", config.VARS.AudioMaxSize)
+			} else {
+				logCtx.Error("unable to read user audio", fid, "error", err)
+			}
+			return ingestedAudio{}, err
+		}
+
+		prefix, complete = b, true
+
+		if in.info, err = audio.Probe(b); err != nil {
+			logCtx.Warn("invalid user audio", fid, "extension", fileExt, "error", err)
+			return ingestedAudio{}, common.ErrBadRequest{Msg: "invalid audio: " + err.Error()}
+		}
+	}
+
+	// A duration measured from a prefix is partial, only the FLAC STREAMINFO has the total.
+	if !complete && in.info.Container != audio.ContainerFLAC {
+		in.partial, in.partialSize = in.info.Duration, len(prefix)
+		in.info.Duration = 0
+		in.info.HasDuration = false
+	}
+
+	if in.info.HasDuration {
+		if in.info.Duration == 0 {
+			logCtx.Warn("empty user audio", fid, "container", in.info.Container)
+			return ingestedAudio{}, common.ErrNoSpeech{Msg: "empty audio"}
+		}
+
+		if maxDuration := time.Duration(config.VARS.AudioMaxDuration) * time.Second; maxDuration > 0 && in.info.Duration > maxDuration {
+			logCtx.Warn("user audio too long", fid, "duration", in.info.Duration.Seconds(), "max_duration", config.VARS.AudioMaxDuration)
+			return ingestedAudio{}, common.ErrBadRequest{Msg: "audio too long"}
+		}
+	}
+
+	if pcmWAV {
+		pcm, ok := audio.TrimSilence(audio.WAVData(prefix, in.info), in.info.SampleRate, in.info.Channels, config.VARS.AudioSilenceThreshold)
+		if !ok {
+			logCtx.Warn("silent user audio", fid, "duration", in.info.Duration.Seconds())
+			return ingestedAudio{}, common.ErrNoSpeech{Msg: "silent audio"}
+		}
+
+		data := append(common.WAVHeader(in.info.SampleRate, in.info.Channels, uint32(len(pcm))), pcm...)
+		in.r = bytes.NewReader(data)
+		in.info.Duration = time.Duration(len(pcm)) * time.Second / time.Duration(in.info.SampleRate*in.info.Channels*2)
+		in.info.Size = len(data)
+	}
+
+	logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "ingest", "container", in.info.Container, "codec", in.info.Codec, "audio_duration", in.info.Duration.Seconds(), "streamed", !complete)
+
+	return in, nil
+}
@@ -2,22 +2,27 @@ package play
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
 
-	fs "cloud.google.com/go/firestore"
-
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/configs"
+	"disruptive/lib/docstore"
 	"disruptive/pkg/vox/accounts"
 	"disruptive/pkg/vox/characters"
 	"disruptive/pkg/vox/moderate"
+	"disruptive/pkg/vox/notifications"
 	"disruptive/pkg/vox/profiles"
 )
 
+// regeneratePrompt asks for a new response after the previous one failed moderation.
+const regeneratePrompt = "Your previous response was not appropriate for a %d year old child. " +
+	"Respond again to the last message in a way that is safe and suitable for that age, without mentioning this instruction."
+
 // GetSTSModeration get a moderation response for STS and save it to last_user_audio. Send the notification email.
+// Profiles without moderation are only checked for self-harm, which escalates whatever the profile's settings.
+// Triggered moderations are added to the review queue. Escalations are recorded and alert the guardian.
 func GetSTSModeration(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, characterVersion, audioID string) (*moderate.Response, error) {
 	fid := slog.String("fid", "vox.moderate.GetSTSModeration")
 
@@ -43,48 +48,98 @@ func GetSTSModeration(ctx context.Context, logCtx *slog.Logger, profile *profile
 	}
 
 	m := moderate.Get(ctx, logCtx, session.LastUserAudio[audioID].Text, profileCharacter.Language)
-	if m == nil {
-		logCtx.Error("unable to generate moderation", fid)
-		return &moderate.Response{}, errors.New("unable to generate moderation")
-	}
 
-	m.Triggered, m.Analysis.NotAgeAppropriate = validateModeration(profile, m)
+	if !applyCrisisRule(m) && profile.Moderate {
+		applyPolicy(ctx, logCtx, profile, m)
+	}
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profile.ID, character.Character)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("unable to find collection", fid, "path", path)
 		return &moderate.Response{}, nil
 	}
 
-	updates := []fs.Update{
+	updates := []docstore.Update{
 		{Path: fmt.Sprintf("last_user_audio.%s.moderation", audioID), Value: m},
 	}
 
-	if _, err := collection.Doc("latest").Update(ctx, updates); err != nil {
+	if err := collection.Doc("latest").Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to update latest document", fid, "error", err)
 		return &moderate.Response{}, err
 	}
 
+	// Every triggered moderation of a user audio is reviewed, whether or not the profile moderates or sends notifications.
+	// GetSTSAudio moderates the turns the client did not, and profiles without moderation only trigger on a crisis.
+	if m.Triggered {
+		lastUserAudio := session.LastUserAudio[audioID]
+		lastUserAudio.AudioID = audioID
+		lastUserAudio.Moderation = m
+
+		if err := notifications.PostReview(ctx, logCtx, profile, character.Character, &lastUserAudio); err != nil {
+			logCtx.Warn("unable to add moderation review", fid, "error", err)
+		}
+	}
+
+	if m.Action == configs.ModerationEscalate {
+		escalate(ctx, logCtx, profile, characterVersion, character.Character, audioID, session.LastUserAudio[audioID], m)
+	}
+
 	return m, nil
 }
 
-func validateModeration(profile *profiles.Document, m *moderate.Response) (bool, bool) {
-	if profile.ResponseAge < min(m.Analysis.AssessmentAge, ratingsToAge[m.Analysis.MovieRating], ratingsToAge[m.Analysis.TVRating], ratingsToAge[m.Analysis.ESRBRating], m.Analysis.PEGIRating) {
-		return true, true
+// escalate records a crisis escalation of the user audio and alerts the guardian.
+func escalate(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, characterVersion, characterName, audioID string, lastUserAudio characters.UserAudio, m *moderate.Response) {
+	fid := slog.String("fid", "vox.characters.play.escalate")
+
+	language := profile.Characters[characterName].Language
+	if language == "" {
+		language = lastUserAudio.DetectedLanguage
+	}
+
+	localize, err := configs.GetLocalization(ctx, logCtx, strings.Split(characterVersion, "_")[1], language)
+	if err != nil {
+		logCtx.Error("unable to get character localize configs", fid, "error", err)
+		return
+	}
+
+	lastUserAudio.AudioID = audioID
+	lastUserAudio.Moderation = m
+
+	if _, err := notifications.PostEscalation(ctx, logCtx, profile, characterName, &lastUserAudio, &localize); err != nil {
+		logCtx.Error("unable to escalate", fid, "error", err)
+	}
+}
+
+// moderateResponse runs the input moderation on an assistant response and applies the profile's moderation policy.
+// It reports whether the response may be spoken as is. The crisis rule is left to the user input, so a response
+// that offers help is not replaced.
+func moderateResponse(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, language, text string) (*moderate.Response, bool) {
+	if language == "" {
+		language = "en-US"
+	}
+
+	m := moderate.Get(ctx, logCtx, text, language)
+	applyPolicy(ctx, logCtx, profile, m)
+
+	return m, m.Action == configs.ModerationAllow || m.Action == configs.ModerationNotify
+}
+
+// moderationResponseText returns the localized response spoken in place of moderated content.
+func moderationResponseText(profile *profiles.Document, localize *configs.Localize) string {
+	if profile.ResponseAge > 12 {
+		return localize.Character["moderation_response_1"]
+	}
+
+	return localize.Character["moderation_response_2"]
+}
+
+// crisisResponseText returns the localized safe response with help resources spoken after a crisis escalation.
+func crisisResponseText(profile *profiles.Document, localize *configs.Localize) string {
+	if text := localize.Character["crisis_response"]; text != "" {
+		return text
 	}
 
-	return profile.Notifications.Moderations.Hate && m.Categories["hate"] ||
-		profile.Notifications.Moderations.HateThreatening && m.Categories["hate/threatening"] ||
-		profile.Notifications.Moderations.Harassment && m.Categories["harassment"] ||
-		profile.Notifications.Moderations.HarassmentThreatening && m.Categories["harassment/threatening"] ||
-		profile.Notifications.Moderations.Violence && m.Categories["violence"] ||
-		profile.Notifications.Moderations.ViolenceGraphic && m.Categories["violence/graphic"] ||
-		profile.Notifications.Moderations.Sexual && m.Categories["sexual"] ||
-		profile.Notifications.Moderations.SexualMinors && m.Categories["sexual/minors"] ||
-		profile.Notifications.Moderations.Selfharm && m.Categories["self-harm"] ||
-		profile.Notifications.Moderations.SelfharmIntent && m.Categories["self-harm/intent"] ||
-		profile.Notifications.Moderations.SelfharmInstructions && m.Categories["self-harm/instructions"] ||
-		profile.Notifications.TextAnalysis.Toxic && m.Analysis.Toxic, false
+	return moderationResponseText(profile, localize)
 }
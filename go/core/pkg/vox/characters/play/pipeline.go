@@ -0,0 +1,695 @@
+package play
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
+	"disruptive/lib/elevenlabs"
+	"disruptive/lib/firebase"
+	"disruptive/lib/llm"
+	"disruptive/lib/objectstore"
+	"disruptive/lib/opus"
+	"disruptive/lib/tts"
+	"disruptive/pkg/vox/accounts"
+	"disruptive/pkg/vox/characters"
+	"disruptive/pkg/vox/moderate"
+	"disruptive/pkg/vox/profiles"
+)
+
+const (
+	// minSentenceLength keeps short sentences together so each TTS request has enough text for natural prosody.
+	minSentenceLength = 24
+
+	// maxPipelineTTS limits the concurrent TTS requests of one response.
+	maxPipelineTTS = 3
+
+	// minModerationLength batches the sentences after the first into one moderation request of at least this many
+	// characters. The first sentence is moderated alone so its audio is not held back.
+	minModerationLength = 240
+
+	// maxModerationSentences ends a batch before its sentences fill the chunks channel, which the response waits on.
+	maxModerationSentences = 4
+
+	sentenceTerminators    = ".!?…\n"
+	cjkSentenceTerminators = "。！？"
+)
+
+// audioChunk buffers the TTS audio of one sentence so later sentences are synthesized while earlier ones play.
+// The sentence is moderated with its batch while it is synthesized, its audio is only played once the batch is
+// moderated and allowed.
+type audioChunk struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  bytes.Buffer
+	done bool
+	err  error
+
+	text   string
+	cancel context.CancelFunc
+	batch  *moderationBatch
+}
+
+func newAudioChunk(text string, cancel context.CancelFunc) *audioChunk {
+	a := &audioChunk{text: text, cancel: cancel}
+	a.cond = sync.NewCond(&a.mu)
+	return a
+}
+
+// moderationBatch is the moderation of consecutive sentences of a response. moderation and allowed are set once
+// moderated is closed.
+type moderationBatch struct {
+	text       strings.Builder
+	sentences  int
+	moderated  chan struct{}
+	moderation *moderate.Response
+	allowed    bool
+}
+
+func newModerationBatch() *moderationBatch {
+	return &moderationBatch{moderated: make(chan struct{})}
+}
+
+func (a *audioChunk) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n, err := a.buf.Write(p)
+	a.cond.Broadcast()
+	return n, err
+}
+
+func (a *audioChunk) Read(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for a.buf.Len() == 0 && !a.done {
+		a.cond.Wait()
+	}
+
+	if a.buf.Len() > 0 {
+		return a.buf.Read(p)
+	}
+
+	if a.err != nil {
+		return 0, a.err
+	}
+
+	return 0, io.EOF
+}
+
+func (a *audioChunk) close(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.done = true
+	a.err = err
+	a.cond.Broadcast()
+}
+
+// pipelineSTS streams the LLM response, splits it at sentence boundaries and starts TTS for each
+// sentence while later sentences are still generating. The sentences are moderated in batches while they are
+// synthesized and their audio waits for the verdict. The audio is stitched into one stream.
+// The session entry is stored and charged once the full response is known, before the stream ends.
+func pipelineSTS(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, characterVersion, format, tttModel, ttsModel, optimizingStreamLatency, userPrompt, audioID string, charge func(ctx context.Context, sessionID int) error) (io.ReadCloser, string, error) {
+	fid := slog.String("fid", "vox.characters.play.pipelineSTS")
+	t := time.Now()
+
+	fileExt, ok := elevenlabs.AudioFormatExtensions[format]
+	if !ok {
+		logCtx.Error("invalid audio format", fid, "format", format)
+		return nil, "", common.ErrBadRequest{Msg: "invalid audio format"}
+	}
+
+	contentType, ok := elevenlabs.AudioFormatContentTypes[format]
+	if !ok {
+		logCtx.Error("invalid audio format", fid, "format", format)
+		return nil, "", common.ErrBadRequest{Msg: "invalid audio format"}
+	}
+
+	p, err := buildTTTPrompt(ctx, logCtx, profile, characterVersion, tttModel, userPrompt, audioID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	profileCharacter := profile.Characters[p.character.Character]
+
+	if profileCharacter.Voice == "" {
+		profileCharacter.Voice = "default"
+	}
+
+	if profileCharacter.Language == "" {
+		profileCharacter.Language = "en-US"
+	}
+
+	// Container formats are requested as PCM per sentence and wrapped once so the stream has one header.
+	sentenceFormat, wavSampleRate, oggOpus, err := pipelineFormat(format)
+	if err != nil {
+		logCtx.Error("invalid audio format", fid, "format", format, "error", err)
+		return nil, "", common.ErrBadRequest{Msg: "invalid audio format"}
+	}
+
+	engine := p.character.Engine
+	ttsReq := tts.Request{
+		Format:                   sentenceFormat,
+		Language:                 profileCharacter.Language,
+		Model:                    ttsModel,
+		OptimizeStreamingLatency: optimizingStreamLatency,
+		Voice:                    p.character.Voices[profileCharacter.Voice],
+	}
+
+	if err := tts.Validate(engine, ttsReq.Voice, sentenceFormat); err != nil {
+		logCtx.Error("invalid tts request", fid, "engine", engine, "error", err)
+		return nil, "", err
+	}
+
+	// The TTS audio stops with the request. The response text, its session entry and charge do not depend on the
+	// consumer, so a client that stops reading the audio still pays for the response.
+	pipelineCtx, cancel := context.WithCancel(ctx)
+	genCtx := context.WithoutCancel(ctx)
+
+	// The chat stream is canceled on its own when a sentence fails moderation, the audio before it still plays.
+	streamCtx, cancelStream := context.WithCancel(genCtx)
+
+	stream, err := p.provider.ChatStream(streamCtx, logCtx, p.model, *p.req)
+	if err != nil {
+		cancelStream()
+		cancel()
+		logCtx.Error("unable to get chat stream", fid, "model", p.model.Name, "provider", p.model.Provider, "error", err)
+		return nil, "", err
+	}
+
+	chunks := make(chan *audioChunk, 8)
+
+	var (
+		streamErr      error
+		tokensPrompt   int
+		tokensResponse int
+	)
+
+	synthesize := func(ctx context.Context, chunk *audioChunk, text string) {
+		req := ttsReq
+		req.Text = text
+
+		r, err := tts.Stream(ctx, logCtx, engine, req)
+		if err != nil {
+			logCtx.Error("unable to get tts stream", fid, "engine", engine, "error", err)
+			chunk.close(err)
+			return
+		}
+		defer r.Close()
+
+		_, err = io.Copy(chunk, r)
+		chunk.close(err)
+	}
+
+	// Split the response into sentences and start the TTS of each one while its batch is moderated.
+	go func() {
+		defer close(chunks)
+
+		sem := make(chan struct{}, maxPipelineTTS)
+		boundaries := filterBoundaries(profile)
+		pending := ""
+		held := ""
+		buf := make([]byte, 256)
+
+		var batch *moderationBatch
+		batches := 0
+
+		moderateBatch := func() {
+			b := batch
+			batch = nil
+			batches++
+
+			go func() {
+				defer close(b.moderated)
+				b.moderation, b.allowed = moderateResponse(genCtx, logCtx, profile, p.language, b.text.String())
+			}()
+		}
+
+		// The last batch is moderated however short, its sentences wait for it. After a substitution nothing waits.
+		defer func() {
+			if batch == nil {
+				return
+			}
+
+			if streamCtx.Err() != nil {
+				batch.moderation = &moderate.Response{}
+				close(batch.moderated)
+				return
+			}
+
+			moderateBatch()
+		}()
+
+		speak := func(sentence string) bool {
+			sentence = strings.TrimSpace(filterResponse(logCtx, profile, sentence))
+			if sentence == "" {
+				return true
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-streamCtx.Done():
+				return false
+			}
+
+			chunkCtx, cancelChunk := context.WithCancel(pipelineCtx)
+			chunk := newAudioChunk(sentence, cancelChunk)
+
+			if batch == nil {
+				batch = newModerationBatch()
+			}
+
+			if batch.text.Len() > 0 {
+				batch.text.WriteString(" ")
+			}
+			batch.text.WriteString(sentence)
+			batch.sentences++
+			chunk.batch = batch
+
+			select {
+			case chunks <- chunk:
+			case <-streamCtx.Done():
+				cancelChunk()
+				<-sem
+				return false
+			}
+
+			if batches == 0 || batch.text.Len() >= minModerationLength || batch.sentences >= maxModerationSentences {
+				moderateBatch()
+			}
+
+			go func() {
+				defer func() { <-sem }()
+				synthesize(chunkCtx, chunk, sentence)
+			}()
+
+			return true
+		}
+
+		for {
+			n, err := stream.Read(buf)
+			if n > 0 {
+				var sentences []string
+				sentences, pending = splitSentences(pending + string(buf[:n]))
+
+				for _, sentence := range sentences {
+					// A dont_say or replace_words phrase may span the sentence boundary so
+					// the sentence is held back and filtered together with the next one.
+					sentence = held + sentence
+					held = ""
+					if endsWithBoundary(sentence, boundaries) {
+						held = sentence
+						continue
+					}
+
+					if !speak(sentence) {
+						return
+					}
+				}
+			}
+
+			if err == io.EOF {
+				if u, ok := stream.(llm.UsageReader); ok {
+					tokensPrompt, tokensResponse = u.Usage()
+				}
+				break
+			}
+
+			if err != nil {
+				// A stream canceled after a moderated sentence ended early, it did not fail.
+				if streamCtx.Err() == nil {
+					logCtx.Error("unable to read chat stream", fid, "error", err)
+					streamErr = err
+				}
+				return
+			}
+		}
+
+		speak(held + pending)
+	}()
+
+	approved := make(chan *audioChunk, 8)
+	stored := make(chan struct{})
+
+	var (
+		res       *Result
+		resultErr error
+	)
+
+	// Decide the response sentences in order and store the session entry and charge once the full text is known.
+	// A sentence that fails moderation is replaced by the moderation response and ends the response.
+	go func() {
+		defer close(stored)
+		defer cancelStream()
+
+		var text strings.Builder
+		verdict := &characters.AssistantModeration{Action: characters.ModerationPassed}
+
+		substituted := false
+
+		for chunk := range chunks {
+			if substituted {
+				chunk.cancel()
+				continue
+			}
+
+			<-chunk.batch.moderated
+
+			m := chunk.batch.moderation
+			if verdict.Moderation == nil || m.Analysis.AssessmentAge >= verdict.Moderation.Analysis.AssessmentAge || !chunk.batch.allowed {
+				verdict.Moderation = m
+			}
+
+			sentence := chunk.text
+
+			if !chunk.batch.allowed {
+				logCtx.Warn("assistant sentence failed moderation", fid, "assessment_age", m.Analysis.AssessmentAge, "response_age", profile.ResponseAge)
+				verdict.Action = characters.ModerationSubstituted
+				substituted = true
+
+				chunk.cancel()
+				cancelStream()
+
+				sentence = moderationResponseText(profile, &p.localize)
+				chunk = newAudioChunk(sentence, func() {})
+				go synthesize(pipelineCtx, chunk, sentence)
+			}
+
+			if text.Len() > 0 {
+				text.WriteString(" ")
+			}
+			text.WriteString(sentence)
+
+			approved <- chunk
+		}
+
+		close(approved)
+
+		switch {
+		case streamErr != nil:
+			resultErr = streamErr
+			return
+		case text.Len() == 0:
+			logCtx.Error("empty chat response", fid)
+			resultErr = errors.New("empty chat response")
+			return
+		}
+
+		res, resultErr = addPipelineSessionEntry(genCtx, logCtx, profile, p, userPrompt, audioID, text.String(), verdict, tokensPrompt, tokensResponse, t)
+		if resultErr != nil {
+			return
+		}
+
+		if resultErr = charge(genCtx, res.SessionID); resultErr != nil {
+			return
+		}
+
+		logTTTCost(logCtx, p, res)
+		logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "sts_pipeline")
+	}()
+
+	r, w := io.Pipe()
+
+	// Stitch the approved sentence audio in order. The stream ends once the session entry is stored.
+	go func() {
+		defer cancel()
+
+		var audio bytes.Buffer
+		out := io.MultiWriter(w, &audio)
+
+		var ogg *opus.OggPCMWriter
+		defer func() {
+			if ogg != nil {
+				ogg.Close()
+			}
+		}()
+
+		fail := func(err error) {
+			logCtx.Error("unable to stream sentence audio", fid, "error", err)
+			w.CloseWithError(err)
+			cancel()
+			for chunk := range approved {
+				chunk.cancel()
+			}
+		}
+
+		switch {
+		case wavSampleRate > 0:
+			if err := common.WriteWAVHeader(out, wavSampleRate); err != nil {
+				fail(err)
+				return
+			}
+		case oggOpus:
+			o, err := opus.NewOggPCMWriter(out, rand.Uint32())
+			if err != nil {
+				fail(err)
+				return
+			}
+			ogg, out = o, o
+		}
+
+		for chunk := range approved {
+			_, err := io.Copy(out, chunk)
+			chunk.cancel()
+
+			if err != nil {
+				fail(err)
+				return
+			}
+		}
+
+		if ogg != nil {
+			err := ogg.Close()
+			ogg = nil
+			if err != nil {
+				fail(err)
+				return
+			}
+		}
+
+		<-stored
+
+		if resultErr != nil {
+			w.CloseWithError(resultErr)
+			return
+		}
+
+		w.Close()
+
+		if format == "opus_16000" {
+			return
+		}
+
+		storePipelineAudio(genCtx, logCtx, profile, p, res, fileExt, contentType, &audio)
+	}()
+
+	return pipelineReader{PipeReader: r, stored: stored}, contentType, nil
+}
+
+// pipelineReader is the stitched response audio. Closing it early stops the audio but waits until the session entry
+// is stored and charged, so a canceled turn still has its entry.
+type pipelineReader struct {
+	*io.PipeReader
+	stored <-chan struct{}
+}
+
+func (r pipelineReader) Close() error {
+	err := r.PipeReader.Close()
+	<-r.stored
+	return err
+}
+
+// addPipelineSessionEntry stores the response with the usage its stream reported. The tokens are counted locally
+// when the provider does not report the usage of streams or the stream ended early.
+func addPipelineSessionEntry(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, p *tttPrompt, userPrompt, audioID, text string, verdict *characters.AssistantModeration, tokensPrompt, tokensResponse int, t time.Time) (*Result, error) {
+	fid := slog.String("fid", "vox.characters.play.addPipelineSessionEntry")
+
+	if tokensPrompt == 0 {
+		tokensPrompt = p.numTokens
+	}
+
+	if tokensResponse == 0 {
+		tokensResponse = p.provider.CountTokens([]llm.Message{{Role: "assistant", Content: text}})
+	}
+
+	sessionEntry := characters.SessionEntry{
+		Assistant:           text,
+		AssistantModeration: verdict,
+		Mode:                p.mode,
+		Timestamp:           t,
+		TokensPrompt:        tokensPrompt,
+		TokensResponse:      tokensResponse,
+		User:                userPrompt,
+	}
+
+	sessionID, err := characters.AddSessionEntry(ctx, logCtx, profile.ID, p.character.Character, audioID, p.session, sessionEntry)
+	if err != nil {
+		logCtx.Error("unable to add session entry", fid, "error", err)
+		return nil, err
+	}
+
+	return &Result{
+		Moderation:     verdict.Moderation,
+		Response:       text,
+		Predefined:     p.session.LastUserAudio[audioID].Predefined,
+		SessionID:      sessionID,
+		TokensPrompt:   tokensPrompt,
+		TokensResponse: tokensResponse,
+	}, nil
+}
+
+func storePipelineAudio(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, p *tttPrompt, res *Result, fileExt, contentType string, audio io.Reader) {
+	fid := slog.String("fid", "vox.characters.play.storePipelineAudio")
+
+	account := ctx.Value(common.AccountKey).(accounts.Document)
+
+	sessionIDStr := fmt.Sprintf("%06d", res.SessionID)
+	archive := p.session.Archive.Format(time.DateOnly)
+
+	path := sessionIDStr + "-assistant." + fileExt
+	if res.Predefined {
+		path = sessionIDStr + "-predefined-assistant." + fileExt
+	}
+
+	gcsPath := filepath.Join("accounts", account.ID, "profiles", profile.ID, "characters", p.character.Character, "archives", archive, path)
+
+	if err := objectstore.Client.Upload(ctx, audio, firebase.GCSBucket, gcsPath, contentType); err != nil {
+		logCtx.Error("unable to store assistant audio file", fid, "error", err)
+		return
+	}
+
+	fsPath := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profile.ID, p.character.Character)
+	collection := docstore.Client.Collection(fsPath)
+	if collection == nil {
+		logCtx.Error("memory collection not found", fid)
+		return
+	}
+
+	entries := "entries"
+	if res.Predefined {
+		entries = "predefined_entries"
+	}
+
+	updates := []docstore.Update{
+		{Path: fmt.Sprintf("%s.%s.assistant_audio.%s", entries, sessionIDStr, fileExt), Value: gcsPath},
+	}
+
+	if err := collection.Doc("latest").Update(ctx, updates); err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Warn("unable to update lastest document", fid, "error", err)
+	}
+}
+
+// pipelineFormat returns the format each sentence is synthesized in. WAV is streamed as PCM behind
+// one header and Ogg Opus is encoded from PCM into one stream, other formats concatenate as is.
+func pipelineFormat(format string) (string, int, bool, error) {
+	switch {
+	case format == "ogg_opus_16000":
+		return "pcm_16000", 0, true, nil
+	case strings.HasPrefix(format, "wav_"):
+		rate, err := strconv.Atoi(strings.TrimPrefix(format, "wav_"))
+		if err != nil {
+			return "", 0, false, err
+		}
+		return fmt.Sprintf("pcm_%d", rate), rate, false, nil
+	}
+
+	return format, 0, false, nil
+}
+
+// filterBoundaries returns the lowercased beginnings of the profile dont_say and replace_words
+// phrases that end at a sentence boundary, so a phrase split across two sentences can be detected.
+func filterBoundaries(profile *profiles.Document) []string {
+	phrases := append([]string{}, profile.DontSay...)
+	for _, v := range profile.ReplaceWords {
+		phrases = append(phrases, v...)
+	}
+
+	boundaries := []string{}
+	for _, phrase := range phrases {
+		phrase = strings.ToLower(phrase)
+		for i, r := range phrase {
+			cjk := strings.ContainsRune(cjkSentenceTerminators, r)
+			if !cjk && !strings.ContainsRune(sentenceTerminators, r) {
+				continue
+			}
+
+			end := i + utf8.RuneLen(r)
+			if end >= len(phrase) {
+				break
+			}
+
+			next, _ := utf8.DecodeRuneInString(phrase[end:])
+			if cjk || unicode.IsSpace(next) {
+				boundaries = append(boundaries, phrase[:end])
+			}
+		}
+	}
+
+	return boundaries
+}
+
+// endsWithBoundary reports whether the sentence ends with the beginning of a filtered phrase.
+func endsWithBoundary(sentence string, boundaries []string) bool {
+	if len(boundaries) == 0 {
+		return false
+	}
+
+	sentence = strings.ToLower(strings.TrimSpace(sentence))
+	for _, b := range boundaries {
+		if strings.HasSuffix(sentence, b) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitSentences returns the complete sentences of text and the remaining partial sentence.
+// A terminator only ends a sentence once the following character is known to be a space,
+// so decimals and ellipses that are still streaming are not split.
+func splitSentences(text string) ([]string, string) {
+	sentences := []string{}
+	start := 0
+
+	for i, r := range text {
+		cjk := strings.ContainsRune(cjkSentenceTerminators, r)
+		if !cjk && !strings.ContainsRune(sentenceTerminators, r) {
+			continue
+		}
+
+		end := i + utf8.RuneLen(r)
+		if end >= len(text) {
+			break
+		}
+
+		next, _ := utf8.DecodeRuneInString(text[end:])
+		if !cjk && !unicode.IsSpace(next) {
+			continue
+		}
+
+		if utf8.RuneCountInString(strings.TrimSpace(text[start:end])) < minSentenceLength {
+			continue
+		}
+
+		sentences = append(sentences, text[start:end])
+		start = end
+	}
+
+	return sentences, text[start:]
+}
@@ -0,0 +1,195 @@
+package play
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"disruptive/lib/common"
+	"disruptive/lib/configs"
+	"disruptive/pkg/vox/moderate"
+	"disruptive/pkg/vox/profiles"
+)
+
+// softenPrompt asks for a gentle response to a message that the moderation policy softens.
+const softenPrompt = "The last message touched on a sensitive subject. Respond gently and briefly in a way that is suitable " +
+	"for a %d year old child, and steer the conversation towards a positive topic."
+
+// crisisRule escalates self-harm in the user input whatever the profile's moderation policy, so the guardian is always alerted.
+// It is checked before the policy, so profile and preset rules on self-harm only decide assistant responses.
+var crisisRule = configs.ModerationRule{
+	Name:       "self_harm_crisis",
+	Action:     configs.ModerationEscalate,
+	Categories: []string{"self-harm", "self-harm/intent", "self-harm/instructions"},
+}
+
+// aboveAgeRule blocks content rated above the profile's response age when no policy decides otherwise.
+var aboveAgeRule = configs.ModerationRule{Name: "above_response_age", Action: configs.ModerationBlock, AboveResponseAge: true}
+
+// applyPolicy evaluates the profile's moderation policy and records the action and rule on the moderation.
+// The first matching profile rule decides. Otherwise the most severe action of the rules from the profile's
+// notification settings, its preset and the response age applies.
+func applyPolicy(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, m *moderate.Response) {
+	m.Analysis.NotAgeAppropriate = aboveResponseAge(profile, m)
+	m.Action, m.Rule = configs.ModerationAllow, ""
+
+	var preset string
+	if profile.ModerationPolicy != nil {
+		preset = profile.ModerationPolicy.Preset
+
+		for _, r := range profile.ModerationPolicy.Rules {
+			if matchRule(r, m) {
+				m.Action, m.Rule = r.Action, r.Name
+				m.Triggered = blocked(m.Action)
+				return
+			}
+		}
+	}
+
+	rules := notificationRules(profile)
+	rules = append(rules, presetRules(ctx, logCtx, preset, profile.ResponseAge)...)
+	rules = append(rules, aboveAgeRule)
+
+	for _, r := range rules {
+		if matchRule(r, m) && configs.ModerationSeverity(r.Action) > configs.ModerationSeverity(m.Action) {
+			m.Action, m.Rule = r.Action, r.Name
+		}
+	}
+
+	m.Triggered = blocked(m.Action)
+}
+
+// applyCrisisRule escalates self-harm in the user input and reports whether it matched. Otherwise the moderation is allowed.
+func applyCrisisRule(m *moderate.Response) bool {
+	if !matchRule(crisisRule, m) {
+		m.Action, m.Rule = configs.ModerationAllow, ""
+		m.Triggered = false
+		return false
+	}
+
+	m.Action, m.Rule = crisisRule.Action, crisisRule.Name
+	m.Triggered = true
+	return true
+}
+
+// blocked reports whether an action replaces the response with the moderation response.
+func blocked(action string) bool {
+	return action == configs.ModerationBlock || action == configs.ModerationEscalate
+}
+
+func presetRules(ctx context.Context, logCtx *slog.Logger, name string, age int) []configs.ModerationRule {
+	fid := slog.String("fid", "vox.characters.play.presetRules")
+
+	policies, err := configs.GetModerationPolicies(ctx, logCtx)
+	if err != nil {
+		if !errors.Is(err, common.ErrNotFound{}) {
+			logCtx.Warn("unable to get moderation policies", fid, "error", err)
+		}
+		return nil
+	}
+
+	preset, ok := policies.Preset(name, age)
+	if !ok {
+		logCtx.Warn("moderation preset not found", fid, "preset", name, "age", age)
+		return nil
+	}
+
+	return preset.Rules
+}
+
+// notificationRules blocks the categories the profile is notified about.
+func notificationRules(profile *profiles.Document) []configs.ModerationRule {
+	n := profile.Notifications
+
+	flags := []struct {
+		on       bool
+		category string
+	}{
+		{n.Moderations.Hate, "hate"},
+		{n.Moderations.HateThreatening, "hate/threatening"},
+		{n.Moderations.Harassment, "harassment"},
+		{n.Moderations.HarassmentThreatening, "harassment/threatening"},
+		{n.Moderations.Violence, "violence"},
+		{n.Moderations.ViolenceGraphic, "violence/graphic"},
+		{n.Moderations.Sexual, "sexual"},
+		{n.Moderations.SexualMinors, "sexual/minors"},
+		{n.Moderations.Selfharm, "self-harm"},
+		{n.Moderations.SelfharmIntent, "self-harm/intent"},
+		{n.Moderations.SelfharmInstructions, "self-harm/instructions"},
+	}
+
+	categories := []string{}
+	for _, f := range flags {
+		if f.on {
+			categories = append(categories, f.category)
+		}
+	}
+
+	rules := []configs.ModerationRule{}
+
+	if len(categories) > 0 {
+		rules = append(rules, configs.ModerationRule{Name: "notifications", Action: configs.ModerationBlock, Categories: categories})
+	}
+
+	if n.TextAnalysis.Toxic {
+		rules = append(rules, configs.ModerationRule{Name: "notifications_toxic", Action: configs.ModerationBlock, Toxic: true})
+	}
+
+	return rules
+}
+
+// matchRule reports whether all of the rule's set conditions match the moderation.
+func matchRule(r configs.ModerationRule, m *moderate.Response) bool {
+	if r.AboveResponseAge && !m.Analysis.NotAgeAppropriate {
+		return false
+	}
+
+	if r.Toxic && !m.Analysis.Toxic {
+		return false
+	}
+
+	if r.MinAssessmentAge > 0 && m.Analysis.AssessmentAge < r.MinAssessmentAge {
+		return false
+	}
+
+	if len(r.Categories) > 0 && !slices.ContainsFunc(r.Categories, func(c string) bool { return m.Categories[c] }) {
+		return false
+	}
+
+	if len(r.Entities) > 0 && !matchAny(r.Entities, m.Analysis.Entities...) {
+		return false
+	}
+
+	if len(r.Intents) > 0 && !matchAny(r.Intents, m.Analysis.Intent) {
+		return false
+	}
+
+	if len(r.Sentiments) > 0 && !matchAny(r.Sentiments, m.Analysis.Sentiment, m.Analysis.Emotion) {
+		return false
+	}
+
+	if len(r.SubjectCategories) > 0 && !matchAny(r.SubjectCategories, m.Analysis.SubjectCategory, m.Analysis.Topic) {
+		return false
+	}
+
+	return true
+}
+
+func matchAny(values []string, fields ...string) bool {
+	for _, v := range values {
+		for _, f := range fields {
+			if strings.EqualFold(v, f) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// aboveResponseAge reports whether the content is rated above the profile's response age.
+func aboveResponseAge(profile *profiles.Document, m *moderate.Response) bool {
+	return profile.ResponseAge < min(m.Analysis.AssessmentAge, ratingsToAge[m.Analysis.MovieRating], ratingsToAge[m.Analysis.TVRating], ratingsToAge[m.Analysis.ESRBRating], m.Analysis.PEGIRating)
+}
@@ -5,14 +5,13 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"path/filepath"
 	"strings"
 
-	fs "cloud.google.com/go/firestore"
-
 	"disruptive/config"
 	"disruptive/lib/common"
 	"disruptive/lib/configs"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 	"disruptive/pkg/vox/accounts"
 	"disruptive/pkg/vox/characters"
 	"disruptive/pkg/vox/notifications"
@@ -61,7 +60,8 @@ func GetSTSText(ctx context.Context, logCtx *slog.Logger, profileID, characterVe
 }
 
 // GetSTSAudio retrieves last_user_audio and returns audio.
-func GetSTSAudio(ctx context.Context, logCtx *slog.Logger, profileID, characterVersion, format, tttModel, ttsModel, optimizingStreamLatency, audioID string) (io.ReadCloser, string, error) {
+// In pipeline mode the LLM response is streamed into TTS one sentence at a time.
+func GetSTSAudio(ctx context.Context, logCtx *slog.Logger, profileID, characterVersion, format, tttModel, ttsModel, optimizingStreamLatency, audioID string, pipeline bool) (io.ReadCloser, string, error) {
 	fid := slog.String("fid", "vox.characters.play.GetSTSAudio")
 
 	account := ctx.Value(common.AccountKey).(accounts.Document)
@@ -92,6 +92,16 @@ func GetSTSAudio(ctx context.Context, logCtx *slog.Logger, profileID, characterV
 		return ttsResult, cType, nil
 	}
 
+	if audioID == "2" {
+		ttsResult, cType, err := processCrisisResponse(ctx, logCtx, &profile, characterVersion, format)
+		if err != nil {
+			logCtx.Error("unable to get text-to-speech response", fid, "error", err)
+			return nil, "", err
+		}
+
+		return ttsResult, cType, nil
+	}
+
 	characterName := strings.Split(characterVersion, "_")[0]
 
 	s, err := characters.GetSession(ctx, logCtx, profileID, characterName)
@@ -107,12 +117,62 @@ func GetSTSAudio(ctx context.Context, logCtx *slog.Logger, profileID, characterV
 		profile.Characters[characterName] = characterPref
 	}
 
-	var (
-		tttResult *Result
-	)
+	// A turn the client did not moderate is moderated here, so a crisis is escalated whatever the client or profile asks for.
+	if sessionID == 0 && !s.LastUserAudio[audioID].Predefined {
+		m := s.LastUserAudio[audioID].Moderation
+		if m == nil {
+			if m, err = GetSTSModeration(ctx, logCtx, &profile, characterVersion, audioID); err != nil {
+				logCtx.Warn("unable to get moderation", fid, "error", err)
+			}
+		}
+
+		if m.Action == configs.ModerationEscalate {
+			return processCrisisResponse(ctx, logCtx, &profile, characterVersion, format)
+		}
+
+		if m.Triggered {
+			return processModerationResponse(ctx, logCtx, &profile, characterVersion, format)
+		}
+	}
+
+	// charge charges the account for the response stored with the session ID.
+	charge := func(ctx context.Context, sessionID int) error {
+		if account.DisableBank {
+			return nil
+		}
+
+		c, err := characters.GetCharacter(ctx, logCtx, characterVersion, profile.Characters[characterName].Language)
+		if err != nil {
+			logCtx.Error("unable to get character", fid, "error", err)
+			return err
+		}
+
+		tier := c.Modes[s.LastUserAudio[audioID].Mode].Tier
+		if tier == "" {
+			tier = "tier-free"
+		}
+
+		if _, err := accounts.ChargeBank(ctx, logCtx, characterVersion, tier, sessionID, audioID); err != nil {
+			logCtx.Warn("unable to charge account", fid, "error", err)
+			return err
+		}
+
+		return nil
+	}
+
+	// The pipeline stores the session entry once the response is complete and charges it then.
+	if sessionID == 0 && pipeline {
+		ttsReader, cType, err := pipelineSTS(ctx, logCtx, &profile, characterVersion, format, tttModel, ttsModel, optimizingStreamLatency, s.LastUserAudio[audioID].Text, audioID, charge)
+		if err != nil {
+			logCtx.Error("unable to get speech-to-speech pipeline response", fid, "error", err)
+			return nil, "", err
+		}
+
+		return ttsReader, cType, nil
+	}
 
 	if sessionID == 0 {
-		tttResult, err = TTT(ctx, logCtx, &profile, characterVersion, tttModel, s.LastUserAudio[audioID].Text, audioID)
+		tttResult, err := TTT(ctx, logCtx, &profile, characterVersion, tttModel, s.LastUserAudio[audioID].Text, audioID)
 		if err != nil {
 			logCtx.Error("unable to get text-to-text response", fid, "error", err)
 			return nil, "", err
@@ -127,24 +187,7 @@ func GetSTSAudio(ctx context.Context, logCtx *slog.Logger, profileID, characterV
 		return nil, "", err
 	}
 
-	if account.DisableBank {
-		return ttsReader, cType, nil
-	}
-
-	c, err := characters.GetCharacter(ctx, logCtx, characterVersion, profile.Characters[characterName].Language)
-	if err != nil {
-		logCtx.Error("unable to get character", fid, "error", err)
-		return nil, "", err
-	}
-
-	tier := c.Modes[s.LastUserAudio[audioID].Mode].Tier
-	if tier == "" {
-		tier = "tier-free"
-	}
-
-	_, err = accounts.ChargeBank(ctx, logCtx, characterVersion, tier)
-	if err != nil {
-		logCtx.Warn("unable to charge account", fid, "error", err)
+	if err := charge(ctx, sessionID); err != nil {
 		return ttsReader, cType, err
 	}
 
@@ -192,11 +235,29 @@ func GetSTSModerationResponseText(ctx context.Context, logCtx *slog.Logger, prof
 		return characters.SessionEntry{}, err
 	}
 
-	if profile.ResponseAge > 12 {
-		return characters.SessionEntry{Assistant: localize.Character["moderation_response_1"]}, nil
+	return characters.SessionEntry{Assistant: moderationResponseText(&profile, &localize)}, nil
+}
+
+// GetSTSCrisisResponseText retrieves the predefined crisis response.
+func GetSTSCrisisResponseText(ctx context.Context, logCtx *slog.Logger, profileID, characterVersion string) (characters.SessionEntry, error) {
+	fid := slog.String("fid", "vox.characters.play.GetSTSCrisisResponseText")
+
+	profile, err := profiles.GetByID(ctx, logCtx, profileID)
+	if err != nil {
+		logCtx.Error("unable to get profile", fid, "error", err)
+		return characters.SessionEntry{}, err
 	}
 
-	return characters.SessionEntry{Assistant: localize.Character["moderation_response_2"]}, nil
+	characterName := strings.Split(characterVersion, "_")[0]
+	profileCharacter := profile.Characters[characterName]
+
+	localize, err := configs.GetLocalization(ctx, logCtx, strings.Split(characterVersion, "_")[1], profileCharacter.Language)
+	if err != nil {
+		logCtx.Error("unable to get character localize configs", fid, "error", err)
+		return characters.SessionEntry{}, err
+	}
+
+	return characters.SessionEntry{Assistant: crisisResponseText(&profile, &localize)}, nil
 }
 
 // CloseSTS finalizes the STS process. Updates the session entry with last_user_audio values, and sends moderation email if necessary.
@@ -255,24 +316,40 @@ func CloseSTS(ctx context.Context, logCtx *slog.Logger, profile *profiles.Docume
 
 	resp := CloseResponse{}
 
-	if profile.Moderate && session.LastUserAudio[audioID].Moderation != nil && session.LastUserAudio[audioID].Moderation.Triggered {
+	moderation := session.LastUserAudio[audioID].Moderation
+
+	modText := moderationResponseText(profile, &localize)
+	if moderation != nil && moderation.Action == configs.ModerationEscalate {
+		modText = crisisResponseText(profile, &localize)
+	}
+
+	if profile.Moderate && moderation != nil && (moderation.Triggered || moderation.Action == configs.ModerationNotify) {
 		account := ctx.Value(common.AccountKey).(accounts.Document)
 
 		path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profile.ID, character.Character)
-		collection := firestore.Client.Collection(path)
+		collection := docstore.Client.Collection(path)
 		if collection == nil {
 			logCtx.Error("memory collection not found", fid)
 			return CloseResponse{}, common.ErrNotFound{}
 		}
 
-		modText := localize.Character["moderation_response_2"]
-		if profile.ResponseAge > 12 {
-			modText = localize.Character["moderation_response_1"]
+		entry := session.Entries[sessionIDStr]
+
+		// Moderated before a response, the user input is only in last_user_audio.
+		if sessionID == 0 {
+			lastUserAudio := session.LastUserAudio[audioID]
+			entry.User = lastUserAudio.Text
+			entry.Moderation = lastUserAudio.Moderation
+			entry.Timestamp = lastUserAudio.Timestamp
+
+			if lastUserAudio.Path != "" {
+				entry.UserAudio = map[string]string{strings.TrimPrefix(filepath.Ext(lastUserAudio.Path), "."): lastUserAudio.Path}
+			}
 		}
 
-		m := session.Entries[sessionIDStr]
-		m.Assistant = modText
-		session.Entries[sessionIDStr] = m
+		if moderation.Triggered {
+			entry.Assistant = modText
+		}
 
 		req := notifications.ModerationValue{
 			Profile: notifications.ModerationProfileValue{
@@ -284,7 +361,7 @@ func CloseSTS(ctx context.Context, logCtx *slog.Logger, profile *profiles.Docume
 			},
 			Session: &notifications.ModerationSessionValue{
 				Archive:     session.Archive,
-				Entry:       session.Entries[sessionIDStr],
+				Entry:       entry,
 				EntryNumber: sessionID,
 			},
 		}
@@ -296,13 +373,16 @@ func CloseSTS(ctx context.Context, logCtx *slog.Logger, profile *profiles.Docume
 		}
 
 		if sessionID != 0 {
-			updates := []fs.Update{
+			updates := []docstore.Update{
 				{Path: fmt.Sprintf("entries.%s.notification_id", sessionIDStr), Value: doc.ID},
-				{Path: fmt.Sprintf("entries.%s.assistant", sessionIDStr), Value: modText},
 				{Path: fmt.Sprintf("last_user_audio.%s.notification_id", audioID), Value: doc.ID},
 			}
 
-			if _, err := collection.Doc("latest").Update(ctx, updates); err != nil {
+			if moderation.Triggered {
+				updates = append(updates, docstore.Update{Path: fmt.Sprintf("entries.%s.assistant", sessionIDStr), Value: modText})
+			}
+
+			if err := collection.Doc("latest").Update(ctx, updates); err != nil {
 				err = common.ConvertGRPCError(err)
 				logCtx.Error("unable to update latest document", fid, "error", err)
 				return CloseResponse{}, err
@@ -311,16 +391,22 @@ func CloseSTS(ctx context.Context, logCtx *slog.Logger, profile *profiles.Docume
 
 		resp.NotificationID = doc.ID
 
-		if len(profile.Notifications.Emails) < 1 {
-			return resp, nil
-		}
+		// Escalations already emailed the guardian.
+		if moderation.Action != configs.ModerationEscalate && len(profile.Notifications.Emails) > 0 {
+			lastUserAudio := session.LastUserAudio[audioID]
+			if err := notifications.SendModerationEmail(ctx, logCtx, profile, character.Character, &lastUserAudio, sessionID, &localize); err != nil {
+				logCtx.Warn("unable to create email html", fid, "error", err)
+			}
 
-		lastUserAudio := session.LastUserAudio[audioID]
-		if err := notifications.SendModerationEmail(ctx, logCtx, profile, character.Character, &lastUserAudio, sessionID, &localize); err != nil {
-			logCtx.Warn("unable to create email html", fid, "error", err)
+			resp.ModerationEmailSent = true
 		}
+	}
 
-		resp.ModerationEmailSent = true
+	// GetSTSModeration queued the review, it gets the session entry and notification once they are known.
+	if moderation != nil && moderation.Triggered {
+		if err := notifications.UpdateReviewEntry(ctx, logCtx, audioID, resp.NotificationID, modText, sessionID); err != nil {
+			logCtx.Warn("unable to update moderation review", fid, "error", err)
+		}
 	}
 
 	return resp, nil
@@ -349,3 +435,15 @@ func processModerationResponse(ctx context.Context, logCtx *slog.Logger, profile
 
 	return ttsReader, cType, nil
 }
+
+func processCrisisResponse(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, characterVersion, format string) (io.ReadCloser, string, error) {
+	fid := slog.String("fid", "vox.characters.play.processCrisisResponse")
+
+	ttsReader, cType, err := TTSCrisisResponse(ctx, logCtx, profile, characterVersion, format)
+	if err != nil {
+		logCtx.Error("unable to get text-to-speech crisis response", fid, "error", err)
+		return nil, "", err
+	}
+
+	return ttsReader, cType, nil
+}
@@ -7,18 +7,19 @@ import (
 	"time"
 
 	"disruptive/lib/common"
-	"disruptive/lib/deepgram"
 	"disruptive/lib/firebase"
-	"disruptive/lib/gcp"
+	"disruptive/lib/objectstore"
+	"disruptive/lib/stt"
 )
 
 // STTResponse contains the response structure.
 type STTResponse struct {
 	AudioID string `json:"audio_id"`
 	Text    string `json:"text"`
+	Engine  string `json:"engine,omitempty"`
 }
 
-// STT retrieves audio and returns transcribed text.
+// STT retrieves audio and returns transcribed text. The audio is archived even when the transcription fails.
 func STT(ctx context.Context, logCtx *slog.Logger, extension, language, version, gcsPath string, r io.Reader) (STTResponse, string, error) {
 	fid := slog.String("fid", "vox.characters.play.STT")
 	t := time.Now()
@@ -29,6 +30,7 @@ func STT(ctx context.Context, logCtx *slog.Logger, extension, language, version,
 		contentType      string
 		detectedLanguage string
 		ok               bool
+		sttErr           error
 	)
 
 	contentType, ok = common.AudioExtensionContentTypes[extension]
@@ -43,26 +45,28 @@ func STT(ctx context.Context, logCtx *slog.Logger, extension, language, version,
 	go func() {
 		defer wPipe.Close()
 
-		var (
-			err  error
-			text string
-		)
-
-		text, detectedLanguage, err = deepgram.PostTranscriptionsText(ctx, logCtx, teeReader, language, version)
-
+		sttRes, err := stt.Transcribe(ctx, logCtx, teeReader, extension, language, version)
 		if err != nil {
 			logCtx.Error("unable to create transcription", fid, "error", err)
+			sttErr = err
+			io.Copy(io.Discard, teeReader)
 			return
 		}
 
-		res.Text = text
+		res.Text = sttRes.Text
+		res.Engine = sttRes.Engine
+		detectedLanguage = sttRes.DetectedLanguage
 	}()
 
-	if err := gcp.Storage.Upload(ctx, rPipe, firebase.GCSBucket, gcsPath, contentType); err != nil {
+	if err := objectstore.Client.Upload(ctx, rPipe, firebase.GCSBucket, gcsPath, contentType); err != nil {
 		logCtx.Warn("unable to upload user audio", fid, "error", err)
 		return res, "", err
 	}
 
+	if sttErr != nil {
+		return res, "", sttErr
+	}
+
 	logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "stt")
 
 	return res, detectedLanguage, nil
@@ -7,15 +7,14 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
 	"disruptive/lib/common"
 	"disruptive/lib/configs"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 	"disruptive/pkg/vox/accounts"
 	"disruptive/pkg/vox/characters"
 	"disruptive/pkg/vox/profiles"
-
-	fs "cloud.google.com/go/firestore"
-	"github.com/google/uuid"
 )
 
 // STSText is a structure for text.
@@ -39,7 +38,7 @@ func PostUserText(ctx context.Context, logCtx *slog.Logger, profile *profiles.Do
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profile.ID, characterName)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("memory collection not found", fid)
 		return characters.UserAudio{}, common.ErrNotFound{}
@@ -103,6 +102,10 @@ func PostUserText(ctx context.Context, logCtx *slog.Logger, profile *profiles.Do
 		}
 	}
 
+	if err := useInteraction(ctx, logCtx, profile, 0); err != nil {
+		return characters.UserAudio{}, err
+	}
+
 	if s.LastUserAudio == nil {
 		s.Archive = now
 		s.LastUserAudio = map[string]characters.UserAudio{}
@@ -114,17 +117,17 @@ func PostUserText(ctx context.Context, logCtx *slog.Logger, profile *profiles.Do
 			s.StartEntry = 1
 		}
 
-		if _, err := collection.Doc("latest").Set(ctx, s); err != nil {
+		if err := collection.Doc("latest").Set(ctx, s); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to set latest document", fid, "error", err)
 			return characters.UserAudio{}, err
 		}
 	} else {
-		updates := []fs.Update{
+		updates := []docstore.Update{
 			{Path: "last_user_audio." + audioID, Value: s.LastUserAudio[audioID]},
 		}
 
-		if _, err := collection.Doc("latest").Update(ctx, updates); err != nil {
+		if err := collection.Doc("latest").Update(ctx, updates); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to update lastest document", fid, "error", err)
 			return characters.UserAudio{}, err
@@ -133,3 +136,21 @@ func PostUserText(ctx context.Context, logCtx *slog.Logger, profile *profiles.Do
 
 	return userAudio, nil
 }
+
+// useInteraction enforces the account and profile usage limits and records the interaction.
+func useInteraction(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, seconds float64) error {
+	return accounts.UseInteraction(ctx, logCtx, profile.ID, usageLimits(profile), seconds)
+}
+
+// checkUsage enforces the account and profile usage limits before speech is transcribed, without recording it.
+func checkUsage(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document) error {
+	return accounts.CheckUsage(ctx, logCtx, profile.ID, usageLimits(profile))
+}
+
+func usageLimits(profile *profiles.Document) accounts.UsageLimits {
+	if profile.UsageLimits == nil {
+		return accounts.UsageLimits{}
+	}
+
+	return *profile.UsageLimits
+}
@@ -10,15 +10,13 @@ import (
 	"strings"
 	"time"
 
-	fs "cloud.google.com/go/firestore"
-	"cloud.google.com/go/storage"
-
 	"disruptive/lib/common"
 	"disruptive/lib/configs"
+	"disruptive/lib/docstore"
 	"disruptive/lib/elevenlabs"
 	"disruptive/lib/firebase"
-	"disruptive/lib/firestore"
-	"disruptive/lib/gcp"
+	"disruptive/lib/objectstore"
+	"disruptive/lib/tts"
 	"disruptive/pkg/vox/accounts"
 	"disruptive/pkg/vox/characters"
 	"disruptive/pkg/vox/profiles"
@@ -84,9 +82,9 @@ func TTS(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, c
 	)
 
 	if e.AssistantAudio[fileExt] != "" {
-		rc, cType, err = gcp.Storage.Download(ctx, firebase.GCSBucket, e.AssistantAudio[fileExt])
+		rc, cType, err = objectstore.Client.Download(ctx, firebase.GCSBucket, e.AssistantAudio[fileExt])
 		if err != nil {
-			if !errors.Is(err, storage.ErrObjectNotExist) {
+			if !errors.Is(err, objectstore.ErrObjectNotExist) {
 				logCtx.Error("unable to download assistant audio file", fid, "error", err)
 				return nil, "", err
 			}
@@ -96,22 +94,18 @@ func TTS(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, c
 	}
 
 	if e.AssistantAudio[fileExt] == "" || regen {
-		v := elevenlabs.Voices[c.Voices[profileCharacter.Voice]]
-		req := elevenlabs.Request{
+		req := tts.Request{
 			Format:                   format,
 			Language:                 profileCharacter.Language,
 			Model:                    ttsModel,
 			OptimizeStreamingLatency: optimizingStreamLatency,
-			Voice:                    v.ID,
+			Voice:                    c.Voices[profileCharacter.Voice],
 			Text:                     e.Assistant,
-			SimilarityBoost:          v.SimilarityBoost,
-			Stability:                v.Stability,
-			StyleExaggeration:        v.StyleExaggeration,
 		}
 
-		r, err := elevenlabs.TTSStream(ctx, logCtx, req)
+		r, err := tts.Stream(ctx, logCtx, c.Engine, req)
 		if err != nil {
-			logCtx.Error("unable to get elevenlabs tts stream", fid, "error", err)
+			logCtx.Error("unable to get tts stream", fid, "engine", c.Engine, "error", err)
 			return nil, "", err
 		}
 
@@ -122,7 +116,7 @@ func TTS(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, c
 		}
 
 		if format == "opus_16000" {
-			return io.NopCloser(r), contentType, nil
+			return r, contentType, nil
 		}
 
 		rPipe, wPipe := io.Pipe()
@@ -144,30 +138,30 @@ func TTS(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, c
 
 			gcsPath := filepath.Join("accounts", account.ID, "profiles", profile.ID, "characters", c.Character, "archives", archive, path)
 
-			if err := gcp.Storage.Upload(ctx, teeReader, firebase.GCSBucket, gcsPath, contentType); err != nil {
+			if err := objectstore.Client.Upload(ctx, teeReader, firebase.GCSBucket, gcsPath, contentType); err != nil {
 				logCtx.Error("unable to store assistant audio file", fid, "error", err)
 				return
 			}
 
 			fsPath := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profile.ID, c.Character)
-			collection := firestore.Client.Collection(fsPath)
+			collection := docstore.Client.Collection(fsPath)
 			if collection == nil {
 				logCtx.Error("memory collection not fonud", fid, "error", err)
 				return
 			}
 
-			var updates []fs.Update
+			var updates []docstore.Update
 			if predefined {
-				updates = []fs.Update{
+				updates = []docstore.Update{
 					{Path: fmt.Sprintf("predefined_entries.%s.assistant_audio.%s", sessionIDStr, fileExt), Value: gcsPath},
 				}
 			} else {
-				updates = []fs.Update{
+				updates = []docstore.Update{
 					{Path: fmt.Sprintf("entries.%s.assistant_audio.%s", sessionIDStr, fileExt), Value: gcsPath},
 				}
 			}
 
-			if _, err := collection.Doc("latest").Update(ctx, updates); err != nil {
+			if err := collection.Doc("latest").Update(ctx, updates); err != nil {
 				err = common.ConvertGRPCError(err)
 				logCtx.Warn("unable to update lastest document", fid, "error", err)
 				return
@@ -208,20 +202,16 @@ func TTSDontUnderstand(ctx context.Context, logCtx *slog.Logger, profile *profil
 		return nil, "", err
 	}
 
-	v := elevenlabs.Voices[c.Voices[profileCharacter.Voice]]
-	req := elevenlabs.Request{
-		Format:            format,
-		Language:          profileCharacter.Language,
-		Voice:             v.ID,
-		Text:              localize.Character["dont_understand"],
-		SimilarityBoost:   v.SimilarityBoost,
-		Stability:         v.Stability,
-		StyleExaggeration: v.StyleExaggeration,
+	req := tts.Request{
+		Format:   format,
+		Language: profileCharacter.Language,
+		Voice:    c.Voices[profileCharacter.Voice],
+		Text:     localize.Character["dont_understand"],
 	}
 
-	r, err := elevenlabs.TTSStream(ctx, logCtx, req)
+	r, err := tts.Stream(ctx, logCtx, c.Engine, req)
 	if err != nil {
-		logCtx.Error("unable to get elevenlabs tts stream", fid, "error", err)
+		logCtx.Error("unable to get tts stream", fid, "engine", c.Engine, "error", err)
 		return nil, "", err
 	}
 
@@ -232,7 +222,7 @@ func TTSDontUnderstand(ctx context.Context, logCtx *slog.Logger, profile *profil
 	}
 
 	if format == "opus_16000" {
-		return io.NopCloser(r), contentType, nil
+		return r, contentType, nil
 	}
 
 	logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "tts")
@@ -268,25 +258,72 @@ func TTSModerationResponse(ctx context.Context, logCtx *slog.Logger, profile *pr
 		return nil, "", err
 	}
 
-	text := localize.Character["moderation_response_2"]
-	if profile.ResponseAge > 12 {
-		text = localize.Character["moderation_response_1"]
+	req := tts.Request{
+		Format:   format,
+		Language: profileCharacter.Language,
+		Voice:    c.Voices[profileCharacter.Voice],
+		Text:     moderationResponseText(profile, &localize),
+	}
+
+	r, err := tts.Stream(ctx, logCtx, c.Engine, req)
+	if err != nil {
+		logCtx.Error("unable to get tts stream", fid, "engine", c.Engine, "error", err)
+		return nil, "", err
+	}
+
+	contentType, ok := elevenlabs.AudioFormatContentTypes[format]
+	if !ok {
+		logCtx.Error("invalid audio format", fid, "format", format)
+		return nil, "", common.ErrBadRequest{Msg: "invalid audio format"}
+	}
+
+	if format == "opus_16000" {
+		return r, contentType, nil
+	}
+
+	logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "tts")
+
+	return io.NopCloser(r), contentType, nil
+}
+
+// TTSCrisisResponse converts crisis_response text to audio.
+func TTSCrisisResponse(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, characterVersion, format string) (io.ReadCloser, string, error) {
+	fid := slog.String("fid", "vox.characters.play.TTSCrisisResponse")
+	t := time.Now()
+
+	characterName := strings.Split(characterVersion, "_")[0]
+	profileCharacter := profile.Characters[characterName]
+
+	c, err := characters.GetCharacter(ctx, logCtx, characterVersion, profileCharacter.Language)
+	if err != nil {
+		logCtx.Error("unable to get character", fid, "error", err)
+		return nil, "", err
+	}
+
+	if profileCharacter.Voice == "" {
+		profileCharacter.Voice = "default"
+	}
+
+	if profileCharacter.Language == "" {
+		profileCharacter.Language = "en-US"
+	}
+
+	localize, err := configs.GetLocalization(ctx, logCtx, strings.Split(characterVersion, "_")[1], profileCharacter.Language)
+	if err != nil {
+		logCtx.Error("unable to get character localize configs", fid, "error", err)
+		return nil, "", err
 	}
 
-	v := elevenlabs.Voices[c.Voices[profileCharacter.Voice]]
-	req := elevenlabs.Request{
-		Format:            format,
-		Language:          profileCharacter.Language,
-		Voice:             v.ID,
-		Text:              text,
-		SimilarityBoost:   v.SimilarityBoost,
-		Stability:         v.Stability,
-		StyleExaggeration: v.StyleExaggeration,
+	req := tts.Request{
+		Format:   format,
+		Language: profileCharacter.Language,
+		Voice:    c.Voices[profileCharacter.Voice],
+		Text:     crisisResponseText(profile, &localize),
 	}
 
-	r, err := elevenlabs.TTSStream(ctx, logCtx, req)
+	r, err := tts.Stream(ctx, logCtx, c.Engine, req)
 	if err != nil {
-		logCtx.Error("unable to get elevenlabs tts stream", fid, "error", err)
+		logCtx.Error("unable to get tts stream", fid, "engine", c.Engine, "error", err)
 		return nil, "", err
 	}
 
@@ -297,7 +334,7 @@ func TTSModerationResponse(ctx context.Context, logCtx *slog.Logger, profile *pr
 	}
 
 	if format == "opus_16000" {
-		return io.NopCloser(r), contentType, nil
+		return r, contentType, nil
 	}
 
 	logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "tts")
@@ -11,13 +11,16 @@ import (
 	"disruptive/config"
 	"disruptive/lib/common"
 	"disruptive/lib/configs"
-	"disruptive/lib/openai"
+	"disruptive/lib/llm"
 	"disruptive/pkg/vox/accounts"
 	"disruptive/pkg/vox/characters"
 	"disruptive/pkg/vox/moderate"
 	"disruptive/pkg/vox/profiles"
 )
 
+// funModel is the model registry entry used by the fun mode regardless of the character model.
+const funModel = "fun"
+
 // Result contains TTT response data.
 type Result struct {
 	Moderation     *moderate.Response `json:"moderation,omitempty"`
@@ -28,11 +31,46 @@ type Result struct {
 	TokensResponse int                `json:"tokens_response"`
 }
 
+// tttPrompt is a built prompt and the model resolved to answer it.
+type tttPrompt struct {
+	character characters.Character
+	session   characters.SessionDocument
+	mode      string
+	language  string
+	localize  configs.Localize
+	req       *llm.Request
+	model     llm.Model
+	provider  llm.Provider
+	numTokens int
+}
+
 // TTT runs one LLM prompt/response.
 func TTT(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, characterVersion, tttModel, userPrompt, audioID string) (*Result, error) {
 	fid := slog.String("fid", "vox.characters.play.TTT")
 	t := time.Now()
 
+	p, err := buildTTTPrompt(ctx, logCtx, profile, characterVersion, tttModel, userPrompt, audioID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := playGPT(ctx, logCtx, profile, p, userPrompt, audioID)
+	if err != nil {
+		logCtx.Error("unable to post chat", fid, "model", p.model.Name, "provider", p.model.Provider)
+		return nil, errors.New("unable to post chat")
+	}
+
+	logTTTCost(logCtx, p, res)
+
+	logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "ttt")
+
+	return res, nil
+}
+
+// buildTTTPrompt builds the character prompt for the profile mode and resolves the model.
+func buildTTTPrompt(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, characterVersion, tttModel, userPrompt, audioID string) (*tttPrompt, error) {
+	fid := slog.String("fid", "vox.characters.play.buildTTTPrompt")
+
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	characterName := strings.Split(characterVersion, "_")[0]
@@ -88,98 +126,82 @@ func TTT(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, c
 		return nil, err
 	}
 
-	var (
-		chatReq      *openai.ChatRequest
-		numTokens    int
-		promptCost   float64
-		responseCost float64
-	)
-
 	if tttModel != "" {
 		c.Model = tttModel
 	}
 
-	if c.Model == "gpt-4-turbo" {
-		c.Model = characters.GPT4Turbo
-	}
-
-	switch c.Model {
-	case "gpt-3.5-turbo", characters.GPT4Turbo:
-		switch profileCharacter.Mode {
-		case "conversation", "story", "teach_me_something":
-			switch c.Version {
-			case 1:
-				chatReq, numTokens = conversationGPTPromptBuilderV1(profile, &c, &mode, &session, userPrompt, &localize, session.LastUserAudio[audioID].Predefined)
-			case 2:
-				chatReq, numTokens = conversationGPTPromptBuilderV2(profile, &c, &mode, &session, userPrompt, &localize, session.LastUserAudio[audioID].Predefined)
-			default:
-				logCtx.Error("unable to build conversation/story prompt invalid version", fid, "mode", profileCharacter.Mode, "version", c.Version)
-				return nil, errors.New("unable to build prompt invalid version")
-			}
-
-		case "fun":
-			c.Model = characters.GPT4Turbo
-
-			switch c.Version {
-			case 1:
-				chatReq, numTokens = funGPTPromptBuilderV1(profile, &c, &mode, &session, userPrompt, &localize)
-			case 2:
-				chatReq, numTokens = funGPTPromptBuilderV2(profile, &c, &mode, &session, userPrompt, &localize)
-			default:
-				logCtx.Error("unable to build fun prompt invalid version", fid, "mode", profileCharacter.Mode, "version", c.Version)
-				return nil, errors.New("unable to build prompt invalid version")
-			}
+	var chatReq *llm.Request
 
+	switch profileCharacter.Mode {
+	case "conversation", "story", "teach_me_something":
+		switch c.Version {
+		case 1:
+			chatReq = conversationGPTPromptBuilderV1(profile, &c, &mode, &session, userPrompt, &localize, session.LastUserAudio[audioID].Predefined)
+		case 2:
+			chatReq = conversationGPTPromptBuilderV2(profile, &c, &mode, &session, userPrompt, &localize, session.LastUserAudio[audioID].Predefined)
 		default:
-			logCtx.Error("unable to build prompt invalid mode", fid, "mode", profileCharacter.Mode)
-			return nil, errors.New("unable to build prompt invalid mode")
+			logCtx.Error("unable to build conversation/story prompt invalid version", fid, "mode", profileCharacter.Mode, "version", c.Version)
+			return nil, errors.New("unable to build prompt invalid version")
 		}
 
-		if chatReq.Model == "gpt-3.5-turbo" && numTokens > characters.MaxPromptTokens35Turbo {
-			logCtx.Info("gpt-3-turbo token length exceeded. switching to gpt-4-turbo model.", fid, "prompt_tokens", numTokens, "model", chatReq.Model)
-			chatReq.Model = characters.GPT4Turbo
-		}
+	case "fun":
+		c.Model = funModel
 
-		if chatReq.Model == characters.GPT4Turbo && numTokens > characters.MaxPromptTokensGPT4Turbo {
-			logCtx.Error("gpt-4-turbo token length exceeded.", fid, "prompt_tokens", numTokens, "model", chatReq.Model)
-			return nil, common.ErrBadRequest{Msg: "Request exceeds max token length."}
+		switch c.Version {
+		case 1:
+			chatReq = funGPTPromptBuilderV1(profile, &c, &mode, &session, userPrompt, &localize)
+		case 2:
+			chatReq = funGPTPromptBuilderV2(profile, &c, &mode, &session, userPrompt, &localize)
+		default:
+			logCtx.Error("unable to build fun prompt invalid version", fid, "mode", profileCharacter.Mode, "version", c.Version)
+			return nil, errors.New("unable to build prompt invalid version")
 		}
 
-		res, err := playGPT(ctx, logCtx, profile, &c, &session, chatReq, userPrompt, audioID)
-		if err != nil {
-			logCtx.Error("unable to post chat to GPT", fid)
-			return nil, errors.New("unable to post chat to GPT")
-		}
+	default:
+		logCtx.Error("unable to build prompt invalid mode", fid, "mode", profileCharacter.Mode)
+		return nil, errors.New("unable to build prompt invalid mode")
+	}
 
-		switch chatReq.Model {
-		case characters.GPT4Turbo:
-			promptCost = float64(res.TokensPrompt) * config.VARS.GPT4TurboPromptCost / 1000.0
-			responseCost = float64(res.TokensResponse) * config.VARS.GPT4TurboResponseCost / 1000.0
-		default:
-			promptCost = float64(res.TokensPrompt) * config.VARS.GPT35TurboPromptCost / 1000.0
-			responseCost = float64(res.TokensResponse) * config.VARS.GPT35TurboResponseCost / 1000.0
-		}
+	if m := session.LastUserAudio[audioID].Moderation; m != nil && m.Action == configs.ModerationSoften {
+		chatReq.Messages = append(chatReq.Messages, llm.Message{Role: "system", Content: fmt.Sprintf(softenPrompt, profile.ResponseAge)})
+	}
 
-		logCtx.Info(
-			"cost",
-			"feature", "chat",
-			"predefined", res.Predefined,
-			"mode", profileCharacter.Mode,
-			"model", c.Model,
-			"version", c.Version,
-			"tokens_prompt", res.TokensPrompt,
-			"tokens_response", res.TokensResponse,
-			"tokens_total", res.TokensPrompt+res.TokensResponse,
-			"cost_prompt", fmt.Sprintf("%.7f", promptCost),
-			"cost_response", fmt.Sprintf("%.7f", responseCost),
-			"cost_total", fmt.Sprintf("%.7f", promptCost+responseCost),
-		)
-
-		logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "ttt")
-
-		return res, nil
+	model, provider, numTokens, err := llm.Resolve(ctx, logCtx, c.Model, chatReq.Messages)
+	if err != nil {
+		logCtx.Error("unable to resolve model", fid, "model", c.Model, "error", err)
+		return nil, err
 	}
 
-	logCtx.Error("invalid model", fid, "model", c.Model)
-	return nil, errors.New("invalid model")
+	return &tttPrompt{
+		character: c,
+		session:   session,
+		mode:      profileCharacter.Mode,
+		language:  language,
+		localize:  localize,
+		req:       chatReq,
+		model:     model,
+		provider:  provider,
+		numTokens: numTokens,
+	}, nil
+}
+
+func logTTTCost(logCtx *slog.Logger, p *tttPrompt, res *Result) {
+	promptCost, responseCost := p.provider.Cost(p.model, res.TokensPrompt, res.TokensResponse)
+
+	logCtx.Info(
+		"cost",
+		"feature", "chat",
+		"predefined", res.Predefined,
+		"mode", p.mode,
+		"model", p.model.Name,
+		"provider", p.model.Provider,
+		"version", p.character.Version,
+		"tokens_estimated", p.numTokens,
+		"tokens_prompt", res.TokensPrompt,
+		"tokens_response", res.TokensResponse,
+		"tokens_total", res.TokensPrompt+res.TokensResponse,
+		"cost_prompt", fmt.Sprintf("%.7f", promptCost),
+		"cost_response", fmt.Sprintf("%.7f", responseCost),
+		"cost_total", fmt.Sprintf("%.7f", promptCost+responseCost),
+	)
 }
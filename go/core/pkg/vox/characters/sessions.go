@@ -9,10 +9,8 @@ import (
 	"strings"
 	"time"
 
-	fs "cloud.google.com/go/firestore"
-
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 	"disruptive/pkg/vox/accounts"
 	"disruptive/pkg/vox/moderate"
 	"disruptive/pkg/vox/profiles"
@@ -30,30 +28,46 @@ type SessionDocument struct {
 
 // SessionEntry contains a single user/assistant pair.
 type SessionEntry struct {
-	ID             int                `firestore:"id" json:"id,omitempty"`
-	EndSequence    bool               `firestore:"end_sequence,omitempty" json:"end_sequence,omitempty"`
-	User           string             `firestore:"user" json:"user,omitempty"`
-	Assistant      string             `firestore:"assistant" json:"assistant,omitempty"`
-	UserAudio      map[string]string  `firestore:"user_audio,omitempty" json:"user_audio,omitempty"`
-	AssistantAudio map[string]string  `firestore:"assistant_audio" json:"assistant_audio,omitempty"`
-	Mode           string             `firestore:"mode,omitempty" json:"mode,omitempty"`
-	Moderation     *moderate.Response `firestore:"moderation,omitempty" json:"moderation,omitempty"`
-	NotificationID string             `firestore:"notification_id,omitempty" json:"notification_id,omitempty"`
-	Timestamp      time.Time          `firestore:"timestamp" json:"timestamp,omitempty"`
-	TokensPrompt   int                `firestore:"tokens_prompt" json:"tokens_prompt,omitempty"`
-	TokensResponse int                `firestore:"tokens_response" json:"tokens_response,omitempty"`
+	ID                  int                  `firestore:"id" json:"id,omitempty"`
+	EndSequence         bool                 `firestore:"end_sequence,omitempty" json:"end_sequence,omitempty"`
+	User                string               `firestore:"user" json:"user,omitempty"`
+	Assistant           string               `firestore:"assistant" json:"assistant,omitempty"`
+	AssistantModeration *AssistantModeration `firestore:"assistant_moderation,omitempty" json:"assistant_moderation,omitempty"`
+	UserAudio           map[string]string    `firestore:"user_audio,omitempty" json:"user_audio,omitempty"`
+	AssistantAudio      map[string]string    `firestore:"assistant_audio" json:"assistant_audio,omitempty"`
+	Mode                string               `firestore:"mode,omitempty" json:"mode,omitempty"`
+	Moderation          *moderate.Response   `firestore:"moderation,omitempty" json:"moderation,omitempty"`
+	NotificationID      string               `firestore:"notification_id,omitempty" json:"notification_id,omitempty"`
+	Timestamp           time.Time            `firestore:"timestamp" json:"timestamp,omitempty"`
+	TokensPrompt        int                  `firestore:"tokens_prompt" json:"tokens_prompt,omitempty"`
+	TokensResponse      int                  `firestore:"tokens_response" json:"tokens_response,omitempty"`
+}
+
+// Assistant moderation actions.
+const (
+	ModerationPassed      = "passed"
+	ModerationRegenerated = "regenerated"
+	ModerationSubstituted = "substituted"
+)
+
+// AssistantModeration contains the moderation verdict of an assistant response and the action taken.
+type AssistantModeration struct {
+	Action     string             `firestore:"action" json:"action"`
+	Moderation *moderate.Response `firestore:"moderation,omitempty" json:"moderation,omitempty"`
 }
 
 // UserAudio contains a user's audio info.
 type UserAudio struct {
 	AudioID          string             `firestore:"audio_id" json:"audio_id"`
 	DetectedLanguage string             `firestore:"detected_language,omitempty" json:"detected_language,omitempty"`
+	Duration         float64            `firestore:"duration,omitempty" json:"duration,omitempty"`
 	Predefined       bool               `firestore:"predefined,omitempty" json:"predefined,omitempty"`
 	Mode             string             `firestore:"mode,omitempty" json:"mode,omitempty"`
 	Moderation       *moderate.Response `firestore:"moderation,omitempty" json:"moderation,omitempty"`
 	NotificationID   string             `firestore:"notification_id,omitempty" json:"notification_id,omitempty"`
 	Path             string             `firestore:"path,omitempty" json:"path,omitempty"`
 	SessionID        int                `firestore:"session_id" json:"session_id,omitempty"`
+	STTEngine        string             `firestore:"stt_engine,omitempty" json:"stt_engine,omitempty"`
 	Text             string             `firestore:"text" json:"text"`
 	Timestamp        time.Time          `firestore:"timestamp" json:"timestamp"`
 }
@@ -65,7 +79,7 @@ func GetSession(ctx context.Context, logCtx *slog.Logger, profileID string, char
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profileID, character)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("memory collection not found", fid)
 		return SessionDocument{}, common.ErrNotFound{}
@@ -83,7 +97,7 @@ func GetSession(ctx context.Context, logCtx *slog.Logger, profileID string, char
 
 		logCtx.Info("creating latest doc", fid)
 
-		_, err = collection.Doc("latest").Set(ctx, s)
+		err = collection.Doc("latest").Set(ctx, s)
 		if err != nil {
 			logCtx.Error("unable to create latest document", fid, "error", err)
 			return SessionDocument{}, err
@@ -106,7 +120,7 @@ func GetSessionEntryByID(ctx context.Context, logCtx *slog.Logger, profileID, ch
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profileID, characterVersion)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("memory collection not found", fid)
 		return SessionEntry{}, common.ErrNotFound{}
@@ -148,7 +162,7 @@ func AddSessionEntry(ctx context.Context, logCtx *slog.Logger, profileID, charac
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profileID, character)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("memory collection not found", fid)
 		return 0, common.ErrNotFound{}
@@ -181,7 +195,7 @@ func AddSessionEntry(ctx context.Context, logCtx *slog.Logger, profileID, charac
 		session.LastUserAudio = nil
 		session.PredefinedEntries = nil
 
-		if _, err := collection.Doc(id).Set(ctx, session); err != nil {
+		if err := collection.Doc(id).Set(ctx, session); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("set archive document", fid, "error", err)
 			return 0, err
@@ -191,14 +205,14 @@ func AddSessionEntry(ctx context.Context, logCtx *slog.Logger, profileID, charac
 		sessionEntry.ID = sessionID
 		keepEntries[fmt.Sprintf("%06d", sessionID)] = sessionEntry
 
-		updates := []fs.Update{
+		updates := []docstore.Update{
 			{Path: "entries", Value: keepEntries},
 			{Path: "start_entry", Value: startEntry},
 			{Path: "archive", Value: sessionEntry.Timestamp},
 			{Path: "last_archive", Value: session.Archive},
 		}
 
-		if _, err := collection.Doc("latest").Update(ctx, updates); err != nil {
+		if err := collection.Doc("latest").Update(ctx, updates); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to update lastest document", fid, "error", err)
 			return 0, err
@@ -208,7 +222,7 @@ func AddSessionEntry(ctx context.Context, logCtx *slog.Logger, profileID, charac
 		logCtx = logCtx.With("mode", "update")
 
 		path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profileID, character)
-		collection := firestore.Client.Collection(path)
+		collection := docstore.Client.Collection(path)
 		if collection == nil {
 			logCtx.Error("memory collection not found", fid)
 			return 0, common.ErrNotFound{}
@@ -225,7 +239,7 @@ func AddSessionEntry(ctx context.Context, logCtx *slog.Logger, profileID, charac
 			id: archive,
 		}
 
-		if _, err := collection.Doc("index").Set(ctx, docUpdate, fs.MergeAll); err != nil {
+		if err := collection.Doc("index").Set(ctx, docUpdate, docstore.MergeAll); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to update archive index", fid, "error", err)
 			return 0, err
@@ -238,30 +252,30 @@ func AddSessionEntry(ctx context.Context, logCtx *slog.Logger, profileID, charac
 
 	var (
 		sessionID int
-		updates   []fs.Update
+		updates   []docstore.Update
 	)
 
 	if session.LastUserAudio[audioID].Predefined {
 		sessionID = len(session.PredefinedEntries) + 1
 		sessionEntry.ID = sessionID
 
-		updates = []fs.Update{
+		updates = []docstore.Update{
 			{Path: fmt.Sprintf("predefined_entries.%06d", sessionID), Value: sessionEntry},
 		}
 	} else {
 		sessionID = session.StartEntry + len(session.Entries)
 		sessionEntry.ID = sessionID
 
-		updates = []fs.Update{
+		updates = []docstore.Update{
 			{Path: fmt.Sprintf("entries.%06d", sessionID), Value: sessionEntry},
 		}
 	}
 
 	if audioID != "" {
-		updates = append(updates, fs.Update{Path: fmt.Sprintf("last_user_audio.%s.session_id", audioID), Value: sessionID})
+		updates = append(updates, docstore.Update{Path: fmt.Sprintf("last_user_audio.%s.session_id", audioID), Value: sessionID})
 	}
 
-	if _, err := collection.Doc("latest").Update(ctx, updates); err != nil {
+	if err := collection.Doc("latest").Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to update latest document", fid, "error", err)
 		return 0, err
@@ -282,7 +296,7 @@ func UpdateLastUserAudio(ctx context.Context, logCtx *slog.Logger, profileID, ch
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profileID, character)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("unable to find collection", fid, "path", path)
 		return common.ErrNotFound{}
@@ -290,7 +304,7 @@ func UpdateLastUserAudio(ctx context.Context, logCtx *slog.Logger, profileID, ch
 
 	sessionIDStr := fmt.Sprintf("%06d", sessionID)
 
-	updates := []fs.Update{
+	updates := []docstore.Update{
 		{Path: fmt.Sprintf("entries.%s.user", sessionIDStr), Value: session.LastUserAudio[audioID].Text},
 	}
 
@@ -302,19 +316,19 @@ func UpdateLastUserAudio(ctx context.Context, logCtx *slog.Logger, profileID, ch
 				return common.ErrBadRequest{Msg: "invalid extension"}
 			}
 
-			updates = append(updates, fs.Update{Path: fmt.Sprintf("entries.%s.user_audio%s", sessionIDStr, ext), Value: session.LastUserAudio[audioID].Path})
+			updates = append(updates, docstore.Update{Path: fmt.Sprintf("entries.%s.user_audio%s", sessionIDStr, ext), Value: session.LastUserAudio[audioID].Path})
 		}
 
-		updates = append(updates, fs.Update{Path: fmt.Sprintf("entries.%s.moderation", sessionIDStr), Value: session.LastUserAudio[audioID].Moderation})
+		updates = append(updates, docstore.Update{Path: fmt.Sprintf("entries.%s.moderation", sessionIDStr), Value: session.LastUserAudio[audioID].Moderation})
 	}
 
 	for id, audio := range session.LastUserAudio {
 		if time.Now().Sub(audio.Timestamp) > time.Duration(5*time.Minute) && id != audioID {
-			updates = append(updates, fs.Update{Path: fmt.Sprintf("last_user_audio.%s", id), Value: fs.Delete})
+			updates = append(updates, docstore.Update{Path: fmt.Sprintf("last_user_audio.%s", id), Value: docstore.Delete})
 		}
 	}
 
-	if _, err := collection.Doc("latest").Update(ctx, updates); err != nil {
+	if err := collection.Doc("latest").Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to update latest document", fid, "error", err)
 		return err
@@ -353,17 +367,17 @@ func EndSequence(ctx context.Context, logCtx *slog.Logger, profile *profiles.Doc
 	endSequenceIDStr := fmt.Sprintf("%06d", endSequenceID)
 
 	path := fmt.Sprintf("accounts/%s/profiles/%s/vox_sessions/%s/memory", account.ID, profile.ID, character.Character)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("memory collection not found", fid)
 		return SessionEntry{}, common.ErrNotFound{}
 	}
 
-	updates := []fs.Update{
+	updates := []docstore.Update{
 		{Path: fmt.Sprintf("entries.%s.end_sequence", endSequenceIDStr), Value: true},
 	}
 
-	if _, err := collection.Doc("latest").Update(ctx, updates); err != nil {
+	if err := collection.Doc("latest").Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to update latest document", fid, "error", err)
 		return SessionEntry{}, err
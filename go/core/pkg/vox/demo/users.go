@@ -5,10 +5,8 @@ import (
 	"context"
 	"log/slog"
 
-	fs "cloud.google.com/go/firestore"
-
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 )
 
 // Document contains the demo user fields.
@@ -20,16 +18,16 @@ type Document struct {
 func PatchUser(ctx context.Context, logCtx *slog.Logger, user string, document Document) error {
 	fid := slog.String("fid", "vox.demo.Patch")
 
-	collection := firestore.Client.Collection("demo")
+	collection := docstore.Client.Collection("demo")
 	if collection == nil {
 		logCtx.Error("demo collection not found", fid)
 		return common.ErrNotFound{}
 	}
 
-	update := []fs.Update{}
+	update := []docstore.Update{}
 
 	if document.Status != nil {
-		update = append(update, fs.Update{Path: "status", Value: *document.Status})
+		update = append(update, docstore.Update{Path: "status", Value: *document.Status})
 	}
 
 	if len(update) < 1 {
@@ -37,7 +35,7 @@ func PatchUser(ctx context.Context, logCtx *slog.Logger, user string, document D
 		return nil
 	}
 
-	if _, err := collection.Doc(user).Update(ctx, update); err != nil {
+	if err := collection.Doc(user).Update(ctx, update); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Warn("unable to update demo user document", fid, "error", err)
 		return err
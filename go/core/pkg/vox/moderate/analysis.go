@@ -0,0 +1,90 @@
+package moderate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const repairPromptTemplate = "The JSON OUTPUT is invalid: %s. " +
+	"Reply again with only the corrected RFC8259 compliant JSON, without any explanations. " +
+	"It must contain 'assessment', 'assessment_age' (integer from 0 to 18), 'movie_rating' (G, PG, PG-13, R or NC-17), " +
+	"'tv_rating' (TV-Y, TV-Y7, TV-Y7-FV, TV-G, TV-PG, TV-14 or TV-MA), 'esrb_rating' (EC, E, E10+, T, M or AO), " +
+	"'pegi_rating' (3, 7, 12, 16 or 18) and 'toxic' (boolean)."
+
+// requiredAnalysisFields are the fields every analysis must contain for a verdict.
+var requiredAnalysisFields = []string{"assessment", "assessment_age", "movie_rating", "tv_rating", "esrb_rating", "pegi_rating", "toxic"}
+
+var (
+	movieRatings = []string{"G", "PG", "PG-13", "R", "NC-17"}
+	tvRatings    = []string{"TV-Y", "TV-Y7", "TV-Y7-FV", "TV-G", "TV-PG", "TV-14", "TV-MA"}
+	esrbRatings  = []string{"EC", "E", "E10+", "T", "M", "AO"}
+	pegiRatings  = []int{3, 7, 12, 16, 18}
+)
+
+// parseAnalysis decodes an LLM analysis and validates it against the analysis schema.
+// The JSON may be wrapped in text or a code block.
+func parseAnalysis(text string) (Analysis, error) {
+	a := Analysis{}
+
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return a, errors.New("no JSON object")
+	}
+
+	data := []byte(text[start : end+1])
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return a, fmt.Errorf("malformed JSON: %w", err)
+	}
+
+	missing := []string{}
+	for _, f := range requiredAnalysisFields {
+		if v, ok := fields[f]; !ok || string(v) == "null" {
+			missing = append(missing, f)
+		}
+	}
+
+	if len(missing) > 0 {
+		return a, fmt.Errorf("missing fields %s", strings.Join(missing, ", "))
+	}
+
+	if err := json.Unmarshal(data, &a); err != nil {
+		return a, fmt.Errorf("invalid field type: %w", err)
+	}
+
+	switch {
+	case strings.TrimSpace(a.Assessment) == "":
+		return a, errors.New("empty assessment")
+	case a.AssessmentAge < 0 || a.AssessmentAge > 18:
+		return a, fmt.Errorf("invalid assessment_age %d", a.AssessmentAge)
+	case !slices.Contains(movieRatings, a.MovieRating):
+		return a, fmt.Errorf("invalid movie_rating %q", a.MovieRating)
+	case !slices.Contains(tvRatings, a.TVRating):
+		return a, fmt.Errorf("invalid tv_rating %q", a.TVRating)
+	case !slices.Contains(esrbRatings, a.ESRBRating):
+		return a, fmt.Errorf("invalid esrb_rating %q", a.ESRBRating)
+	case !slices.Contains(pegiRatings, a.PEGIRating):
+		return a, fmt.Errorf("invalid pegi_rating %d", a.PEGIRating)
+	}
+
+	return a, nil
+}
+
+// ratingsForAge sets the ratings that correspond to an assessment age.
+func ratingsForAge(a *Analysis) {
+	switch {
+	case a.AssessmentAge >= 17:
+		a.MovieRating, a.TVRating, a.ESRBRating, a.PEGIRating = "R", "TV-MA", "M", 18
+	case a.AssessmentAge >= 13:
+		a.MovieRating, a.TVRating, a.ESRBRating, a.PEGIRating = "PG-13", "TV-14", "T", 12
+	case a.AssessmentAge >= 10:
+		a.MovieRating, a.TVRating, a.ESRBRating, a.PEGIRating = "PG", "TV-PG", "E10+", 7
+	default:
+		a.MovieRating, a.TVRating, a.ESRBRating, a.PEGIRating = "G", "TV-Y", "E", 3
+	}
+}
@@ -0,0 +1,118 @@
+package moderate
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// defaultWordlistLanguage is always checked in addition to the text's language.
+const defaultWordlistLanguage = "en"
+
+//go:embed wordlists/*.json
+var wordlistFiles embed.FS
+
+// wordlist contains the local classifier rules of one language.
+type wordlist struct {
+	Rules []struct {
+		Category string   `json:"category"`
+		Age      int      `json:"age"`
+		Toxic    bool     `json:"toxic"`
+		Words    []string `json:"words"`
+	} `json:"rules"`
+}
+
+// rule matches any of its words as whole words.
+type rule struct {
+	category string
+	age      int
+	toxic    bool
+	regex    *regexp.Regexp
+}
+
+var (
+	wordlistsOnce sync.Once
+	wordlists     map[string][]rule
+)
+
+// loadWordlists compiles the embedded wordlists by ISO-639-1 language code.
+func loadWordlists() {
+	wordlists = map[string][]rule{}
+
+	entries, err := wordlistFiles.ReadDir("wordlists")
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		b, err := wordlistFiles.ReadFile(path.Join("wordlists", e.Name()))
+		if err != nil {
+			continue
+		}
+
+		w := wordlist{}
+		if err := json.Unmarshal(b, &w); err != nil {
+			continue
+		}
+
+		language := strings.TrimSuffix(e.Name(), path.Ext(e.Name()))
+
+		for _, r := range w.Rules {
+			if len(r.Words) == 0 {
+				continue
+			}
+
+			// Letters outside ASCII are not word characters for \b, so word boundaries are explicit.
+			regex, err := regexp.Compile(`(?i)(?:^|[^\p{L}\p{N}])(?:` + strings.Join(r.Words, "|") + `)(?:$|[^\p{L}\p{N}])`)
+			if err != nil {
+				continue
+			}
+
+			wordlists[language] = append(wordlists[language], rule{category: r.Category, age: r.Age, toxic: r.Toxic, regex: regex})
+		}
+	}
+}
+
+// classify is the deterministic local classifier used when the moderation services are unavailable or invalid.
+// It matches the wordlists of the text's language and English, and returns the matched categories and an analysis.
+func classify(text, language string) (map[string]bool, Analysis) {
+	wordlistsOnce.Do(loadWordlists)
+
+	code := strings.ToLower(strings.Split(language, "-")[0])
+
+	rules := wordlists[defaultWordlistLanguage]
+	if code != defaultWordlistLanguage {
+		rules = append(slices.Clone(wordlists[code]), rules...)
+	}
+
+	categories := map[string]bool{}
+	a := Analysis{Language: code}
+
+	for _, r := range rules {
+		if !r.regex.MatchString(text) {
+			continue
+		}
+
+		if !categories[r.category] {
+			a.Classifications = append(a.Classifications, r.category)
+		}
+
+		categories[r.category] = true
+		a.AssessmentAge = max(a.AssessmentAge, r.age)
+		a.Toxic = a.Toxic || r.toxic
+	}
+
+	ratingsForAge(&a)
+
+	if len(a.Classifications) == 0 {
+		a.Assessment = "No concerning words found by the local classifier."
+	} else {
+		a.Assessment = "The local classifier found words about " + strings.Join(a.Classifications, ", ") + "."
+	}
+
+	return categories, a
+}
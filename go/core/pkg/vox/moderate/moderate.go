@@ -3,7 +3,6 @@ package moderate
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -107,35 +106,52 @@ JSON OUTPUT:
 `
 )
 
+// Sources of a moderation analysis.
+const (
+	SourceLLM      = "llm"
+	SourceRepaired = "llm_repaired"
+	SourceLocal    = "local"
+)
+
+// maxAnalysisRepairs is the number of times an invalid analysis is sent back to be repaired.
+const maxAnalysisRepairs = 1
+
+// Analysis contains the text analysis of a moderation.
+type Analysis struct {
+	AssessmentAge         int      `firestore:"assessment_age,omitempty" json:"assessment_age,omitempty"`
+	Assessment            string   `firestore:"assessment,omitempty" json:"assessment,omitempty"`
+	AssessmentTranslation string   `firestore:"assessment_translation,omitempty" json:"assessment_translation,omitempty"`
+	Classifications       []string `firestore:"classifications,omitempty" json:"classifications,omitempty"`
+	Emotion               string   `firestore:"emotion,omitempty" json:"emotion,omitempty"`
+	Entities              []string `firestore:"entities,omitempty" json:"named_entities,omitempty"`
+	ESRBRating            string   `firestore:"esrb_rating,omitempty" json:"esrb_rating,omitempty"`
+	Intent                string   `firestore:"intent,omitempty" json:"intent,omitempty"`
+	Language              string   `firestore:"language,omitempty" json:"language,omitempty"`
+	MovieRating           string   `firestore:"movie_rating,omitempty" json:"movie_rating,omitempty"`
+	NotAgeAppropriate     bool     `firestore:"not_age_appropriate,omitempty" json:"not_age_appropriate,omitempty"`
+	PEGIRating            int      `firestore:"pegi_rating,omitempty" json:"pegi_rating,omitempty"`
+	Sentiment             string   `firestore:"sentiment,omitempty" json:"sentiment,omitempty"`
+	SubjectCategory       string   `firestore:"subject_category,omitempty" json:"subject_category,omitempty"`
+	Topic                 string   `firestore:"topic,omitempty" json:"topic,omitempty"`
+	Toxic                 bool     `firestore:"toxic,omitempty" json:"toxic,omitempty"`
+	TVRating              string   `firestore:"tv_rating,omitempty" json:"tv_rating,omitempty"`
+}
+
 // Response contains moderation information from all services.
 type Response struct {
 	sync.Mutex
-	Categories map[string]bool `firestore:"categories" json:"categories"`
-	Analysis   struct {
-		AssessmentAge         int      `firestore:"assessment_age,omitempty" json:"assessment_age,omitempty"`
-		Assessment            string   `firestore:"assessment,omitempty" json:"assessment,omitempty"`
-		AssessmentTranslation string   `firestore:"assessment_translation,omitempty" json:"assessment_translation,omitempty"`
-		Classifications       []string `firestore:"classifications,omitempty" json:"classifications,omitempty"`
-		Emotion               string   `firestore:"emotion,omitempty" json:"emotion,omitempty"`
-		Entities              []string `firestore:"entities,omitempty" json:"named_entities,omitempty"`
-		ESRBRating            string   `firestore:"esrb_rating,omitempty" json:"esrb_rating,omitempty"`
-		Intent                string   `firestore:"intent,omitempty" json:"intent,omitempty"`
-		Language              string   `firestore:"language,omitempty" json:"language,omitempty"`
-		MovieRating           string   `firestore:"movie_rating,omitempty" json:"movie_rating,omitempty"`
-		NotAgeAppropriate     bool     `firestore:"not_age_appropriate,omitempty" json:"not_age_appropriate,omitempty"`
-		PEGIRating            int      `firestore:"pegi_rating,omitempty" json:"pegi_rating,omitempty"`
-		Sentiment             string   `firestore:"sentiment,omitempty" json:"sentiment,omitempty"`
-		SubjectCategory       string   `firestore:"subject_category,omitempty" json:"subject_category,omitempty"`
-		Topic                 string   `firestore:"topic,omitempty" json:"topic,omitempty"`
-		Toxic                 bool     `firestore:"toxic,omitempty" json:"toxic,omitempty"`
-		TVRating              string   `firestore:"tv_rating,omitempty" json:"tv_rating,omitempty"`
-	} `firestore:"analysis" json:"analysis"`
-	TokensPrompt   int  `firestore:"tokens_prompt" json:"tokens_prompt,omitempty"`
-	TokensResponse int  `firestore:"tokens_response" json:"tokens_response,omitempty"`
-	Triggered      bool `firestore:"triggered" json:"triggered,omitempty"`
+	Action         string          `firestore:"action,omitempty" json:"action,omitempty"`
+	Categories     map[string]bool `firestore:"categories" json:"categories"`
+	Analysis       Analysis        `firestore:"analysis" json:"analysis"`
+	Rule           string          `firestore:"rule,omitempty" json:"rule,omitempty"`
+	Source         string          `firestore:"source,omitempty" json:"source,omitempty"`
+	TokensPrompt   int             `firestore:"tokens_prompt" json:"tokens_prompt,omitempty"`
+	TokensResponse int             `firestore:"tokens_response" json:"tokens_response,omitempty"`
+	Triggered      bool            `firestore:"triggered" json:"triggered,omitempty"`
 }
 
 // Get return a moderation response for some input text.
+// The analysis falls back to the local classifier, as do the categories when the moderation service fails.
 func Get(ctx context.Context, logCtx *slog.Logger, text, language string) *Response {
 	fid := slog.String("fid", "vox.moderate.Get")
 
@@ -155,6 +171,10 @@ func Get(ctx context.Context, logCtx *slog.Logger, text, language string) *Respo
 		logCtx.Warn("unable to process all moderations", fid, "error", err)
 	}
 
+	if res.Categories == nil {
+		res.Categories, _ = classify(text, language)
+	}
+
 	return res
 }
 
@@ -178,6 +198,8 @@ func getCategories(ctx context.Context, logCtx *slog.Logger, text string, respon
 	return nil
 }
 
+// getAnalysis asks the LLM for an analysis and validates it, sending invalid replies back with a repair prompt.
+// Without a valid analysis the local classifier produces it.
 func getAnalysis(ctx context.Context, logCtx *slog.Logger, text, language string, response *Response) error {
 	fid := slog.String("fid", "vox.moderate.getAnalysis")
 
@@ -187,16 +209,71 @@ func getAnalysis(ctx context.Context, logCtx *slog.Logger, text, language string
 		MaxTokens: 250,
 	}
 
-	chatRes, err := openai.PostChat(ctx, logCtx, chatReq)
-	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			logCtx.Error("timeout", fid, "error", err)
-			return err
+	var (
+		analysis       Analysis
+		source         string
+		tokensPrompt   int
+		tokensResponse int
+		err            error
+	)
+
+	for i := 0; i <= maxAnalysisRepairs; i++ {
+		var chatRes openai.ChatResponse
+
+		chatRes, err = openai.PostChat(ctx, logCtx, chatReq)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				logCtx.Error("timeout", fid, "error", err)
+			} else {
+				logCtx.Error("unable to get chat response", fid, "error", err)
+			}
+			break
 		}
-		logCtx.Error("unable to get chat response", fid, "error", err)
-		return err
+
+		logAnalysisCost(logCtx, chatRes)
+
+		tokensPrompt += chatRes.UsagePrompt
+		tokensResponse += chatRes.UsageResponse
+
+		analysis, err = parseAnalysis(chatRes.Text)
+		if err == nil {
+			source = SourceLLM
+			if i > 0 {
+				source = SourceRepaired
+			}
+			break
+		}
+
+		logCtx.Warn("invalid analysis", fid, "attempt", i+1, "error", err)
+
+		chatReq.Messages = append(chatReq.Messages,
+			openai.ChatMessage{Role: "assistant", Content: chatRes.Text},
+			openai.ChatMessage{Role: "user", Content: fmt.Sprintf(repairPromptTemplate, err)},
+		)
 	}
 
+	if source == "" {
+		logCtx.Warn("using local analysis", fid, "error", err)
+		_, analysis = classify(text, language)
+		source = SourceLocal
+	}
+
+	response.Lock()
+	response.Analysis = analysis
+	response.Source = source
+	response.TokensPrompt = tokensPrompt
+	response.TokensResponse = tokensResponse
+
+	if strings.Contains(language, "en-") {
+		response.Analysis.AssessmentTranslation = response.Analysis.Assessment
+	}
+
+	response.Unlock()
+
+	return err
+}
+
+func logAnalysisCost(logCtx *slog.Logger, chatRes openai.ChatResponse) {
 	promptCost := float64(chatRes.UsagePrompt) * config.VARS.GPT35TurboPromptCost / 1000.0
 	responseCost := float64(chatRes.UsageResponse) * config.VARS.GPT35TurboResponseCost / 1000.0
 
@@ -211,17 +288,4 @@ func getAnalysis(ctx context.Context, logCtx *slog.Logger, text, language string
 		"cost_response", fmt.Sprintf("%.7f", responseCost),
 		"cost_total", fmt.Sprintf("%.7f", promptCost+responseCost),
 	)
-
-	response.Lock()
-	json.Unmarshal([]byte(chatRes.Text), &response.Analysis)
-	response.TokensPrompt = chatRes.UsagePrompt
-	response.TokensResponse = chatRes.UsageResponse
-
-	if strings.Contains(language, "en-") {
-		response.Analysis.AssessmentTranslation = response.Analysis.Assessment
-	}
-
-	response.Unlock()
-
-	return nil
 }
@@ -0,0 +1,344 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	"disruptive/config"
+	"disruptive/lib/common"
+	"disruptive/lib/configs"
+	"disruptive/lib/docstore"
+	"disruptive/pkg/vox/accounts"
+	"disruptive/pkg/vox/characters"
+	"disruptive/pkg/vox/profiles"
+)
+
+// Escalation is a crisis escalation of a user message, kept for admin review.
+type Escalation struct {
+	ID             string    `firestore:"id" json:"id"`
+	AccountID      string    `firestore:"account_id" json:"account_id"`
+	Acknowledged   bool      `firestore:"acknowledged" json:"acknowledged"`
+	AcknowledgedBy string    `firestore:"acknowledged_by,omitempty" json:"acknowledged_by,omitempty"`
+	AcknowledgedAt time.Time `firestore:"acknowledged_at,omitempty" json:"acknowledged_at,omitempty"`
+	AudioID        string    `firestore:"audio_id" json:"audio_id"`
+	Categories     []string  `firestore:"categories" json:"categories"`
+	Character      string    `firestore:"character" json:"character"`
+	EmailSent      bool      `firestore:"email_sent" json:"email_sent"`
+	Note           string    `firestore:"note,omitempty" json:"note,omitempty"`
+	ProfileID      string    `firestore:"profile_id" json:"profile_id"`
+	ProfileName    string    `firestore:"profile_name" json:"profile_name"`
+	PushSent       bool      `firestore:"push_sent" json:"push_sent"`
+	Rule           string    `firestore:"rule" json:"rule"`
+	Text           string    `firestore:"text" json:"text"`
+	Timestamp      time.Time `firestore:"timestamp" json:"timestamp"`
+}
+
+const escalationHTML = `
+<!DOCTYPE html>
+<html>
+<head>
+<style>
+  blockquote {
+    margin-left: 20px;
+    border-left: 2px solid #333;
+    padding-left: 10px;
+  }
+</style>
+</head>
+<body>
+  <h3>{{.Title}}</h3>
+  <p>{{.Body}}</p>
+
+  <h4>{{.InformationSection}}</h4>
+  <ul>
+    <li>{{.ProfileLabel}}: {{.Name}}</li>
+    <li>{{.CharacterLabel}}: {{.Character}}</li>
+    <li>{{.TimeLabel}}: {{.Time}}</li>
+  </ul>
+
+  <h4>{{.ContentSection}}</h4>
+
+  <blockquote>
+    <h4>{{.Name}}</h4>
+    {{.User}}
+  </blockquote>
+<body>
+</html>
+`
+
+type escalationHTMLVars struct {
+	Body               string
+	Character          string
+	CharacterLabel     string
+	ContentSection     string
+	InformationSection string
+	Name               string
+	ProfileLabel       string
+	Time               string
+	TimeLabel          string
+	Title              string
+	User               string
+}
+
+// PostEscalation records a crisis escalation and alerts the guardian with a high priority push notification and
+// an email, regardless of the account's and the profile's notification settings.
+// An escalation is recorded once per user audio, so repeated moderations do not repeat the alerts.
+func PostEscalation(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, characterName string, lastUserAudio *characters.UserAudio, localize *configs.Localize) (Escalation, error) {
+	fid := slog.String("fid", "vox.notifications.PostEscalation")
+
+	account := ctx.Value(common.AccountKey).(accounts.Document)
+
+	collection := docstore.Client.Collection("escalations")
+	if collection == nil {
+		logCtx.Error("escalations collection not found", fid)
+		return Escalation{}, common.ErrNotFound{}
+	}
+
+	d := Escalation{
+		ID:          fmt.Sprintf("%s_%s", account.ID, lastUserAudio.AudioID),
+		AccountID:   account.ID,
+		AudioID:     lastUserAudio.AudioID,
+		Categories:  []string{},
+		Character:   characterName,
+		ProfileID:   profile.ID,
+		ProfileName: profile.Name,
+		Text:        lastUserAudio.Text,
+		Timestamp:   time.Now(),
+	}
+
+	if m := lastUserAudio.Moderation; m != nil {
+		d.Rule = m.Rule
+
+		for k, v := range m.Categories {
+			if v {
+				d.Categories = append(d.Categories, k)
+			}
+		}
+
+		sort.Strings(d.Categories)
+	}
+
+	if err := collection.Doc(d.ID).Create(ctx, d); err != nil {
+		err = common.ConvertGRPCError(err)
+		if errors.Is(err, common.ErrAlreadyExists{}) {
+			logCtx.Info("escalation already recorded", fid, "escalation_id", d.ID)
+			return GetEscalation(ctx, logCtx, d.ID)
+		}
+
+		logCtx.Error("unable to create escalation document", fid, "error", err)
+		return Escalation{}, err
+	}
+
+	logCtx.Warn("crisis escalation", fid, "escalation_id", d.ID, "profile_id", profile.ID, "rule", d.Rule)
+
+	title := fmt.Sprintf(localize.Email["crisis_notification_subject"], profile.Name)
+
+	token, _ := account.Preferences[accounts.PreferenceFCMToken].(string)
+	if token != "" {
+		req := accounts.PushNotificationRequest{
+			FCMToken: token,
+			Title:    title,
+			Body:     fmt.Sprintf(localize.Email["crisis_notification_push"], profile.Name),
+			Priority: accounts.PushPriorityHigh,
+		}
+
+		if _, err := accounts.PushNotification(ctx, logCtx, req); err != nil {
+			logCtx.Error("unable to push escalation notification", fid, "error", err)
+		} else {
+			d.PushSent = true
+		}
+	}
+
+	if err := sendEscalationEmail(ctx, logCtx, profile, characterName, lastUserAudio, title, localize); err != nil {
+		logCtx.Error("unable to send escalation email", fid, "error", err)
+	} else {
+		d.EmailSent = true
+	}
+
+	updates := []docstore.Update{
+		{Path: "email_sent", Value: d.EmailSent},
+		{Path: "push_sent", Value: d.PushSent},
+	}
+
+	if err := collection.Doc(d.ID).Update(ctx, updates); err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Warn("unable to update escalation document", fid, "error", err)
+	}
+
+	return d, nil
+}
+
+// sendEscalationEmail emails the account and the profile's notification addresses.
+func sendEscalationEmail(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, characterName string, lastUserAudio *characters.UserAudio, title string, localize *configs.Localize) error {
+	fid := slog.String("fid", "vox.notifications.sendEscalationEmail")
+
+	account := ctx.Value(common.AccountKey).(accounts.Document)
+
+	to := []string{}
+	if account.Email != "" {
+		to = append(to, account.Email)
+	}
+
+	for _, email := range profile.Notifications.Emails {
+		if !slices.Contains(to, email) {
+			to = append(to, email)
+		}
+	}
+
+	if len(to) == 0 {
+		return errors.New("no email address")
+	}
+
+	t, err := template.New("t").Parse(escalationHTML)
+	if err != nil {
+		logCtx.Error("unable to parse template", fid, "error", err)
+		return err
+	}
+
+	loc, err := time.LoadLocation(account.Timezone)
+	if err != nil {
+		loc, _ = time.LoadLocation("UTC")
+	}
+
+	vars := escalationHTMLVars{
+		Body:               localize.Email["crisis_notification_body"],
+		Character:          characterName,
+		CharacterLabel:     localize.Email["moderation_notification_character_label"],
+		ContentSection:     localize.Email["moderation_notification_content_section"],
+		InformationSection: localize.Email["moderation_notification_information_section"],
+		Name:               profile.Name,
+		ProfileLabel:       localize.Email["moderation_notification_profile_label"],
+		Time:               lastUserAudio.Timestamp.In(loc).Format(time.DateTime),
+		TimeLabel:          localize.Email["moderation_notification_time_label"],
+		Title:              title,
+		User:               lastUserAudio.Text,
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		logCtx.Error("unable to execute email template", fid, "error", err)
+		return err
+	}
+
+	req := accounts.EmailRequest{
+		From:    config.VARS.MailgunNotificationsFrom,
+		To:      to,
+		Subject: title,
+		HTML:    buf.String(),
+	}
+
+	return accounts.SendEmail(ctx, logCtx, req)
+}
+
+// GetEscalations returns the escalations of all accounts, newest first, optionally filtered by acknowledgement.
+func GetEscalations(ctx context.Context, logCtx *slog.Logger, acknowledged *bool) ([]Escalation, error) {
+	fid := slog.String("fid", "vox.notifications.GetEscalations")
+
+	collection := docstore.Client.Collection("escalations")
+	if collection == nil {
+		logCtx.Error("escalations collection not found", fid)
+		return nil, common.ErrNotFound{}
+	}
+
+	var iter *docstore.DocumentIterator
+	if acknowledged != nil {
+		iter = collection.Where("acknowledged", "==", *acknowledged).Documents(ctx)
+	} else {
+		iter = collection.Documents(ctx)
+	}
+
+	docs, err := iter.GetAll()
+	if err != nil {
+		logCtx.Error("unable to get documents", fid, "error", err)
+		return nil, err
+	}
+
+	escalations := []Escalation{}
+
+	for _, doc := range docs {
+		d := Escalation{}
+		if err := doc.DataTo(&d); err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Error("unable to read escalation data", fid, "error", err)
+			return nil, err
+		}
+
+		escalations = append(escalations, d)
+	}
+
+	sort.Slice(escalations, func(i, j int) bool {
+		return escalations[i].Timestamp.After(escalations[j].Timestamp)
+	})
+
+	return escalations, nil
+}
+
+// GetEscalation returns an escalation.
+func GetEscalation(ctx context.Context, logCtx *slog.Logger, id string) (Escalation, error) {
+	fid := slog.String("fid", "vox.notifications.GetEscalation")
+
+	collection := docstore.Client.Collection("escalations")
+	if collection == nil {
+		logCtx.Error("escalations collection not found", fid)
+		return Escalation{}, common.ErrNotFound{}
+	}
+
+	doc, err := collection.Doc(id).Get(ctx)
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Warn("unable to get escalation document", fid, "error", err)
+		return Escalation{}, err
+	}
+
+	d := Escalation{}
+	if err := doc.DataTo(&d); err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to read escalation data", fid, "error", err)
+		return Escalation{}, err
+	}
+
+	return d, nil
+}
+
+// AcknowledgeEscalation marks an escalation as reviewed by the admin account in the context.
+func AcknowledgeEscalation(ctx context.Context, logCtx *slog.Logger, id, note string) (Escalation, error) {
+	fid := slog.String("fid", "vox.notifications.AcknowledgeEscalation")
+
+	admin := ctx.Value(common.AccountKey).(accounts.Document)
+
+	by := admin.Email
+	if by == "" {
+		by = admin.ID
+	}
+
+	collection := docstore.Client.Collection("escalations")
+	if collection == nil {
+		logCtx.Error("escalations collection not found", fid)
+		return Escalation{}, common.ErrNotFound{}
+	}
+
+	updates := []docstore.Update{
+		{Path: "acknowledged", Value: true},
+		{Path: "acknowledged_by", Value: by},
+		{Path: "acknowledged_at", Value: time.Now()},
+	}
+
+	if note != "" {
+		updates = append(updates, docstore.Update{Path: "note", Value: note})
+	}
+
+	if err := collection.Doc(id).Update(ctx, updates); err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Warn("unable to update escalation document", fid, "error", err)
+		return Escalation{}, err
+	}
+
+	return GetEscalation(ctx, logCtx, id)
+}
@@ -6,12 +6,12 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/google/uuid"
+
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 	"disruptive/pkg/vox/accounts"
 	"disruptive/pkg/vox/characters"
-
-	"github.com/google/uuid"
 )
 
 // ModerationValue contains the moderation notification information.
@@ -39,14 +39,14 @@ type ModerationSessionValue struct {
 	EntryNumber int                     `firestore:"entry_number" json:"entry_number"`
 }
 
-// PostModeration creates a new notification
+// PostModeration creates a new moderation notification. The review queue is written by the moderation itself.
 func PostModeration(ctx context.Context, logCtx *slog.Logger, req ModerationValue) (Document, error) {
 	fid := slog.String("fid", "vox.notifications.PostModeration")
 
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	path := fmt.Sprintf("accounts/%s/notifications", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("notifications collection not found", fid)
 		return Document{}, common.ErrNotFound{}
@@ -61,7 +61,7 @@ func PostModeration(ctx context.Context, logCtx *slog.Logger, req ModerationValu
 		ModerationValue: &req,
 	}
 
-	if _, err := collection.Doc(uuid).Create(ctx, document); err != nil {
+	if err := collection.Doc(uuid).Create(ctx, document); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to create notification document", fid, "error", err)
 		return Document{}, err
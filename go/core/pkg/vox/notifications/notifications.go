@@ -5,10 +5,8 @@ import (
 	"fmt"
 	"log/slog"
 
-	fs "cloud.google.com/go/firestore"
-
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 	"disruptive/pkg/vox/accounts"
 )
 
@@ -26,7 +24,7 @@ func GetNotifications(ctx context.Context, logCtx *slog.Logger, all, inactive bo
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	path := fmt.Sprintf("accounts/%s/notifications", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("notifications collection not found", fid)
 		return nil, common.ErrNotFound{}
@@ -34,7 +32,7 @@ func GetNotifications(ctx context.Context, logCtx *slog.Logger, all, inactive bo
 
 	documents := []Document{}
 
-	var iter *fs.DocumentIterator
+	var iter *docstore.DocumentIterator
 	if all {
 		iter = collection.Documents(ctx)
 	} else if inactive {
@@ -72,7 +70,7 @@ func GetNotification(ctx context.Context, logCtx *slog.Logger, id string) (Docum
 	d := Document{}
 
 	path := fmt.Sprintf("accounts/%s/notifications", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Warn("notifications collection not found", fid)
 		return d, common.ErrNotFound{}
@@ -101,24 +99,24 @@ func PatchNotification(ctx context.Context, logCtx *slog.Logger, document PatchD
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	path := fmt.Sprintf("accounts/%s/notifications", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Warn("notifications collection not found", fid)
 		return Document{}, common.ErrNotFound{}
 	}
 
-	update := []fs.Update{}
+	update := []docstore.Update{}
 
 	if document.Read != nil {
-		update = append(update, fs.Update{Path: "read", Value: *document.Read})
+		update = append(update, docstore.Update{Path: "read", Value: *document.Read})
 	}
 
 	if document.Inactive != nil {
-		update = append(update, fs.Update{Path: "inactive", Value: *document.Inactive})
+		update = append(update, docstore.Update{Path: "inactive", Value: *document.Inactive})
 	}
 
 	if len(update) > 0 {
-		if _, err := collection.Doc(document.ID).Update(ctx, update); err != nil {
+		if err := collection.Doc(document.ID).Update(ctx, update); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Warn("unable to update notification document", fid, "error", err)
 			return Document{}, err
@@ -141,13 +139,13 @@ func DeleteNotification(ctx context.Context, logCtx *slog.Logger, id string) err
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	path := fmt.Sprintf("accounts/%s/notifications", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Warn("notifications collection not found", fid)
 		return common.ErrNotFound{}
 	}
 
-	if _, err := collection.Doc(id).Delete(ctx); err != nil {
+	if err := collection.Doc(id).Delete(ctx); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Warn("unable to delete notification document", fid, "error", err)
 		return err
@@ -0,0 +1,454 @@
+package notifications
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
+	"disruptive/pkg/vox/accounts"
+	"disruptive/pkg/vox/characters"
+	"disruptive/pkg/vox/moderate"
+	"disruptive/pkg/vox/profiles"
+)
+
+// Review decisions.
+const (
+	ReviewFalsePositive = "false_positive"
+	ReviewConfirmed     = "confirmed"
+	ReviewEscalated     = "escalated"
+)
+
+// ReviewPending filters the reviews without a decision.
+const ReviewPending = "pending"
+
+var reviewDecisions = []string{ReviewFalsePositive, ReviewConfirmed, ReviewEscalated}
+
+// Review is a triggered moderation in the cross-account review queue.
+type Review struct {
+	ID             string             `firestore:"id" json:"id"`
+	AccountID      string             `firestore:"account_id" json:"account_id"`
+	Assistant      string             `firestore:"assistant,omitempty" json:"assistant,omitempty"`
+	AudioURL       string             `firestore:"-" json:"audio_url,omitempty"`
+	Categories     []string           `firestore:"categories" json:"categories"`
+	Character      string             `firestore:"character" json:"character"`
+	Decision       string             `firestore:"decision" json:"decision"`
+	DecisionAt     time.Time          `firestore:"decision_at,omitempty" json:"decision_at,omitempty"`
+	DecisionBy     string             `firestore:"decision_by,omitempty" json:"decision_by,omitempty"`
+	EntryNumber    int                `firestore:"entry_number" json:"entry_number"`
+	Language       string             `firestore:"language" json:"language"`
+	Moderation     *moderate.Response `firestore:"moderation" json:"moderation"`
+	Note           string             `firestore:"note,omitempty" json:"note,omitempty"`
+	NotificationID string             `firestore:"notification_id" json:"notification_id"`
+	ProfileID      string             `firestore:"profile_id" json:"profile_id"`
+	ProfileName    string             `firestore:"profile_name" json:"profile_name"`
+	ResponseAge    int                `firestore:"response_age" json:"response_age"`
+	Timestamp      time.Time          `firestore:"timestamp" json:"timestamp"`
+	User           string             `firestore:"user" json:"user"`
+	UserAudio      string             `firestore:"user_audio,omitempty" json:"-"`
+}
+
+// ReviewFilter selects reviews. Empty fields do not filter.
+type ReviewFilter struct {
+	Category string
+	Decision string
+	From     time.Time
+	Language string
+	MaxAge   int
+	MinAge   int
+	To       time.Time
+}
+
+// ReviewDecision is a reviewer's decision on a review.
+type ReviewDecision struct {
+	Decision string `json:"decision"`
+	Note     string `json:"note"`
+}
+
+// ReviewPage is a page of reviews, newest first.
+type ReviewPage struct {
+	Reviews       []Review `json:"reviews"`
+	NextPageToken string   `json:"next_page_token,omitempty"`
+}
+
+const (
+	// DefaultReviewLimit is the page size when none is requested.
+	DefaultReviewLimit = 50
+
+	// MaxReviewLimit is the largest page size.
+	MaxReviewLimit = 500
+
+	// maxReviewScan bounds the reviews read for one page when the filters skip most of them.
+	maxReviewScan = 5000
+)
+
+// PostReview adds the triggered moderation of a user audio to the review queue. The review is keyed by the audio,
+// so moderating the same audio again updates its moderation and keeps the decision and session entry.
+func PostReview(ctx context.Context, logCtx *slog.Logger, profile *profiles.Document, characterName string, lastUserAudio *characters.UserAudio) error {
+	fid := slog.String("fid", "vox.notifications.PostReview")
+
+	account := ctx.Value(common.AccountKey).(accounts.Document)
+
+	m := lastUserAudio.Moderation
+	if m == nil {
+		return nil
+	}
+
+	collection := docstore.Client.Collection("moderation_reviews")
+	if collection == nil {
+		logCtx.Error("moderation reviews collection not found", fid)
+		return common.ErrNotFound{}
+	}
+
+	d := Review{
+		ID:          reviewID(account.ID, lastUserAudio.AudioID),
+		AccountID:   account.ID,
+		Categories:  []string{},
+		Character:   characterName,
+		Language:    m.Analysis.Language,
+		Moderation:  m,
+		ProfileID:   profile.ID,
+		ProfileName: profile.Name,
+		ResponseAge: profile.ResponseAge,
+		Timestamp:   lastUserAudio.Timestamp,
+		User:        lastUserAudio.Text,
+		UserAudio:   lastUserAudio.Path,
+	}
+
+	if d.Timestamp.IsZero() {
+		d.Timestamp = time.Now()
+	}
+
+	for k, on := range m.Categories {
+		if on {
+			d.Categories = append(d.Categories, k)
+		}
+	}
+
+	sort.Strings(d.Categories)
+
+	err := collection.Doc(d.ID).Create(ctx, d)
+	if err == nil {
+		return nil
+	}
+
+	err = common.ConvertGRPCError(err)
+	if !errors.Is(err, common.ErrAlreadyExists{}) {
+		logCtx.Error("unable to create moderation review document", fid, "error", err)
+		return err
+	}
+
+	updates := []docstore.Update{
+		{Path: "categories", Value: d.Categories},
+		{Path: "language", Value: d.Language},
+		{Path: "moderation", Value: d.Moderation},
+	}
+
+	if err := collection.Doc(d.ID).Update(ctx, updates); err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to update moderation review document", fid, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateReviewEntry records the session entry, and the notification when one was sent, on the review of a user audio.
+func UpdateReviewEntry(ctx context.Context, logCtx *slog.Logger, audioID, notificationID, assistant string, entryNumber int) error {
+	fid := slog.String("fid", "vox.notifications.UpdateReviewEntry")
+
+	account := ctx.Value(common.AccountKey).(accounts.Document)
+
+	collection := docstore.Client.Collection("moderation_reviews")
+	if collection == nil {
+		logCtx.Error("moderation reviews collection not found", fid)
+		return common.ErrNotFound{}
+	}
+
+	updates := []docstore.Update{
+		{Path: "assistant", Value: assistant},
+		{Path: "entry_number", Value: entryNumber},
+		{Path: "notification_id", Value: notificationID},
+	}
+
+	if err := collection.Doc(reviewID(account.ID, audioID)).Update(ctx, updates); err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Warn("unable to update moderation review document", fid, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func reviewID(accountID, audioID string) string {
+	return fmt.Sprintf("%s_%s", accountID, audioID)
+}
+
+// GetReviews returns a page of the reviews of all accounts matching the filter, newest first. pageToken is the
+// NextPageToken of the previous page. A page may be short when the filters skip many reviews, the next page
+// continues the scan.
+func GetReviews(ctx context.Context, logCtx *slog.Logger, filter ReviewFilter, limit int, pageToken string) (ReviewPage, error) {
+	fid := slog.String("fid", "vox.notifications.GetReviews")
+
+	if err := filter.Validate(); err != nil {
+		return ReviewPage{}, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultReviewLimit
+	}
+
+	if limit > MaxReviewLimit {
+		limit = MaxReviewLimit
+	}
+
+	collection := docstore.Client.Collection("moderation_reviews")
+	if collection == nil {
+		logCtx.Error("moderation reviews collection not found", fid)
+		return ReviewPage{}, common.ErrNotFound{}
+	}
+
+	query := collection.OrderBy("timestamp", docstore.Desc).OrderBy("id", docstore.Desc)
+
+	switch filter.Decision {
+	case "":
+	case ReviewPending:
+		query = query.Where("decision", "==", "")
+	default:
+		query = query.Where("decision", "==", filter.Decision)
+	}
+
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp", ">=", filter.From)
+	}
+
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp", "<", filter.To)
+	}
+
+	var last Review
+
+	if pageToken != "" {
+		doc, err := collection.Doc(pageToken).Get(ctx)
+		if err == nil {
+			err = doc.DataTo(&last)
+		}
+
+		if err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Warn("invalid page token", fid, "page_token", pageToken, "error", err)
+			return ReviewPage{}, common.ErrBadRequest{Msg: "invalid page token"}
+		}
+	}
+
+	// One more review than the page is collected to know whether there is a next page.
+	reviews := []Review{}
+	scanned := 0
+
+	for len(reviews) <= limit && scanned < maxReviewScan {
+		q := query.Limit(limit + 1)
+		if last.ID != "" {
+			q = q.StartAfter(last.Timestamp, last.ID)
+		}
+
+		docs, err := q.Documents(ctx).GetAll()
+		if err != nil {
+			err = common.ConvertGRPCError(err)
+			logCtx.Error("unable to get documents", fid, "error", err)
+			return ReviewPage{}, err
+		}
+
+		for _, doc := range docs {
+			d := Review{}
+			if err := doc.DataTo(&d); err != nil {
+				err = common.ConvertGRPCError(err)
+				logCtx.Error("unable to read moderation review data", fid, "error", err)
+				return ReviewPage{}, err
+			}
+
+			last = d
+			scanned++
+
+			if filter.match(d) {
+				reviews = append(reviews, d)
+				if len(reviews) > limit {
+					break
+				}
+			}
+		}
+
+		if len(docs) <= limit && len(reviews) <= limit {
+			return ReviewPage{Reviews: reviews}, nil
+		}
+	}
+
+	page := ReviewPage{Reviews: reviews}
+
+	switch {
+	case len(reviews) > limit:
+		page.Reviews = reviews[:limit]
+		page.NextPageToken = reviews[limit-1].ID
+	default:
+		// The scan limit was reached, the next page continues after the last review read.
+		page.NextPageToken = last.ID
+	}
+
+	return page, nil
+}
+
+// ExportReviews writes the decided reviews matching the filter as a labeled CSV dataset, reading them page by page.
+func ExportReviews(ctx context.Context, logCtx *slog.Logger, w io.Writer, filter ReviewFilter) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(reviewsCSVHeader); err != nil {
+		return err
+	}
+
+	pageToken := ""
+
+	for {
+		page, err := GetReviews(ctx, logCtx, filter, MaxReviewLimit, pageToken)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range page.Reviews {
+			if r.Decision == "" || r.Moderation == nil {
+				continue
+			}
+
+			if err := cw.Write(reviewCSVRecord(r)); err != nil {
+				return err
+			}
+		}
+
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+
+		if page.NextPageToken == "" {
+			return nil
+		}
+
+		pageToken = page.NextPageToken
+	}
+}
+
+// Validate checks the filter decision.
+func (f ReviewFilter) Validate() error {
+	if f.Decision != "" && f.Decision != ReviewPending && !slices.Contains(reviewDecisions, f.Decision) {
+		return common.ErrBadRequest{Src: "decision", Msg: fmt.Sprintf("invalid decision %q", f.Decision)}
+	}
+
+	return nil
+}
+
+// match applies the filters not handled by the query.
+func (f ReviewFilter) match(r Review) bool {
+	switch {
+	case f.Category != "" && !slices.Contains(r.Categories, f.Category):
+		return false
+	case f.Language != "" && !strings.EqualFold(f.Language, r.Language):
+		return false
+	case f.MinAge > 0 && r.ResponseAge < f.MinAge:
+		return false
+	case f.MaxAge > 0 && r.ResponseAge > f.MaxAge:
+		return false
+	}
+
+	return true
+}
+
+// GetReview returns a review.
+func GetReview(ctx context.Context, logCtx *slog.Logger, id string) (Review, error) {
+	fid := slog.String("fid", "vox.notifications.GetReview")
+
+	collection := docstore.Client.Collection("moderation_reviews")
+	if collection == nil {
+		logCtx.Error("moderation reviews collection not found", fid)
+		return Review{}, common.ErrNotFound{}
+	}
+
+	doc, err := collection.Doc(id).Get(ctx)
+	if err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Warn("unable to get moderation review document", fid, "error", err)
+		return Review{}, err
+	}
+
+	d := Review{}
+	if err := doc.DataTo(&d); err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Error("unable to read moderation review data", fid, "error", err)
+		return Review{}, err
+	}
+
+	return d, nil
+}
+
+// DecideReview records the decision of the admin account in the context on a review.
+func DecideReview(ctx context.Context, logCtx *slog.Logger, id string, decision ReviewDecision) (Review, error) {
+	fid := slog.String("fid", "vox.notifications.DecideReview")
+
+	if !slices.Contains(reviewDecisions, decision.Decision) {
+		return Review{}, common.ErrBadRequest{Src: "decision", Msg: fmt.Sprintf("invalid decision %q", decision.Decision)}
+	}
+
+	admin := ctx.Value(common.AccountKey).(accounts.Document)
+
+	by := admin.Email
+	if by == "" {
+		by = admin.ID
+	}
+
+	collection := docstore.Client.Collection("moderation_reviews")
+	if collection == nil {
+		logCtx.Error("moderation reviews collection not found", fid)
+		return Review{}, common.ErrNotFound{}
+	}
+
+	updates := []docstore.Update{
+		{Path: "decision", Value: decision.Decision},
+		{Path: "decision_at", Value: time.Now()},
+		{Path: "decision_by", Value: by},
+		{Path: "note", Value: decision.Note},
+	}
+
+	if err := collection.Doc(id).Update(ctx, updates); err != nil {
+		err = common.ConvertGRPCError(err)
+		logCtx.Warn("unable to update moderation review document", fid, "error", err)
+		return Review{}, err
+	}
+
+	return GetReview(ctx, logCtx, id)
+}
+
+var reviewsCSVHeader = []string{"id", "label", "text", "language", "response_age", "categories", "assessment_age", "toxic", "action", "rule", "source", "assessment", "timestamp"}
+
+func reviewCSVRecord(r Review) []string {
+	return []string{
+		r.ID,
+		r.Decision,
+		r.User,
+		r.Language,
+		strconv.Itoa(r.ResponseAge),
+		strings.Join(r.Categories, ";"),
+		strconv.Itoa(r.Moderation.Analysis.AssessmentAge),
+		strconv.FormatBool(r.Moderation.Analysis.Toxic),
+		r.Moderation.Action,
+		r.Moderation.Rule,
+		r.Moderation.Source,
+		r.Moderation.Analysis.Assessment,
+		r.Timestamp.UTC().Format(time.RFC3339),
+	}
+}
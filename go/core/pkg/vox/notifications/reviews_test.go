@@ -0,0 +1,200 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"disruptive/lib/common"
+	"disruptive/lib/docstore"
+	"disruptive/pkg/vox/accounts"
+	"disruptive/pkg/vox/characters"
+	"disruptive/pkg/vox/moderate"
+	"disruptive/pkg/vox/profiles"
+)
+
+var testLogCtx = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func setupReviews(t *testing.T) context.Context {
+	t.Helper()
+
+	backend, err := docstore.NewMemory("")
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+
+	store := docstore.Client
+	t.Cleanup(func() { docstore.Client = store })
+
+	docstore.Client = docstore.New(backend)
+
+	return context.WithValue(context.Background(), common.AccountKey, accounts.Document{ID: "account-1"})
+}
+
+// addReviews adds n reviews, two per second so pages split reviews with the same timestamp.
+func addReviews(t *testing.T, ctx context.Context, n int) {
+	t.Helper()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < n; i++ {
+		r := Review{
+			ID:         fmt.Sprintf("account-1_audio-%03d", i),
+			AccountID:  "account-1",
+			Categories: []string{"harassment"},
+			Moderation: &moderate.Response{},
+			Timestamp:  base.Add(time.Duration(i/2) * time.Second),
+		}
+
+		if i%3 == 0 {
+			r.Categories = []string{"violence"}
+			r.Decision = ReviewConfirmed
+		}
+
+		if err := docstore.Client.Collection("moderation_reviews").Doc(r.ID).Set(ctx, r); err != nil {
+			t.Fatalf("set review: %v", err)
+		}
+	}
+}
+
+func pageAll(t *testing.T, ctx context.Context, filter ReviewFilter, limit int) []Review {
+	t.Helper()
+
+	all := []Review{}
+	token := ""
+
+	for i := 0; ; i++ {
+		if i > 100 {
+			t.Fatalf("too many pages")
+		}
+
+		page, err := GetReviews(ctx, testLogCtx, filter, limit, token)
+		if err != nil {
+			t.Fatalf("GetReviews: %v", err)
+		}
+
+		if len(page.Reviews) > limit {
+			t.Fatalf("page of %d reviews, want at most %d", len(page.Reviews), limit)
+		}
+
+		all = append(all, page.Reviews...)
+
+		if page.NextPageToken == "" {
+			return all
+		}
+		token = page.NextPageToken
+	}
+}
+
+func TestGetReviewsPages(t *testing.T) {
+	ctx := setupReviews(t)
+	addReviews(t, ctx, 25)
+
+	tests := []struct {
+		name   string
+		filter ReviewFilter
+		want   int
+	}{
+		{name: "all", want: 25},
+		{name: "category", filter: ReviewFilter{Category: "violence"}, want: 9},
+		{name: "pending", filter: ReviewFilter{Decision: ReviewPending}, want: 16},
+		{name: "range", filter: ReviewFilter{From: time.Date(2026, 1, 1, 0, 0, 5, 0, time.UTC), To: time.Date(2026, 1, 1, 0, 0, 10, 0, time.UTC)}, want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reviews := pageAll(t, ctx, tt.filter, 4)
+			if len(reviews) != tt.want {
+				t.Fatalf("got %d reviews, want %d", len(reviews), tt.want)
+			}
+
+			seen := map[string]bool{}
+			for i, r := range reviews {
+				if seen[r.ID] {
+					t.Fatalf("review %s returned twice", r.ID)
+				}
+				seen[r.ID] = true
+
+				if i > 0 && (r.Timestamp.After(reviews[i-1].Timestamp) || (r.Timestamp.Equal(reviews[i-1].Timestamp) && r.ID > reviews[i-1].ID)) {
+					t.Fatalf("review %s out of order", r.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestGetReviewsInvalid(t *testing.T) {
+	ctx := setupReviews(t)
+
+	if _, err := GetReviews(ctx, testLogCtx, ReviewFilter{}, 10, "missing"); !errors.Is(err, common.ErrBadRequest{}) {
+		t.Errorf("invalid page token error = %v, want ErrBadRequest", err)
+	}
+
+	if _, err := GetReviews(ctx, testLogCtx, ReviewFilter{Decision: "maybe"}, 10, ""); !errors.Is(err, common.ErrBadRequest{}) {
+		t.Errorf("invalid decision error = %v, want ErrBadRequest", err)
+	}
+}
+
+func TestPostReview(t *testing.T) {
+	ctx := setupReviews(t)
+
+	profile := &profiles.Document{ID: "profile-1", Name: "Sam", ResponseAge: 8}
+	lastUserAudio := &characters.UserAudio{
+		AudioID:    "audio-1",
+		Moderation: &moderate.Response{Categories: map[string]bool{"violence": true, "hate": false}},
+		Path:       "accounts/account-1/audio-1.wav",
+		Text:       "some text",
+	}
+
+	if err := PostReview(ctx, testLogCtx, profile, "ginger", lastUserAudio); err != nil {
+		t.Fatalf("PostReview: %v", err)
+	}
+
+	if err := UpdateReviewEntry(ctx, testLogCtx, "audio-1", "notification-1", "moderated", 3); err != nil {
+		t.Fatalf("UpdateReviewEntry: %v", err)
+	}
+
+	r, err := GetReview(ctx, testLogCtx, "account-1_audio-1")
+	if err != nil {
+		t.Fatalf("GetReview: %v", err)
+	}
+
+	if r.ProfileID != "profile-1" || r.ResponseAge != 8 || r.User != "some text" || r.UserAudio != lastUserAudio.Path || r.Decision != "" {
+		t.Errorf("review = %+v", r)
+	}
+
+	if strings.Join(r.Categories, ",") != "violence" || r.NotificationID != "notification-1" || r.EntryNumber != 3 || r.Assistant != "moderated" {
+		t.Errorf("review = %+v", r)
+	}
+
+	var b strings.Builder
+	if _, err := DecideReview(ctx, testLogCtx, r.ID, ReviewDecision{Decision: ReviewConfirmed}); err != nil {
+		t.Fatalf("DecideReview: %v", err)
+	}
+
+	// Moderating the audio again keeps the decision and session entry.
+	if err := PostReview(ctx, testLogCtx, profile, "ginger", lastUserAudio); err != nil {
+		t.Fatalf("PostReview again: %v", err)
+	}
+
+	if r, err = GetReview(ctx, testLogCtx, r.ID); err != nil {
+		t.Fatalf("GetReview: %v", err)
+	}
+
+	if r.Decision != ReviewConfirmed || r.EntryNumber != 3 || r.NotificationID != "notification-1" {
+		t.Errorf("review after moderating again = %+v", r)
+	}
+
+	if err := ExportReviews(ctx, testLogCtx, &b, ReviewFilter{}); err != nil {
+		t.Fatalf("ExportReviews: %v", err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(b.String()), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], "account-1_audio-1,confirmed,some text") {
+		t.Errorf("export = %q", b.String())
+	}
+}
@@ -11,13 +11,10 @@ import (
 	"path/filepath"
 
 	"disruptive/lib/common"
+	"disruptive/lib/docstore"
 	"disruptive/lib/firebase"
-	"disruptive/lib/firestore"
-	"disruptive/lib/gcp"
+	"disruptive/lib/objectstore"
 	"disruptive/pkg/vox/accounts"
-
-	fs "cloud.google.com/go/firestore"
-	"cloud.google.com/go/storage"
 )
 
 // GetProfilePicture retrieves a profile's picture.
@@ -35,9 +32,9 @@ func GetProfilePicture(ctx context.Context, logCtx *slog.Logger, profileID strin
 		return nil, "", common.ErrNotFound{Msg: "profile picture not found"}
 	}
 
-	rc, cType, err := gcp.Storage.Download(ctx, firebase.GCSBucket, profile.Picture)
+	rc, cType, err := objectstore.Client.Download(ctx, firebase.GCSBucket, profile.Picture)
 	if err != nil {
-		if errors.Is(err, storage.ErrObjectNotExist) {
+		if errors.Is(err, objectstore.ErrObjectNotExist) {
 			logCtx.Error("unable to download profile picture", fid, "error", err)
 			return nil, "", common.ErrGone{Msg: "profile picture gone"}
 		}
@@ -59,21 +56,21 @@ func PutProfilePicture(ctx context.Context, logCtx *slog.Logger, profileID, file
 	contentType := mime.TypeByExtension(ext)
 	gcsPath := filepath.Join("store", "accounts", account.ID, "profiles", profileID, "picture"+ext)
 
-	if err := gcp.Storage.Upload(ctx, r, firebase.GCSBucket, gcsPath, contentType); err != nil {
+	if err := objectstore.Client.Upload(ctx, r, firebase.GCSBucket, gcsPath, contentType); err != nil {
 		logCtx.Error("unable to upload profile picture", fid, "error", err)
 		return err
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not found", fid)
 		return common.ErrNotFound{}
 	}
 
-	update := []fs.Update{{Path: "picture", Value: gcsPath}}
+	update := []docstore.Update{{Path: "picture", Value: gcsPath}}
 
-	if _, err := collection.Doc(profileID).Update(ctx, update); err != nil {
+	if err := collection.Doc(profileID).Update(ctx, update); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to update profile document", fid, "error", err)
 		return err
@@ -6,10 +6,8 @@ import (
 	"fmt"
 	"log/slog"
 
-	fs "cloud.google.com/go/firestore"
-
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/docstore"
 	"disruptive/pkg/vox/accounts"
 )
 
@@ -20,7 +18,7 @@ func GetPreferences(ctx context.Context, logCtx *slog.Logger, profileID string)
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	path := fmt.Sprintf("accounts/%s/profiles", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not found", fid)
 		return nil, common.ErrNotFound{}
@@ -51,17 +49,17 @@ func PutPreferences(ctx context.Context, logCtx *slog.Logger, profileID string,
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	path := fmt.Sprintf("accounts/%s/profiles", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not found", fid)
 		return common.ErrNotFound{}
 	}
 
-	update := []fs.Update{
+	update := []docstore.Update{
 		{Path: "preferences", Value: preferences},
 	}
 
-	if _, err := collection.Doc(profileID).Update(ctx, update); err != nil {
+	if err := collection.Doc(profileID).Update(ctx, update); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to update profiles preferences", fid, "error", err)
 		return err
@@ -77,19 +75,19 @@ func PatchPreferences(ctx context.Context, logCtx *slog.Logger, profileID string
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	path := fmt.Sprintf("accounts/%s/profiles", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not fonud", fid)
 		return nil, common.ErrNotFound{}
 	}
 
-	updates := make([]fs.Update, 0, len(preferences))
+	updates := make([]docstore.Update, 0, len(preferences))
 	for k, v := range preferences {
-		updates = append(updates, fs.Update{Path: "preferences." + k, Value: v})
+		updates = append(updates, docstore.Update{Path: "preferences." + k, Value: v})
 	}
 
 	if len(updates) > 0 {
-		if _, err := collection.Doc(profileID).Update(ctx, updates); err != nil {
+		if err := collection.Doc(profileID).Update(ctx, updates); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to update profiles preferences", fid, "error", err)
 			return nil, err
@@ -112,18 +110,18 @@ func DeletePreferences(ctx context.Context, logCtx *slog.Logger, profileID strin
 	account := ctx.Value(common.AccountKey).(accounts.Document)
 
 	path := fmt.Sprintf("accounts/%s/profiles", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not found", fid)
 		return nil, common.ErrNotFound{}
 	}
 
-	updates := make([]fs.Update, 0, len(preferences))
+	updates := make([]docstore.Update, 0, len(preferences))
 	for _, v := range preferences {
-		updates = append(updates, fs.Update{Path: "preferences." + v, Value: fs.Delete})
+		updates = append(updates, docstore.Update{Path: "preferences." + v, Value: docstore.Delete})
 	}
 
-	if _, err := collection.Doc(profileID).Update(ctx, updates); err != nil {
+	if err := collection.Doc(profileID).Update(ctx, updates); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to delete profiles preferences", fid, "error", err)
 		return nil, err
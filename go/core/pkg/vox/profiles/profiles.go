@@ -6,12 +6,12 @@ import (
 	"log/slog"
 	"time"
 
-	fs "cloud.google.com/go/firestore"
 	"github.com/google/uuid"
 	"google.golang.org/api/iterator"
 
 	"disruptive/lib/common"
-	"disruptive/lib/firestore"
+	"disruptive/lib/configs"
+	"disruptive/lib/docstore"
 	"disruptive/pkg/vox/accounts"
 	"disruptive/pkg/vox/moderate"
 )
@@ -26,6 +26,7 @@ type Document struct {
 	Inactive             bool                            `firestore:"inactive" json:"inactive,omitempty"`
 	Interests            []string                        `firestore:"interests" json:"interests,omitempty"`
 	Moderate             bool                            `firestore:"moderate" json:"moderate,omitempty"`
+	ModerationPolicy     *ModerationPolicy               `firestore:"moderation_policy,omitempty" json:"moderation_policy,omitempty"`
 	ModifiedDate         time.Time                       `firestore:"modified_date" json:"modified_date"`
 	Name                 string                          `firestore:"name" json:"name"`
 	Notifications        Notifications                   `firestore:"notifications" json:"notifications,omitempty"`
@@ -36,6 +37,8 @@ type Document struct {
 	SelectedCharacter    string                          `firestore:"selected_character" json:"selected_character"`
 	TopicsDiscourage     []string                        `firestore:"topics_discourage" json:"topics_discourage"`
 	TopicsEncourage      []string                        `firestore:"topics_encourage" json:"topics_encourage"`
+	Usage                *accounts.Usage                 `firestore:"-" json:"usage,omitempty"`
+	UsageLimits          *accounts.UsageLimits           `firestore:"usage_limits,omitempty" json:"usage_limits,omitempty"`
 }
 
 // PatchDocument contains a firestore profile document.
@@ -45,6 +48,7 @@ type PatchDocument struct {
 	Inactive             *bool                 `json:"inactive"`
 	Interests            *[]string             `json:"interests"`
 	Moderate             *bool                 `json:"moderate"`
+	ModerationPolicy     *ModerationPolicy     `json:"moderation_policy"`
 	Name                 *string               `json:"name"`
 	Notifications        *Notifications        `json:"notifications"`
 	ReplaceWords         *map[string]*[]string `json:"replace_words"`
@@ -52,6 +56,14 @@ type PatchDocument struct {
 	SelectedCharacter    *string               `json:"selected_character"`
 	TopicsDiscourage     *[]string             `json:"topics_discourage"`
 	TopicsEncourage      *[]string             `json:"topics_encourage"`
+	UsageLimits          *accounts.UsageLimits `json:"usage_limits"`
+}
+
+// ModerationPolicy contains a profile's moderation rules, evaluated before the rules of its preset.
+// An empty preset uses the preset of the profile's response age.
+type ModerationPolicy struct {
+	Preset string                   `firestore:"preset,omitempty" json:"preset,omitempty"`
+	Rules  []configs.ModerationRule `firestore:"rules,omitempty" json:"rules,omitempty"`
 }
 
 // CharacterPreferences contains a firestore profile character document.
@@ -111,8 +123,20 @@ func Create(ctx context.Context, logCtx *slog.Logger, document Document) (Docume
 		return Document{}, common.ErrBadRequest{Src: "profile", Msg: "invalid profile name"}
 	}
 
+	if document.UsageLimits != nil {
+		if err := accounts.ValidateUsageLimits(*document.UsageLimits); err != nil {
+			return Document{}, err
+		}
+	}
+
+	if document.ModerationPolicy != nil {
+		if err := configs.ValidateModerationRules(document.ModerationPolicy.Rules); err != nil {
+			return Document{}, err
+		}
+	}
+
 	path := fmt.Sprintf("accounts/%s/profiles", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not found", fid)
 		return Document{}, common.ErrNotFound{}
@@ -123,7 +147,7 @@ func Create(ctx context.Context, logCtx *slog.Logger, document Document) (Docume
 	document.AddQuestionFrequency = 60
 	document.CreatedDate = time.Now()
 
-	if _, err := collection.Doc(uuid).Create(ctx, document); err != nil {
+	if err := collection.Doc(uuid).Create(ctx, document); err != nil {
 		err = common.ConvertGRPCError(err)
 		logCtx.Error("unable to create accounts document", fid, "error", err)
 		return Document{}, err
@@ -141,13 +165,13 @@ func Get(ctx context.Context, logCtx *slog.Logger, all, inactive bool) ([]Docume
 	docs := []Document{}
 
 	path := fmt.Sprintf("accounts/%s/profiles", user.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not found", fid)
 		return nil, common.ErrNotFound{}
 	}
 
-	var iter *fs.DocumentIterator
+	var iter *docstore.DocumentIterator
 	if all {
 		iter = collection.Documents(ctx)
 	} else if inactive {
@@ -193,7 +217,7 @@ func GetByID(ctx context.Context, logCtx *slog.Logger, id string) (Document, err
 	d := Document{}
 
 	path := fmt.Sprintf("accounts/%s/profiles", account.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not found", fid)
 		return d, common.ErrNotFound{}
@@ -228,7 +252,7 @@ func GetByName(ctx context.Context, logCtx *slog.Logger, name string) ([]Documen
 	docs := []Document{}
 
 	path := fmt.Sprintf("accounts/%s/profiles", user.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not found", fid)
 		return nil, common.ErrNotFound{}
@@ -268,7 +292,7 @@ func GetIDsByAccount(ctx context.Context, logCtx *slog.Logger, id string) ([]str
 	fid := slog.String("fid", "vox.profiles.GetIDsByAccount")
 
 	path := fmt.Sprintf("accounts/%s/profiles", id)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not found", fid)
 		return nil, common.ErrNotFound{}
@@ -303,16 +327,16 @@ func Patch(ctx context.Context, logCtx *slog.Logger, profileID string, document
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles", user.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not found", fid)
 		return Document{}, common.ErrNotFound{}
 	}
 
-	update := []fs.Update{}
+	update := []docstore.Update{}
 
 	if document.Name != nil {
-		update = append(update, fs.Update{Path: "name", Value: *document.Name})
+		update = append(update, docstore.Update{Path: "name", Value: *document.Name})
 
 		res := moderate.Get(ctx, logCtx, *document.Name, "en-US")
 
@@ -328,59 +352,75 @@ func Patch(ctx context.Context, logCtx *slog.Logger, profileID string, document
 	}
 
 	if document.AddQuestionFrequency != nil {
-		update = append(update, fs.Update{Path: "add_question_frequency", Value: *document.AddQuestionFrequency})
+		update = append(update, docstore.Update{Path: "add_question_frequency", Value: *document.AddQuestionFrequency})
 	}
 
 	if document.DontSay != nil {
-		update = append(update, fs.Update{Path: "dont_say", Value: *document.DontSay})
+		update = append(update, docstore.Update{Path: "dont_say", Value: *document.DontSay})
 	}
 
 	if document.Inactive != nil {
-		update = append(update, fs.Update{Path: "inactive", Value: *document.Inactive})
+		update = append(update, docstore.Update{Path: "inactive", Value: *document.Inactive})
 	}
 
 	if document.Interests != nil {
-		update = append(update, fs.Update{Path: "interests", Value: *document.Interests})
+		update = append(update, docstore.Update{Path: "interests", Value: *document.Interests})
 	}
 
 	if document.Moderate != nil {
-		update = append(update, fs.Update{Path: "moderate", Value: *document.Moderate})
+		update = append(update, docstore.Update{Path: "moderate", Value: *document.Moderate})
+	}
+
+	if document.ModerationPolicy != nil {
+		if err := configs.ValidateModerationRules(document.ModerationPolicy.Rules); err != nil {
+			return Document{}, err
+		}
+
+		update = append(update, docstore.Update{Path: "moderation_policy", Value: *document.ModerationPolicy})
 	}
 
 	if document.Notifications != nil {
-		update = append(update, fs.Update{Path: "notifications", Value: *document.Notifications})
+		update = append(update, docstore.Update{Path: "notifications", Value: *document.Notifications})
 	}
 
 	if document.ReplaceWords != nil {
 		for k, v := range *document.ReplaceWords {
 			if v != nil {
-				update = append(update, fs.Update{Path: "replace_words." + k, Value: v})
+				update = append(update, docstore.Update{Path: "replace_words." + k, Value: v})
 			} else {
-				update = append(update, fs.Update{Path: "replace_words." + k, Value: fs.Delete})
+				update = append(update, docstore.Update{Path: "replace_words." + k, Value: docstore.Delete})
 			}
 		}
 	}
 
 	if document.ResponseAge != nil {
-		update = append(update, fs.Update{Path: "response_age", Value: *document.ResponseAge})
+		update = append(update, docstore.Update{Path: "response_age", Value: *document.ResponseAge})
 	}
 
 	if document.SelectedCharacter != nil {
-		update = append(update, fs.Update{Path: "selected_character", Value: *document.SelectedCharacter})
+		update = append(update, docstore.Update{Path: "selected_character", Value: *document.SelectedCharacter})
 	}
 
 	if document.TopicsDiscourage != nil {
-		update = append(update, fs.Update{Path: "topics_discourage", Value: *document.TopicsDiscourage})
+		update = append(update, docstore.Update{Path: "topics_discourage", Value: *document.TopicsDiscourage})
 	}
 
 	if document.TopicsEncourage != nil {
-		update = append(update, fs.Update{Path: "topics_encourage", Value: *document.TopicsEncourage})
+		update = append(update, docstore.Update{Path: "topics_encourage", Value: *document.TopicsEncourage})
+	}
+
+	if document.UsageLimits != nil {
+		if err := accounts.ValidateUsageLimits(*document.UsageLimits); err != nil {
+			return Document{}, err
+		}
+
+		update = append(update, docstore.Update{Path: "usage_limits", Value: *document.UsageLimits})
 	}
 
 	if len(update) > 0 {
-		update = append(update, fs.Update{Path: "modified_date", Value: time.Now()})
+		update = append(update, docstore.Update{Path: "modified_date", Value: time.Now()})
 
-		if _, err := collection.Doc(profileID).Update(ctx, update); err != nil {
+		if err := collection.Doc(profileID).Update(ctx, update); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to update profile document", fid, "error", err)
 			return Document{}, err
@@ -408,32 +448,32 @@ func PatchCharacter(ctx context.Context, logCtx *slog.Logger, profileID, charact
 	}
 
 	path := fmt.Sprintf("accounts/%s/profiles", user.ID)
-	collection := firestore.Client.Collection(path)
+	collection := docstore.Client.Collection(path)
 	if collection == nil {
 		logCtx.Error("profiles collection not found", fid)
 		return Document{}, common.ErrNotFound{}
 	}
 
-	update := []fs.Update{}
+	update := []docstore.Update{}
 
 	if document.ImageStyle != nil {
-		update = append(update, fs.Update{Path: "characters." + characterName + ".image_style", Value: *document.ImageStyle})
+		update = append(update, docstore.Update{Path: "characters." + characterName + ".image_style", Value: *document.ImageStyle})
 	}
 
 	if document.Language != nil {
-		update = append(update, fs.Update{Path: "characters." + characterName + ".language", Value: *document.Language})
+		update = append(update, docstore.Update{Path: "characters." + characterName + ".language", Value: *document.Language})
 	}
 
 	if document.Mode != nil {
-		update = append(update, fs.Update{Path: "characters." + characterName + ".mode", Value: *document.Mode})
+		update = append(update, docstore.Update{Path: "characters." + characterName + ".mode", Value: *document.Mode})
 	}
 
 	if document.Voice != nil {
-		update = append(update, fs.Update{Path: "characters." + characterName + ".voice", Value: *document.Voice})
+		update = append(update, docstore.Update{Path: "characters." + characterName + ".voice", Value: *document.Voice})
 	}
 
 	if len(update) > 0 {
-		if _, err := collection.Doc(profileID).Update(ctx, update); err != nil {
+		if err := collection.Doc(profileID).Update(ctx, update); err != nil {
 			err = common.ConvertGRPCError(err)
 			logCtx.Error("unable to update profile document", fid, "error", err)
 			return Document{}, err
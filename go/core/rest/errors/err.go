@@ -59,6 +59,10 @@ func Err(logCtx *slog.Logger, err error, fid slog.Attr, msg string) *echo.HTTPEr
 		logCtx.Warn("not found", fid, "error", err)
 		return echo.NewHTTPError(http.StatusNotFound, "not found")
 
+	case errors.Is(err, common.ErrNoSpeech{}):
+		logCtx.Warn("no speech", fid, "error", err)
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "no speech: "+err.Error())
+
 	case errors.Is(err, common.ErrNoResults): // TG specific. TG can "successfully" return no results.
 		logCtx.Warn("no results", fid, "error", err)
 		return echo.NewHTTPError(http.StatusNotFound, "no results")
@@ -0,0 +1,96 @@
+package deepgram
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"disruptive/lib/deepgram"
+	"disruptive/rest/auth"
+)
+
+var (
+	websocketUpgrader = websocket.Upgrader{CheckOrigin: checkOrigin}
+)
+
+func checkOrigin(_ *http.Request) bool {
+	return true
+}
+
+// GetSTTLiveWS is the REST API for Deepgram live STT.
+// Audio is sent as binary frames and a text frame ends the audio. Interim, final and utterance_end results
+// are sent as they arrive followed by a transcript message with the final text.
+func GetSTTLiveWS(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.lib.deepgram.GetSTTLiveWS")
+
+	ws, err := websocketUpgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		logCtx.Error("unable to upgrade to websocket", fid, "error", err)
+		return err
+	}
+	defer ws.Close()
+
+	language := c.QueryParam("language")
+	version := strings.ToLower(c.QueryParam("version"))
+	encoding := strings.ToLower(c.QueryParam("encoding"))
+
+	var wsMutex sync.Mutex
+
+	send := func(v any) {
+		wsMutex.Lock()
+		defer wsMutex.Unlock()
+
+		ws.WriteJSON(v)
+	}
+
+	options := deepgram.LiveOptions{
+		Language: language,
+		Version:  version,
+		Encoding: encoding,
+		OnResult: func(res deepgram.LiveResult) { send(res) },
+	}
+
+	if encoding == "linear16" {
+		options.SampleRate = 16000
+	}
+
+	l, err := deepgram.NewLiveTranscription(ctx, logCtx, options)
+	if err != nil {
+		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseAbnormalClosure, "unable to create deepgram live transcription"))
+		return nil
+	}
+
+	for {
+		mt, data, err := ws.ReadMessage()
+		if err != nil {
+			l.Close()
+			return nil
+		}
+
+		if mt == websocket.TextMessage {
+			break
+		}
+
+		if _, err := l.Write(data); err != nil {
+			logCtx.Error("unable to send audio", fid, "error", err)
+			l.Close()
+			ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseAbnormalClosure, "unable to send audio"))
+			return nil
+		}
+	}
+
+	text, err := l.Close()
+	if err != nil {
+		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseAbnormalClosure, "unable to create deepgram transcription"))
+		return nil
+	}
+
+	send(map[string]any{"type": "transcript", "language": language, "text": text})
+
+	ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+
+	return nil
+}
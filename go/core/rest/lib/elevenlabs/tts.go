@@ -36,6 +36,10 @@ func PostTTS(c echo.Context) error {
 		req.Format = "pcm_16000"
 	} else if req.Format == "opus" {
 		req.Format = "opus_16000"
+	} else if req.Format == "ogg" {
+		req.Format = "ogg_opus_16000"
+	} else if req.Format == "wav" {
+		req.Format = "wav_16000"
 	}
 
 	if _, ok := elevenlabs.AudioFormatExtensions[req.Format]; !ok {
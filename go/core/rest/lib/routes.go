@@ -23,6 +23,7 @@ func Routes(e *echo.Echo) {
 	g = e.Group("/api/lib/deepgram", auth.SetMiddleware)
 	g.POST("/stt/file", deepgram.PostSTTFile)
 	g.POST("/stt/stream", deepgram.PostSTTStream)
+	g.GET("/stt/live", deepgram.GetSTTLiveWS)
 
 	// Elevenlabs
 	g = e.Group("/api/lib/elevenlabs", auth.SetMiddleware)
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
@@ -59,7 +60,7 @@ func AddBalance(c echo.Context) error {
 	return c.JSON(http.StatusOK, res)
 }
 
-// ChargeBank charges an account's balance.
+// ChargeBank charges an account's balance. A repeated charge with the same idempotency key returns the original balance.
 func ChargeBank(c echo.Context) error {
 	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.accounts.ChargeBank")
 
@@ -74,7 +75,20 @@ func ChargeBank(c echo.Context) error {
 		return e.ErrBad(logCtx, fid, "tier required")
 	}
 
-	res, err := accounts.ChargeBank(ctx, logCtx, characterVersion, tier)
+	sessionID := 0
+	if s := c.QueryParam("session_id"); s != "" {
+		var err error
+		if sessionID, err = strconv.Atoi(s); err != nil {
+			return e.ErrBad(logCtx, fid, "invalid session_id")
+		}
+	}
+
+	idempotencyKey := c.QueryParam("idempotency_key")
+	if idempotencyKey == "" {
+		idempotencyKey = c.Request().Header.Get("Idempotency-Key")
+	}
+
+	res, err := accounts.ChargeBank(ctx, logCtx, characterVersion, tier, sessionID, idempotencyKey)
 	if err != nil {
 		return e.Err(logCtx, err, fid, "unable to update accounts bank balance")
 	}
@@ -82,6 +96,64 @@ func ChargeBank(c echo.Context) error {
 	return c.JSON(http.StatusOK, res)
 }
 
+// GetBankTransactions returns the user's bank ledger, newest first.
+// start and end are RFC3339 times or dates, an end date includes the whole day. The default range is the last 30 days.
+func GetBankTransactions(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.accounts.GetBankTransactions")
+
+	end := time.Now().UTC()
+	if s := c.QueryParam("end"); s != "" {
+		t, dateOnly, err := parseTime(s)
+		if err != nil {
+			return e.ErrBad(logCtx, fid, "invalid end")
+		}
+
+		end = t
+		if dateOnly {
+			end = t.AddDate(0, 0, 1)
+		}
+	}
+
+	start := end.AddDate(0, 0, -30)
+	if s := c.QueryParam("start"); s != "" {
+		t, _, err := parseTime(s)
+		if err != nil {
+			return e.ErrBad(logCtx, fid, "invalid start")
+		}
+
+		start = t
+	}
+
+	if !start.Before(end) {
+		return e.ErrBad(logCtx, fid, "start must be before end")
+	}
+
+	limit := 0
+	if s := c.QueryParam("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 {
+			return e.ErrBad(logCtx, fid, "invalid limit")
+		}
+	}
+
+	res, err := accounts.GetTransactions(ctx, logCtx, start, end, limit, c.QueryParam("page_token"))
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to get accounts bank transactions")
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// parseTime parses an RFC3339 time or a date.
+func parseTime(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	return t, false, err
+}
+
 // GetSubscription gets a user's subscription information.
 func GetSubscription(c echo.Context) error {
 	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.accounts.GetSubscription")
@@ -3,6 +3,7 @@ package bank
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -27,28 +28,6 @@ type AndroidNotification struct {
 	Subscription string `json:"subscription"`
 }
 
-// AndroidNotificationData contains the AndroidNotification data contents.
-type AndroidNotificationData struct {
-	Version                  string `json:"version"`
-	PackageName              string `json:"packageName"`
-	Time                     string `json:"eventTimeMillis"`
-	SubscriptionNotification *struct {
-		Version          string `json:"version"`
-		SubscriptionType int    `json:"notificationType"`
-		PurchaseToken    string `json:"purchaseToken"`
-		SubcriptionID    string `json:"subscriptionId"`
-	} `json:"subscriptionNotification"`
-	OneTimeProductNotification *struct {
-		Version          string `json:"version"`
-		SubscriptionType int    `json:"notificationType"`
-		PurchaseToken    string `json:"purchaseToken"`
-		SKU              string `json:"sku"`
-	} `json:"oneTimeProductNotification"`
-	TestNotification *struct {
-		Version string `json:"version"`
-	} `json:"testNotification"`
-}
-
 // PostAndroidIAPTransaction is the REST API for creating Android IAP transactions.
 func PostAndroidIAPTransaction(c echo.Context) error {
 	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.bank.PostAndroidIAPTransaction")
@@ -136,6 +115,10 @@ func PostAndroidNotifications(c echo.Context) error {
 	fid := slog.String("fid", "rest.vox.bank.PostAndroidNotifications")
 	logCtx := slog.With("sid", c.Response().Header().Get(echo.HeaderXRequestID))
 
+	if err := bank.VerifyAndroidPush(c.Request().Context(), logCtx, c.Request().Header.Get(echo.HeaderAuthorization)); err != nil {
+		return e.Err(logCtx, err, fid, "unable to verify android notification")
+	}
+
 	req := AndroidNotification{}
 
 	if err := c.Bind(&req); err != nil {
@@ -151,7 +134,7 @@ func PostAndroidNotifications(c echo.Context) error {
 		return e.ErrBad(logCtx, fid, "unable to read android notification data")
 	}
 
-	data := AndroidNotificationData{}
+	data := bank.AndroidNotificationData{}
 	if err := json.Unmarshal(dataDecoded, &data); err != nil {
 		return e.ErrBad(logCtx, fid, "unable to unmarshal data")
 	}
@@ -180,9 +163,21 @@ func PostAndroidNotifications(c echo.Context) error {
 			"token", data.OneTimeProductNotification.PurchaseToken,
 			"sku", data.OneTimeProductNotification.SKU,
 		)
+	} else if data.VoidedPurchaseNotification != nil {
+		logCtx.Info("android store",
+			"purchase", "voided",
+			"type", data.VoidedPurchaseNotification.ProductType,
+			"token", data.VoidedPurchaseNotification.PurchaseToken,
+			"order_id", data.VoidedPurchaseNotification.OrderID,
+		)
 	} else {
 		return e.ErrBad(logCtx, fid, "invalid data")
 	}
 
+	// Acknowledge notifications for unknown purchases so Pub/Sub does not redeliver them.
+	if err := bank.PostAndroidNotification(c.Request().Context(), logCtx, data); err != nil && !errors.Is(err, common.ErrNotFound{}) {
+		return e.Err(logCtx, err, fid, "unable to process android notification")
+	}
+
 	return c.NoContent(http.StatusOK)
 }
@@ -1,6 +1,7 @@
 package bank
 
 import (
+	"log/slog"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -23,16 +24,8 @@ func PostAppleIAPTransaction(c echo.Context) error {
 		return e.ErrBad(logCtx, fid, "unable to read apple iap transaction")
 	}
 
-	if req.ProductID == "" {
-		return e.ErrBad(logCtx, fid, "missing productId")
-	}
-
-	if req.TransactionID == "" {
-		return e.ErrBad(logCtx, fid, "missing transactionId")
-	}
-
-	if req.TransactionReceipt == "" {
-		return e.ErrBad(logCtx, fid, "missing transactionReceipt")
+	if req.SignedTransactionInfo == "" {
+		return e.ErrBad(logCtx, fid, "missing signedTransactionInfo")
 	}
 
 	txn, err := bank.PostAppleIAPTransaction(ctx, logCtx, account.ID, req)
@@ -42,3 +35,30 @@ func PostAppleIAPTransaction(c echo.Context) error {
 
 	return c.JSON(http.StatusCreated, txn)
 }
+
+// AppleNotification contains an App Store Server Notification v2.
+type AppleNotification struct {
+	SignedPayload string `json:"signedPayload"`
+}
+
+// PostAppleNotifications is the REST API for App Store Server Notifications v2.
+func PostAppleNotifications(c echo.Context) error {
+	fid := slog.String("fid", "rest.vox.bank.PostAppleNotifications")
+	logCtx := slog.With("sid", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	req := AppleNotification{}
+
+	if err := c.Bind(&req); err != nil {
+		return e.ErrBad(logCtx, fid, "unable to read apple notification")
+	}
+
+	if req.SignedPayload == "" {
+		return e.ErrBad(logCtx, fid, "missing signedPayload")
+	}
+
+	if _, err := bank.PostAppleNotification(c.Request().Context(), logCtx, req.SignedPayload); err != nil {
+		return e.Err(logCtx, err, fid, "unable to process apple notification")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -1,6 +1,7 @@
 package bank
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -55,3 +56,64 @@ func ExpireGiftCard(c echo.Context) error {
 
 	return c.NoContent(http.StatusOK)
 }
+
+// PostGiftCards mints a batch of gift cards. The cards are returned as CSV when format=csv.
+func PostGiftCards(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.bank.PostGiftCards")
+
+	req := bank.GiftCardBatch{}
+
+	if err := c.Bind(&req); err != nil {
+		return e.ErrBad(logCtx, fid, "unable to read gift card batch")
+	}
+
+	res, err := bank.MintGiftCards(ctx, logCtx, req)
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to mint gift cards")
+	}
+
+	if c.QueryParam("format") == "csv" {
+		return writeGiftCardsCSV(c, http.StatusCreated, req.Campaign, res)
+	}
+
+	return c.JSON(http.StatusCreated, res)
+}
+
+// ExportGiftCards exports the gift cards of a campaign as CSV.
+func ExportGiftCards(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.bank.ExportGiftCards")
+
+	campaign := c.QueryParam("campaign")
+	if campaign == "" {
+		return e.ErrBad(logCtx, fid, "campaign required")
+	}
+
+	res, err := bank.GetCampaignGiftCards(ctx, logCtx, campaign)
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to get campaign gift cards")
+	}
+
+	return writeGiftCardsCSV(c, http.StatusOK, campaign, res)
+}
+
+// GetGiftCardCampaign reports the gift cards and redemptions of a campaign.
+func GetGiftCardCampaign(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.bank.GetGiftCardCampaign")
+
+	campaign := c.Param("campaign")
+
+	res, err := bank.GetCampaignReport(ctx, logCtx, campaign)
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to get campaign report")
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func writeGiftCardsCSV(c echo.Context, code int, campaign string, cards []bank.GiftCard) error {
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", campaign+".csv"))
+	c.Response().WriteHeader(code)
+
+	return bank.WriteGiftCardsCSV(c.Response(), cards)
+}
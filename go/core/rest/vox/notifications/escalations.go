@@ -0,0 +1,65 @@
+package notifications
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"disruptive/pkg/vox/notifications"
+	"disruptive/rest/auth"
+	e "disruptive/rest/errors"
+)
+
+// GetEscalations retrieves the crisis escalations of all accounts.
+func GetEscalations(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.notifications.GetEscalations")
+
+	var acknowledged *bool
+	if v := c.QueryParam("acknowledged"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return e.ErrBad(logCtx, fid, "invalid acknowledged")
+		}
+		acknowledged = &b
+	}
+
+	res, err := notifications.GetEscalations(ctx, logCtx, acknowledged)
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to get escalations")
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetEscalation retrieves a crisis escalation.
+func GetEscalation(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.notifications.GetEscalation")
+
+	res, err := notifications.GetEscalation(ctx, logCtx, c.Param("escalation_id"))
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to get escalation")
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// PostEscalationAcknowledge marks a crisis escalation as reviewed.
+func PostEscalationAcknowledge(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.notifications.PostEscalationAcknowledge")
+
+	req := struct {
+		Note string `json:"note"`
+	}{}
+
+	if err := c.Bind(&req); err != nil {
+		return e.ErrBad(logCtx, fid, "unable to read data")
+	}
+
+	res, err := notifications.AcknowledgeEscalation(ctx, logCtx, c.Param("escalation_id"), req.Note)
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to acknowledge escalation")
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,183 @@
+package notifications
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"disruptive/lib/firebase"
+	"disruptive/lib/objectstore"
+	"disruptive/pkg/vox/notifications"
+	"disruptive/rest/auth"
+	e "disruptive/rest/errors"
+)
+
+// GetReviews retrieves a page of the moderation review queue of all accounts.
+func GetReviews(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.notifications.GetReviews")
+
+	filter, err := reviewFilter(c)
+	if err != nil {
+		return e.ErrBad(logCtx, fid, err.Error())
+	}
+
+	limit := 0
+	if s := c.QueryParam("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 {
+			return e.ErrBad(logCtx, fid, "invalid limit")
+		}
+	}
+
+	res, err := notifications.GetReviews(ctx, logCtx, filter, limit, c.QueryParam("page_token"))
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to get moderation reviews")
+	}
+
+	for i := range res.Reviews {
+		setAudioURL(&res.Reviews[i])
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetReview retrieves a moderation review.
+func GetReview(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.notifications.GetReview")
+
+	res, err := notifications.GetReview(ctx, logCtx, c.Param("review_id"))
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to get moderation review")
+	}
+
+	setAudioURL(&res)
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetReviewAudio streams the user audio of a moderation review.
+func GetReviewAudio(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.notifications.GetReviewAudio")
+
+	review, err := notifications.GetReview(ctx, logCtx, c.Param("review_id"))
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to get moderation review")
+	}
+
+	if review.UserAudio == "" {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	r, cType, err := objectstore.Client.Download(ctx, firebase.GCSBucket, review.UserAudio)
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to get user audio")
+	}
+	defer r.Close()
+
+	return c.Stream(http.StatusOK, cType, r)
+}
+
+// PatchReview records a reviewer decision on a moderation review.
+func PatchReview(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.notifications.PatchReview")
+
+	req := notifications.ReviewDecision{}
+
+	if err := c.Bind(&req); err != nil {
+		return e.ErrBad(logCtx, fid, "unable to read data")
+	}
+
+	res, err := notifications.DecideReview(ctx, logCtx, c.Param("review_id"), req)
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to decide moderation review")
+	}
+
+	setAudioURL(&res)
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// ExportReviews exports the decided moderation reviews as a labeled CSV dataset.
+func ExportReviews(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.notifications.ExportReviews")
+
+	filter, err := reviewFilter(c)
+	if err != nil {
+		return e.ErrBad(logCtx, fid, err.Error())
+	}
+
+	if filter.Decision == notifications.ReviewPending {
+		return e.ErrBad(logCtx, fid, "pending reviews have no label")
+	}
+
+	if err := filter.Validate(); err != nil {
+		return e.ErrBad(logCtx, fid, err.Error())
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="moderation_reviews.csv"`)
+	c.Response().WriteHeader(http.StatusOK)
+
+	if err := notifications.ExportReviews(ctx, logCtx, c.Response(), filter); err != nil {
+		logCtx.Error("unable to export moderation reviews", fid, "error", err)
+	}
+
+	return nil
+}
+
+// reviewFilter reads the review filters. Dates are RFC 3339 or YYYY-MM-DD, and a date-only to includes the whole day.
+func reviewFilter(c echo.Context) (notifications.ReviewFilter, error) {
+	f := notifications.ReviewFilter{
+		Category: c.QueryParam("category"),
+		Decision: c.QueryParam("decision"),
+		Language: c.QueryParam("language"),
+	}
+
+	for name, age := range map[string]*int{"min_age": &f.MinAge, "max_age": &f.MaxAge} {
+		if v := c.QueryParam(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s", name)
+			}
+			*age = n
+		}
+	}
+
+	if v := c.QueryParam("from"); v != "" {
+		t, _, err := parseReviewDate(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid from")
+		}
+		f.From = t
+	}
+
+	if v := c.QueryParam("to"); v != "" {
+		t, dateOnly, err := parseReviewDate(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid to")
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		f.To = t
+	}
+
+	return f, nil
+}
+
+func parseReviewDate(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	return t, false, err
+}
+
+func setAudioURL(r *notifications.Review) {
+	if r.UserAudio != "" {
+		r.AudioURL = fmt.Sprintf("/api/vox/notifications/reviews/%s/audio", r.ID)
+	}
+}
@@ -35,6 +35,10 @@ func GetAssistantAudio(c echo.Context) error {
 
 	if format == "" || format == "mp3" || format == "mp3_44100" {
 		format = "mp3_44100_128"
+	} else if format == "ogg" {
+		format = "ogg_opus_16000"
+	} else if format == "wav" {
+		format = "wav_16000"
 	}
 
 	if _, ok := common.AudioFormatExtensions[format]; !ok {
@@ -0,0 +1,425 @@
+package play
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"disruptive/lib/common"
+	"disruptive/lib/configs"
+	"disruptive/lib/elevenlabs"
+	"disruptive/pkg/vox/characters"
+	"disruptive/pkg/vox/characters/play"
+	"disruptive/pkg/vox/moderate"
+	"disruptive/pkg/vox/profiles"
+	"disruptive/rest/auth"
+)
+
+const (
+	// maxConversationFrames buffers microphone frames while speech-to-text is catching up.
+	maxConversationFrames = 256
+
+	conversationAudioChunk = 4096
+)
+
+var errConversationClosed = errors.New("conversation closed")
+
+// conversationMessage is a control message sent by the client.
+//
+//	end_of_speech: the current utterance is complete and the turn starts.
+//	cancel: barge-in, stops the audio of the turn in flight.
+type conversationMessage struct {
+	Type string `json:"type"`
+}
+
+// conversationEvent is an event sent to the client. Assistant audio is sent as binary frames
+// between the assistant and end_of_turn events.
+type conversationEvent struct {
+	Type                string             `json:"type"`
+	AudioID             string             `json:"audio_id,omitempty"`
+	Text                string             `json:"text,omitempty"`
+	Moderation          *moderate.Response `json:"moderation,omitempty"`
+	SessionID           int                `json:"session_id,omitempty"`
+	ModerationEmailSent bool               `json:"moderation_email_sent,omitempty"`
+	NotificationID      string             `json:"notification_id,omitempty"`
+	Message             string             `json:"message,omitempty"`
+}
+
+type conversationUpload struct {
+	frames chan []byte
+	w      *io.PipeWriter
+	result chan conversationUploadResult
+}
+
+type conversationUploadResult struct {
+	audio characters.UserAudio
+	err   error
+}
+
+type conversation struct {
+	ws      *websocket.Conn
+	wsMutex sync.Mutex
+	wg      sync.WaitGroup
+
+	turnCtx    context.Context
+	cancelTurn context.CancelFunc
+
+	logCtx                  *slog.Logger
+	profileID               string
+	characterVersion        string
+	inputFormat             string
+	format                  string
+	tttModel                string
+	ttsModel                string
+	optimizingStreamLatency string
+	version                 string
+	pipeline                bool
+}
+
+// GetSTSConversationWS is the REST API for a full-duplex speech-to-speech conversation.
+// Microphone audio is sent upstream as binary frames, each utterance ends with an end_of_speech message.
+// Transcript, moderation and turn events are sent downstream as JSON with the assistant audio as binary frames.
+func GetSTSConversationWS(c echo.Context) error {
+	ctx, logCtx, fid := auth.InitRequest(c, "rest.vox.play.GetSTSConversationWS")
+
+	ws, err := websocketUpgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		logCtx.Error("unable to upgrade to websocket", fid, "error", err)
+		return err
+	}
+	defer ws.Close()
+
+	cv := &conversation{
+		ws:                      ws,
+		logCtx:                  logCtx,
+		profileID:               c.Param("profile_id"),
+		characterVersion:        c.Param("character_version"),
+		inputFormat:             strings.ToLower(c.QueryParam("input_format")),
+		format:                  strings.ToLower(c.QueryParam("format")),
+		tttModel:                strings.ToLower(c.QueryParam("ttt_model")),
+		ttsModel:                strings.ToLower(c.QueryParam("tts_model")),
+		optimizingStreamLatency: c.QueryParam("optimizing_stream_latency"),
+		version:                 strings.ToLower(c.QueryParam("version")),
+		pipeline:                c.QueryParam("pipeline") == "1",
+	}
+
+	if cv.inputFormat == "" {
+		cv.inputFormat = "mp3"
+	}
+
+	if _, ok := common.AudioExtensionContentTypes[cv.inputFormat]; !ok {
+		logCtx.Error("invalid input audio format", fid)
+		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseAbnormalClosure, "invalid input audio format"))
+		return nil
+	}
+
+	if cv.format == "" || cv.format == "mp3" || cv.format == "mp3_44100" {
+		cv.format = "mp3_44100_128"
+	} else if cv.format == "pcm" {
+		cv.format = "pcm_16000"
+	} else if cv.format == "opus" {
+		cv.format = "opus_16000"
+	} else if cv.format == "ogg" {
+		cv.format = "ogg_opus_16000"
+	} else if cv.format == "wav" {
+		cv.format = "wav_16000"
+	}
+
+	if _, ok := elevenlabs.AudioFormatExtensions[cv.format]; !ok {
+		logCtx.Error("invalid audio format", fid)
+		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseAbnormalClosure, "invalid audio format"))
+		return nil
+	}
+
+	if !slices.Contains(elevenlabs.OptimizingStreamLatency, cv.optimizingStreamLatency) {
+		logCtx.Error("invalid optimizing_stream_latency", fid)
+		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseAbnormalClosure, "invalid optimizing_stream_latency"))
+		return nil
+	}
+
+	if _, err := profiles.GetByID(ctx, logCtx, cv.profileID); err != nil {
+		logCtx.Error("invalid profile", fid, "error", err)
+		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseAbnormalClosure, "invalid profile"))
+		return nil
+	}
+
+	cv.run(ctx)
+
+	return nil
+}
+
+// run reads client messages until the connection closes. Turns run one at a time in the order the utterances ended.
+func (cv *conversation) run(ctx context.Context) {
+	fid := slog.String("fid", "rest.vox.play.conversation.run")
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	// Barge-in cancels every turn started so far, later turns get a new context.
+	cv.turnCtx, cv.cancelTurn = context.WithCancel(ctx)
+
+	var upload *conversationUpload
+
+	prev := make(chan struct{})
+	close(prev)
+
+	defer func() {
+		if upload != nil {
+			upload.w.CloseWithError(errConversationClosed)
+			close(upload.frames)
+		}
+
+		cv.cancelTurn()
+		cancel()
+		cv.wg.Wait()
+	}()
+
+	for {
+		mt, data, err := cv.ws.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				cv.logCtx.Warn("conversation closed", fid, "error", err)
+			}
+			return
+		}
+
+		switch mt {
+		case websocket.BinaryMessage:
+			if upload == nil {
+				upload = cv.startUpload(ctx)
+			}
+
+			upload.frames <- data
+
+		case websocket.TextMessage:
+			msg := conversationMessage{}
+			if err := json.Unmarshal(data, &msg); err != nil {
+				cv.sendError("invalid message")
+				continue
+			}
+
+			switch msg.Type {
+			case "end_of_speech":
+				if upload == nil {
+					cv.sendError("no audio")
+					continue
+				}
+
+				close(upload.frames)
+
+				done := make(chan struct{})
+				cv.wg.Add(1)
+				go cv.turn(ctx, cv.turnCtx, upload, prev, done)
+
+				upload = nil
+				prev = done
+
+			case "cancel":
+				cv.cancelTurn()
+				cv.turnCtx, cv.cancelTurn = context.WithCancel(ctx)
+
+			default:
+				cv.sendError("invalid message type")
+			}
+		}
+	}
+}
+
+// startUpload streams the microphone frames of one utterance into PostUserAudio.
+func (cv *conversation) startUpload(ctx context.Context) *conversationUpload {
+	r, w := io.Pipe()
+
+	u := &conversationUpload{
+		frames: make(chan []byte, maxConversationFrames),
+		w:      w,
+		result: make(chan conversationUploadResult, 1),
+	}
+
+	cv.wg.Add(2)
+
+	go func() {
+		defer cv.wg.Done()
+
+		for frame := range u.frames {
+			if _, err := w.Write(frame); err != nil {
+				for range u.frames {
+				}
+				return
+			}
+		}
+
+		w.Close()
+	}()
+
+	go func() {
+		defer cv.wg.Done()
+
+		profile, err := profiles.GetByID(ctx, cv.logCtx, cv.profileID)
+		if err != nil {
+			r.CloseWithError(err)
+			u.result <- conversationUploadResult{err: err}
+			return
+		}
+
+		audio, err := play.PostUserAudio(ctx, cv.logCtx, &profile, cv.characterVersion, cv.inputFormat, cv.version, r)
+		r.CloseWithError(errConversationClosed)
+
+		// An utterance without speech is answered like an empty transcript.
+		if errors.Is(err, common.ErrNoSpeech{}) {
+			audio, err = characters.UserAudio{AudioID: "0", Timestamp: time.Now()}, nil
+		}
+
+		u.result <- conversationUploadResult{audio: audio, err: err}
+	}()
+
+	return u
+}
+
+// turn answers one utterance. A canceled turnCtx stops the assistant audio but the turn is still closed.
+func (cv *conversation) turn(ctx, turnCtx context.Context, u *conversationUpload, prev <-chan struct{}, done chan struct{}) {
+	fid := slog.String("fid", "rest.vox.play.conversation.turn")
+
+	defer cv.wg.Done()
+	defer close(done)
+
+	res := <-u.result
+	<-prev
+
+	if res.err != nil {
+		cv.logCtx.Error("unable to process user audio", fid, "error", res.err)
+		cv.sendError("unable to process user audio")
+		return
+	}
+
+	audioID := res.audio.AudioID
+	cv.send(conversationEvent{Type: "transcript", AudioID: audioID, Text: res.audio.Text})
+
+	profile, err := profiles.GetByID(ctx, cv.logCtx, cv.profileID)
+	if err != nil {
+		cv.logCtx.Error("unable to get profile", fid, "error", err)
+		cv.sendError("invalid profile")
+		return
+	}
+
+	ttsAudioID := audioID
+
+	if audioID != "0" {
+		m, err := play.GetSTSModeration(turnCtx, cv.logCtx, &profile, cv.characterVersion, audioID)
+		if err != nil {
+			cv.logCtx.Error("unable to get moderation", fid, "error", err)
+			cv.sendError("unable to get moderation")
+		} else {
+			cv.send(conversationEvent{Type: "moderation", AudioID: audioID, Moderation: m})
+
+			if m.Action == configs.ModerationEscalate {
+				ttsAudioID = "2"
+			} else if m.Triggered {
+				ttsAudioID = "1"
+			}
+		}
+	}
+
+	if err := cv.streamAudio(turnCtx, ttsAudioID); err != nil && turnCtx.Err() == nil {
+		cv.logCtx.Error("unable to process speech-to-speech", fid, "error", err)
+		cv.sendError("unable to process speech-to-speech")
+	}
+
+	// The turn is closed even when canceled so the moderation notifications are kept. Closing a pipelined response
+	// waits for its session entry and charge, a response canceled before it had an entry is closed without one.
+	closeCtx := context.WithoutCancel(ctx)
+
+	if turnCtx.Err() != nil {
+		cv.send(conversationEvent{Type: "cancelled", AudioID: audioID})
+	} else if entry, err := cv.assistantText(closeCtx, ttsAudioID); err != nil {
+		cv.logCtx.Error("unable to get assistant text", fid, "error", err)
+	} else {
+		cv.send(conversationEvent{Type: "assistant", AudioID: audioID, SessionID: entry.ID, Text: entry.Assistant})
+	}
+
+	closeRes, err := play.CloseSTS(closeCtx, cv.logCtx, &profile, cv.characterVersion, audioID)
+	if err != nil {
+		cv.logCtx.Error("unable to close speech-to-speech", fid, "error", err)
+		cv.sendError("unable to close speech-to-speech")
+		return
+	}
+
+	cv.send(conversationEvent{
+		Type:                "end_of_turn",
+		AudioID:             audioID,
+		ModerationEmailSent: closeRes.ModerationEmailSent,
+		NotificationID:      closeRes.NotificationID,
+	})
+}
+
+// streamAudio sends the assistant audio as binary frames until it ends or the turn is canceled.
+func (cv *conversation) streamAudio(turnCtx context.Context, audioID string) error {
+	r, _, err := play.GetSTSAudio(turnCtx, cv.logCtx, cv.profileID, cv.characterVersion, cv.format, cv.tttModel, cv.ttsModel, cv.optimizingStreamLatency, audioID, cv.pipeline)
+	if err != nil {
+		if errors.Is(err, common.ErrNoResults) {
+			return nil
+		}
+		return err
+	}
+	defer r.Close()
+
+	// Unblock a pending read when the client barges in. The close stops the audio, not the stored response.
+	stop := context.AfterFunc(turnCtx, func() { r.Close() })
+	defer stop()
+
+	buffer := make([]byte, conversationAudioChunk)
+	for {
+		n, err := r.Read(buffer)
+		if n > 0 {
+			if err := cv.sendAudio(buffer[:n]); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (cv *conversation) assistantText(ctx context.Context, audioID string) (characters.SessionEntry, error) {
+	switch audioID {
+	case "0":
+		return play.GetSTSDontUnderstandText(ctx, cv.logCtx, cv.profileID, cv.characterVersion)
+	case "1":
+		return play.GetSTSModerationResponseText(ctx, cv.logCtx, cv.profileID, cv.characterVersion)
+	case "2":
+		return play.GetSTSCrisisResponseText(ctx, cv.logCtx, cv.profileID, cv.characterVersion)
+	default:
+		return play.GetSTSText(ctx, cv.logCtx, cv.profileID, cv.characterVersion, audioID)
+	}
+}
+
+func (cv *conversation) send(event conversationEvent) error {
+	cv.wsMutex.Lock()
+	defer cv.wsMutex.Unlock()
+
+	return cv.ws.WriteJSON(event)
+}
+
+func (cv *conversation) sendError(msg string) error {
+	return cv.send(conversationEvent{Type: "error", Message: msg})
+}
+
+func (cv *conversation) sendAudio(b []byte) error {
+	cv.wsMutex.Lock()
+	defer cv.wsMutex.Unlock()
+
+	return cv.ws.WriteMessage(websocket.BinaryMessage, b)
+}
@@ -25,10 +25,6 @@ func GetSTSModeration(c echo.Context) error {
 		e.ErrBad(logCtx, fid, "invalid profile")
 	}
 
-	if !profile.Moderate {
-		return c.NoContent(http.StatusNoContent)
-	}
-
 	m, err := play.GetSTSModeration(ctx, logCtx, &profile, characterVersion, audioID)
 	if err != nil {
 		return e.ErrBad(logCtx, fid, "unable to get moderation")
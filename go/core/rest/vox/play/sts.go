@@ -170,6 +170,7 @@ func GetSTSAudio(c echo.Context) error {
 	ttsModel := strings.ToLower(c.QueryParam("tts_model"))
 	tttModel := strings.ToLower(c.QueryParam("ttt_model"))
 	optimizingStreamLatency := c.QueryParam("optimizing_stream_latency")
+	pipeline := c.QueryParam("pipeline") == "1"
 
 	if format == "" || format == "mp3" || format == "mp3_44100" {
 		format = "mp3_44100_128"
@@ -177,6 +178,10 @@ func GetSTSAudio(c echo.Context) error {
 		format = "pcm_16000"
 	} else if format == "opus" {
 		format = "opus_16000"
+	} else if format == "ogg" {
+		format = "ogg_opus_16000"
+	} else if format == "wav" {
+		format = "wav_16000"
 	}
 
 	if _, ok := elevenlabs.AudioFormatExtensions[format]; !ok {
@@ -192,7 +197,7 @@ func GetSTSAudio(c echo.Context) error {
 		logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "tts")
 	}()
 
-	r, cType, err := play.GetSTSAudio(ctx, logCtx, profileID, characterVersion, format, tttModel, ttsModel, optimizingStreamLatency, audioID)
+	r, cType, err := play.GetSTSAudio(ctx, logCtx, profileID, characterVersion, format, tttModel, ttsModel, optimizingStreamLatency, audioID, pipeline)
 	if err != nil {
 		if errors.Is(err, common.ErrNoResults) {
 			return c.NoContent(http.StatusNoContent)
@@ -223,6 +228,7 @@ func GetSTSAudioWS(c echo.Context) error {
 	ttsModel := strings.ToLower(c.QueryParam("tts_model"))
 	tttModel := strings.ToLower(c.QueryParam("ttt_model"))
 	optimizingStreamLatency := c.QueryParam("optimizing_stream_latency")
+	pipeline := c.QueryParam("pipeline") == "1"
 
 	if format == "" || format == "mp3" || format == "mp3_44100" {
 		format = "mp3_44100_128"
@@ -230,6 +236,10 @@ func GetSTSAudioWS(c echo.Context) error {
 		format = "pcm_16000"
 	} else if format == "opus" {
 		format = "opus_16000"
+	} else if format == "ogg" {
+		format = "ogg_opus_16000"
+	} else if format == "wav" {
+		format = "wav_16000"
 	}
 
 	if _, ok := elevenlabs.AudioFormatExtensions[format]; !ok {
@@ -247,7 +257,7 @@ func GetSTSAudioWS(c echo.Context) error {
 		logCtx.Info("duration", "duration", time.Since(t).Milliseconds(), "span", "tts")
 	}()
 
-	r, _, err := play.GetSTSAudio(ctx, logCtx, profileID, characterVersion, format, tttModel, ttsModel, optimizingStreamLatency, audioID)
+	r, _, err := play.GetSTSAudio(ctx, logCtx, profileID, characterVersion, format, tttModel, ttsModel, optimizingStreamLatency, audioID, pipeline)
 	if err != nil {
 		if errors.Is(err, common.ErrNoResults) {
 			ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
@@ -309,6 +319,11 @@ func GetSTSText(c echo.Context) error {
 		if err != nil {
 			return e.Err(logCtx, err, fid, "unable to get moderation response assistant text")
 		}
+	case "2":
+		res, err = play.GetSTSCrisisResponseText(ctx, logCtx, profileID, characterVersion)
+		if err != nil {
+			return e.Err(logCtx, err, fid, "unable to get crisis response assistant text")
+		}
 	default:
 		res, err = play.GetSTSText(ctx, logCtx, profileID, characterVersion, audioID)
 		if err != nil {
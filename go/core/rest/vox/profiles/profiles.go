@@ -6,6 +6,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 
+	"disruptive/pkg/vox/accounts"
 	"disruptive/pkg/vox/characters"
 	"disruptive/pkg/vox/profiles"
 	"disruptive/rest/auth"
@@ -55,6 +56,13 @@ func Get(c echo.Context) error {
 		return e.Err(logCtx, err, fid, "unable to get profile")
 	}
 
+	usage, err := accounts.GetUsage(ctx, logCtx, id)
+	if err != nil {
+		return e.Err(logCtx, err, fid, "unable to get profile usage")
+	}
+
+	p.Usage = &usage
+
 	return c.JSON(http.StatusOK, p)
 }
 
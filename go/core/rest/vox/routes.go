@@ -34,11 +34,12 @@ func Routes(e *echo.Echo) {
 	g.GET("/me/bank/balance/available", accounts.GetAvailableBalance)
 	g.PATCH("/me/bank/balance/add", accounts.AddBalance)
 	g.PATCH("/me/bank/balance/charge", accounts.ChargeBank)
+	g.GET("/me/bank/transactions", accounts.GetBankTransactions)
 	g.POST("/me/bank/balance/gift_cards/:gift_card/redeem", accounts.RedeemGiftCard)
 
 	g.GET("/me/bank/subscriptions", accounts.GetSubscription)
 	g.PATCH("/me/bank/subscriptions/subscribe", accounts.Subscribe)
-	g.PATCH("/me/bank/subscriptions/pending", accounts.Subscribe)
+	g.PATCH("/me/bank/subscriptions/pending", accounts.PatchPendingSubscription)
 	g.DELETE("/me/bank/subscriptions/unsubscribe", accounts.Unsubscribe)
 
 	g.POST("/me/email_pin", accounts.PostEmailPin)
@@ -59,6 +60,9 @@ func Routes(e *echo.Echo) {
 
 	// Bank
 	g = e.Group("/api/vox/bank")
+	g.POST("/gift_cards", bank.PostGiftCards, auth.SetAdminMiddleware)
+	g.GET("/gift_cards/export", bank.ExportGiftCards, auth.SetAdminMiddleware)
+	g.GET("/gift_cards/campaigns/:campaign", bank.GetGiftCardCampaign, auth.SetAdminMiddleware)
 	g.GET("/gift_cards", bank.GetGiftCards, auth.SetMiddleware)
 	g.GET("/gift_cards/:gift_card", bank.GetGiftCard, auth.SetMiddleware)
 	g.DELETE("/gift_cards/:gift_card", bank.ExpireGiftCard, auth.SetMiddleware)
@@ -68,6 +72,7 @@ func Routes(e *echo.Echo) {
 	g.POST("/android/notifications", bank.PostAndroidNotifications)
 
 	g.POST("/apple/iap/transaction", bank.PostAppleIAPTransaction, auth.SetMiddleware)
+	g.POST("/apple/notifications", bank.PostAppleNotifications)
 
 	// Characters
 	g = e.Group("/api/vox/characters", auth.SetMiddleware)
@@ -124,6 +129,7 @@ func Routes(e *echo.Echo) {
 	g.POST("/sts/text/predefined", play.PostSTSTextPredefined)
 	g.GET("/sts/audio/:audio_id", play.GetSTSAudio)
 	g.GET("/sts/audio/ws/:audio_id", play.GetSTSAudioWS)
+	g.GET("/sts/conversation", play.GetSTSConversationWS)
 	g.GET("/sts/moderation/:audio_id", play.GetSTSModeration)
 	g.GET("/sts/text/:audio_id", play.GetSTSText)
 
@@ -140,6 +146,14 @@ func Routes(e *echo.Echo) {
 	// Admin Notifications
 	g = e.Group("/api/vox/notifications", auth.SetAdminMiddleware)
 	g.DELETE("/:notification_id", notifications.Delete)
+	g.GET("/escalations", notifications.GetEscalations)
+	g.GET("/escalations/:escalation_id", notifications.GetEscalation)
+	g.POST("/escalations/:escalation_id/acknowledge", notifications.PostEscalationAcknowledge)
+	g.GET("/reviews", notifications.GetReviews)
+	g.GET("/reviews/export", notifications.ExportReviews)
+	g.GET("/reviews/:review_id", notifications.GetReview)
+	g.GET("/reviews/:review_id/audio", notifications.GetReviewAudio)
+	g.PATCH("/reviews/:review_id", notifications.PatchReview)
 
 	// Notifications
 	g = e.Group("/api/vox/notifications", auth.SetMiddleware)